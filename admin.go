@@ -0,0 +1,217 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Discard ratio used by the GC endpoint if none is given
+	defaultAdminGCDiscardRatio = 0.5
+)
+
+// AdminOptions configures the handler returned by AdminHandler.
+type AdminOptions struct {
+	// ShowStableValues includes the values of stable store keys in the
+	// /stable endpoint. Only their sizes are shown by default, since
+	// applications may keep secrets in the stable store.
+	ShowStableValues bool
+}
+
+// adminIndexRange is the range of logs held by a store, as served by the
+// /index admin endpoint.
+type adminIndexRange struct {
+	FirstIndex uint64 `json:"first_index"`
+	LastIndex  uint64 `json:"last_index"`
+}
+
+// StableKey describes a stable store key, as served by the /stable admin
+// endpoint. Value is only set if AdminOptions.ShowStableValues is.
+type StableKey struct {
+	Key   string `json:"key"`
+	Size  int    `json:"size"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// AdminHandler returns an http.Handler exposing the store for operators.
+// It serves:
+//
+//	GET  /stats   the store's Stats
+//	GET  /index   the first and last index of the log
+//	GET  /span    the first and last index and when they were appended
+//	GET  /stable  the stable store keys, see AdminOptions
+//	POST /gc      one value log GC run, with an optional discard_ratio
+//	GET  /backup  a bundle written by ExportState
+//	GET  /follow  every log from the from index on as it is appended, as
+//	              newline delimited JSON like ExportJSON writes; without
+//	              from only logs appended from then on
+//
+// Paths are relative, so the handler can be mounted into an existing admin
+// mux with http.StripPrefix. It does no authentication of its own.
+func (b *BadgerRaftStore) AdminHandler(options AdminOptions) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, b.Stats())
+	})
+
+	mux.HandleFunc("GET /index", func(w http.ResponseWriter, r *http.Request) {
+		var rng adminIndexRange
+		var err error
+		if rng.FirstIndex, err = b.FirstIndex(); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		if rng.LastIndex, err = b.LastIndex(); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, rng)
+	})
+
+	mux.HandleFunc("GET /span", func(w http.ResponseWriter, r *http.Request) {
+		span, err := b.LogTimeSpan()
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, span)
+	})
+
+	mux.HandleFunc("GET /stable", func(w http.ResponseWriter, r *http.Request) {
+		keys, err := b.stableKeys(options.ShowStableValues)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, keys)
+	})
+
+	mux.HandleFunc("POST /gc", func(w http.ResponseWriter, r *http.Request) {
+		ratio := defaultAdminGCDiscardRatio
+		if s := r.URL.Query().Get("discard_ratio"); s != "" {
+			var err error
+			if ratio, err = strconv.ParseFloat(s, 64); err != nil || ratio <= 0 || ratio >= 1 {
+				http.Error(w, "discard_ratio must be between 0 and 1", http.StatusBadRequest)
+				return
+			}
+		}
+
+		err := b.RunValueLogGC(ratio)
+		if err != nil && !errors.Is(err, badger.ErrNoRewrite) {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, map[string]bool{"rewritten": err == nil})
+	})
+
+	mux.HandleFunc("GET /follow", func(w http.ResponseWriter, r *http.Request) {
+		var from uint64
+		var err error
+		if s := r.URL.Query().Get("from"); s != "" {
+			if from, err = strconv.ParseUint(s, 10, 64); err != nil {
+				http.Error(w, "from must be an index", http.StatusBadRequest)
+				return
+			}
+		} else {
+			if from, err = b.LastIndex(); err != nil {
+				writeAdminError(w, err)
+				return
+			}
+			from++
+		}
+
+		logs, err := b.SubscribeLogs(r.Context(), from)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		enc := json.NewEncoder(w)
+		for l := range logs {
+			if err := enc.Encode(newJSONLog(l)); err != nil {
+				return
+			}
+			// Send logs as they come unless more are already waiting
+			if flusher != nil && len(logs) == 0 {
+				flusher.Flush()
+			}
+		}
+	})
+
+	mux.HandleFunc("GET /backup", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", `attachment; filename="raft-state.tar"`)
+
+		// Headers are sent with the first write, so a failure midway can
+		// only be reported by cutting the response short.
+		if err := b.ExportState(w); err != nil {
+			log.Error().Err(err).Msg("Failed to export state for backup")
+			panic(http.ErrAbortHandler)
+		}
+	})
+
+	return mux
+}
+
+// stableKeys lists the keys of the stable store, with their values if
+// withValues is set.
+func (b *BadgerRaftStore) stableKeys(withValues bool) ([]StableKey, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.stableDB, false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = withValues
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	keys := []StableKey{}
+	for it.Seek(dbConf); it.ValidForPrefix(dbConf); it.Next() {
+		item := it.Item()
+		key := StableKey{
+			Key:  string(item.Key()[len(dbConf):]),
+			Size: int(item.ValueSize()),
+		}
+		if withValues {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, storageError(err)
+			}
+			key.Value = val
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Failed to write admin response")
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrClosed) {
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,135 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_AdminHandler(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	require.NoError(t, store.Set([]byte("secret"), []byte("hunter2")))
+
+	server := httptest.NewServer(http.StripPrefix("/raft", store.AdminHandler(AdminOptions{})))
+	defer server.Close()
+
+	get := func(path string, v any) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+
+	var stats Stats
+	get("/raft/stats", &stats)
+	assert.Equal(t, uint64(2), stats.Appends)
+
+	var rng adminIndexRange
+	get("/raft/index", &rng)
+	assert.Equal(t, adminIndexRange{FirstIndex: 1, LastIndex: 2}, rng)
+
+	var span LogTimeSpan
+	get("/raft/span", &span)
+	assert.Equal(t, LogTimeSpan{FirstIndex: 1, LastIndex: 2}, span)
+
+	// Values are redacted by default
+	var keys []StableKey
+	get("/raft/stable", &keys)
+	assert.Equal(t, []StableKey{{Key: "secret", Size: 7}}, keys)
+
+	resp, err := http.Post(server.URL+"/raft/gc?discard_ratio=0.7", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+"/raft/gc?discard_ratio=2", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The backup can be imported into an empty store
+	resp, err = http.Get(server.URL + "/raft/backup")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	restored := testBadgerStore(t)
+	defer restored.Close()
+	defer os.Remove(restored.path)
+	require.NoError(t, restored.ImportState(resp.Body))
+
+	val, err := restored.Get([]byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hunter2"), val)
+}
+
+func TestBadgerStore_AdminHandler_ShowStableValues(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.Set([]byte("key"), []byte("value")))
+
+	rec := httptest.NewRecorder()
+	store.AdminHandler(AdminOptions{ShowStableValues: true}).ServeHTTP(rec, httptest.NewRequest("GET", "/stable", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var keys []StableKey
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keys))
+	assert.Equal(t, []StableKey{{Key: "key", Size: 5, Value: []byte("value")}}, keys)
+}
+
+func TestBadgerStore_AdminHandler_Follow(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 3)
+
+	server := httptest.NewServer(store.AdminHandler(AdminOptions{}))
+	defer server.Close()
+
+	follow := func(query string) (*json.Decoder, func()) {
+		resp, err := http.Get(server.URL + "/follow" + query)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return json.NewDecoder(resp.Body), func() { resp.Body.Close() }
+	}
+
+	// From an index, stored logs come first
+	all, closeAll := follow("?from=2")
+	defer closeAll()
+	// Without one, only logs appended from then on
+	tail, closeTail := follow("")
+	defer closeTail()
+
+	storeTestLogs(t, store, 4, 5)
+
+	for _, want := range []uint64{2, 3, 4, 5} {
+		var l jsonLog
+		require.NoError(t, all.Decode(&l))
+		assert.Equal(t, want, l.Index)
+	}
+	for _, want := range []uint64{4, 5} {
+		var l jsonLog
+		require.NoError(t, tail.Decode(&l))
+		assert.Equal(t, want, l.Index)
+		assert.Equal(t, raft.LogCommand.String(), l.Type)
+	}
+
+	resp, err := http.Get(server.URL + "/follow?from=x")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
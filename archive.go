@@ -0,0 +1,137 @@
+package raftbadgerstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/raft"
+)
+
+// archiver streams logs that are about to be deleted to the configured
+// archive writer or directory. Archives are a sequence of records, each
+// holding a big endian uint32 length followed by the encoded log as it was
+// stored, recompressed without the store's dictionary if it was compressed
+// with one. ReadArchive decodes them again.
+type archiver struct {
+	// mu serializes writes to w between concurrent deletions.
+	mu  sync.Mutex
+	w   io.Writer
+	dir string
+}
+
+func newArchiver(w io.Writer, dir string) *archiver {
+	if w == nil && dir == "" {
+		return nil
+	}
+	return &archiver{w: w, dir: dir}
+}
+
+// archiveSession collects the logs removed by a single DeleteRange call.
+type archiveSession struct {
+	a     *archiver
+	w     io.Writer
+	file  *os.File
+	first uint64
+	last  uint64
+}
+
+// begin starts an archive session. It returns nil if archiving is disabled.
+func (a *archiver) begin() (*archiveSession, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	if a.dir == "" {
+		a.mu.Lock()
+		return &archiveSession{a: a, w: a.w}, nil
+	}
+
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(a.dir, "logs-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &archiveSession{a: a, w: f, file: f}, nil
+}
+
+// write appends one encoded log to the archive.
+func (s *archiveSession) write(idx uint64, val []byte) error {
+	if s.first == 0 {
+		s.first = idx
+	}
+	s.last = idx
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(val)))
+	if _, err := s.w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := s.w.Write(val)
+	return err
+}
+
+// sync makes the archived logs durable before they are deleted.
+func (s *archiveSession) sync() error {
+	if s.file != nil {
+		return s.file.Sync()
+	}
+	if syncer, ok := s.w.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// close finishes the session. Archive files are renamed after the range of
+// logs they hold, or removed if nothing was archived.
+func (s *archiveSession) close() error {
+	if s.file == nil {
+		s.a.mu.Unlock()
+		return nil
+	}
+
+	name := s.file.Name()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.first == 0 {
+		return os.Remove(name)
+	}
+	return os.Rename(name, filepath.Join(s.a.dir, fmt.Sprintf("logs-%020d-%020d.archive", s.first, s.last)))
+}
+
+// ReadArchive decodes an archive produced by DeleteRange and calls fn for
+// every log in it, in the order they were deleted.
+func ReadArchive(r io.Reader, fn func(*raft.Log) error) error {
+	br := bufio.NewReader(r)
+
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		val := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(br, val); err != nil {
+			return err
+		}
+
+		entry := new(raft.Log)
+		if err := decodeLog(val, entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_ArchiveWriter(t *testing.T) {
+	var buf bytes.Buffer
+	store := testBadgerStoreWithOptions(t, Options{ArchiveWriter: &buf})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	require.NoError(t, store.DeleteRange(1, 2))
+
+	var archived []*raft.Log
+	err := ReadArchive(&buf, func(l *raft.Log) error {
+		archived = append(archived, l)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, logs[:2], archived)
+}
+
+func TestBadgerStore_ArchiveDir(t *testing.T) {
+	dir, err := os.MkdirTemp("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := testBadgerStoreWithOptions(t, Options{ArchiveDir: dir})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	require.NoError(t, store.DeleteRange(2, 10))
+
+	// Deleting nothing leaves no archive behind
+	require.NoError(t, store.DeleteRange(5, 10))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "logs-00000000000000000002-00000000000000000003.archive", filepath.Base(files[0]))
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	var archived []*raft.Log
+	err = ReadArchive(f, func(l *raft.Log) error {
+		archived = append(archived, l)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, logs[1:], archived)
+}
+
+// stallingWriter signals started on its first write and waits for release.
+type stallingWriter struct {
+	bytes.Buffer
+	started, release chan struct{}
+	once             sync.Once
+}
+
+func (w *stallingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return w.Buffer.Write(p)
+}
+
+func TestBadgerStore_ArchiveWriter_ConcurrentOverwrite(t *testing.T) {
+	w := &stallingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	store := testBadgerStoreWithOptions(t, Options{ArchiveWriter: w})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 3)
+
+	deleted := make(chan error, 1)
+	go func() {
+		deleted <- store.DeleteRange(1, 3)
+	}()
+	<-w.started
+
+	// A log overwritten while its batch is archived waits for the deletion
+	// instead of being deleted without being archived
+	stored := make(chan error, 1)
+	go func() {
+		stored <- store.StoreLogs([]*raft.Log{testRaftLog(2, "new")})
+	}()
+	select {
+	case <-stored:
+		close(w.release)
+		t.Fatal("StoreLogs did not wait for the deletion")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(w.release)
+	require.NoError(t, <-deleted)
+	require.NoError(t, <-stored)
+
+	var log raft.Log
+	require.NoError(t, store.GetLog(2, &log))
+	assert.Equal(t, "new", string(log.Data))
+
+	var archived []uint64
+	require.NoError(t, ReadArchive(&w.Buffer, func(l *raft.Log) error {
+		archived = append(archived, l.Index)
+		return nil
+	}))
+	assert.Equal(t, []uint64{1, 2, 3}, archived)
+}
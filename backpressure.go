@@ -0,0 +1,149 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// How often a throttled append checks whether compaction caught up
+	backpressurePollInterval = 50 * time.Millisecond
+)
+
+var (
+	// An error indicating an append was rejected because compaction fell
+	// too far behind, see Options.PendingCompactionBytes
+	ErrBackpressure = errors.New("backpressure")
+)
+
+// appendThrottle limits the rate of appends and holds them back while
+// compaction is behind.
+type appendThrottle struct {
+	// limiter limits the bytes appended per second, if set.
+	limiter *tokenBucket
+
+	// highWater is the number of bytes pending compaction above which
+	// appends wait up to wait for compaction to catch up. Zero disables it.
+	highWater int64
+	wait      time.Duration
+}
+
+func newAppendThrottle(options Options) appendThrottle {
+	return appendThrottle{
+		limiter:   newTokenBucket(options.AppendBytesPerSecond, options.AppendBurstBytes),
+		highWater: options.PendingCompactionBytes,
+		wait:      options.BackpressureWait,
+	}
+}
+
+// tokenBucket is a token bucket refilled with rate tokens per second up to
+// burst. Takes larger than the available tokens go into debt, which later
+// takes wait off.
+type tokenBucket struct {
+	rate, burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket, or nil if rate is not positive.
+// burst defaults to rate.
+func newTokenBucket(rate, burst int64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take takes n tokens and returns how long to wait until the bucket is out
+// of debt.
+func (t *tokenBucket) take(n int) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.tokens = min(t.burst, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+
+	t.tokens -= float64(n)
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.rate * float64(time.Second))
+}
+
+// throttleAppend delays an append of logs as the rate limit requires, and
+// then while more bytes are pending compaction than the high-water mark. If
+// compaction doesn't catch up within BackpressureWait it fails with
+// ErrBackpressure. Either wait ends early with ErrClosed when the store is
+// closed.
+func (b *BadgerRaftStore) throttleAppend(logs []*raft.Log) error {
+	if b.throttle.limiter != nil {
+		var size int
+		for _, l := range logs {
+			size += len(l.Data) + len(l.Extensions)
+		}
+		if delay := b.throttle.limiter.take(size); delay > 0 {
+			b.stats.throttled.Add(1)
+			if err := b.sleep(delay); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b.throttle.highWater <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(b.throttle.wait)
+	for waited := false; ; waited = true {
+		pending := b.pendingCompactionBytes()
+		if pending <= b.throttle.highWater {
+			return nil
+		}
+		if !waited {
+			b.stats.throttled.Add(1)
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %d bytes pending compaction exceed %d", ErrBackpressure, pending, b.throttle.highWater)
+		}
+		if err := b.sleep(min(backpressurePollInterval, time.Until(deadline))); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d, failing with ErrClosed if the store is closed first.
+func (b *BadgerRaftStore) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-b.shutdownCh:
+		return ErrClosed
+	}
+}
+
+// pendingCompactionBytes estimates how far compaction is behind: everything
+// in level 0 plus what every other level holds beyond its target size.
+func (b *BadgerRaftStore) pendingCompactionBytes() int64 {
+	var pending int64
+	for _, level := range b.db.Levels() {
+		switch {
+		case level.Level == 0:
+			pending += level.Size
+		case level.TargetSize > 0 && level.Size > level.TargetSize:
+			pending += level.Size - level.TargetSize
+		}
+	}
+	return pending
+}
@@ -0,0 +1,97 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	assert.Nil(t, newTokenBucket(0, 100))
+
+	bucket := newTokenBucket(1000, 0)
+	assert.Zero(t, bucket.take(600))
+	assert.Zero(t, bucket.take(400))
+
+	// Out of tokens, the next take goes into debt
+	delay := bucket.take(500)
+	assert.InDelta(t, 500*time.Millisecond, delay, float64(50*time.Millisecond))
+}
+
+func TestBadgerStore_StoreLogs_RateLimit(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		AppendBytesPerSecond: 10000,
+		AppendBurstBytes:     1000,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	data := string(bytes.Repeat([]byte("x"), 1000))
+	start := time.Now()
+	require.NoError(t, store.StoreLog(testRaftLog(1, data)))
+	require.NoError(t, store.StoreLog(testRaftLog(2, data)))
+	require.NoError(t, store.StoreLog(testRaftLog(3, data)))
+
+	// The burst covers the first log, the others wait 100ms each
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	assert.Equal(t, uint64(2), store.Stats().Throttled)
+}
+
+func TestBadgerStore_StoreLogs_Backpressure(t *testing.T) {
+	dir := t.TempDir()
+	opts := badger.DefaultOptions(dir).WithLogger(nil).WithMemTableSize(1 << 20).WithValueThreshold(1 << 10).WithNumLevelZeroTables(100).WithNumLevelZeroTablesStall(200)
+	db, err := badger.Open(opts)
+	require.NoError(t, err)
+	store, err := New(db, Options{PendingCompactionBytes: 1})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Nothing is pending until a memtable is flushed to level 0
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+
+	data := bytes.Repeat([]byte("x"), 512)
+	deadline := time.Now().Add(10 * time.Second)
+	for i := uint64(0); store.pendingCompactionBytes() == 0; i++ {
+		require.True(t, time.Now().Before(deadline), "no table was flushed to level 0")
+		err := store.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(uint64ToBytes(i), data)
+		})
+		require.NoError(t, err)
+	}
+
+	err = store.StoreLog(testRaftLog(2, "log2"))
+	assert.ErrorIs(t, err, ErrBackpressure)
+	assert.Equal(t, uint64(1), store.Stats().Throttled)
+	assert.Equal(t, uint64(1), store.Stats().ErrorsByCategory["backpressure"])
+
+	var log raft.Log
+	assert.ErrorIs(t, store.GetLog(2, &log), raft.ErrLogNotFound)
+}
+
+func TestBadgerStore_StoreLogs_RateLimitClosed(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		AppendBytesPerSecond: 1,
+		AppendBurstBytes:     1,
+	})
+	defer os.Remove(store.path)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- store.StoreLog(testRaftLog(1, "a log that takes a while at one byte per second"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, store.Close())
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("StoreLog kept waiting after the store was closed")
+	}
+}
@@ -0,0 +1,108 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How many batches of a backup are written to Badger concurrently while
+	// it is loaded
+	backupLoadPendingWrites = 256
+)
+
+var (
+	// An error indicating a backup can only be loaded into an empty store
+	ErrNotEmpty = errors.New("store is not empty")
+)
+
+// Backup writes a consistent image of the logs database to w, in Badger's
+// backup format, and returns the newest version it contains. Raft can keep
+// storing and deleting logs while it runs: the image is read at a single
+// version pinned when Backup starts, so it holds exactly the logs that were
+// stored then.
+//
+// The stable store is part of the image unless SeparateStableDir is set.
+// The image can be loaded into an empty store with LoadBackup.
+func (b *BadgerRaftStore) Backup(w io.Writer) (uint64, error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	var version uint64
+	var err error
+	if b.clock != nil {
+		ts := b.clock.pin()
+		defer b.clock.unpin(ts)
+
+		stream := b.db.NewStreamAt(ts)
+		stream.LogPrefix = "raftbadgerstore.Backup"
+		version, err = stream.Backup(w, 0)
+	} else {
+		// Each goroutine of a stream reads in a transaction of its own,
+		// which would see appends made while others already finished, so
+		// a single one reads the whole image
+		stream := b.db.NewStream()
+		stream.LogPrefix = "raftbadgerstore.Backup"
+		stream.NumGo = 1
+		version, err = stream.Backup(w, 0)
+	}
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return version, nil
+}
+
+// LoadBackup loads an image written by Backup into the store, which must not
+// hold any logs yet, and rebuilds the log metadata from the loaded logs. It
+// fails with ErrNotEmpty otherwise.
+func (b *BadgerRaftStore) LoadBackup(r io.Reader) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	if err := b.checkWritable(); err != nil {
+		return err
+	}
+
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+
+	txn := b.newTransaction(b.db, false)
+	last, err := lastIndex(txn)
+	txn.Discard()
+	if err != nil {
+		return storageError(err)
+	}
+	if last != 0 {
+		return fmt.Errorf("%w: it holds logs up to %d", ErrNotEmpty, last)
+	}
+
+	if err := b.db.Load(r, backupLoadPendingWrites); err != nil {
+		return b.writeError(err)
+	}
+	if b.clock != nil {
+		b.clock.advance(b.db.MaxVersion())
+	}
+	// The image carries the dictionaries its logs were compressed with
+	if err := b.loadDictionaries(); err != nil {
+		return err
+	}
+	if err := b.rebuildLogMeta(); err != nil {
+		return err
+	}
+
+	txn = b.newTransaction(b.db, false)
+	defer txn.Discard()
+	meta, err := loadLogMeta(txn)
+	if err != nil {
+		return storageError(err)
+	}
+	log.Info().Uint64("first_index", meta.FirstIndex).Uint64("last_index", meta.LastIndex).Msg("Loaded backup")
+	return nil
+}
@@ -0,0 +1,141 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackupDuringAppends backs store up while logs are appended to it as
+// fast as possible, and checks that the backup loads into a contiguous log.
+func testBackupDuringAppends(t *testing.T, store, restored *BadgerRaftStore) {
+	const batch = 100
+	var next uint64 = 1
+	for ; next <= 20000; next += batch {
+		logs := make([]*raft.Log, batch)
+		for i := range logs {
+			logs[i] = testRaftLog(next+uint64(i), fmt.Sprintf("log%d", next+uint64(i)))
+		}
+		require.NoError(t, store.StoreLogs(logs))
+	}
+	before := next - 1
+
+	var stop atomic.Bool
+	var appended atomic.Uint64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for idx := next; !stop.Load(); idx += batch {
+			logs := make([]*raft.Log, batch)
+			for i := range logs {
+				logs[i] = testRaftLog(idx+uint64(i), fmt.Sprintf("log%d", idx+uint64(i)))
+			}
+			if err := store.StoreLogs(logs); err != nil {
+				t.Error(err)
+				return
+			}
+			// Raft compacts the head of the log as it grows
+			if err := store.DeleteRange(idx-20000, idx-20000+batch-1); err != nil {
+				t.Error(err)
+				return
+			}
+			appended.Add(batch)
+		}
+	}()
+
+	var buf bytes.Buffer
+	_, err := store.Backup(&buf)
+	stop.Store(true)
+	wg.Wait()
+	require.NoError(t, err)
+
+	require.NoError(t, restored.LoadBackup(&buf))
+
+	report, err := restored.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+	assert.GreaterOrEqual(t, report.LastIndex, before)
+	assert.Equal(t, report.LastIndex-report.FirstIndex+1, report.Entries)
+
+	first, err := restored.FirstIndex()
+	require.NoError(t, err)
+	last, err := restored.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, report.FirstIndex, first)
+	assert.Equal(t, report.LastIndex, last)
+
+	var l raft.Log
+	require.NoError(t, restored.GetLog(last, &l))
+	assert.Equal(t, fmt.Sprintf("log%d", last), string(l.Data))
+	t.Logf("backed up logs %d to %d while %d logs were appended", first, last, appended.Load())
+}
+
+func TestBadgerStore_Backup_ConcurrentAppends(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	restored := testBadgerStore(t)
+	defer restored.Close()
+	defer os.Remove(restored.path)
+
+	testBackupDuringAppends(t, store, restored)
+}
+
+func TestBadgerStore_Backup_ConcurrentAppends_KeepVersions(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	restored := testVersionedStore(t)
+	defer restored.Close()
+	defer os.Remove(restored.path)
+
+	testBackupDuringAppends(t, store, restored)
+}
+
+func TestBadgerStore_Backup_StableStore(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "a"), testRaftLog(2, "b")}))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 7))
+
+	var buf bytes.Buffer
+	_, err := store.Backup(&buf)
+	require.NoError(t, err)
+
+	restored := testBadgerStore(t)
+	defer restored.Close()
+	defer os.Remove(restored.path)
+	require.NoError(t, restored.LoadBackup(&buf))
+
+	term, err := restored.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), term)
+	var l raft.Log
+	require.NoError(t, restored.GetLog(2, &l))
+	assert.Equal(t, "b", string(l.Data))
+}
+
+func TestBadgerStore_LoadBackup_NotEmpty(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "a")))
+
+	var buf bytes.Buffer
+	_, err := store.Backup(&buf)
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.LoadBackup(&buf), ErrNotEmpty)
+}
@@ -1,14 +1,28 @@
 package raftbadgerstore
 
 import (
+	"context"
+	"crypto/cipher"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
 	"github.com/hashicorp/raft"
+	"github.com/klauspost/compress/zstd"
 	"github.com/rs/zerolog/log"
 )
 
 const (
+	// Name of the keyspace holding the logs
+	logsKeyspace = "logs"
+
 	// Permissions to use on the db file. This is only used if the
 	// database file does not exist and needs to be created.
 	dbFileMode = 0600
@@ -16,11 +30,26 @@ const (
 
 var (
 	// Bucket names we perform transactions in
-	dbLogs = []byte("logs")
+	dbLogs = []byte(logsKeyspace)
 	dbConf = []byte("conf")
+	dbMeta = []byte("meta")
 
 	// An error indicating a given key does not exist
 	ErrKeyNotFound = errors.New("not found")
+
+	// An error indicating Close gave up waiting for background tasks
+	ErrCloseTimeout = errors.New("timed out waiting for background tasks to stop")
+
+	// An error indicating a compaction would delete logs above the minimum
+	// retain index
+	ErrRetainIndex = errors.New("refusing to delete logs above the minimum retain index")
+
+	// An error indicating stored logs would leave a gap in the log
+	ErrLogGap = errors.New("log gap")
+
+	// An error indicating logs would overwrite existing ones, see
+	// Options.DisallowOverwrite
+	ErrOverwrite = errors.New("overwrite of existing logs")
 )
 
 // BadgerRaftStore provides access to Badger for Raft to store and retrieve
@@ -30,17 +59,199 @@ type BadgerRaftStore struct {
 	// db is the underlying handle to the db.
 	db *badger.DB
 
+	// stableDB holds the stable store keyspace. It is db unless
+	// SeparateStableDir is set.
+	stableDB *badger.DB
+
 	// The path to the Badger database file
 	path string
 
+	// openReport is the result of the check run on open, if any.
+	openReport *VerifyReport
+
+	// tornWrites lists the logs rolled back on open.
+	tornWrites []uint64
+
+	// lockInfoPath is the lock info file written by Open, removed on Close.
+	lockInfoPath string
+
 	msgpackUseNewTimeFormat bool
+
+	// checksums wraps every stored log in an envelope carrying a CRC32.
+	checksums bool
+
+	// codec encodes newly stored logs.
+	codec Codec
+
+	// strictFidelity checks that every stored log decodes unchanged.
+	strictFidelity bool
+
+	// compressLogs compresses logs larger than compressMinSize bytes.
+	compressLogs    bool
+	compressMinSize int
+
+	// dictEncoder compresses logs with the trained dictionary, if any.
+	dictEncoder atomic.Pointer[zstd.Encoder]
+
+	// idempotentAppends stores a content hash with every log and skips
+	// logs already stored with the same content.
+	idempotentAppends bool
+
+	// disallowOverwrite rejects logs at or below the last index.
+	disallowOverwrite bool
+
+	// checkTerms rejects logs with a lower term than the log before them.
+	checkTerms bool
+
+	// cold moves old logs to a cold tier if set.
+	cold *coldTier
+
+	// compressSnapshots compresses the chunks of new snapshots, and
+	// snapshotCipher encrypts them if set.
+	compressSnapshots bool
+	snapshotCipher    cipher.AEAD
+
+	// clock hands out transaction timestamps if Badger runs in managed
+	// mode to keep versions, and versionRetention is how long they are
+	// kept at least.
+	clock            *versionClock
+	versionRetention time.Duration
+
+	// allowLogGaps disables the contiguity check in StoreLogs.
+	allowLogGaps bool
+
+	// closeTimeout, finalGCDiscardRatio and flattenOnClose control what
+	// Close does before the db handle is released.
+	closeTimeout        time.Duration
+	finalGCDiscardRatio float64
+	flattenOnClose      bool
+
+	// retention describes the optional background log retention policy.
+	retention retentionPolicy
+
+	// minRetainIndex protects logs above it from compaction. It holds
+	// math.MaxUint64 while the guard is unset.
+	minRetainIndex atomic.Uint64
+
+	// trailingLogs is how many logs OnSnapshotPersisted keeps behind a
+	// snapshot. pendingTruncate holds the newest snapshot index waiting to
+	// be compacted and truncateCh wakes the background task that does it.
+	trailingLogs    uint64
+	truncateMu      sync.Mutex
+	pendingTruncate uint64
+	truncateCh      chan struct{}
+
+	// logMu serializes the transactions that write logs together with the
+	// metadata and count derived from them, so they never conflict with
+	// each other. A write that timed out keeps it until it finishes, so the
+	// next write waits for it.
+	logMu sync.Mutex
+
+	// archive receives logs before DeleteRange removes them, if set.
+	archive *archiver
+
+	// deleteBatchSize and maxDeleteBatchSize size the transactions
+	// DeleteRange deletes logs in, and deleteCommitInterval is how long it
+	// pauses between them.
+	deleteBatchSize      int
+	maxDeleteBatchSize   int
+	deleteCommitInterval time.Duration
+
+	// throttle limits the rate of appends and applies backpressure.
+	throttle appendThrottle
+
+	// rangePrefetchSize is how many values range reads prefetch.
+	rangePrefetchSize int
+
+	// readahead holds the logs read ahead of sequential GetLog calls, if
+	// enabled.
+	readahead *readahead
+
+	// shutdownCh is closed by Close to signal background tasks to exit,
+	// and wg tracks those tasks so Close can wait for them.
+	shutdownCh chan struct{}
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+	closeErr   error
+
+	// degraded is set while writes are rejected because the disk filled up,
+	// which only happens if degradeOnDiskFull is enabled.
+	degradeOnDiskFull     bool
+	diskFullProbeInterval time.Duration
+	degraded              atomic.Bool
+
+	// opTimeout bounds how long StoreLogs, GetLog and DeleteRange may take.
+	opTimeout time.Duration
+
+	// slowOpThreshold is the duration above which operations are logged.
+	slowOpThreshold time.Duration
+
+	// largeEntryThreshold is the encoded size above which stored logs are
+	// logged.
+	largeEntryThreshold int
+
+	// maxEntrySize is the encoded size above which logs are rejected.
+	maxEntrySize int
+
+	// gc collects the statistics of value log GC cycles.
+	gc gcTracker
+
+	// gcInterval is how often value log GC runs in the background with
+	// gcDiscardRatio, if set. gcPause pauses it during write bursts; it is
+	// nil if GC never pauses.
+	gcInterval     time.Duration
+	gcDiscardRatio float64
+	gcPause        *gcPause
+
+	// reclaimAfterDelete is how many logs a DeleteRange must delete to
+	// wake the background task reclaiming their space through reclaimCh,
+	// if set.
+	reclaimAfterDelete int
+	reclaimCh          chan struct{}
+
+	// metricsInterval is how often gauges are reported, if set.
+	metricsInterval time.Duration
+
+	// watchdogInterval is how often watchdogSamples logs are spot checked,
+	// if set.
+	watchdogInterval time.Duration
+	watchdogSamples  int
+
+	// retry decides how operations failing with transient errors are retried.
+	retry RetryPolicy
+
+	// failpoints injects failures for testing. It is nil unless configured.
+	failpoints *Failpoints
+
+	// commitLatency and readLatency inject delays for testing.
+	commitLatency Latency
+	readLatency   Latency
+
+	// profiler captures profiles when StoreLogs gets slow. It is nil if
+	// profiling is disabled.
+	profiler *profiler
+
+	// closed is set once Close starts, after which new operations fail
+	// with ErrClosed. inflight counts the operations Close waits for.
+	closed   atomic.Bool
+	inflight atomic.Int64
+
+	// stats counts operations for Stats and PublishExpvar.
+	stats storeStats
+
+	// errors tracks failed operations for LastError and Stats.
+	errors errorTracker
+
+	// hooks are called after successful mutations.
+	hooks Hooks
 }
 
 // Options contains all the configuration used to open the Badger
 type Options struct {
 	// NoSync causes the database to skip fsync calls after each
 	// write to the log. This is unsafe, so it should be used
-	// with caution.
+	// with caution. It applies to the Badger options Open and NewRaftNode
+	// build; explicit BadgerOptions and StableBadgerOptions are kept.
 	NoSync bool
 
 	// MsgpackUseNewTimeFormat when set to true, force the underlying msgpack
@@ -48,99 +259,771 @@ type Options struct {
 	// go-msgpack v1.1.5 by default). Decoding is not affected, as all
 	// go-msgpack v2.1.0+ decoders know how to decode both formats.
 	MsgpackUseNewTimeFormat bool
+
+	// Checksums stores a CRC32 alongside every log so corruption is detected
+	// when the log is read or verified. Logs written without a checksum stay
+	// readable, so this can be enabled on an existing store.
+	Checksums bool
+
+	// Codec is the encoding of newly stored logs, CodecMsgpack by default.
+	// Every log is read with the codec it was written with, so the codec
+	// can be changed on an existing store and old logs stay readable.
+	Codec Codec
+
+	// StrictFidelity makes StoreLogs check that every log decodes exactly
+	// as it was given: the same data and extensions, down to whether they
+	// are nil, and the same AppendedAt instant. Logs the codec can't
+	// reproduce, such as empty but non-nil data in CodecBinary, are stored
+	// with msgpack instead, and counted in Stats.FidelityFallbacks. Logs
+	// that can't be stored unchanged at all fail with ErrLossyEncoding.
+	// Every log is decoded once more as it is stored.
+	StrictFidelity bool
+
+	// CompressLogs compresses newly stored logs with zstd once their
+	// encoded size exceeds CompressMinSize bytes, which defaults to 256.
+	// Smaller logs, and those that don't get smaller, are stored as they
+	// are, as compressing them costs CPU for no savings. Every log records
+	// whether it is compressed, so this can be changed on an existing
+	// store, but stores written with it can't be read by versions older
+	// than the one introducing it. TrainCompressionDictionary improves the
+	// compression of small logs, which may warrant a lower CompressMinSize.
+	CompressLogs    bool
+	CompressMinSize int
+
+	// IdempotentAppends stores a hash of every log's index, term, type,
+	// data and extensions, and makes storing a log that is already stored
+	// with the same content a no-op. Storing a log with different content
+	// at an existing index fails with a LogConflictError instead of
+	// overwriting it, so logs must be deleted before they are replaced, as
+	// raft does. Logs stored without a hash are decoded to compare them.
+	// Stores written with it can't be read by versions older than the one
+	// introducing it.
+	IdempotentAppends bool
+
+	// DisallowOverwrite makes StoreLogs fail with ErrOverwrite for logs at
+	// or below the last index, so history can only be rewritten after
+	// DeleteRange truncated it, as raft does when resolving conflicts. With
+	// IdempotentAppends also set, logs identical to those stored are still
+	// skipped.
+	DisallowOverwrite bool
+
+	// CheckTerms makes StoreLogs fail with a TermRegressionError for logs
+	// whose term is lower than that of the log at the index before them,
+	// which raft never writes, catching corruption or misuse when it
+	// happens rather than at the next election. It costs a read of the log
+	// before every batch.
+	CheckTerms bool
+
+	// AllowLogGaps disables the check that makes StoreLogs return ErrLogGap
+	// when logs would not be contiguous with the existing log. The store then
+	// no longer reports itself as a raft.MonotonicLogStore.
+	AllowLogGaps bool
+
+	// CloseTimeout bounds how long Close waits for background tasks and
+	// the optional final GC before closing the database. Zero means wait
+	// indefinitely.
+	CloseTimeout time.Duration
+
+	// FinalGCDiscardRatio, when greater than zero, makes Close run value
+	// log GC with this discard ratio until there is nothing left to rewrite.
+	FinalGCDiscardRatio float64
+
+	// GCInterval, when set, makes the store run value log GC with
+	// GCDiscardRatio, which defaults to 0.5, every interval until there is
+	// nothing left to rewrite.
+	GCInterval     time.Duration
+	GCDiscardRatio float64
+
+	// GCPauseAppendRate and GCPauseAppendLatency pause the background GC
+	// while more than this many logs per second are appended, or while the
+	// p99 latency of recent StoreLogs calls is above this, so GC doesn't
+	// compete with raft during write bursts. GC resumes once appends calm
+	// down.
+	GCPauseAppendRate    float64
+	GCPauseAppendLatency time.Duration
+
+	// ReclaimAfterDelete, when set, makes every DeleteRange deleting at
+	// least this many logs schedule a background pass that flattens the LSM
+	// tree and then runs value log GC with GCDiscardRatio until there is
+	// nothing left to rewrite. Without it, deleted logs only free disk
+	// space once compaction and GC get to them.
+	ReclaimAfterDelete int
+
+	// FlattenOnClose makes Close compact the LSM tree into a single level
+	// before closing the database.
+	FlattenOnClose bool
+
+	// RetentionMaxAge, when set, makes a background task periodically
+	// delete logs whose AppendedAt is older than this duration.
+	RetentionMaxAge time.Duration
+
+	// RetentionMaxBytes, when set, makes a background task periodically
+	// delete the oldest logs whenever the logs keyspace grows beyond this
+	// many bytes, as estimated by Badger.
+	RetentionMaxBytes int64
+
+	// RetentionInterval is how often the retention policy is enforced.
+	// Defaults to one minute.
+	RetentionInterval time.Duration
+
+	// TrailingLogs is how many logs are kept behind a snapshot when logs
+	// are compacted by OnSnapshotPersisted: a snapshot at index deletes the
+	// logs up to index minus TrailingLogs.
+	TrailingLogs uint64
+
+	// ArchiveWriter, if set, receives every log before DeleteRange removes
+	// it, so deleted history can be kept in cold storage. See ReadArchive.
+	ArchiveWriter io.Writer
+
+	// ArchiveDir, if set, makes DeleteRange write the logs it removes to a
+	// new archive file in this directory, named after the range it holds.
+	ArchiveDir string
+
+	// MaxDeleteBatchSize caps how many logs DeleteRange deletes in a single
+	// transaction. Batches start small and grow while they commit quickly,
+	// up to this size. Defaults to 100000.
+	MaxDeleteBatchSize int
+
+	// DeleteBatchSize, when set, makes DeleteRange delete exactly this many
+	// logs per transaction instead of sizing batches adaptively. Smaller
+	// batches hold up concurrent appends for less time; larger ones truncate
+	// faster.
+	DeleteBatchSize int
+
+	// DeleteCommitInterval makes DeleteRange pause this long between two
+	// transactions, so a large truncation leaves room for concurrent
+	// appends.
+	DeleteCommitInterval time.Duration
+
+	// DegradeOnDiskFull switches the store into a read-only degraded mode
+	// when a write fails because the disk is full. StoreLogs and Set then
+	// fail fast with ErrReadOnly until a background probe finds that space
+	// was recovered. DeleteRange keeps working so logs can be truncated.
+	DegradeOnDiskFull bool
+
+	// DiskFullProbeInterval is how often a degraded store checks whether
+	// the disk accepts writes again. Defaults to 10 seconds.
+	DiskFullProbeInterval time.Duration
+
+	// OpTimeout bounds how long StoreLogs, GetLog and DeleteRange may take.
+	// An operation that takes longer fails with ErrTimeout, so a hung disk
+	// surfaces as an error instead of blocking raft indefinitely. The
+	// operation itself can't be interrupted and keeps running in the
+	// background, and may still commit. Writes to the log that follow it,
+	// such as raft retrying the same logs, wait for it to finish first; a
+	// DeleteRange stops after the batch it is deleting.
+	// Zero disables the timeout.
+	OpTimeout time.Duration
+
+	// AppendBytesPerSecond limits the rate of StoreLogs with a token bucket
+	// holding up to AppendBurstBytes, which defaults to one second's worth.
+	// Appends beyond the rate are delayed. Sizes count the data and
+	// extensions of the logs. Zero disables the limit.
+	AppendBytesPerSecond int64
+	AppendBurstBytes     int64
+
+	// PendingCompactionBytes makes StoreLogs wait while more bytes than
+	// this are waiting for compaction, so a burst of writes can't outrun
+	// Badger until it stalls. If compaction doesn't catch up within
+	// BackpressureWait the append fails with ErrBackpressure, right away if
+	// it is zero. Zero disables the check.
+	PendingCompactionBytes int64
+	BackpressureWait       time.Duration
+
+	// Retry configures retries of StoreLogs, GetLog and DeleteRange when
+	// they fail with a transient error, such as a conflict with another
+	// writer sharing the Badger database. Retries are disabled by default.
+	Retry RetryPolicy
+
+	// SlowOpThreshold, when set, logs every store operation that takes
+	// longer than this, along with the index range and batch size it
+	// touched. Slow disks are a common cause of leadership instability.
+	SlowOpThreshold time.Duration
+
+	// LargeEntryThreshold, when set, logs a warning with the index of every
+	// log whose encoded size exceeds this many bytes. The sizes of all logs
+	// are sampled as the raft.badgerstore.entry_size metric.
+	LargeEntryThreshold int
+
+	// MaxEntrySize, when set, makes StoreLogs fail with an
+	// EntryTooLargeError for logs whose encoded size exceeds this many
+	// bytes, rather than with an opaque error from deep inside Badger. No
+	// log of the batch is stored.
+	MaxEntrySize int
+
+	// MetricsInterval, when set, reports the sizes of the LSM tree and
+	// value log, the first and last index, the number of logs and the bytes
+	// pending compaction as raft.badgerstore gauges every interval, so
+	// dashboards don't need the application to poll the store.
+	MetricsInterval time.Duration
+
+	// WatchdogInterval, when set, runs SpotCheck on WatchdogSamples random
+	// logs every interval, which defaults to 100, logging and counting the
+	// anomalies it finds in the raft.badgerstore.watchdog.anomalies metric.
+	WatchdogInterval time.Duration
+	WatchdogSamples  int
+
+	// ProfileP99Threshold, when set, makes the store capture a CPU and an
+	// allocation profile whenever the p99 latency of the last 100 StoreLogs
+	// calls exceeds it. Profiles are written to ProfileDir, which defaults
+	// to a "profiles" directory inside the database directory.
+	ProfileP99Threshold time.Duration
+	ProfileDir          string
+
+	// ProfileDuration is how long the CPU profile runs. Defaults to 10
+	// seconds.
+	ProfileDuration time.Duration
+
+	// ProfileCooldown is the minimum time between two captured profiles.
+	// Defaults to 10 minutes.
+	ProfileCooldown time.Duration
+
+	// BadgerOptions configures the database opened by Open. Its Dir is
+	// replaced by the path passed to Open. Defaults to
+	// badger.DefaultOptions(path) with a logger writing to the global zerolog
+	// logger; see NewBadgerLogger. It is ignored by New.
+	BadgerOptions *badger.Options
+
+	// ValueThreshold is the size above which Open stores values in the
+	// value log instead of the LSM tree, overriding BadgerOptions. Without
+	// BadgerOptions it defaults to 64 KiB, which keeps typical logs out of
+	// the value log and its GC while large FSM commands still spill there.
+	ValueThreshold int64
+
+	// MemoryBudget, when set, is roughly how many bytes Badger may use for
+	// memtables and caches. Open derives the memtable count and size and the
+	// block and index cache sizes from it, overriding those of
+	// BadgerOptions, so stores in small containers don't run out of memory.
+	// It is ignored by New.
+	MemoryBudget int64
+
+	// OpenRetryTimeout is how long Open keeps retrying while another
+	// process holds the database directory lock, as happens during rolling
+	// restarts. Zero fails immediately.
+	OpenRetryTimeout time.Duration
+
+	// Failpoints, if set, injects commit failures, read delays and
+	// corruption errors for testing. See NewFailpoints.
+	Failpoints *Failpoints
+
+	// CommitLatency and ReadLatency, if set, delay every commit and every
+	// read by a duration drawn from them, to reproduce slow disks in tests.
+	// See FixedLatency, UniformLatency and StallLatency.
+	CommitLatency Latency
+	ReadLatency   Latency
+
+	// VerifyOnOpen checks the store before New returns it, and makes New
+	// fail with a VerifyError if problems are found. See OpenReport.
+	VerifyOnOpen VerifyMode
+
+	// ForceOpen opens the store even if VerifyOnOpen found problems.
+	ForceOpen bool
+
+	// RecoverTruncatedLog makes New discard any number of unreadable logs
+	// at the tail of the log, as left behind when the value log was
+	// truncated by a crash, instead of failing with ErrCorrupt. raft may
+	// have acknowledged these logs, so the discarded ranges are recorded;
+	// see LostIndexes.
+	RecoverTruncatedLog bool
+
+	// VerifyValueChecksum and ChecksumVerificationMode set the Badger
+	// options of the same name on the database opened by Open, making
+	// Badger check its own checksums when reading values and tables.
+	VerifyValueChecksum      bool
+	ChecksumVerificationMode options.ChecksumVerificationMode
+
+	// SeparateStableDir, if set, keeps the stable store in its own Badger
+	// database in this directory, so the small, frequently synced writes of
+	// votes and terms don't queue behind log appends. It is opened with
+	// StableBadgerOptions, which default to synchronous writes and small
+	// memtables.
+	SeparateStableDir   string
+	StableBadgerOptions *badger.Options
+
+	// KeepVersions, if set, runs Badger in managed mode and keeps up to this
+	// many versions of every key, so logs that were overwritten or deleted
+	// can still be read with LogVersions and GetLogAt. Every version written
+	// within VersionRetention is kept as well, and deleted logs are only
+	// kept for that long. Open opens the database accordingly; databases
+	// passed to New must be opened with badger.OpenManaged and
+	// NumVersionsToKeep set.
+	//
+	// Conflict detection tracks every commit within VersionRetention, so
+	// it is best combined with badger.Options.DetectConflicts disabled.
+	KeepVersions int
+
+	// VersionRetention is how long versions are kept at least when
+	// KeepVersions is set. Defaults to 10 minutes.
+	VersionRetention time.Duration
+
+	// RetainSnapshots is how many snapshots NewNodeStore keeps. Defaults
+	// to 2, unless RetainSnapshotsFor is set.
+	RetainSnapshots int
+
+	// RetainSnapshotsFor makes NewNodeStore delete snapshots older than
+	// it, always keeping the newest one. With RetainSnapshots also set,
+	// snapshots beyond either limit are deleted.
+	RetainSnapshotsFor time.Duration
+
+	// ColdTier moves logs appended longer than ColdTierAge ago to the
+	// sink in compressed segments, keeping Badger small. GetLog reads
+	// them through from the sink; other reads only see the logs in Badger.
+	// Logs are moved every ColdTierInterval, which defaults to a minute.
+	ColdTier         Sink
+	ColdTierAge      time.Duration
+	ColdTierInterval time.Duration
+
+	// CompressSnapshots compresses the chunks of new snapshots with zstd.
+	// Each chunk records whether it is compressed, so snapshots written
+	// either way can be read.
+	CompressSnapshots bool
+
+	// SnapshotEncryptionKey encrypts the chunks of new snapshots with
+	// AES-GCM. It must be 16, 24 or 32 bytes long, and defaults to the
+	// EncryptionKey of the Badger database, so enabling encryption at rest
+	// covers snapshots too. Encrypted snapshots can only be read with the
+	// key they were written with.
+	SnapshotEncryptionKey []byte
+
+	// RangePrefetchSize is how many values range reads such as GetLogs,
+	// StreamLogs and ExportJSON fetch ahead of the one being read.
+	// Defaults to 100. Larger values speed up reading large logs from the
+	// value log at the cost of memory.
+	RangePrefetchSize int
+
+	// ReadaheadSize, if set, makes GetLog read up to this many logs ahead
+	// once it is called for consecutive indexes, as when a follower catches
+	// up. The logs are read on another goroutine while the caller works
+	// through the previous ones, and dropped whenever logs are written.
+	ReadaheadSize int
+
+	// Hooks are called after StoreLogs, DeleteRange and Set succeed.
+	Hooks Hooks
 }
 
 // NewBadgerRaftStore takes a file path and returns a connected Raft backend.
+// Writes aren't synced, as they never were with NewBadgerRaftStore; use Open
+// for a store that syncs every write.
 func NewBadgerRaftStore(path string) (*BadgerRaftStore, error) {
-	db, err := badger.Open(badger.DefaultOptions(path))
-	if err != nil {
-		return nil, err
-	}
-
-	return New(db, Options{})
+	return Open(path, Options{NoSync: true})
 }
 
 // New uses the supplied options to open the Badger and prepare it for use as a raft backend.
+// It returns ErrAlreadyOpen if another store for the same directory is open
+// in this process.
 func New(db *badger.DB, options Options) (*BadgerRaftStore, error) {
-	// Try to connect
+	if options.Codec >= numCodecs {
+		return nil, fmt.Errorf("unknown codec %d", options.Codec)
+	}
+	snapshotKey := options.SnapshotEncryptionKey
+	if len(snapshotKey) == 0 {
+		snapshotKey = db.Opts().EncryptionKey
+	}
+	snapshotCipher, err := newSnapshotCipher(snapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	managed := options.KeepVersions > 0
+	if managed != isManaged(db) {
+		if managed {
+			return nil, errors.New("KeepVersions needs a database opened with badger.OpenManaged")
+		}
+		return nil, errors.New("managed databases are only supported with KeepVersions set")
+	}
+	if err := register(db.Opts().Dir); err != nil {
+		return nil, err
+	}
+
+	stableDB := db
+	if options.SeparateStableDir != "" {
+		var err error
+		if stableDB, err = openStableDB(options); err != nil {
+			unregister(db.Opts().Dir)
+			return nil, err
+		}
+	}
 
 	// Create the new store
 	store := &BadgerRaftStore{
 		db:                      db,
+		stableDB:                stableDB,
+		path:                    db.Opts().Dir,
 		msgpackUseNewTimeFormat: options.MsgpackUseNewTimeFormat,
+		checksums:               options.Checksums,
+		codec:                   options.Codec,
+		strictFidelity:          options.StrictFidelity,
+		compressLogs:            options.CompressLogs,
+		compressMinSize:         options.CompressMinSize,
+		idempotentAppends:       options.IdempotentAppends,
+		disallowOverwrite:       options.DisallowOverwrite,
+		checkTerms:              options.CheckTerms,
+		cold:                    newColdTier(options.ColdTier, options.ColdTierAge, options.ColdTierInterval),
+		compressSnapshots:       options.CompressSnapshots,
+		snapshotCipher:          snapshotCipher,
+		versionRetention:        options.VersionRetention,
+		allowLogGaps:            options.AllowLogGaps,
+		closeTimeout:            options.CloseTimeout,
+		finalGCDiscardRatio:     options.FinalGCDiscardRatio,
+		flattenOnClose:          options.FlattenOnClose,
+		retention: retentionPolicy{
+			maxAge:   options.RetentionMaxAge,
+			maxBytes: options.RetentionMaxBytes,
+			interval: options.RetentionInterval,
+		},
+		trailingLogs: options.TrailingLogs,
+		truncateCh:   make(chan struct{}, 1),
+		archive:      newArchiver(options.ArchiveWriter, options.ArchiveDir),
+
+		deleteBatchSize:      options.DeleteBatchSize,
+		maxDeleteBatchSize:   options.MaxDeleteBatchSize,
+		deleteCommitInterval: options.DeleteCommitInterval,
+
+		degradeOnDiskFull:     options.DegradeOnDiskFull,
+		diskFullProbeInterval: options.DiskFullProbeInterval,
+		opTimeout:             options.OpTimeout,
+		throttle:              newAppendThrottle(options),
+		rangePrefetchSize:     options.RangePrefetchSize,
+		readahead:             newReadahead(options.ReadaheadSize),
+		retry:                 options.Retry,
+		slowOpThreshold:       options.SlowOpThreshold,
+		largeEntryThreshold:   options.LargeEntryThreshold,
+		maxEntrySize:          options.MaxEntrySize,
+		metricsInterval:       options.MetricsInterval,
+		gcInterval:            options.GCInterval,
+		gcDiscardRatio:        options.GCDiscardRatio,
+		gcPause:               newGCPause(options.GCPauseAppendRate, options.GCPauseAppendLatency),
+		reclaimAfterDelete:    options.ReclaimAfterDelete,
+		reclaimCh:             make(chan struct{}, 1),
+		watchdogInterval:      options.WatchdogInterval,
+		watchdogSamples:       options.WatchdogSamples,
+		profiler:              newProfiler(options, db.Opts().Dir),
+		failpoints:            options.Failpoints,
+		commitLatency:         options.CommitLatency,
+		readLatency:           options.ReadLatency,
+		hooks:                 options.Hooks,
+
+		shutdownCh: make(chan struct{}),
+	}
+	store.minRetainIndex.Store(math.MaxUint64)
+	if store.watchdogSamples <= 0 {
+		store.watchdogSamples = defaultWatchdogSamples
+	}
+	if store.gcDiscardRatio <= 0 {
+		store.gcDiscardRatio = defaultGCDiscardRatio
+	}
+	if store.compressMinSize <= 0 {
+		store.compressMinSize = defaultCompressMinSize
+	}
+	if store.rangePrefetchSize <= 0 {
+		store.rangePrefetchSize = defaultRangePrefetchSize
+	}
+	if managed {
+		store.clock = newVersionClock(db, stableDB)
+		if store.versionRetention <= 0 {
+			store.versionRetention = defaultVersionRetention
+		}
+	}
+
+	if err := store.migrateKeyLayout(keyLayoutMigrations); err != nil {
+		store.abandon()
+		return nil, err
+	}
+	if err := store.loadDictionaries(); err != nil {
+		store.abandon()
+		return nil, err
+	}
+	if err := store.initLogCount(); err != nil {
+		store.abandon()
+		return nil, storageError(err)
+	}
+	if err := store.rollBackTornWrites(options.RecoverTruncatedLog); err != nil {
+		store.abandon()
+		if errors.Is(err, ErrCorrupt) {
+			return nil, err
+		}
+		return nil, storageError(err)
+	}
+	if err := store.verifyOnOpen(options.VerifyOnOpen, options.ForceOpen); err != nil {
+		store.abandon()
+		return nil, err
+	}
+
+	store.goBackground(store.runTruncation)
+	if store.retention.enabled() {
+		store.goBackground(store.runRetention)
+	}
+	if store.degradeOnDiskFull {
+		store.goBackground(store.runDiskFullProbe)
+	}
+	if store.profiler != nil {
+		store.goBackground(store.runProfiler)
+	}
+	if store.watchdogInterval > 0 {
+		store.goBackground(store.runWatchdog)
+	}
+	if store.metricsInterval > 0 {
+		store.goBackground(store.runMetricsReporter)
+	}
+	if store.clock != nil && !db.Opts().ReadOnly {
+		store.goBackground(store.runVersionDiscard)
+	}
+	if store.cold != nil && !db.Opts().ReadOnly {
+		store.goBackground(store.runColdTier)
+	}
+	if store.gcInterval > 0 && !db.Opts().ReadOnly {
+		store.goBackground(store.runGC)
+	}
+	if store.reclaimAfterDelete > 0 && !db.Opts().ReadOnly {
+		store.goBackground(store.runReclaim)
 	}
 	return store, nil
 }
 
-// Close is used to gracefully close the DB connection.
+// Path returns the directory the underlying Badger database lives in. It is
+// empty for in-memory databases.
+func (b *BadgerRaftStore) Path() string {
+	return b.path
+}
+
+// goBackground runs fn in a goroutine that Close waits for. fn must return
+// once shutdownCh is closed.
+func (b *BadgerRaftStore) goBackground(fn func(shutdownCh <-chan struct{})) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.shutdownCh)
+	}()
+}
+
+// enter marks the start of an operation on the database. It returns
+// ErrClosed once the store is closing; otherwise exit must be called when
+// the operation is done.
+func (b *BadgerRaftStore) enter() error {
+	b.inflight.Add(1)
+	if b.closed.Load() {
+		b.inflight.Add(-1)
+		return ErrClosed
+	}
+	return nil
+}
+
+// exit marks the end of an operation started by enter.
+func (b *BadgerRaftStore) exit() {
+	b.inflight.Add(-1)
+}
+
+// Close is used to gracefully close the DB connection. It rejects new
+// operations with ErrClosed, waits for running ones and all background tasks
+// to finish, optionally runs a final value log GC and flatten, and
+// then closes the database. It is safe to call Close multiple times; only
+// the first call does any work and later calls return its result.
 func (b *BadgerRaftStore) Close() error {
-	return b.db.Close()
+	b.closeOnce.Do(func() {
+		if b.lockInfoPath != "" {
+			os.Remove(b.lockInfoPath)
+		}
+		b.closeErr = b.close()
+		unregister(b.path)
+	})
+	return b.closeErr
 }
 
-// FirstIndex returns the first known index from the Raft log.
-func (b *BadgerRaftStore) FirstIndex() (uint64, error) {
-	txn := b.db.NewTransaction(false)
-	defer txn.Discard()
+func (b *BadgerRaftStore) close() error {
+	var deadline <-chan time.Time
+	if b.closeTimeout > 0 {
+		timer := time.NewTimer(b.closeTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
 
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchSize = 10
-	opts.PrefetchValues = false
+	b.closed.Store(true)
+	close(b.shutdownCh)
 
-	it := txn.NewIterator(opts)
-	defer it.Close()
-	prefix := []byte(dbLogs)
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		for b.inflight.Load() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
 
-	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-		item := it.Item()
-		key := item.Key()
+	select {
+	case <-done:
+	case <-deadline:
+		log.Warn().Dur("timeout", b.closeTimeout).Msg("Background tasks and operations did not stop in time, closing anyway")
+		return errors.Join(ErrCloseTimeout, b.db.Close(), b.closeStableDB())
+	}
+
+	if b.finalGCDiscardRatio > 0 {
+		b.runFinalGC(deadline)
+	}
 
-		return bytesToUint64(key[len(dbLogs):]), nil
+	if b.flattenOnClose {
+		if err := b.db.Flatten(1); err != nil {
+			log.Warn().Err(err).Msg("Failed to flatten database on close")
+		}
 	}
-	return 0, nil
+
+	return errors.Join(b.db.Close(), b.closeStableDB())
 }
 
-// LastIndex returns the last known index from the Raft log.
-func (b *BadgerRaftStore) LastIndex() (uint64, error) {
-	txn := b.db.NewTransaction(false)
-	defer txn.Discard()
+// closeStableDB closes the separate stable store database, if any.
+func (b *BadgerRaftStore) closeStableDB() error {
+	if b.stableDB == b.db {
+		return nil
+	}
+	unregister(b.stableDB.Opts().Dir)
+	return b.stableDB.Close()
+}
 
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchSize = 10
-	opts.PrefetchValues = false
-	opts.Reverse = true
+// abandon releases what New acquired when it fails after registering the
+// store. The db passed to New stays open.
+func (b *BadgerRaftStore) abandon() {
+	unregister(b.path)
+	b.closeStableDB()
+}
 
-	it := txn.NewIterator(opts)
-	defer it.Close()
-	prefix := []byte(dbLogs)
+// runFinalGC runs value log GC until there is nothing left to rewrite or
+// the deadline passes.
+func (b *BadgerRaftStore) runFinalGC(deadline <-chan time.Time) {
+	for {
+		select {
+		case <-deadline:
+			log.Warn().Msg("Final value log GC interrupted by close timeout")
+			return
+		default:
+		}
 
-	it.Rewind()
+		err := b.runValueLogGC(b.finalGCDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("Final value log GC failed")
+			return
+		}
+	}
+}
 
-	for it.Seek(End(prefix)); it.ValidForPrefix(prefix); it.Next() {
+// FirstIndex returns the first known index from the Raft log.
+func (b *BadgerRaftStore) FirstIndex() (idx uint64, err error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+	defer b.observe(op{name: "FirstIndex"}, time.Now(), &err)
 
-		item := it.Item()
-		key := item.Key()
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	return firstIndex(txn)
+}
 
-		return bytesToUint64(key[len(dbLogs):]), nil
+// firstIndex returns the first index of the Raft log as seen by txn.
+func firstIndex(txn *badger.Txn) (uint64, error) {
+	// Logs in the cold tier are older than those in Badger
+	if first, err := coldFirstIndex(txn); err != nil || first > 0 {
+		return first, err
 	}
-	return 0, nil
+	return boundaryLogIndex(txn, false), nil
+}
+
+// LastIndex returns the last known index from the Raft log.
+func (b *BadgerRaftStore) LastIndex() (idx uint64, err error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+	defer b.observe(op{name: "LastIndex"}, time.Now(), &err)
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	return lastIndex(txn)
+}
+
+// lastIndex returns the last index of the Raft log as seen by txn.
+func lastIndex(txn *badger.Txn) (uint64, error) {
+	return boundaryLogIndex(txn, true), nil
 }
 
 // GetLog is used to retrieve a log from badger at a given index.
 func (b *BadgerRaftStore) GetLog(idx uint64, raftLog *raft.Log) error {
-	txn := b.db.NewTransaction(false)
-	defer txn.Discard()
+	o := op{name: "GetLog", min: idx, max: idx}
+	if b.opTimeout <= 0 {
+		return b.do(o, func() error {
+			return b.getLog(idx, raftLog)
+		})
+	}
 
-	item, err := txn.Get(addPrefix(dbLogs, uint64ToBytes(idx)))
+	// Decode into a private log so a timed out read can't write into
+	// raftLog after GetLog returned.
+	var result raft.Log
+	err := b.do(o, func() error {
+		return b.getLog(idx, &result)
+	})
+	if err == nil {
+		*raftLog = result
+	}
+	return err
+}
+
+func (b *BadgerRaftStore) getLog(idx uint64, raftLog *raft.Log) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	if err := b.beforeRead(); err != nil {
+		return err
+	}
+
+	if log, ok := b.readahead.take(idx); ok {
+		*raftLog = *log
+		b.stats.readaheadHits.Add(1)
+	} else {
+		txn := b.newTransaction(b.db, false)
+		defer txn.Discard()
+
+		err := readLog(txn, idx, raftLog)
+		if errors.Is(err, raft.ErrLogNotFound) && b.cold != nil {
+			err = b.readColdLog(txn, idx, raftLog)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	b.stats.reads.Add(1)
+
+	if first, last, gen, ok := b.readahead.read(idx); ok {
+		go b.fetchAhead(first, last, gen)
+	}
+	return nil
+}
+
+// readLog reads the log at idx as seen by txn.
+func readLog(txn *badger.Txn, idx uint64, raftLog *raft.Log) error {
+	kb := getKeyBuf()
+	defer kb.release()
+
+	item, err := txn.Get(kb.logKey(idx))
 	if err != nil {
-		return raft.ErrLogNotFound
+		return readError(err, raft.ErrLogNotFound)
 	}
 
 	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return readError(err, raft.ErrLogNotFound)
+	}
+	if len(val) == 0 {
+		return fmt.Errorf("%w: log %d is empty", ErrCorrupt, idx)
+	}
 
-	if val == nil || err != nil {
-		return raft.ErrLogNotFound
+	if err := decodeLog(val, raftLog); err != nil {
+		return fmt.Errorf("%w: log %d: %w", ErrCorrupt, idx, err)
 	}
-	return DecodeMsgPack(val, raftLog)
+	return nil
 }
 
 // StoreLog is used to store a single raft log
@@ -150,112 +1033,529 @@ func (b *BadgerRaftStore) StoreLog(log *raft.Log) error {
 
 // StoreLogs is used to store a set of raft logs
 func (b *BadgerRaftStore) StoreLogs(logs []*raft.Log) error {
+	o := op{name: "StoreLogs", batch: len(logs)}
+	if len(logs) > 0 {
+		o.min, o.max = logs[0].Index, logs[len(logs)-1].Index
+	}
+	start := time.Now()
+	throttled := false
+	err := b.do(o, func() error {
+		// Retries were already admitted
+		if !throttled {
+			if err := b.throttleAppend(logs); err != nil {
+				return err
+			}
+			throttled = true
+		}
+		return b.storeLogs(logs)
+	})
+	elapsed := time.Since(start)
+	b.profiler.record(elapsed)
+	b.gcPause.record(elapsed)
+	return err
+}
+
+func (b *BadgerRaftStore) storeLogs(logs []*raft.Log) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
 	log.Debug().Msgf("Storing logs: %+v", logs)
 
-	txn := b.db.NewTransaction(true)
+	if err := b.checkWritable(); err != nil {
+		return err
+	}
+
+	if len(logs) == 0 {
+		return nil
+	}
+
+	if err := b.writeLogs(logs); err != nil {
+		return err
+	}
+	b.readahead.invalidate()
+	recordAppendLatency(logs, time.Now())
+	b.stats.appends.Add(uint64(len(logs)))
+	b.hooks.storeLogs(logs[0].Index, logs[len(logs)-1].Index)
+	return nil
+}
+
+// writeLogs writes logs and updates the log metadata and count in a single
+// transaction.
+func (b *BadgerRaftStore) writeLogs(logs []*raft.Log) error {
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+
+	txn := b.newTransaction(b.db, true)
 	defer txn.Discard()
 
-	for _, log := range logs {
-		key := uint64ToBytes(log.Index)
-		val, err := EncodeMsgPack(log, b.msgpackUseNewTimeFormat)
-		if err != nil {
+	meta, err := loadLogMeta(txn)
+	if err != nil {
+		return storageError(err)
+	}
+
+	if !b.allowLogGaps {
+		if err := checkContiguous(meta, logs); err != nil {
 			return err
 		}
+	}
+	if b.checkTerms {
+		if err := checkTerms(txn, logs); err != nil {
+			return err
+		}
+	}
 
-		if err := txn.Set(addPrefix(dbLogs, key), val.Bytes()); err != nil {
+	keys := logKeys(logs)
+	var added uint64
+	for i, log := range logs {
+		if b.idempotentAppends && log.Index >= meta.FirstIndex && log.Index <= meta.LastIndex {
+			dup, err := storedDuplicate(txn, keys[i], log)
+			if err != nil {
+				return err
+			}
+			if dup {
+				continue
+			}
+		}
+		if b.disallowOverwrite && log.Index <= meta.LastIndex {
+			return fmt.Errorf("%w: log %d is not above last index %d", ErrOverwrite, log.Index, meta.LastIndex)
+		}
+
+		val, err := b.encodeLog(log)
+		if err != nil {
 			return err
 		}
+		b.recordEntrySize(log.Index, len(val))
+		if b.maxEntrySize > 0 && len(val) > b.maxEntrySize {
+			return &EntryTooLargeError{Index: log.Index, Size: len(val), Max: b.maxEntrySize}
+		}
+
+		// Logs between the first and last index usually exist, but gaps are
+		// allowed or left behind by a truncation that stopped part way
+		exists := meta.LastIndex > 0 && log.Index >= meta.FirstIndex && log.Index <= meta.LastIndex
+		if exists {
+			_, err := txn.Get(keys[i])
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return storageError(err)
+			}
+			exists = err == nil
+		}
+		if !exists {
+			added++
+		}
+
+		if err := txn.Set(keys[i], val); err != nil {
+			return storageError(err)
+		}
+	}
+
+	meta.extend(logs[0].Index, logs[len(logs)-1].Index)
+	if err := writeLogMeta(txn, meta); err != nil {
+		return storageError(err)
+	}
+	if err := adjustLogCount(txn, added, 0); err != nil {
+		return storageError(err)
+	}
+
+	if err := b.commit(txn); err != nil {
+		return b.writeError(err)
+	}
+	return nil
+}
+
+// checkContiguous returns ErrLogGap if storing logs would leave a hole in
+// the log, either within the batch or after the current last index.
+// Overwriting existing indexes, as raft does after truncating a conflicting
+// suffix, is allowed.
+func checkContiguous(meta LogMetadata, logs []*raft.Log) error {
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Index != logs[i-1].Index+1 {
+			return fmt.Errorf("%w: log %d follows log %d", ErrLogGap, logs[i].Index, logs[i-1].Index)
+		}
 	}
 
-	return txn.Commit()
+	if meta.LastIndex > 0 && logs[0].Index > meta.LastIndex+1 {
+		return fmt.Errorf("%w: log %d follows last index %d", ErrLogGap, logs[0].Index, meta.LastIndex)
+	}
+	return nil
+}
+
+// IsMonotonic implements raft.MonotonicLogStore. Unless gaps are allowed the
+// store rejects them, so raft must remove all logs when restoring a snapshot
+// instead of leaving a gap behind.
+func (b *BadgerRaftStore) IsMonotonic() bool {
+	return !b.allowLogGaps
+}
+
+// SetMinRetainIndex protects all logs above idx from compaction until the
+// index is advanced again, typically once a snapshot covering them has been
+// persisted. DeleteRange calls that remove the head of the log beyond idx
+// fail with ErrRetainIndex and retention policies stop at idx. Truncating a
+// suffix of the log, as raft does when resolving conflicts, is not affected.
+// Passing math.MaxUint64 removes the guard.
+func (b *BadgerRaftStore) SetMinRetainIndex(idx uint64) {
+	b.minRetainIndex.Store(idx)
+}
+
+// checkMinRetainIndex returns ErrRetainIndex if deleting [min, max] would
+// compact logs above the minimum retain index.
+func (b *BadgerRaftStore) checkMinRetainIndex(min, max uint64) error {
+	guard := b.minRetainIndex.Load()
+	if max <= guard {
+		return nil
+	}
+
+	first, err := b.FirstIndex()
+	if err != nil {
+		return err
+	}
+	if min > first {
+		return nil
+	}
+	return fmt.Errorf("%w: deleting up to %d, retaining above %d", ErrRetainIndex, max, guard)
 }
 
 // DeleteRange is used to delete logs within a given range inclusively.
 func (b *BadgerRaftStore) DeleteRange(min, max uint64) error {
-	batchSize := 100 // Adjust the batch size as needed
+	return b.DeleteRangeWithProgress(context.Background(), min, max, nil)
+}
 
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchSize = 10
+// DeleteRangeWithProgress deletes logs within a given range inclusively
+// like DeleteRange, calling progress, if set, after every committed batch
+// with the number of logs deleted so far and an estimate of how many are
+// left. It stops between batches once ctx is done and returns ctx's error,
+// leaving the logs not deleted yet in place, so long truncations can be
+// cancelled during shutdown.
+func (b *BadgerRaftStore) DeleteRangeWithProgress(ctx context.Context, min, max uint64, progress func(deleted, remaining uint64)) error {
+	return b.doContext(ctx, op{name: "DeleteRange", min: min, max: max}, func(ctx context.Context) error {
+		return b.deleteRange(ctx, min, max, progress)
+	})
+}
 
-	// Convert min to the prefixed byte array
-	minKey := addPrefix(dbLogs, uint64ToBytes(min))
+func (b *BadgerRaftStore) deleteRange(ctx context.Context, min, max uint64, progress func(deleted, remaining uint64)) (err error) {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
 
-	for {
-		txn := b.db.NewTransaction(true)
-		it := txn.NewIterator(opts)
+	if err := b.checkMinRetainIndex(min, max); err != nil {
+		return err
+	}
 
-		count := 0
-		var lastKey []byte
+	if b.cold != nil {
+		if err := b.trimColdTier(min, max); err != nil {
+			return err
+		}
+	}
 
-		for it.Seek(minKey); it.ValidForPrefix(dbLogs); it.Next() {
-			item := it.Item()
-			k := item.Key()
-			lastKey = append([]byte{}, k...)
+	var total uint64
+	if progress != nil {
+		if total, err = b.countRange(min, max); err != nil {
+			return storageError(err)
+		}
+	}
 
-			if bytesToUint64(k[len(dbLogs):]) > max {
-				break
-			}
+	batcher := newDeleteBatcher(b.deleteBatchSize, b.maxDeleteBatchSize, b.db.MaxBatchCount())
 
-			if err := txn.Delete(k); err != nil {
-				it.Close()
-				txn.Discard()
-				return err
+	arc, err := b.archive.begin()
+	if err != nil {
+		return err
+	}
+	if arc != nil {
+		defer func() {
+			if cerr := arc.close(); err == nil {
+				err = cerr
 			}
+		}()
+	}
 
-			count++
-			if count >= batchSize {
-				break
-			}
-		}
+	// Convert min to the prefixed byte array
+	minKey := logKey(min)
+	deleted := 0
 
-		it.Close()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
+		start := time.Now()
+		count, lastKey, err := b.deleteLogs(ctx, min, minKey, max, batcher.size, arc)
+		if err != nil {
+			return err
+		}
 		if count == 0 {
 			// No more items to delete
-			txn.Discard()
 			break
 		}
+		b.readahead.invalidate()
+		b.stats.deletes.Add(uint64(count))
+		deleted += count
+		full := count >= batcher.size
+		batcher.adjust(count, time.Since(start), false)
 
-		// Commit the current transaction
-		if err := txn.Commit(); err != nil {
-			return err
+		if progress != nil {
+			var remaining uint64
+			if total > uint64(deleted) {
+				remaining = total - uint64(deleted)
+			}
+			progress(uint64(deleted), remaining)
 		}
 
 		// Set the minKey for the next batch to be the lastKey + 1
 		minKey = append(lastKey, 0)
+
+		// Let concurrent appends through before the next batch, unless the
+		// store is closing
+		if full && b.deleteCommitInterval > 0 {
+			select {
+			case <-time.After(b.deleteCommitInterval):
+			case <-b.shutdownCh:
+			case <-ctx.Done():
+			}
+		}
 	}
 
+	if deleted > 0 {
+		b.hooks.deleteRange(min, max)
+	}
+	if b.reclaimAfterDelete > 0 && deleted >= b.reclaimAfterDelete {
+		b.scheduleReclaim()
+	}
 	return nil
 }
 
-// Set is used to set a key/value set outside of the raft log
-func (b *BadgerRaftStore) Set(k, v []byte) error {
-	txn := b.db.NewTransaction(true)
+// deleteLogs deletes up to size logs from minKey on with an index of at most
+// max, writing them to arc first if set, and updates the log metadata and
+// count in the same transaction, so a crash never leaves them out of sync
+// with the logs. The logs are collected, archived and deleted while holding
+// logMu, so no log is written in between and deleted without being
+// archived. min is where the DeleteRange call started, below which the logs
+// are untouched. It returns how many logs were deleted and the key of the
+// last one.
+func (b *BadgerRaftStore) deleteLogs(ctx context.Context, min uint64, minKey []byte, max uint64, size int, arc *archiveSession) (int, []byte, error) {
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+
+	// A DeleteRange that timed out must not delete logs written after it
+	// gave up, such as those of raft retrying a conflict truncation
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	txn := b.newTransaction(b.db, true)
+	defer txn.Discard()
+
+	meta, err := loadLogMeta(txn)
+	if err != nil {
+		return 0, nil, storageError(err)
+	}
+
+	keys, err := collectDeleteBatch(txn, minKey, max, size, arc)
+	if err != nil || len(keys) == 0 {
+		return 0, nil, err
+	}
+
+	// Archived logs must be durable before they are deleted
+	if arc != nil {
+		if err := arc.sync(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for _, k := range keys {
+		if err := txn.Delete(k); err != nil {
+			return 0, nil, b.writeError(err)
+		}
+	}
+	first, last := logIndex(keys[0]), logIndex(keys[len(keys)-1])
+	if err := writeLogMeta(txn, meta.afterDelete(txn, min, first, last)); err != nil {
+		return 0, nil, storageError(err)
+	}
+	if err := adjustLogCount(txn, 0, uint64(len(keys))); err != nil {
+		return 0, nil, storageError(err)
+	}
+	if err := b.commit(txn); err != nil {
+		return 0, nil, b.writeError(err)
+	}
+	return len(keys), keys[len(keys)-1], nil
+}
+
+// collectDeleteBatch returns the keys of up to size logs from minKey on with
+// an index of at most max, writing them to arc if set.
+func collectDeleteBatch(txn *badger.Txn, minKey []byte, max uint64, size int, arc *archiveSession) ([][]byte, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+	opts.PrefetchValues = arc != nil
+	opts.Prefix = dbLogs
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var keys [][]byte
+	for it.Seek(minKey); it.Valid() && len(keys) < size; it.Next() {
+		item := it.Item()
+		k := item.KeyCopy(nil)
+
+		idx := logIndex(k)
+		if idx > max {
+			break
+		}
+
+		if arc != nil {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, storageError(err)
+			}
+			if val, err = portableLogValue(val); err != nil {
+				return nil, fmt.Errorf("%w: log %d: %w", ErrCorrupt, idx, err)
+			}
+			if err := arc.write(idx, val); err != nil {
+				return nil, storageError(err)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// rebuildLogMeta rebuilds the log metadata and count from the logs after
+// they were written in bulk. The caller must hold logMu.
+func (b *BadgerRaftStore) rebuildLogMeta() error {
+	err := b.update(b.db, func(txn *badger.Txn) error {
+		meta, err := scanLogMeta(txn)
+		if err != nil {
+			return err
+		}
+		if err := writeLogMeta(txn, meta); err != nil {
+			return err
+		}
+		_, err = rebuildLogCount(txn)
+		return err
+	})
+	if err != nil {
+		return b.writeError(err)
+	}
+	return nil
+}
+
+// countRange estimates how many logs there are between min and max
+// inclusively, assuming the log has no gaps.
+func (b *BadgerRaftStore) countRange(min, max uint64) (uint64, error) {
+	txn := b.newTransaction(b.db, false)
 	defer txn.Discard()
 
-	if err := txn.Set(addPrefix(dbConf, k), v); err != nil {
+	meta, err := loadLogMeta(txn)
+	if err != nil || meta.LastIndex == 0 {
+		return 0, err
+	}
+
+	first, last := meta.FirstIndex, meta.LastIndex
+	if min > first {
+		first = min
+	}
+	if max < last {
+		last = max
+	}
+	if first > last {
+		return 0, nil
+	}
+	return last - first + 1, nil
+}
+
+// RetainLast deletes all logs except the most recent n. Like raft's own log
+// compaction it removes everything up to and including LastIndex-n, which
+// makes it a drop-in for the "keep trailing logs after a snapshot" pattern.
+func (b *BadgerRaftStore) RetainLast(n uint64) error {
+	first, err := b.FirstIndex()
+	if err != nil {
+		return err
+	}
+	last, err := b.LastIndex()
+	if err != nil {
 		return err
 	}
 
-	return txn.Commit()
+	if last <= n {
+		return nil
+	}
+
+	max := min(last-n, b.minRetainIndex.Load())
+	if first > max {
+		return nil
+	}
+	return b.DeleteRange(first, max)
 }
 
-// Get is used to retrieve a value from the k/v store by key
-func (b *BadgerRaftStore) Get(k []byte) ([]byte, error) {
-	txn := b.db.NewTransaction(false)
+// Set is used to set a key/value set outside of the raft log
+func (b *BadgerRaftStore) Set(k, v []byte) (err error) {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+	defer b.observe(op{name: "Set"}, time.Now(), &err)
+
+	if err := b.checkWritable(); err != nil {
+		return err
+	}
+
+	txn := b.newTransaction(b.stableDB, true)
 	defer txn.Discard()
 
-	item, err := txn.Get(addPrefix(dbConf, k))
+	if err := txn.Set(prefixedKey(dbConf, k), v); err != nil {
+		return storageError(err)
+	}
+
+	if err := b.commit(txn); err != nil {
+		return b.writeError(err)
+	}
+	b.hooks.set(k)
+	return nil
+}
+
+// Get is used to retrieve a value from the k/v store by key. Wrap the store
+// with middleware.WithCache to keep hot keys such as raft's CurrentTerm in
+// memory.
+func (b *BadgerRaftStore) Get(k []byte) (val []byte, err error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+	defer b.observe(op{name: "Get"}, time.Now(), &err)
+
+	if err := b.beforeRead(); err != nil {
+		return nil, err
+	}
+
+	txn := b.newTransaction(b.stableDB, false)
+	defer txn.Discard()
+
+	if val, err = readConf(txn, k); err != nil {
+		return nil, err
+	}
+	b.stats.reads.Add(1)
+	return val, nil
+}
+
+// readConf reads the stable store key k as seen by txn.
+func readConf(txn *badger.Txn, k []byte) ([]byte, error) {
+	kb := getKeyBuf()
+	defer kb.release()
+
+	item, err := txn.Get(kb.prefixedKey(dbConf, k))
 	if err != nil {
-		return nil, ErrKeyNotFound
+		return nil, readError(err, ErrKeyNotFound)
 	}
 
 	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, readError(err, ErrKeyNotFound)
+	}
 
-	if val == nil || err != nil {
+	if val == nil {
 		return nil, ErrKeyNotFound
 	}
-	return append([]byte(nil), val...), nil
+	return val, nil
 }
 
 // SetUint64 is like Set, but handles uint64 values
@@ -269,11 +1569,24 @@ func (b *BadgerRaftStore) GetUint64(key []byte) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
+	return confUint64(key, val)
+}
+
+// confUint64 decodes the stable store value val of key as a uint64.
+func confUint64(key, val []byte) (uint64, error) {
+	if len(val) != 8 {
+		return 0, fmt.Errorf("%w: %q holds %d bytes, not a uint64", ErrCorrupt, key, len(val))
+	}
 	return bytesToUint64(val), nil
 }
 
 func (b *BadgerRaftStore) RunValueLogGC(discardRatio float64) error {
-	return b.db.RunValueLogGC(discardRatio)
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	return b.runValueLogGC(discardRatio)
 }
 
 func (b *BadgerRaftStore) Size() (lsm, vlog int64) {
@@ -3,7 +3,9 @@ package raftbadgerstore
 import (
 	"os"
 	"testing"
+	"time"
 
+	"github.com/dgraph-io/badger/v4"
 	"github.com/hashicorp/raft"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,6 +24,21 @@ func testBadgerStore(t testing.TB) *BadgerRaftStore {
 	return store
 }
 
+func testBadgerStoreWithOptions(t testing.TB, options Options) *BadgerRaftStore {
+	dirname, err := os.MkdirTemp("", "store")
+	require.NoError(t, err)
+
+	os.Remove(dirname)
+
+	db, err := badger.Open(badger.DefaultOptions(dirname).WithLogger(nil))
+	require.NoError(t, err)
+
+	store, err := New(db, options)
+	require.NoError(t, err)
+
+	return store
+}
+
 func testRaftLog(idx uint64, data string) *raft.Log {
 	return &raft.Log{
 		Data:  []byte(data),
@@ -36,6 +53,9 @@ func TestBadgerStore_Implements(t *testing.T) {
 
 	_, ok = store.(raft.LogStore)
 	assert.True(t, ok)
+
+	_, ok = store.(raft.MonotonicLogStore)
+	assert.True(t, ok)
 }
 
 func TestBadgerStore_FirstIndex(t *testing.T) {
@@ -169,6 +189,67 @@ func TestBadgerStore_SetLogs(t *testing.T) {
 	assert.Equal(t, logs[2], result2)
 }
 
+func TestBadgerStore_SetLogs_Gap(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	assert.True(t, store.IsMonotonic())
+
+	// Any index can start an empty log
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(5, "log5")}))
+
+	// Gaps within a batch are rejected
+	err := store.StoreLogs([]*raft.Log{testRaftLog(6, "log6"), testRaftLog(8, "log8")})
+	assert.ErrorIs(t, err, ErrLogGap)
+
+	// Gaps after the last index are rejected
+	err = store.StoreLogs([]*raft.Log{testRaftLog(7, "log7")})
+	assert.ErrorIs(t, err, ErrLogGap)
+
+	// Appending and overwriting are allowed
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(6, "log6")}))
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(5, "log5"), testRaftLog(6, "log6")}))
+
+	gappy := testBadgerStoreWithOptions(t, Options{AllowLogGaps: true})
+	defer gappy.Close()
+	defer os.Remove(gappy.path)
+
+	assert.False(t, gappy.IsMonotonic())
+	require.NoError(t, gappy.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(3, "log3")}))
+}
+
+func TestBadgerStore_SetLogs_DisallowOverwrite(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{DisallowOverwrite: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 5)
+
+	// Rewriting the tail or the whole batch is rejected, appending isn't
+	err := store.StoreLogs([]*raft.Log{testRaftLog(5, "other"), testRaftLog(6, "log6")})
+	assert.ErrorIs(t, err, ErrOverwrite)
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(1, "other")), ErrOverwrite)
+	require.NoError(t, store.StoreLog(testRaftLog(6, "log6")))
+	assert.Equal(t, uint64(2), store.Stats().ErrorsByCategory["overwrite"])
+
+	var log raft.Log
+	require.NoError(t, store.GetLog(5, &log))
+	assert.Equal(t, "log5", string(log.Data))
+
+	// Once truncated, the logs can be rewritten
+	require.NoError(t, store.DeleteRange(4, 6))
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(4, "new4"), testRaftLog(5, "new5")}))
+	require.NoError(t, store.GetLog(5, &log))
+	assert.Equal(t, "new5", string(log.Data))
+
+	// Identical logs are still skipped with idempotent appends
+	store.idempotentAppends = true
+	require.NoError(t, store.StoreLog(testRaftLog(6, "log6")))
+	require.NoError(t, store.StoreLog(testRaftLog(6, "log6")))
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(6, "other")), ErrLogConflict)
+}
+
 func TestBadgerStore_DeleteRange(t *testing.T) {
 	store := testBadgerStore(t)
 	defer store.Close()
@@ -205,6 +286,80 @@ func TestBadgerStore_DeleteRange(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestBadgerStore_RetainLast(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Retaining on an empty log is a no-op
+	require.NoError(t, store.RetainLast(2))
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+		testRaftLog(4, "log4"),
+		testRaftLog(5, "log5"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Retaining more logs than exist keeps everything
+	require.NoError(t, store.RetainLast(10))
+	idx, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), idx)
+
+	require.NoError(t, store.RetainLast(2))
+
+	idx, err = store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), idx)
+
+	idx, err = store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), idx)
+}
+
+func TestBadgerStore_SetMinRetainIndex(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+		testRaftLog(4, "log4"),
+		testRaftLog(5, "log5"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	store.SetMinRetainIndex(2)
+
+	// Compacting beyond the guard is refused
+	err := store.DeleteRange(1, 3)
+	assert.ErrorIs(t, err, ErrRetainIndex)
+
+	// RetainLast stops at the guard
+	require.NoError(t, store.RetainLast(1))
+	idx, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), idx)
+
+	// Truncating the tail is still allowed
+	require.NoError(t, store.DeleteRange(5, 5))
+	idx, err = store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), idx)
+
+	// Advancing the guard allows compaction again
+	store.SetMinRetainIndex(3)
+	require.NoError(t, store.DeleteRange(3, 3))
+	idx, err = store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), idx)
+}
+
 func TestBadgerStore_Set_Get(t *testing.T) {
 	store := testBadgerStore(t)
 	defer store.Close()
@@ -249,9 +404,15 @@ func TestBadgerStore_SetUint64_GetUint64(t *testing.T) {
 
 // TestDBPath tests that the DBPath method returns the correct path
 func TestDBPath(t *testing.T) {
-	store := testBadgerStore(t)
+	dirname, err := os.MkdirTemp("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dirname)
+
+	store, err := NewBadgerRaftStore(dirname)
+	require.NoError(t, err)
 	defer store.Close()
-	defer os.Remove(store.path)
+
+	assert.Equal(t, dirname, store.Path())
 }
 
 // TestSize tests that the Size method returns the correct size
@@ -286,3 +447,45 @@ func TestRunValueLogGC(t *testing.T) {
 	// err = store.RunValueLogGC(0.5)
 	// require.Equal(t, badger.ErrNoRewrite, err)
 }
+
+func TestBadgerStore_Close(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		FinalGCDiscardRatio: 0.5,
+		FlattenOnClose:      true,
+	})
+	defer os.Remove(store.path)
+
+	stopped := make(chan struct{})
+	store.goBackground(func(shutdownCh <-chan struct{}) {
+		<-shutdownCh
+		close(stopped)
+	})
+
+	require.NoError(t, store.Close())
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("background task was not stopped")
+	}
+
+	// Closing again is a no-op
+	require.NoError(t, store.Close())
+}
+
+func TestBadgerStore_Close_Timeout(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		CloseTimeout: 10 * time.Millisecond,
+	})
+	defer os.Remove(store.path)
+
+	release := make(chan struct{})
+	defer close(release)
+	store.goBackground(func(shutdownCh <-chan struct{}) {
+		<-release
+	})
+
+	err := store.Close()
+	assert.ErrorIs(t, err, ErrCloseTimeout)
+	assert.ErrorIs(t, store.Close(), ErrCloseTimeout)
+}
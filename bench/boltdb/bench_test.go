@@ -0,0 +1,97 @@
+// Package boltdb compares the store with raft-boltdb, the store most raft
+// users replace. It is a module of its own so raft-boltdb doesn't become a
+// dependency of the store; run the benchmarks from this directory with:
+//
+//	go mod tidy
+//	go test -run ^$ -bench .
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	raftbench "github.com/hashicorp/raft/bench"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// store is a store the benchmarks run against.
+type store interface {
+	raft.LogStore
+	raft.StableStore
+}
+
+// stores are the stores compared. Both sync every write to disk before it
+// returns, the defaults of Open and NewBoltStore, so the comparison is of
+// equally durable stores.
+var stores = []struct {
+	name string
+	open func(b *testing.B) store
+}{
+	{"Badger", func(b *testing.B) store {
+		// raft's DeleteRange benchmark stores logs with gaps
+		s, err := raftbadgerstore.Open(b.TempDir(), raftbadgerstore.Options{AllowLogGaps: true})
+		if err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		b.Cleanup(func() { s.Close() })
+		return s
+	}},
+	{"BoltDB", func(b *testing.B) store {
+		s, err := raftboltdb.NewBoltStore(filepath.Join(b.TempDir(), "raft.db"))
+		if err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		b.Cleanup(func() { s.Close() })
+		return s
+	}},
+}
+
+func runStores(b *testing.B, fn func(b *testing.B, s store)) {
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			fn(b, s.open(b))
+		})
+	}
+}
+
+func BenchmarkFirstIndex(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.FirstIndex(b, s) })
+}
+
+func BenchmarkLastIndex(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.LastIndex(b, s) })
+}
+
+func BenchmarkGetLog(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.GetLog(b, s) })
+}
+
+func BenchmarkStoreLog(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.StoreLog(b, s) })
+}
+
+func BenchmarkStoreLogs(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.StoreLogs(b, s) })
+}
+
+func BenchmarkDeleteRange(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.DeleteRange(b, s) })
+}
+
+func BenchmarkSet(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.Set(b, s) })
+}
+
+func BenchmarkGet(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.Get(b, s) })
+}
+
+func BenchmarkSetUint64(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.SetUint64(b, s) })
+}
+
+func BenchmarkGetUint64(b *testing.B) {
+	runStores(b, func(b *testing.B, s store) { raftbench.GetUint64(b, s) })
+}
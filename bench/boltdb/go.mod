@@ -0,0 +1,11 @@
+module github.com/kgantsov/raft-badgerstore/bench/boltdb
+
+go 1.24.1
+
+require (
+	github.com/hashicorp/raft v1.7.3
+	github.com/hashicorp/raft-boltdb/v2 v2.3.1
+	github.com/kgantsov/raft-badgerstore v0.0.0
+)
+
+replace github.com/kgantsov/raft-badgerstore => ../..
@@ -0,0 +1,170 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	raftbench "github.com/hashicorp/raft/bench"
+)
+
+// benchStore is a store the benchmarks run against.
+type benchStore interface {
+	raft.LogStore
+	raft.StableStore
+}
+
+// benchStores are the stores compared by the benchmarks. raft's InmemStore
+// is the baseline for the cost of the store itself. The comparison with
+// raft-boltdb lives in the bench/boltdb module, so it isn't a dependency of
+// this one.
+var benchStores = []struct {
+	name string
+	open func(b *testing.B) benchStore
+}{
+	{"Badger", func(b *testing.B) benchStore {
+		// raft's DeleteRange benchmark stores logs with gaps
+		store := testBadgerStoreWithOptions(b, Options{AllowLogGaps: true})
+		b.Cleanup(func() {
+			store.Close()
+			os.RemoveAll(store.path)
+		})
+		return store
+	}},
+	{"Inmem", func(b *testing.B) benchStore {
+		return raft.NewInmemStore()
+	}},
+}
+
+func runBenchStores(b *testing.B, fn func(b *testing.B, store benchStore)) {
+	for _, s := range benchStores {
+		b.Run(s.name, func(b *testing.B) {
+			fn(b, s.open(b))
+		})
+	}
+}
+
+func BenchmarkBadgerStore_FirstIndex(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.FirstIndex(b, store) })
+}
+
+func BenchmarkBadgerStore_LastIndex(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.LastIndex(b, store) })
+}
+
+func BenchmarkBadgerStore_GetLog(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.GetLog(b, store) })
+}
+
+func BenchmarkBadgerStore_StoreLog(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.StoreLog(b, store) })
+}
+
+func BenchmarkBadgerStore_StoreLogs(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.StoreLogs(b, store) })
+}
+
+// BenchmarkBadgerStore_StoreLogs_BatchSize measures appending batches of the
+// sizes raft typically produces under load.
+func BenchmarkBadgerStore_StoreLogs_BatchSize(b *testing.B) {
+	for _, size := range []int{1, 16, 64, 256, 1024} {
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			runBenchStores(b, func(b *testing.B, store benchStore) {
+				data := make([]byte, 256)
+				logs := make([]*raft.Log, size)
+				for i := range logs {
+					logs[i] = &raft.Log{Data: data}
+				}
+
+				var next uint64 = 1
+				b.SetBytes(int64(size * len(data)))
+				b.ResetTimer()
+
+				for n := 0; n < b.N; n++ {
+					for _, log := range logs {
+						log.Index = next
+						next++
+					}
+					if err := store.StoreLogs(logs); err != nil {
+						b.Fatalf("err: %s", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkBadgerStore_DeleteRange(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.DeleteRange(b, store) })
+}
+
+func BenchmarkBadgerStore_Set(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.Set(b, store) })
+}
+
+func BenchmarkBadgerStore_Get(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.Get(b, store) })
+}
+
+func BenchmarkBadgerStore_SetUint64(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.SetUint64(b, store) })
+}
+
+func BenchmarkBadgerStore_GetUint64(b *testing.B) {
+	runBenchStores(b, func(b *testing.B, store benchStore) { raftbench.GetUint64(b, store) })
+}
+
+// BenchmarkBadgerStore_Allocs reports the allocations of the hot paths of
+// the Badger store alone.
+func BenchmarkBadgerStore_Allocs(b *testing.B) {
+	store := testBadgerStore(b)
+	b.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(store.path)
+	})
+
+	logs := make([]*raft.Log, 64)
+	for i := range logs {
+		logs[i] = &raft.Log{Index: uint64(i + 1), Data: make([]byte, 256)}
+	}
+	if err := store.StoreLogs(logs); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	if err := store.SetUint64([]byte("CurrentTerm"), 1); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	b.Run("GetLog", func(b *testing.B) {
+		b.ReportAllocs()
+		var log raft.Log
+		for n := 0; n < b.N; n++ {
+			if err := store.GetLog(uint64(n%len(logs))+1, &log); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+
+	b.Run("GetUint64", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if _, err := store.GetUint64([]byte("CurrentTerm")); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+
+	b.Run("StoreLogs", func(b *testing.B) {
+		b.ReportAllocs()
+		next := uint64(len(logs) + 1)
+		for n := 0; n < b.N; n++ {
+			for _, log := range logs {
+				log.Index = next
+				next++
+			}
+			if err := store.StoreLogs(logs); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+}
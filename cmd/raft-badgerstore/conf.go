@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// Directory setconf saves old values to, unless -backup-dir is set, inside
+// the directory of the database holding the stable store
+const confBackupDir = "conf-backups"
+
+// confBackup is the file setconf saves a value to before changing it.
+type confBackup struct {
+	Key     string    `json:"key"`
+	Value   string    `json:"value_hex"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// confFormatFlag registers the -format flag of getconf and setconf.
+func confFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "string", "value format: string, hex or uint64")
+}
+
+func runGetConf(args []string) error {
+	fs := flag.NewFlagSet("getconf", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	key := fs.String("key", "", "stable store key, such as CurrentTerm")
+	format := confFormatFlag(fs)
+	fs.Parse(args)
+
+	if *key == "" {
+		return errors.New("-key is required")
+	}
+
+	store, err := flags.open(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	val, err := store.Get([]byte(*key))
+	if err != nil {
+		return err
+	}
+	s, err := formatConfValue(val, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Println(s)
+	return nil
+}
+
+func runSetConf(args []string) error {
+	fs := flag.NewFlagSet("setconf", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	key := fs.String("key", "", "stable store key, such as CurrentTerm")
+	value := fs.String("value", "", "new value, in -format")
+	format := confFormatFlag(fs)
+	force := fs.Bool("force", false, "overwrite an existing value")
+	backupDir := fs.String("backup-dir", "", "directory to save the old value to (default conf-backups in the stable store directory)")
+	fs.Parse(args)
+
+	if *key == "" {
+		return errors.New("-key is required")
+	}
+	newVal, err := parseConfValue(*value, *format)
+	if err != nil {
+		return err
+	}
+
+	store, err := flags.open(true)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	oldVal, err := store.Get([]byte(*key))
+	switch {
+	case errors.Is(err, raftbadgerstore.ErrKeyNotFound):
+		fmt.Printf("%s has no value\n", *key)
+	case err != nil:
+		return err
+	default:
+		old, err := formatConfValue(oldVal, *format)
+		if err != nil {
+			old = hex.EncodeToString(oldVal) + " (hex)"
+		}
+		fmt.Printf("%s is %s\n", *key, old)
+		if !*force {
+			return errors.New("refusing to overwrite an existing value without -force")
+		}
+
+		dir := *backupDir
+		if dir == "" {
+			dir = filepath.Join(flags.stableStoreDir(), confBackupDir)
+		}
+		path, err := backUpConfValue(dir, *key, oldVal)
+		if err != nil {
+			return fmt.Errorf("backing up the old value: %w", err)
+		}
+		fmt.Printf("saved the old value to %s\n", path)
+	}
+
+	if err := store.Set([]byte(*key), newVal); err != nil {
+		return err
+	}
+	fmt.Printf("set %s to %s\n", *key, *value)
+	return nil
+}
+
+// parseConfValue parses s as a value in format.
+func parseConfValue(s, format string) ([]byte, error) {
+	switch format {
+	case "string":
+		return []byte(s), nil
+	case "hex":
+		return hex.DecodeString(s)
+	case "uint64":
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(nil, n), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// formatConfValue formats val in format.
+func formatConfValue(val []byte, format string) (string, error) {
+	switch format {
+	case "string":
+		return string(val), nil
+	case "hex":
+		return hex.EncodeToString(val), nil
+	case "uint64":
+		if len(val) != 8 {
+			return "", fmt.Errorf("value holds %d bytes, not a uint64", len(val))
+		}
+		return strconv.FormatUint(binary.BigEndian.Uint64(val), 10), nil
+	default:
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// backUpConfValue saves the value val of key to a new file in dir and
+// returns its path.
+func backUpConfValue(dir, key string, val []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	buf, err := json.MarshalIndent(confBackup{Key: key, Value: hex.EncodeToString(val), SavedAt: now}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s.json", hex.EncodeToString([]byte(key)), now.Format("20060102T150405.000000000"))
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, append(buf, '\n'), 0o600)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConfStore creates a store in dir, with its stable store in stableDir
+// if set, holding CurrentTerm 5.
+func testConfStore(t *testing.T, dir, stableDir string) {
+	store, err := raftbadgerstore.Open(dir, raftbadgerstore.Options{SeparateStableDir: stableDir, NoSync: true})
+	require.NoError(t, err)
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 5))
+	require.NoError(t, store.Close())
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+	err = fn()
+	w.Close()
+	return string(<-out), err
+}
+
+// readConfBackups returns the backups saved in dir.
+func readConfBackups(t *testing.T, dir string) []confBackup {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+
+	var backups []confBackup
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var b confBackup
+		require.NoError(t, json.Unmarshal(data, &b))
+		backups = append(backups, b)
+	}
+	return backups
+}
+
+func TestGetConf(t *testing.T) {
+	dir := t.TempDir()
+	testConfStore(t, dir, "")
+
+	out, err := captureStdout(t, func() error {
+		return runGetConf([]string{"-dir", dir, "-key", "CurrentTerm", "-format", "uint64"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "5\n", out)
+
+	_, err = captureStdout(t, func() error {
+		return runGetConf([]string{"-dir", dir, "-key", "LastVoteCand"})
+	})
+	assert.ErrorIs(t, err, raftbadgerstore.ErrKeyNotFound)
+}
+
+func TestSetConf(t *testing.T) {
+	dir := t.TempDir()
+	testConfStore(t, dir, "")
+
+	// Existing values are only overwritten with -force
+	_, err := captureStdout(t, func() error {
+		return runSetConf([]string{"-dir", dir, "-key", "CurrentTerm", "-value", "6", "-format", "uint64"})
+	})
+	assert.ErrorContains(t, err, "-force")
+	assert.NoDirExists(t, filepath.Join(dir, confBackupDir))
+
+	out, err := captureStdout(t, func() error {
+		return runGetConf([]string{"-dir", dir, "-key", "CurrentTerm", "-format", "uint64"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "5\n", out)
+
+	_, err = captureStdout(t, func() error {
+		return runSetConf([]string{"-dir", dir, "-key", "CurrentTerm", "-value", "6", "-format", "uint64", "-force"})
+	})
+	require.NoError(t, err)
+
+	out, err = captureStdout(t, func() error {
+		return runGetConf([]string{"-dir", dir, "-key", "CurrentTerm", "-format", "uint64"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "6\n", out)
+
+	backups := readConfBackups(t, filepath.Join(dir, confBackupDir))
+	require.Len(t, backups, 1)
+	assert.Equal(t, "CurrentTerm", backups[0].Key)
+	assert.Equal(t, hex.EncodeToString([]byte{0, 0, 0, 0, 0, 0, 0, 5}), backups[0].Value)
+
+	// New keys need no -force and have nothing to back up
+	_, err = captureStdout(t, func() error {
+		return runSetConf([]string{"-dir", dir, "-key", "LastVoteCand", "-value", "node1"})
+	})
+	require.NoError(t, err)
+	assert.Len(t, readConfBackups(t, filepath.Join(dir, confBackupDir)), 1)
+}
+
+func TestSetConf_BackupDir(t *testing.T) {
+	dir, stableDir := t.TempDir(), t.TempDir()
+	testConfStore(t, dir, stableDir)
+
+	// Old values are saved next to the store holding the key
+	_, err := captureStdout(t, func() error {
+		return runSetConf([]string{"-dir", dir, "-stable-dir", stableDir, "-key", "CurrentTerm", "-value", "6", "-format", "uint64", "-force"})
+	})
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, confBackupDir))
+	assert.Len(t, readConfBackups(t, filepath.Join(stableDir, confBackupDir)), 1)
+
+	// or in -backup-dir
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	_, err = captureStdout(t, func() error {
+		return runSetConf([]string{"-dir", dir, "-stable-dir", stableDir, "-key", "CurrentTerm", "-value", "7", "-format", "uint64", "-force", "-backup-dir", backupDir})
+	})
+	require.NoError(t, err)
+	backups := readConfBackups(t, backupDir)
+	require.Len(t, backups, 1)
+	assert.Equal(t, hex.EncodeToString([]byte{0, 0, 0, 0, 0, 0, 0, 6}), backups[0].Value)
+}
@@ -0,0 +1,114 @@
+// Command raft-badgerstore inspects and maintains the data directory of a
+// raft-badgerstore backed raft node. The node must be stopped while the
+// commands run, except for tail, which follows a running node through its
+// AdminHandler.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// command is a subcommand of the tool.
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"getconf":             {usage: "print the value of a stable store key", run: runGetConf},
+	"repair":              {usage: "rebuild log metadata from the logs", run: runRepair},
+	"setconf":             {usage: "change the value of a stable store key, saving the old one", run: runSetConf},
+	"snapshots":           {usage: "list the snapshots kept in the store", run: runSnapshots},
+	"stats":               {usage: "print the index range, log count and sizes of the store", run: runStats},
+	"tail":                {usage: "print logs as a running node appends them", run: runTail},
+	"verify":              {usage: "check the raft log for gaps, term regressions and corruption", run: runVerify},
+	"upgrade-time-format": {usage: "re-encode logs with the new msgpack time format", run: runUpgradeTimeFormat},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: raft-badgerstore <command> [flags]\n\ncommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+}
+
+// storeFlags are the flags every command uses to locate and open the
+// store. A store created with SeparateStableDir or KeepVersions must be
+// opened with the same settings, or its stable store and versions are
+// missed.
+type storeFlags struct {
+	dir          string
+	stableDir    string
+	keepVersions int
+}
+
+// newStoreFlags registers the store flags in fs.
+func newStoreFlags(fs *flag.FlagSet) *storeFlags {
+	f := &storeFlags{}
+	fs.StringVar(&f.dir, "dir", "", "path to the raft-badgerstore data directory")
+	fs.StringVar(&f.stableDir, "stable-dir", "", "path to the stable store directory, if the store was created with SeparateStableDir")
+	fs.IntVar(&f.keepVersions, "keep-versions", 0, "versions kept of every key, if the store was created with KeepVersions")
+	return f
+}
+
+// stableStoreDir returns the directory of the database holding the stable
+// store.
+func (f *storeFlags) stableStoreDir() string {
+	if f.stableDir != "" {
+		return f.stableDir
+	}
+	return f.dir
+}
+
+// open opens the store. Stores are opened read-only unless writable is set.
+func (f *storeFlags) open(writable bool) (*raftbadgerstore.BadgerRaftStore, error) {
+	if f.dir == "" {
+		return nil, fmt.Errorf("-dir is required")
+	}
+
+	opts := badger.DefaultOptions(f.dir).
+		WithReadOnly(!writable).
+		WithLogger(nil)
+	options := raftbadgerstore.Options{
+		BadgerOptions: &opts,
+		KeepVersions:  f.keepVersions,
+	}
+	if f.stableDir != "" {
+		stableOpts := badger.DefaultOptions(f.stableDir).
+			WithReadOnly(!writable).
+			WithLogger(nil)
+		options.SeparateStableDir = f.stableDir
+		options.StableBadgerOptions = &stableOpts
+	}
+	return raftbadgerstore.Open(f.dir, options)
+}
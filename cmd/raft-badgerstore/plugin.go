@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// decodeFunc decodes the payload of a command log into a value that is
+// printed as JSON.
+type decodeFunc func(data []byte) (any, error)
+
+// loadDecoder loads the Go plugin at path and returns its Decode function,
+// declared either as a function or as a variable holding one. Plugins must
+// be built with the same Go version as the tool.
+func loadDecoder(path string) (decodeFunc, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("Decode")
+	if err != nil {
+		return nil, err
+	}
+
+	switch decode := sym.(type) {
+	case func([]byte) (any, error):
+		return decode, nil
+	case *func([]byte) (any, error):
+		return *decode, nil
+	default:
+		return nil, fmt.Errorf("%s: Decode is a %T, not a func([]byte) (any, error)", path, sym)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+func runRepair(args []string) error {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	fs.Parse(args)
+
+	store, err := flags.open(true)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report, err := store.Repair()
+	if err != nil {
+		return err
+	}
+
+	if !report.Changed() {
+		fmt.Println("metadata is consistent, nothing to repair")
+		return nil
+	}
+	if report.Before != nil {
+		fmt.Printf("before: first index %d, last index %d\n", report.Before.FirstIndex, report.Before.LastIndex)
+	} else {
+		fmt.Println("before: no metadata")
+	}
+	fmt.Printf("after:  first index %d, last index %d\n", report.After.FirstIndex, report.After.LastIndex)
+	return nil
+}
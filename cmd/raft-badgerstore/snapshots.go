@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runSnapshots(args []string) error {
+	fs := flag.NewFlagSet("snapshots", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	asJSON := fs.Bool("json", false, "print the snapshots as JSON")
+	fs.Parse(args)
+
+	store, err := flags.open(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	snapshots, err := store.ListSnapshots()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snapshots)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tINDEX\tTERM\tSIZE\tCREATED\tSERVERS")
+	for _, s := range snapshots {
+		created := "-"
+		if !s.CreatedAt.IsZero() {
+			created = s.CreatedAt.Format(time.RFC3339)
+		}
+		servers := make([]string, 0, len(s.Configuration.Servers))
+		for _, server := range s.Configuration.Servers {
+			servers = append(servers, fmt.Sprintf("%s(%s)", server.ID, server.Suffrage))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", s.ID, s.Index, s.Term, s.Size, created, strings.Join(servers, ","))
+	}
+	return w.Flush()
+}
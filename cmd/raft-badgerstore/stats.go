@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// storeStats is what the stats command prints.
+type storeStats struct {
+	FirstIndex uint64    `json:"first_index"`
+	LastIndex  uint64    `json:"last_index"`
+	LogCount   uint64    `json:"log_count"`
+	Oldest     time.Time `json:"oldest,omitzero"`
+	Newest     time.Time `json:"newest,omitzero"`
+
+	KeyLayoutVersion uint64 `json:"key_layout_version"`
+
+	LSMSize  int64 `json:"lsm_size"`
+	VlogSize int64 `json:"vlog_size"`
+
+	Keyspaces map[string]raftbadgerstore.KeyspaceSize `json:"keyspaces"`
+}
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	asJSON := fs.Bool("json", false, "print the stats as JSON")
+	fs.Parse(args)
+
+	store, err := flags.open(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var stats storeStats
+	span, err := store.LogTimeSpan()
+	if err != nil {
+		return err
+	}
+	stats.FirstIndex, stats.LastIndex = span.FirstIndex, span.LastIndex
+	stats.Oldest, stats.Newest = span.Oldest, span.Newest
+	if stats.LogCount, err = store.LogCount(); err != nil {
+		return err
+	}
+	if stats.KeyLayoutVersion, err = store.KeyLayoutVersion(); err != nil {
+		return err
+	}
+	if stats.Keyspaces, err = store.KeyspaceSizes(); err != nil {
+		return err
+	}
+	stats.LSMSize, stats.VlogSize = store.Size()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	fmt.Printf("first index:        %d\n", stats.FirstIndex)
+	fmt.Printf("last index:         %d\n", stats.LastIndex)
+	fmt.Printf("log count:          %d\n", stats.LogCount)
+	fmt.Printf("oldest log:         %s\n", formatTime(stats.Oldest))
+	fmt.Printf("newest log:         %s\n", formatTime(stats.Newest))
+	fmt.Printf("key layout version: %d\n", stats.KeyLayoutVersion)
+	fmt.Printf("lsm size:           %d\n", stats.LSMSize)
+	fmt.Printf("vlog size:          %d\n", stats.VlogSize)
+	fmt.Println()
+
+	names := make([]string, 0, len(stats.Keyspaces))
+	for name := range stats.Keyspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEYSPACE\tKEYS\tBYTES")
+	for _, name := range names {
+		size := stats.Keyspaces[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\n", name, size.Keys, size.Bytes)
+	}
+	return w.Flush()
+}
+
+// formatTime formats t, or returns "-" if it is unknown.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
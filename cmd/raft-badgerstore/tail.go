@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tailLog is a log as served by the /follow admin endpoint.
+type tailLog struct {
+	Index      uint64    `json:"index"`
+	Term       uint64    `json:"term"`
+	Type       string    `json:"type"`
+	Data       []byte    `json:"data,omitempty"`
+	Extensions []byte    `json:"extensions,omitempty"`
+	AppendedAt time.Time `json:"appended_at,omitzero"`
+}
+
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	addr := fs.String("addr", "", "URL the node serves AdminHandler at, such as http://localhost:8080/raft")
+	from := fs.String("from", "", "index to start from; by default only logs appended from now on are printed")
+	pluginPath := fs.String("plugin", "", "Go plugin exporting Decode func([]byte) (any, error) to decode command payloads")
+	asJSON := fs.Bool("json", false, "print logs as JSON, one per line")
+	fs.Parse(args)
+
+	if *addr == "" {
+		return errors.New("-addr is required; tail follows a running node, as its data directory is locked")
+	}
+	u, err := url.Parse(strings.TrimSuffix(*addr, "/") + "/follow")
+	if err != nil {
+		return err
+	}
+	if *from != "" {
+		if _, err := strconv.ParseUint(*from, 10, 64); err != nil {
+			return fmt.Errorf("-from must be an index: %w", err)
+		}
+		u.RawQuery = url.Values{"from": {*from}}.Encode()
+	}
+
+	var decode decodeFunc
+	if *pluginPath != "" {
+		if decode, err = loadDecoder(*pluginPath); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var l tailLog
+		if err := dec.Decode(&l); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				return errors.New("the node closed the stream")
+			}
+			return err
+		}
+		if err := printTailLog(out, l, decode, *asJSON); err != nil {
+			return err
+		}
+		if dec.More() {
+			continue
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// printTailLog prints l, with its payload decoded by decode if set.
+func printTailLog(w io.Writer, l tailLog, decode decodeFunc, asJSON bool) error {
+	var decoded any
+	var decodeErr error
+	if decode != nil && l.Type == "LogCommand" {
+		decoded, decodeErr = decode(l.Data)
+	}
+
+	if asJSON {
+		line := struct {
+			tailLog
+			Decoded     any    `json:"decoded,omitempty"`
+			DecodeError string `json:"decode_error,omitempty"`
+		}{tailLog: l, Decoded: decoded}
+		if decodeErr != nil {
+			line.DecodeError = decodeErr.Error()
+		}
+		return json.NewEncoder(w).Encode(line)
+	}
+
+	appended := "-"
+	if !l.AppendedAt.IsZero() {
+		appended = l.AppendedAt.Format(time.RFC3339Nano)
+	}
+	payload := formatPayload(l.Data)
+	switch {
+	case decodeErr != nil:
+		payload = fmt.Sprintf("%s (decode failed: %v)", payload, decodeErr)
+	case decoded != nil:
+		buf, err := json.Marshal(decoded)
+		if err != nil {
+			return err
+		}
+		payload = string(buf)
+	}
+	_, err := fmt.Fprintf(w, "%-10d %-6d %-16s %-35s %s\n", l.Index, l.Term, l.Type, appended, payload)
+	return err
+}
+
+// formatPayload returns data quoted if it is text, and in hex otherwise.
+func formatPayload(data []byte) string {
+	if len(data) == 0 {
+		return "-"
+	}
+	if utf8.Valid(data) && strconv.CanBackquote(string(data)) {
+		return strconv.Quote(string(data))
+	}
+	return fmt.Sprintf("%x", data)
+}
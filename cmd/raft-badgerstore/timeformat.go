@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+func runUpgradeTimeFormat(args []string) error {
+	fs := flag.NewFlagSet("upgrade-time-format", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	fs.Parse(args)
+
+	store, err := flags.open(true)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	upgraded, err := store.UpgradeTimeFormat()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("re-encoded %d logs, the store can now be opened with MsgpackUseNewTimeFormat\n", upgraded)
+	return nil
+}
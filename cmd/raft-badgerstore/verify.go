@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags := newStoreFlags(fs)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	store, err := flags.open(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report, err := store.VerifyConsistency()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("first index:       %d\n", report.FirstIndex)
+		fmt.Printf("last index:        %d\n", report.LastIndex)
+		fmt.Printf("entries:           %d\n", report.Entries)
+		fmt.Printf("gaps:              %v\n", report.Gaps)
+		fmt.Printf("term regressions:  %v\n", report.TermRegressions)
+		fmt.Printf("index mismatches:  %v\n", report.IndexMismatches)
+		fmt.Printf("undecodable:       %v\n", report.Undecodable)
+		fmt.Printf("checksum failures: %v\n", report.ChecksumFailures)
+	}
+
+	if !report.OK() {
+		return errors.New("inconsistencies found")
+	}
+	return nil
+}
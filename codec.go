@@ -0,0 +1,303 @@
+package raftbadgerstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// envelopeMagic starts every enveloped log value. 0xc1 is never used by
+	// msgpack, so enveloped values can't be confused with bare msgpack ones
+	// written before envelopes existed or with checksums disabled.
+	envelopeMagic = 0xc1
+
+	// envelopeFlagChecksum marks an envelope carrying a CRC32 of its body.
+	envelopeFlagChecksum = 1 << 0
+
+	// envelopeFlagHash marks an envelope whose body starts with a content
+	// hash of the log, see Options.IdempotentAppends.
+	envelopeFlagHash = 1 << 1
+
+	// envelopeFlagCompressed marks an envelope whose payload is compressed
+	// with zstd, see Options.CompressLogs.
+	envelopeFlagCompressed = 1 << 2
+
+	// The high four bits of the envelope flags hold the Codec of the payload.
+	envelopeCodecShift = 4
+
+	// Size of the envelope header: magic, flags and CRC32.
+	envelopeHeaderSize = 6
+)
+
+var (
+	// An error indicating a stored value failed its checksum
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// An error indicating a stored value has a malformed envelope
+	ErrInvalidEnvelope = errors.New("invalid envelope")
+
+	errBinaryLogTruncated = errors.New("binary log is truncated")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Codec is the encoding of stored logs.
+type Codec uint8
+
+const (
+	// CodecMsgpack encodes logs with msgpack, like raft-boltdb does. It is
+	// the default.
+	CodecMsgpack Codec = iota
+
+	// CodecBinary encodes logs in a compact fixed layout that is faster to
+	// encode and decode than msgpack. Stores using it can't be read by
+	// versions older than the one introducing it.
+	CodecBinary
+
+	// Number of codecs; the envelope has room for 16
+	numCodecs
+)
+
+// String returns the name of the codec.
+func (c Codec) String() string {
+	switch c {
+	case CodecMsgpack:
+		return "msgpack"
+	case CodecBinary:
+		return "binary"
+	default:
+		return fmt.Sprintf("codec(%d)", uint8(c))
+	}
+}
+
+// logEncoding describes how logs are stored.
+type logEncoding struct {
+	codec Codec
+
+	// newTimeFormat selects the msgpack time format.
+	newTimeFormat bool
+
+	// checksum and hash add a CRC32 of the envelope body and a content
+	// hash of the log to the envelope.
+	checksum bool
+	hash     bool
+
+	// compress compresses payloads larger than compressMinSize bytes, with
+	// compressor if set.
+	compress        bool
+	compressMinSize int
+	compressor      *zstd.Encoder
+}
+
+// encodeLog encodes a log the way it is stored, with the store's codec and
+// wrapped in an envelope carrying a checksum and content hash if enabled.
+func (b *BadgerRaftStore) encodeLog(l *raft.Log) ([]byte, error) {
+	enc := b.logEncoding()
+	if b.strictFidelity {
+		return b.encodeFaithfully(l, enc)
+	}
+	return encodeLogValue(l, enc)
+}
+
+// logEncoding returns how the store encodes new logs.
+func (b *BadgerRaftStore) logEncoding() logEncoding {
+	return logEncoding{
+		codec:         b.codec,
+		newTimeFormat: b.msgpackUseNewTimeFormat,
+		checksum:      b.checksums,
+		hash:          b.idempotentAppends,
+
+		compress:        b.compressLogs,
+		compressMinSize: b.compressMinSize,
+		compressor:      b.dictEncoder.Load(),
+	}
+}
+
+// encodeLogValue encodes a log as enc says, wrapping it in an envelope
+// unless it is msgpack without a checksum, hash or compression. Those are
+// stored bare, as they always were.
+func encodeLogValue(l *raft.Log, enc logEncoding) ([]byte, error) {
+	payload, err := encodePayload(l, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed := false
+	if enc.compress {
+		payload, compressed = compressPayload(payload, enc.compressMinSize, enc.compressor)
+	}
+	if enc.codec == CodecMsgpack && !enc.checksum && !enc.hash && !compressed {
+		return payload, nil
+	}
+
+	size := envelopeHeaderSize + len(payload)
+	if enc.hash {
+		size += contentHashSize
+	}
+	val := make([]byte, envelopeHeaderSize, size)
+	val[0] = envelopeMagic
+	val[1] = byte(enc.codec) << envelopeCodecShift
+	if compressed {
+		val[1] |= envelopeFlagCompressed
+	}
+	if enc.hash {
+		val[1] |= envelopeFlagHash
+		val = binary.BigEndian.AppendUint64(val, contentHash(l))
+	}
+	val = append(val, payload...)
+	if enc.checksum {
+		val[1] |= envelopeFlagChecksum
+		binary.BigEndian.PutUint32(val[2:], crc32.Checksum(val[envelopeHeaderSize:], crcTable))
+	}
+	return val, nil
+}
+
+// encodePayload encodes a log with the codec and msgpack time format of enc,
+// without an envelope.
+func encodePayload(l *raft.Log, enc logEncoding) ([]byte, error) {
+	switch enc.codec {
+	case CodecMsgpack:
+		buf, err := EncodeMsgPack(l, enc.newTimeFormat)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecBinary:
+		return appendBinaryLog(nil, l), nil
+	default:
+		return nil, fmt.Errorf("unknown codec %d", enc.codec)
+	}
+}
+
+// storedEncoding returns how a stored log value was written, so it can be
+// rewritten the same way.
+func storedEncoding(val []byte) logEncoding {
+	if len(val) < envelopeHeaderSize || val[0] != envelopeMagic {
+		return logEncoding{codec: CodecMsgpack}
+	}
+	return logEncoding{
+		codec:    Codec(val[1] >> envelopeCodecShift),
+		checksum: val[1]&envelopeFlagChecksum != 0,
+		hash:     val[1]&envelopeFlagHash != 0,
+		compress: val[1]&envelopeFlagCompressed != 0,
+	}
+}
+
+// decodeLog decodes a stored log value with the codec it was written with,
+// verifying its checksum if it has one.
+func decodeLog(val []byte, l *raft.Log) error {
+	codec, payload, err := openEnvelope(val)
+	if err != nil {
+		return err
+	}
+
+	switch codec {
+	case CodecMsgpack:
+		return DecodeMsgPack(payload, l)
+	case CodecBinary:
+		return decodeBinaryLog(payload, l)
+	default:
+		return fmt.Errorf("%w: unknown codec %d", ErrInvalidEnvelope, codec)
+	}
+}
+
+// openEnvelope returns the codec and payload of a stored log value. Values
+// without an envelope are msgpack, returned as they are.
+func openEnvelope(val []byte) (Codec, []byte, error) {
+	if len(val) == 0 || val[0] != envelopeMagic {
+		return CodecMsgpack, val, nil
+	}
+	if len(val) < envelopeHeaderSize {
+		return 0, nil, ErrInvalidEnvelope
+	}
+
+	payload := val[envelopeHeaderSize:]
+	if val[1]&envelopeFlagChecksum != 0 {
+		if binary.BigEndian.Uint32(val[2:]) != crc32.Checksum(payload, crcTable) {
+			return 0, nil, ErrChecksumMismatch
+		}
+	}
+	if val[1]&envelopeFlagHash != 0 {
+		if len(payload) < contentHashSize {
+			return 0, nil, ErrInvalidEnvelope
+		}
+		payload = payload[contentHashSize:]
+	}
+	if val[1]&envelopeFlagCompressed != 0 {
+		var err error
+		if payload, err = decompressPayload(payload); err != nil {
+			return 0, nil, err
+		}
+	}
+	return Codec(val[1] >> envelopeCodecShift), payload, nil
+}
+
+// appendBinaryLog appends l in the CodecBinary layout to dst: the index and
+// term as 8 big-endian bytes, the type as one byte, AppendedAt as Unix
+// nanoseconds in 8 big-endian bytes, zero if unset, and then the data and
+// extensions, each prefixed with its length as a uvarint.
+func appendBinaryLog(dst []byte, l *raft.Log) []byte {
+	dst = binary.BigEndian.AppendUint64(dst, l.Index)
+	dst = binary.BigEndian.AppendUint64(dst, l.Term)
+	dst = append(dst, byte(l.Type))
+
+	var appendedAt int64
+	if !l.AppendedAt.IsZero() {
+		appendedAt = l.AppendedAt.UnixNano()
+	}
+	dst = binary.BigEndian.AppendUint64(dst, uint64(appendedAt))
+
+	dst = binary.AppendUvarint(dst, uint64(len(l.Data)))
+	dst = append(dst, l.Data...)
+	dst = binary.AppendUvarint(dst, uint64(len(l.Extensions)))
+	return append(dst, l.Extensions...)
+}
+
+// decodeBinaryLog decodes a log in the CodecBinary layout.
+func decodeBinaryLog(buf []byte, l *raft.Log) error {
+	const fixedSize = 8 + 8 + 1 + 8
+	if len(buf) < fixedSize {
+		return errBinaryLogTruncated
+	}
+
+	l.Index = binary.BigEndian.Uint64(buf)
+	l.Term = binary.BigEndian.Uint64(buf[8:])
+	l.Type = raft.LogType(buf[16])
+	l.AppendedAt = time.Time{}
+	if ns := int64(binary.BigEndian.Uint64(buf[17:])); ns != 0 {
+		l.AppendedAt = time.Unix(0, ns)
+	}
+	buf = buf[fixedSize:]
+
+	var err error
+	if l.Data, buf, err = readBinaryBytes(buf); err != nil {
+		return err
+	}
+	if l.Extensions, buf, err = readBinaryBytes(buf); err != nil {
+		return err
+	}
+	if len(buf) != 0 {
+		return fmt.Errorf("%d trailing bytes after binary log", len(buf))
+	}
+	return nil
+}
+
+// readBinaryBytes reads a uvarint length prefixed byte slice from buf and
+// returns it, or nil if it's empty, and the rest of buf.
+func readBinaryBytes(buf []byte) (b, rest []byte, err error) {
+	n, size := binary.Uvarint(buf)
+	if size <= 0 || uint64(len(buf)-size) < n {
+		return nil, nil, errBinaryLogTruncated
+	}
+	buf = buf[size:]
+	if n == 0 {
+		return nil, buf, nil
+	}
+	return append([]byte(nil), buf[:n]...), buf[n:], nil
+}
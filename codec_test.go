@@ -0,0 +1,117 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryLog(t *testing.T) {
+	for _, l := range []*raft.Log{
+		{Index: 1, Term: 2, Type: raft.LogCommand, Data: []byte("data"), Extensions: []byte("ext"), AppendedAt: time.Unix(0, 12345)},
+		{Index: 1 << 40, Term: 7, Type: raft.LogConfiguration},
+	} {
+		var got raft.Log
+		require.NoError(t, decodeBinaryLog(appendBinaryLog(nil, l), &got))
+		assert.Equal(t, l.Index, got.Index)
+		assert.Equal(t, l.Term, got.Term)
+		assert.Equal(t, l.Type, got.Type)
+		assert.Equal(t, l.Data, got.Data)
+		assert.Equal(t, l.Extensions, got.Extensions)
+		assert.True(t, l.AppendedAt.Equal(got.AppendedAt))
+	}
+
+	buf := appendBinaryLog(nil, &raft.Log{Index: 1, Data: []byte("data")})
+	assert.Error(t, decodeBinaryLog(buf[:len(buf)-2], new(raft.Log)))
+}
+
+func TestMsgPack_SharedHandle(t *testing.T) {
+	// Decoders are shared, so decoded logs must not alias each other's input
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				in := &raft.Log{Index: uint64(j), Data: []byte(fmt.Sprintf("log%d", j)), AppendedAt: time.Unix(int64(j), 0)}
+				buf, err := EncodeMsgPack(in, j%2 == 0)
+				if !assert.NoError(t, err) {
+					return
+				}
+				payload := buf.Bytes()
+
+				var out raft.Log
+				if !assert.NoError(t, DecodeMsgPack(payload, &out)) {
+					return
+				}
+				clear(payload)
+				assert.Equal(t, in.Index, out.Index)
+				assert.Equal(t, in.Data, out.Data)
+				assert.True(t, in.AppendedAt.Equal(out.AppendedAt))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBadgerStore_Codec_RollingUpgrade(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	require.NoError(t, store.Close())
+
+	// Reopen with the binary codec and checksums; old logs stay readable
+	db, err := badger.Open(badger.DefaultOptions(store.path).WithLogger(nil))
+	require.NoError(t, err)
+	store, err = New(db, Options{Codec: CodecBinary, Checksums: true})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.StoreLog(testRaftLog(3, "log3")))
+
+	for i, data := range []string{"log1", "log2", "log3"} {
+		var l raft.Log
+		require.NoError(t, store.GetLog(uint64(i+1), &l))
+		assert.Equal(t, data, string(l.Data))
+	}
+
+	err = store.db.View(func(txn *badger.Txn) error {
+		for idx, want := range map[uint64]Codec{1: CodecMsgpack, 3: CodecBinary} {
+			item, err := txn.Get(logKey(idx))
+			require.NoError(t, err)
+			val, err := item.ValueCopy(nil)
+			require.NoError(t, err)
+
+			codec, _, err := openEnvelope(val)
+			require.NoError(t, err)
+			assert.Equal(t, want, codec)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestNew_UnknownCodec(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = New(db, Options{Codec: numCodecs})
+	assert.Error(t, err)
+}
@@ -0,0 +1,386 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How many logs are moved to the cold tier together, as one object
+	coldSegmentSize = 1000
+
+	// How often logs are moved to the cold tier if no interval is
+	// configured
+	defaultColdTierInterval = time.Minute
+)
+
+var (
+	// Bucket the index of the cold tier is stored in. Each segment moved
+	// to the cold tier is keyed by its last index, and holds its first
+	// index and the name of its object.
+	dbCold = []byte("cold")
+)
+
+func coldKey(last uint64) []byte {
+	return prefixedKey(dbCold, uint64ToBytes(last))
+}
+
+// coldSegment is a range of logs stored as one object in the cold tier.
+type coldSegment struct {
+	first, last uint64
+	name        string
+}
+
+func (s coldSegment) value() []byte {
+	return append(uint64ToBytes(s.first), s.name...)
+}
+
+func parseColdSegment(key, val []byte) (coldSegment, error) {
+	if len(key) != len(dbCold)+8 || len(val) < 8 {
+		return coldSegment{}, fmt.Errorf("%w: cold tier segment %x", ErrCorrupt, key)
+	}
+	return coldSegment{
+		first: bytesToUint64(val[:8]),
+		last:  bytesToUint64(key[len(dbCold):]),
+		name:  string(val[8:]),
+	}, nil
+}
+
+// coldTier moves old logs to a Sink, see Options.ColdTier.
+type coldTier struct {
+	sink     Sink
+	age      time.Duration
+	interval time.Duration
+
+	// The logs of the segment read last, by index, since raft reads logs
+	// sequentially when it replicates them
+	mu     sync.Mutex
+	cached coldSegment
+	logs   map[uint64][]byte
+}
+
+func newColdTier(sink Sink, age, interval time.Duration) *coldTier {
+	if sink == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = defaultColdTierInterval
+	}
+	return &coldTier{sink: sink, age: age, interval: interval}
+}
+
+// runColdTier moves logs to the cold tier on every tick until the store is
+// closed.
+func (b *BadgerRaftStore) runColdTier(shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(b.cold.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			if _, err := b.MoveToColdTier(); err != nil {
+				log.Error().Err(err).Msg("Failed to move logs to the cold tier")
+			}
+		}
+	}
+}
+
+// MoveToColdTier moves the logs appended longer than ColdTierAge ago from
+// Badger to the cold tier, in compressed segments of up to 1000 logs, and
+// returns how many it moved. The newest log always stays in Badger. It is
+// called periodically in the background when a cold tier is configured,
+// but can also be invoked directly.
+func (b *BadgerRaftStore) MoveToColdTier() (moved int, err error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	if b.cold == nil {
+		return 0, nil
+	}
+	if err := b.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	max, err := b.lastAppendedBefore(time.Now().Add(-b.cold.age))
+	if err != nil || max == 0 {
+		return 0, err
+	}
+	last, err := b.LastIndex()
+	if err != nil {
+		return 0, err
+	}
+	max = min(max, last-1)
+
+	for {
+		n, err := b.moveColdSegment(max)
+		if err != nil {
+			return moved, err
+		}
+		if n == 0 {
+			break
+		}
+		moved += n
+	}
+	if moved > 0 {
+		log.Info().Int("logs", moved).Uint64("max", max).Msg("Moved logs to the cold tier")
+	}
+	return moved, nil
+}
+
+// moveColdSegment moves up to coldSegmentSize of the oldest logs in Badger
+// with an index of at most max to the cold tier. The logs are read and
+// deleted in the same transaction, so a concurrent DeleteRange makes it
+// fail with a conflict instead of resurrecting them in the cold tier.
+func (b *BadgerRaftStore) moveColdSegment(max uint64) (int, error) {
+	txn := b.newTransaction(b.db, true)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	var keys [][]byte
+	var object bytes.Buffer
+	var size [4]byte
+	for it.Seek(dbLogs); it.ValidForPrefix(dbLogs) && len(keys) < coldSegmentSize; it.Next() {
+		item := it.Item()
+		idx := logIndex(item.Key())
+		if idx > max {
+			break
+		}
+		if len(keys) > 0 && idx != logIndex(keys[len(keys)-1])+1 {
+			break
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			it.Close()
+			return 0, storageError(err)
+		}
+		binary.BigEndian.PutUint32(size[:], uint32(len(val)))
+		object.Write(size[:])
+		object.Write(val)
+		keys = append(keys, item.KeyCopy(nil))
+	}
+	it.Close()
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	segment := coldSegment{first: logIndex(keys[0]), last: logIndex(keys[len(keys)-1])}
+	segment.name = fmt.Sprintf("cold/%020d-%020d", segment.first, segment.last)
+	compressed := chunkEncoder().EncodeAll(object.Bytes(), nil)
+	if err := b.cold.sink.Put(context.Background(), segment.name, bytes.NewReader(compressed)); err != nil {
+		return 0, fmt.Errorf("storing cold tier segment %s: %w", segment.name, err)
+	}
+
+	for _, key := range keys {
+		if _, err := txn.Get(key); err != nil {
+			return 0, storageError(err)
+		}
+		if err := txn.Delete(key); err != nil {
+			return 0, storageError(err)
+		}
+	}
+	if err := txn.Set(coldKey(segment.last), segment.value()); err != nil {
+		return 0, storageError(err)
+	}
+	if err := b.commit(txn); err != nil {
+		return 0, b.writeError(err)
+	}
+	return len(keys), nil
+}
+
+// coldFirstIndex returns the first index of the logs in the cold tier, or 0
+// if it holds none.
+func coldFirstIndex(txn *badger.Txn) (uint64, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	it.Seek(dbCold)
+	if !it.ValidForPrefix(dbCold) {
+		return 0, nil
+	}
+	val, err := it.Item().ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	segment, err := parseColdSegment(it.Item().Key(), val)
+	return segment.first, err
+}
+
+// findColdSegment returns the segment of the cold tier holding idx, if any.
+func findColdSegment(txn *badger.Txn, idx uint64) (coldSegment, bool, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	it.Seek(coldKey(idx))
+	if !it.ValidForPrefix(dbCold) {
+		return coldSegment{}, false, nil
+	}
+	val, err := it.Item().ValueCopy(nil)
+	if err != nil {
+		return coldSegment{}, false, err
+	}
+	segment, err := parseColdSegment(it.Item().Key(), val)
+	if err != nil || idx < segment.first {
+		return coldSegment{}, false, err
+	}
+	return segment, true, nil
+}
+
+// readColdLog reads the log at idx from the cold tier, failing with
+// raft.ErrLogNotFound if it isn't there.
+func (b *BadgerRaftStore) readColdLog(txn *badger.Txn, idx uint64, raftLog *raft.Log) error {
+	segment, ok, err := findColdSegment(txn, idx)
+	if err != nil {
+		return storageError(err)
+	}
+	if !ok {
+		return raft.ErrLogNotFound
+	}
+
+	val, err := b.cold.segmentLog(segment, idx)
+	if err != nil {
+		return err
+	}
+	if err := decodeLog(val, raftLog); err != nil {
+		return fmt.Errorf("%w: log %d: %w", ErrCorrupt, idx, err)
+	}
+	return nil
+}
+
+// segmentLog returns the stored value of the log at idx in segment,
+// fetching the segment unless it is cached.
+func (c *coldTier) segmentLog(segment coldSegment, idx uint64) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached.name != segment.name {
+		logs, err := c.fetch(segment.name)
+		if err != nil {
+			return nil, err
+		}
+		c.cached, c.logs = segment, logs
+	}
+	val, ok := c.logs[idx]
+	if !ok {
+		return nil, fmt.Errorf("%w: log %d missing from cold tier segment %s", ErrCorrupt, idx, segment.name)
+	}
+	return val, nil
+}
+
+// fetch reads the segment object with the given name.
+func (c *coldTier) fetch(name string) (map[uint64][]byte, error) {
+	r, err := c.sink.Get(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("reading cold tier segment %s: %w", name, err)
+	}
+	defer r.Close()
+
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading cold tier segment %s: %w", name, err)
+	}
+	object, err := chunkDecoder().DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cold tier segment %s: %w", ErrCorrupt, name, err)
+	}
+
+	logs := make(map[uint64][]byte)
+	for len(object) > 0 {
+		if len(object) < 4 {
+			return nil, fmt.Errorf("%w: cold tier segment %s is truncated", ErrCorrupt, name)
+		}
+		n := binary.BigEndian.Uint32(object)
+		if uint32(len(object)-4) < n {
+			return nil, fmt.Errorf("%w: cold tier segment %s is truncated", ErrCorrupt, name)
+		}
+		val := object[4 : 4+n]
+		object = object[4+n:]
+
+		var l raft.Log
+		if err := decodeLog(val, &l); err != nil {
+			return nil, fmt.Errorf("%w: cold tier segment %s: %w", ErrCorrupt, name, err)
+		}
+		logs[l.Index] = val
+	}
+	return logs, nil
+}
+
+// trimColdTier drops the logs between min and max inclusively from the cold
+// tier index. Segments that are left empty are removed; their objects stay
+// in the sink, which has no way to delete them, for its own lifecycle rules
+// to expire. The log metadata and count are updated in the same transaction.
+func (b *BadgerRaftStore) trimColdTier(min, max uint64) error {
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+
+	err := b.update(b.db, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		var removed uint64
+
+		for it.Seek(coldKey(min)); it.ValidForPrefix(dbCold); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			segment, err := parseColdSegment(it.Item().Key(), val)
+			if err != nil {
+				return err
+			}
+			if segment.first > max {
+				break
+			}
+
+			switch {
+			case segment.first >= min && segment.last <= max:
+				removed += segment.last - segment.first + 1
+				err = txn.Delete(it.Item().KeyCopy(nil))
+			case segment.first >= min:
+				// Raft compacts the log from the front, so only the
+				// head of a segment is ever removed
+				removed += max + 1 - segment.first
+				segment.first = max + 1
+				err = txn.Set(it.Item().KeyCopy(nil), segment.value())
+			default:
+				err = fmt.Errorf("deleting logs %d to %d from the middle of cold tier segment %s is not supported", min, max, segment.name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if removed == 0 {
+			return nil
+		}
+
+		meta, err := scanLogMeta(txn)
+		if err != nil {
+			return err
+		}
+		if err := writeLogMeta(txn, meta); err != nil {
+			return err
+		}
+		return adjustLogCount(txn, 0, removed)
+	})
+	if errors.Is(err, ErrCorrupt) {
+		return err
+	}
+	if err != nil {
+		return b.writeError(err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testColdTierStore(t *testing.T) (*BadgerRaftStore, *FileSink) {
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+
+	store := testBadgerStoreWithOptions(t, Options{
+		ColdTier:         sink,
+		ColdTierAge:      time.Minute,
+		ColdTierInterval: time.Hour,
+	})
+	return store, sink
+}
+
+func storeAgedLogs(t *testing.T, store *BadgerRaftStore, n int, appendedAt time.Time) {
+	var logs []*raft.Log
+	for i := 1; i <= n; i++ {
+		log := testRaftLog(uint64(i), fmt.Sprintf("log%d", i))
+		log.AppendedAt = appendedAt
+		logs = append(logs, log)
+	}
+	require.NoError(t, store.StoreLogs(logs))
+}
+
+func TestBadgerStore_MoveToColdTier(t *testing.T) {
+	store, sink := testColdTierStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeAgedLogs(t, store, 2500, time.Now().Add(-time.Hour))
+	recent := testRaftLog(2501, "log2501")
+	recent.AppendedAt = time.Now()
+	require.NoError(t, store.StoreLog(recent))
+
+	moved, err := store.MoveToColdTier()
+	require.NoError(t, err)
+	assert.Equal(t, 2500, moved)
+
+	objects, err := sink.List(context.Background(), "cold/")
+	require.NoError(t, err)
+	assert.Len(t, objects, 3)
+	assert.Equal(t, 1, countKeys(t, store, dbLogs))
+
+	// The logs are still readable through the cold tier
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2501), last)
+
+	for _, idx := range []uint64{1, 999, 1000, 1001, 2500, 2501} {
+		var log raft.Log
+		require.NoError(t, store.GetLog(idx, &log))
+		assert.Equal(t, idx, log.Index)
+		assert.Equal(t, fmt.Sprintf("log%d", idx), string(log.Data))
+	}
+
+	var log raft.Log
+	assert.ErrorIs(t, store.GetLog(2502, &log), raft.ErrLogNotFound)
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report)
+
+	// Nothing is left to move
+	moved, err = store.MoveToColdTier()
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+}
+
+func TestBadgerStore_MoveToColdTier_KeepsLastLog(t *testing.T) {
+	store, _ := testColdTierStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeAgedLogs(t, store, 10, time.Now().Add(-time.Hour))
+
+	moved, err := store.MoveToColdTier()
+	require.NoError(t, err)
+	assert.Equal(t, 9, moved)
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), last)
+	assert.Equal(t, 1, countKeys(t, store, dbLogs))
+}
+
+func TestBadgerStore_MoveToColdTier_Disabled(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeAgedLogs(t, store, 10, time.Now().Add(-time.Hour))
+
+	moved, err := store.MoveToColdTier()
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+	assert.Equal(t, 10, countKeys(t, store, dbLogs))
+}
+
+func TestBadgerStore_DeleteRange_ColdTier(t *testing.T) {
+	store, _ := testColdTierStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeAgedLogs(t, store, 2500, time.Now().Add(-time.Hour))
+	_, err := store.MoveToColdTier()
+	require.NoError(t, err)
+	requireLogCount(t, store, 2500)
+
+	// Drops the first segment and the head of the second
+	require.NoError(t, store.DeleteRange(1, 1500))
+	requireLogCount(t, store, 1000)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1501), first)
+	assert.Equal(t, 2, countKeys(t, store, dbCold))
+
+	var log raft.Log
+	assert.ErrorIs(t, store.GetLog(1000, &log), raft.ErrLogNotFound)
+	assert.ErrorIs(t, store.GetLog(1500, &log), raft.ErrLogNotFound)
+	require.NoError(t, store.GetLog(1501, &log))
+	assert.Equal(t, "log1501", string(log.Data))
+
+	// Compacting past the cold tier empties it
+	require.NoError(t, store.DeleteRange(1501, 2499))
+	first, err = store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2500), first)
+	assert.Zero(t, countKeys(t, store, dbCold))
+	requireLogCount(t, store, 1)
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report)
+
+	// The metadata and count were updated along with the cold tier
+	repair, err := store.Repair()
+	require.NoError(t, err)
+	assert.False(t, repair.Changed(), repair)
+}
+
+func TestBadgerStore_ColdTier_Reopen(t *testing.T) {
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	options := Options{ColdTier: sink, ColdTierAge: time.Minute, ColdTierInterval: time.Hour}
+
+	store, err := Open(dir, options)
+	require.NoError(t, err)
+	storeAgedLogs(t, store, 20, time.Now().Add(-time.Hour))
+	_, err = store.MoveToColdTier()
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = Open(dir, options)
+	require.NoError(t, err)
+	defer store.Close()
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first)
+
+	var log raft.Log
+	require.NoError(t, store.GetLog(5, &log))
+	assert.Equal(t, "log5", string(log.Data))
+}
@@ -0,0 +1,56 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// Logs whose encoded size is at most this many bytes are stored
+	// uncompressed unless Options.CompressMinSize says otherwise
+	defaultCompressMinSize = 256
+)
+
+var (
+	// Logs are compressed on the append path, so speed matters more than
+	// ratio. The encoder is safe for concurrent EncodeAll calls.
+	logZstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, _ := newLogZstdEncoder()
+		return enc
+	})
+)
+
+// newLogZstdEncoder returns an encoder for logs with the given options,
+// such as a dictionary.
+func newLogZstdEncoder(opts ...zstd.EOption) (*zstd.Encoder, error) {
+	opts = append([]zstd.EOption{zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest)}, opts...)
+	return zstd.NewWriter(nil, opts...)
+}
+
+// compressPayload returns payload compressed with enc, or the default
+// encoder if it is nil, and true. It returns payload and false if it is no
+// larger than minSize or doesn't get smaller.
+func compressPayload(payload []byte, minSize int, enc *zstd.Encoder) ([]byte, bool) {
+	if len(payload) <= minSize {
+		return payload, false
+	}
+	if enc == nil {
+		enc = logZstdEncoder()
+	}
+	compressed := enc.EncodeAll(payload, nil)
+	if len(compressed) >= len(payload) {
+		return payload, false
+	}
+	return compressed, true
+}
+
+// decompressPayload returns the payload of a compressed envelope.
+func decompressPayload(compressed []byte) ([]byte, error) {
+	payload, err := logZstdDecoder().DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,106 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storedValue(t *testing.T, store *BadgerRaftStore, idx uint64) []byte {
+	var val []byte
+	err := store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(logKey(idx))
+		if err != nil {
+			return err
+		}
+		val, err = item.ValueCopy(nil)
+		return err
+	})
+	require.NoError(t, err)
+	return val
+}
+
+func TestBadgerStore_CompressLogs(t *testing.T) {
+	random := make([]byte, 4096)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+
+	for _, opts := range []Options{
+		{CompressLogs: true},
+		{CompressLogs: true, CompressMinSize: 1000},
+		{CompressLogs: true, Checksums: true, IdempotentAppends: true, Codec: CodecBinary},
+	} {
+		store := testBadgerStoreWithOptions(t, opts)
+		defer store.Close()
+		defer os.Remove(store.path)
+
+		logs := []*raft.Log{
+			testRaftLog(1, "small"),
+			{Index: 2, Data: bytes.Repeat([]byte("command "), 64)},
+			{Index: 3, Data: bytes.Repeat([]byte("command "), 512)},
+			{Index: 4, Data: random},
+		}
+		require.NoError(t, store.StoreLogs(logs))
+
+		// Only logs beyond the minimum size that shrink are compressed
+		minSize := max(opts.CompressMinSize, defaultCompressMinSize)
+		for _, l := range logs {
+			val := storedValue(t, store, l.Index)
+			want := l.Index == 3 || (l.Index == 2 && minSize < len(l.Data))
+			assert.Equal(t, want, storedEncoding(val).compress, "log %d", l.Index)
+			if want {
+				assert.Less(t, len(val), len(l.Data))
+			}
+
+			var got raft.Log
+			require.NoError(t, store.GetLog(l.Index, &got))
+			assert.Equal(t, l.Data, got.Data)
+		}
+
+		report, err := store.VerifyConsistency()
+		require.NoError(t, err)
+		assert.True(t, report.OK())
+	}
+}
+
+func TestBadgerStore_CompressLogs_Mixed(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	data := bytes.Repeat([]byte("command "), 512)
+	require.NoError(t, store.StoreLog(&raft.Log{Index: 1, Data: data}))
+	store.compressLogs = true
+	require.NoError(t, store.StoreLog(&raft.Log{Index: 2, Data: data}))
+
+	assert.False(t, storedEncoding(storedValue(t, store, 1)).compress)
+	assert.True(t, storedEncoding(storedValue(t, store, 2)).compress)
+
+	logs, err := store.GetLogs(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, data, logs[0].Data)
+	assert.Equal(t, data, logs[1].Data)
+}
+
+func TestBadgerStore_CompressLogs_Corrupt(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{CompressLogs: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(&raft.Log{Index: 1, Data: bytes.Repeat([]byte("command "), 512)}))
+	val := storedValue(t, store, 1)
+	val[len(val)-1] ^= 0xff
+	err := store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(logKey(1), val)
+	})
+	require.NoError(t, err)
+
+	var l raft.Log
+	assert.ErrorIs(t, store.GetLog(1, &l), ErrCorrupt)
+}
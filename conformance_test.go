@@ -0,0 +1,36 @@
+package raftbadgerstore
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/kgantsov/raft-badgerstore/raftstoretest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_Conformance(t *testing.T) {
+	raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+		opts := badger.DefaultOptions(dir).WithLogger(nil)
+		store, err := Open(dir, Options{BadgerOptions: &opts})
+		require.NoError(t, err)
+		return store
+	})
+}
+
+func TestBadgerStore_Conformance_Checksums(t *testing.T) {
+	raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+		opts := badger.DefaultOptions(dir).WithLogger(nil)
+		store, err := Open(dir, Options{BadgerOptions: &opts, Checksums: true})
+		require.NoError(t, err)
+		return store
+	})
+}
+
+func TestBadgerStore_Conformance_KeepVersions(t *testing.T) {
+	raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+		opts := badger.DefaultOptions(dir).WithLogger(nil)
+		store, err := Open(dir, Options{BadgerOptions: &opts, KeepVersions: 3})
+		require.NoError(t, err)
+		return store
+	})
+}
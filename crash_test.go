@@ -0,0 +1,207 @@
+//go:build crash
+
+package raftbadgerstore
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The crash harness repeatedly runs a raft-like workload in a child process,
+// kills it at a random point, optionally simulates losing the tail of the
+// newest file as on power loss, then reopens the store and checks its
+// invariants. Run it with:
+//
+//	go test -tags crash -run TestCrashConsistency -v
+//
+// CRASH_ITERATIONS sets the number of crashes, 20 by default.
+
+const (
+	// Environment variable telling the test binary to run the workload in
+	// the directory it names
+	crashWorkerEnv = "RAFT_BADGERSTORE_CRASH_DIR"
+
+	// Stable key the workload writes the last acknowledged index to
+	crashStableKey = "CurrentTerm"
+
+	// How many logs the workload keeps before compacting
+	crashKeepLogs = 1000
+)
+
+func openCrashStore(t testing.TB, dir string) *BadgerRaftStore {
+	opts := badger.DefaultOptions(dir).WithLogger(nil).WithSyncWrites(true)
+	store, err := Open(dir, Options{BadgerOptions: &opts, Checksums: true})
+	require.NoError(t, err)
+	return store
+}
+
+// TestCrashWorker is the workload run by the child process. It appends
+// batches of logs and records the last index in the stable store, printing
+// "ack <index>" once both are durable.
+func TestCrashWorker(t *testing.T) {
+	dir := os.Getenv(crashWorkerEnv)
+	if dir == "" {
+		t.Skip("only run as a child of TestCrashConsistency")
+	}
+
+	store := openCrashStore(t, dir)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+
+	for {
+		batch := make([]*raft.Log, 1+rand.IntN(64))
+		for i := range batch {
+			last++
+			batch[i] = &raft.Log{
+				Index: last,
+				Term:  1,
+				Type:  raft.LogCommand,
+				Data:  []byte(strings.Repeat(strconv.FormatUint(last, 10), 1+rand.IntN(100))),
+			}
+		}
+		require.NoError(t, store.StoreLogs(batch))
+		require.NoError(t, store.SetUint64([]byte(crashStableKey), last))
+		fmt.Printf("ack %d\n", last)
+
+		if first, _ := store.FirstIndex(); last-first > crashKeepLogs {
+			require.NoError(t, store.DeleteRange(first, last-crashKeepLogs/2))
+		}
+	}
+}
+
+func TestCrashConsistency(t *testing.T) {
+	iterations := 20
+	if v := os.Getenv("CRASH_ITERATIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		require.NoError(t, err)
+		iterations = n
+	}
+
+	dir := t.TempDir()
+	for i := range iterations {
+		acked := runCrashWorker(t, dir, time.Duration(50+rand.IntN(500))*time.Millisecond)
+
+		truncated := rand.IntN(2) == 0
+		if truncated {
+			truncateNewestFile(t, dir)
+		}
+
+		t.Logf("iteration %d: acked %d, truncated %v", i, acked, truncated)
+		checkCrashInvariants(t, dir, acked, !truncated)
+	}
+}
+
+// runCrashWorker runs the workload in dir for d, kills it and returns the
+// last index it acknowledged.
+func runCrashWorker(t *testing.T, dir string, d time.Duration) uint64 {
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestCrashWorker$")
+	cmd.Env = append(os.Environ(), crashWorkerEnv+"="+dir)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	acks := make(chan uint64)
+	go func() {
+		defer close(acks)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if idx, ok := strings.CutPrefix(scanner.Text(), "ack "); ok {
+				n, _ := strconv.ParseUint(idx, 10, 64)
+				acks <- n
+			}
+		}
+	}()
+
+	var acked uint64
+	kill := time.After(d)
+	for {
+		select {
+		case n, ok := <-acks:
+			if !ok {
+				cmd.Wait()
+				return acked
+			}
+			acked = n
+		case <-kill:
+			// Simulate a crash; nothing gets a chance to clean up
+			require.NoError(t, cmd.Process.Kill())
+			kill = nil
+		}
+	}
+}
+
+// truncateNewestFile cuts a random number of bytes off the newest write-ahead
+// or value log file, like a power loss dropping writes the disk had not
+// persisted yet.
+func truncateNewestFile(t *testing.T, dir string) {
+	var files []string
+	for _, pattern := range []string{"*.mem", "*.vlog"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		require.NoError(t, err)
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	newest := slices.MaxFunc(files, func(a, b string) int {
+		ai, _ := os.Stat(a)
+		bi, _ := os.Stat(b)
+		return ai.ModTime().Compare(bi.ModTime())
+	})
+
+	info, err := os.Stat(newest)
+	require.NoError(t, err)
+	cut := min(info.Size(), int64(1+rand.IntN(4096)))
+	require.NoError(t, os.Truncate(newest, info.Size()-cut))
+}
+
+// checkCrashInvariants reopens the store in dir and checks that the log has
+// no gaps, every log and the stable key are readable and, if durable is
+// set, that nothing acknowledged was lost.
+func checkCrashInvariants(t *testing.T, dir string, acked uint64, durable bool) {
+	store := openCrashStore(t, dir)
+	defer store.Close()
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	require.True(t, report.OK(), "%+v", report)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, last-first+1, report.Entries)
+
+	for idx := first; idx <= last && idx > 0; idx++ {
+		var log raft.Log
+		require.NoError(t, store.GetLog(idx, &log), "log %d", idx)
+		require.Equal(t, idx, log.Index)
+	}
+
+	stable, err := store.GetUint64([]byte(crashStableKey))
+	if err != nil {
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+	// The stable key is written after the logs it points to
+	assert.LessOrEqual(t, stable, last)
+
+	if durable {
+		assert.GreaterOrEqual(t, last, acked, "acknowledged logs lost")
+		assert.GreaterOrEqual(t, stable, acked, "acknowledged stable key lost")
+	}
+}
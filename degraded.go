@@ -0,0 +1,100 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How often a degraded store checks whether disk space was recovered
+	// if no interval is configured.
+	defaultDiskFullProbeInterval = 10 * time.Second
+)
+
+var (
+	// Key written to check whether the disk accepts writes again
+	metaProbe = []byte("probe")
+)
+
+// Degraded reports whether the store switched to read-only mode after
+// running out of disk space.
+func (b *BadgerRaftStore) Degraded() bool {
+	return b.degraded.Load()
+}
+
+// checkWritable returns an error if writes are currently rejected because
+// the store is degraded.
+func (b *BadgerRaftStore) checkWritable() error {
+	if b.degraded.Load() {
+		return fmt.Errorf("%w: %w", ErrReadOnly, ErrDiskFull)
+	}
+	return nil
+}
+
+// writeError wraps an error returned by a Badger write like storageError,
+// counts conflicts with other writers, and switches the store into degraded
+// mode if the disk is full and DegradeOnDiskFull is set.
+func (b *BadgerRaftStore) writeError(err error) error {
+	if errors.Is(err, badger.ErrConflict) {
+		b.stats.conflicts.Add(1)
+		metrics.IncrCounter(metricConflicts, 1)
+	}
+
+	err = storageError(err)
+	if b.degradeOnDiskFull && errors.Is(err, ErrDiskFull) && b.degraded.CompareAndSwap(false, true) {
+		log.Error().Err(err).Msg("Disk full, switching store to read-only mode")
+	}
+	return err
+}
+
+// runDiskFullProbe periodically checks whether a degraded store can write
+// again and leaves degraded mode once it can.
+func (b *BadgerRaftStore) runDiskFullProbe(shutdownCh <-chan struct{}) {
+	interval := b.diskFullProbeInterval
+	if interval <= 0 {
+		interval = defaultDiskFullProbeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			if b.degraded.Load() {
+				b.probeDiskSpace()
+			}
+		}
+	}
+}
+
+// probeDiskSpace tries a small durable write and clears degraded mode if it
+// succeeds.
+func (b *BadgerRaftStore) probeDiskSpace() {
+	if err := b.enter(); err != nil {
+		return
+	}
+	defer b.exit()
+
+	err := b.update(b.db, func(txn *badger.Txn) error {
+		return txn.Set(prefixedKey(dbMeta, metaProbe), uint64ToBytes(uint64(time.Now().UnixNano())))
+	})
+	if err == nil {
+		err = b.db.Sync()
+	}
+	if err != nil {
+		log.Debug().Err(err).Msg("Disk still not writable")
+		return
+	}
+
+	if b.degraded.CompareAndSwap(true, false) {
+		log.Info().Msg("Disk space recovered, leaving read-only mode")
+	}
+}
@@ -0,0 +1,58 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageError_DiskFull(t *testing.T) {
+	err := storageError(&os.PathError{Op: "write", Path: "000001.vlog", Err: syscall.ENOSPC})
+	assert.ErrorIs(t, err, ErrDiskFull)
+	assert.ErrorIs(t, err, ErrRetryable)
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+}
+
+func TestBadgerStore_DegradeOnDiskFull(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{DegradeOnDiskFull: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	assert.False(t, store.Degraded())
+
+	// Simulate a commit failing because the disk is full
+	err := store.writeError(syscall.ENOSPC)
+	assert.ErrorIs(t, err, ErrDiskFull)
+	assert.True(t, store.Degraded())
+
+	// Writes fail fast while degraded, reads and truncation keep working
+	err = store.StoreLog(testRaftLog(3, "log3"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, ErrDiskFull)
+
+	err = store.Set([]byte("key"), []byte("val"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	require.NoError(t, store.GetLog(1, new(raft.Log)))
+	require.NoError(t, store.DeleteRange(1, 1))
+
+	// The store recovers once a probe write succeeds
+	store.probeDiskSpace()
+	assert.False(t, store.Degraded())
+	require.NoError(t, store.StoreLog(testRaftLog(3, "log3")))
+}
+
+func TestBadgerStore_DiskFull_NotDegraded(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	err := store.writeError(syscall.ENOSPC)
+	assert.ErrorIs(t, err, ErrDiskFull)
+	assert.False(t, store.Degraded())
+}
@@ -0,0 +1,70 @@
+package raftbadgerstore
+
+import (
+	"time"
+)
+
+const (
+	// Number of logs deleted by the first transaction of DeleteRange
+	initialDeleteBatchSize = 100
+
+	// Upper bound of the DeleteRange batch size if MaxDeleteBatchSize is
+	// not set
+	defaultMaxDeleteBatchSize = 100_000
+
+	// How long committing a DeleteRange batch should take. Batches grow
+	// while they commit faster and shrink when they commit slower, so a
+	// large truncation neither takes thousands of tiny commits nor holds up
+	// concurrent appends behind one huge one.
+	deleteBatchTarget = 50 * time.Millisecond
+
+	// Keys written alongside the deletions of a batch: the first and last
+	// index and the log count
+	deleteBatchMetaKeys = 3
+)
+
+// deleteBatcher sizes the transactions DeleteRange deletes logs in.
+type deleteBatcher struct {
+	size  int
+	max   int
+	fixed bool
+}
+
+// newDeleteBatcher returns a batcher using batches of size logs if size is
+// positive, and otherwise growing batches up to max logs, or
+// defaultMaxDeleteBatchSize if max is not positive. Batches never exceed
+// what Badger accepts in a single transaction, less room for the metadata
+// written alongside the deletions.
+func newDeleteBatcher(size, max int, maxBatchCount int64) *deleteBatcher {
+	if size > 0 {
+		max = size
+	}
+	if max <= 0 {
+		max = defaultMaxDeleteBatchSize
+	}
+	if limit := int(maxBatchCount) - deleteBatchMetaKeys; limit > 0 && limit < max {
+		max = limit
+	}
+
+	if size > 0 {
+		return &deleteBatcher{size: max, max: max, fixed: true}
+	}
+	return &deleteBatcher{size: min(initialDeleteBatchSize, max), max: max}
+}
+
+// adjust resizes the next batch after a batch of count logs took elapsed to
+// delete and commit. tooBig is set if the batch was cut short because the
+// transaction reached Badger's size limit, which also shrinks fixed size
+// batches.
+func (d *deleteBatcher) adjust(count int, elapsed time.Duration, tooBig bool) {
+	switch {
+	case tooBig:
+		d.size = max(count, 1)
+		d.max = d.size
+	case d.fixed:
+	case elapsed > deleteBatchTarget:
+		d.size = max(d.size/2, 1)
+	case elapsed < deleteBatchTarget/2 && count == d.size:
+		d.size = min(d.size*2, d.max)
+	}
+}
@@ -0,0 +1,225 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteBatcher(t *testing.T) {
+	d := newDeleteBatcher(0, 0, 1000)
+	assert.Equal(t, initialDeleteBatchSize, d.size)
+	assert.Equal(t, 997, d.max)
+
+	// Fast batches grow up to the ceiling
+	for range 10 {
+		d.adjust(d.size, time.Millisecond, false)
+	}
+	assert.Equal(t, 997, d.size)
+
+	// Slow batches shrink
+	d.adjust(d.size, time.Second, false)
+	assert.Equal(t, 498, d.size)
+
+	// Short batches at the end of the range don't grow
+	d.adjust(10, time.Millisecond, false)
+	assert.Equal(t, 498, d.size)
+
+	// Hitting Badger's limit caps the batch size
+	d.adjust(300, time.Millisecond, true)
+	assert.Equal(t, 300, d.size)
+	d.adjust(300, time.Millisecond, false)
+	assert.Equal(t, 300, d.size)
+}
+
+func TestDeleteBatcher_Fixed(t *testing.T) {
+	d := newDeleteBatcher(50, 0, 1000)
+	d.adjust(50, time.Millisecond, false)
+	d.adjust(50, time.Second, false)
+	assert.Equal(t, 50, d.size)
+
+	// Batches still fit in a transaction
+	d = newDeleteBatcher(5000, 0, 1000)
+	assert.Equal(t, 997, d.size)
+}
+
+func TestBadgerStore_DeleteRange_Batches(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{MaxDeleteBatchSize: 7})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 1000; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	require.NoError(t, store.DeleteRange(1, 990))
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(991), first)
+	assert.Equal(t, uint64(990), store.Stats().Deletes)
+}
+
+func TestBadgerStore_DeleteCommitInterval(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		DeleteBatchSize:      10,
+		DeleteCommitInterval: 10 * time.Millisecond,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 50; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Five batches with a pause between each
+	start := time.Now()
+	require.NoError(t, store.DeleteRange(1, 50))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), last)
+}
+
+func TestBadgerStore_DeleteRangeWithProgress(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{DeleteBatchSize: 10})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 50; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	var deleted, remaining []uint64
+	err := store.DeleteRangeWithProgress(context.Background(), 1, 45, func(d, r uint64) {
+		deleted = append(deleted, d)
+		remaining = append(remaining, r)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{10, 20, 30, 40, 45}, deleted)
+	assert.Equal(t, []uint64{35, 25, 15, 5, 0}, remaining)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(46), first)
+}
+
+func TestBadgerStore_DeleteRangeWithProgress_Cancel(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{DeleteBatchSize: 10})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 50; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Cancel once the second batch is committed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := store.DeleteRangeWithProgress(ctx, 1, 50, func(deleted, _ uint64) {
+		if deleted >= 20 {
+			cancel()
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(21), first)
+}
+
+func TestBadgerStore_DeleteRange_ConcurrentAppends(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{DeleteBatchSize: 10})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 500)
+
+	// Appends to the tail never conflict with deleting the head, so neither
+	// needs a retry policy
+	done := make(chan error, 1)
+	go func() {
+		for i := uint64(501); i <= 3500; i += 200 {
+			var logs []*raft.Log
+			for j := i; j < i+200; j++ {
+				logs = append(logs, testRaftLog(j, "log"))
+			}
+			if err := store.StoreLogs(logs); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for appending := true; appending; {
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			appending = false
+		default:
+		}
+
+		first, err := store.FirstIndex()
+		require.NoError(t, err)
+		last, err := store.LastIndex()
+		require.NoError(t, err)
+		if last-first > 10 {
+			require.NoError(t, store.DeleteRange(first, last-10))
+		}
+	}
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3500), last)
+	requireLogCount(t, store, last-first+1)
+
+	report, err := store.Repair()
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+}
+
+func TestBadgerStore_DeleteRange_FailedCommit(t *testing.T) {
+	fp := NewFailpoints()
+	store := testBadgerStoreWithOptions(t, Options{Failpoints: fp, DeleteBatchSize: 4})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 10)
+
+	// The second batch fails, and the first stays deleted along with its
+	// share of the metadata and count
+	calls := 0
+	err := store.DeleteRangeWithProgress(context.Background(), 1, 8, func(deleted, remaining uint64) {
+		calls++
+		if calls == 1 {
+			fp.FailCommits(1, nil)
+		}
+	})
+	require.ErrorIs(t, err, ErrInjected)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), first)
+	requireLogCount(t, store, 6)
+
+	report, err := store.Repair()
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+}
@@ -0,0 +1,289 @@
+package raftbadgerstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Largest dictionary trained by TrainCompressionDictionary
+	maxDictionarySize = 64 << 10
+
+	// Minimum length of the repeated sequences a dictionary is built from
+	dictionaryHashBytes = 6
+)
+
+var (
+	// dbDict holds the compression dictionaries, keyed by their zstd ID.
+	// They are never deleted, as logs compressed with them may remain.
+	dbDict = []byte("dict")
+
+	// metaDictionary is the ID of the dictionary new logs are compressed
+	// with.
+	metaDictionary = []byte("compression_dictionary")
+
+	// An error indicating there are too few logs to train a dictionary
+	ErrTooFewSamples = errors.New("too few logs to train a dictionary")
+)
+
+// logDictionaries holds every dictionary loaded in the process, so the
+// logs of any store can be decompressed without knowing the store. Frames
+// record the ID of their dictionary, and IDs are random, so stores don't
+// clash.
+var logDictionaries struct {
+	mu    sync.Mutex
+	dicts map[uint32][]byte
+
+	// decoder decodes frames with and without the dictionaries.
+	decoder atomic.Pointer[zstd.Decoder]
+}
+
+// logZstdDecoder returns the decoder for compressed logs. It is safe for
+// concurrent DecodeAll calls.
+func logZstdDecoder() *zstd.Decoder {
+	if dec := logDictionaries.decoder.Load(); dec != nil {
+		return dec
+	}
+	// Can't fail without dictionaries
+	registerDictionaries()
+	return logDictionaries.decoder.Load()
+}
+
+// registerDictionaries adds dictionaries to those the decoder knows.
+func registerDictionaries(dicts ...[]byte) error {
+	logDictionaries.mu.Lock()
+	defer logDictionaries.mu.Unlock()
+
+	added := logDictionaries.decoder.Load() == nil
+	for _, d := range dicts {
+		info, err := zstd.InspectDictionary(d)
+		if err != nil {
+			return err
+		}
+		if _, ok := logDictionaries.dicts[info.ID()]; ok {
+			continue
+		}
+		if logDictionaries.dicts == nil {
+			logDictionaries.dicts = make(map[uint32][]byte)
+		}
+		logDictionaries.dicts[info.ID()] = d
+		added = true
+	}
+	if !added {
+		return nil
+	}
+
+	all := make([][]byte, 0, len(logDictionaries.dicts))
+	for _, d := range logDictionaries.dicts {
+		all = append(all, d)
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderDicts(all...))
+	if err != nil {
+		return err
+	}
+	logDictionaries.decoder.Store(dec)
+	return nil
+}
+
+// loadDictionaries registers the store's dictionaries and compresses new
+// logs with the active one, if any.
+func (b *BadgerRaftStore) loadDictionaries() error {
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	var dicts [][]byte
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	for it.Seek(dbDict); it.ValidForPrefix(dbDict); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			it.Close()
+			return storageError(err)
+		}
+		dicts = append(dicts, val)
+	}
+	it.Close()
+	if len(dicts) == 0 {
+		return nil
+	}
+	if err := registerDictionaries(dicts...); err != nil {
+		return fmt.Errorf("%w: compression dictionary: %w", ErrCorrupt, err)
+	}
+
+	item, err := txn.Get(prefixedKey(dbMeta, metaDictionary))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return storageError(err)
+	}
+	id, err := item.ValueCopy(nil)
+	if err != nil {
+		return storageError(err)
+	}
+	item, err = txn.Get(prefixedKey(dbDict, id))
+	if err != nil {
+		return readError(err, fmt.Errorf("%w: compression dictionary %x is missing", ErrCorrupt, id))
+	}
+	d, err := item.ValueCopy(nil)
+	if err != nil {
+		return storageError(err)
+	}
+	return b.useDictionary(d)
+}
+
+// useDictionary compresses new logs with the dictionary d.
+func (b *BadgerRaftStore) useDictionary(d []byte) error {
+	enc, err := newLogZstdEncoder(zstd.WithEncoderDict(d))
+	if err != nil {
+		return fmt.Errorf("%w: compression dictionary: %w", ErrCorrupt, err)
+	}
+	b.dictEncoder.Store(enc)
+	return nil
+}
+
+// portableLogValue returns a stored log value that decodes without the
+// store's dictionaries, for logs that leave the store in streams and
+// archives. Values compressed with a dictionary are recompressed without
+// it, keeping their codec, content hash and checksum; others are returned
+// as they are.
+func portableLogValue(val []byte) ([]byte, error) {
+	if len(val) < envelopeHeaderSize || val[0] != envelopeMagic || val[1]&envelopeFlagCompressed == 0 {
+		return val, nil
+	}
+	body := val[envelopeHeaderSize:]
+	if val[1]&envelopeFlagHash != 0 {
+		if len(body) < contentHashSize {
+			return nil, ErrInvalidEnvelope
+		}
+		body = body[contentHashSize:]
+	}
+	var header zstd.Header
+	if err := header.Decode(body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+	if header.DictionaryID == 0 {
+		return val, nil
+	}
+
+	_, payload, err := openEnvelope(val)
+	if err != nil {
+		return nil, err
+	}
+	payload, compressed := compressPayload(payload, 0, nil)
+
+	flags := val[1]
+	if !compressed {
+		flags &^= envelopeFlagCompressed
+	}
+	out := make([]byte, envelopeHeaderSize, envelopeHeaderSize+contentHashSize+len(payload))
+	out[0] = envelopeMagic
+	out[1] = flags
+	if flags&envelopeFlagHash != 0 {
+		out = append(out, val[envelopeHeaderSize:envelopeHeaderSize+contentHashSize]...)
+	}
+	out = append(out, payload...)
+	if flags&envelopeFlagChecksum != 0 {
+		binary.BigEndian.PutUint32(out[2:], crc32.Checksum(out[envelopeHeaderSize:], crcTable))
+	}
+	return out, nil
+}
+
+// TrainCompressionDictionary trains a zstd dictionary on up to samples of
+// the most recent logs and compresses logs stored from then on with it,
+// which greatly improves the compression of small, repetitive commands. It
+// has no effect unless CompressLogs is set. The dictionary is kept in the
+// store, along with those trained before, so every log stays readable. It
+// returns the ID of the dictionary, or ErrTooFewSamples if the logs don't
+// have enough content to train one.
+func (b *BadgerRaftStore) TrainCompressionDictionary(samples int) (id uint32, err error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	if err := b.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	input, err := b.dictionarySamples(samples)
+	if err != nil {
+		return 0, err
+	}
+	d, err := dict.BuildZstdDict(input, dict.Options{
+		MaxDictSize: maxDictionarySize,
+		HashBytes:   dictionaryHashBytes,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrTooFewSamples, err)
+	}
+	info, err := zstd.InspectDictionary(d)
+	if err != nil {
+		return 0, err
+	}
+	id = info.ID()
+
+	if err := registerDictionaries(d); err != nil {
+		return 0, err
+	}
+	idKey := binary.BigEndian.AppendUint32(nil, id)
+	err = b.update(b.db, func(txn *badger.Txn) error {
+		if err := txn.Set(prefixedKey(dbDict, idKey), d); err != nil {
+			return err
+		}
+		return txn.Set(prefixedKey(dbMeta, metaDictionary), idKey)
+	})
+	if err != nil {
+		return 0, b.writeError(err)
+	}
+	if err := b.useDictionary(d); err != nil {
+		return 0, err
+	}
+
+	log.Info().Uint32("id", id).Int("size", len(d)).Int("samples", len(input)).Msg("Trained a compression dictionary")
+	return id, nil
+}
+
+// dictionarySamples returns the payloads of up to n of the most recent logs
+// as they are compressed, without an envelope.
+func (b *BadgerRaftStore) dictionarySamples(n int) ([][]byte, error) {
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	opts.Prefix = dbLogs
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	enc := logEncoding{codec: b.codec, newTimeFormat: b.msgpackUseNewTimeFormat}
+	var samples [][]byte
+	for it.Seek(lastLogKey); it.ValidForPrefix(dbLogs) && len(samples) < n; it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, storageError(err)
+		}
+		var l raft.Log
+		if err := decodeLog(val, &l); err != nil {
+			return nil, fmt.Errorf("%w: log %d: %w", ErrCorrupt, logIndex(it.Item().Key()), err)
+		}
+		payload, err := encodePayload(&l, enc)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, payload)
+	}
+	if len(samples) == 0 {
+		return nil, ErrTooFewSamples
+	}
+	return samples, nil
+}
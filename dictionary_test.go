@@ -0,0 +1,139 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commandLog(idx uint64) *raft.Log {
+	data := fmt.Sprintf(`{"op":"set","key":"users/%d/profile","value":{"name":"user %d","active":true}}`, idx%97, idx)
+	return &raft.Log{Index: idx, Term: 1, Data: []byte(data)}
+}
+
+// resetDictionaries forgets the dictionaries loaded in the process, as if
+// it was restarted.
+func resetDictionaries() {
+	logDictionaries.mu.Lock()
+	defer logDictionaries.mu.Unlock()
+	logDictionaries.dicts = nil
+	logDictionaries.decoder.Store(nil)
+}
+
+func TestBadgerStore_TrainCompressionDictionary(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{CompressLogs: true, CompressMinSize: 32})
+	defer os.Remove(store.path)
+
+	_, err := store.TrainCompressionDictionary(100)
+	assert.ErrorIs(t, err, ErrTooFewSamples)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 500; i++ {
+		logs = append(logs, commandLog(i))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	before := len(storedValue(t, store, 500))
+
+	id, err := store.TrainCompressionDictionary(500)
+	require.NoError(t, err)
+	assert.NotZero(t, id)
+
+	logs = logs[:0]
+	for i := uint64(501); i <= 1000; i++ {
+		logs = append(logs, commandLog(i))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	after := storedValue(t, store, 1000)
+	assert.True(t, storedEncoding(after).compress)
+	assert.Less(t, len(after), before)
+	assert.Less(t, len(after), len(commandLog(1000).Data))
+
+	// A restarted process reads the logs back with the stored dictionary
+	require.NoError(t, store.Close())
+	resetDictionaries()
+
+	db, err := badger.Open(badger.DefaultOptions(store.path).WithLogger(nil))
+	require.NoError(t, err)
+	store, err = New(db, Options{CompressLogs: true, CompressMinSize: 32})
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, idx := range []uint64{1, 500, 501, 1000} {
+		var l raft.Log
+		require.NoError(t, store.GetLog(idx, &l))
+		assert.Equal(t, commandLog(idx).Data, l.Data)
+	}
+
+	// New logs keep using the dictionary
+	require.NoError(t, store.StoreLog(commandLog(1001)))
+	assert.Less(t, len(storedValue(t, store, 1001)), len(commandLog(1001).Data))
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestBadgerStore_Dictionary_PortableOutputs(t *testing.T) {
+	var archive bytes.Buffer
+	store := testBadgerStoreWithOptions(t, Options{CompressLogs: true, CompressMinSize: 32, ArchiveWriter: &archive})
+	defer os.Remove(store.path)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 500; i++ {
+		logs = append(logs, commandLog(i))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	_, err := store.TrainCompressionDictionary(500)
+	require.NoError(t, err)
+	logs = logs[:0]
+	for i := uint64(501); i <= 1000; i++ {
+		logs = append(logs, commandLog(i))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	var stream, backup bytes.Buffer
+	_, err = store.StreamLogs(1, &stream)
+	require.NoError(t, err)
+	_, err = store.Backup(&backup)
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteRange(1, 1000))
+	require.NoError(t, store.Close())
+
+	// Other processes don't know the dictionary
+	resetDictionaries()
+
+	applied := testBadgerStore(t)
+	defer applied.Close()
+	defer os.Remove(applied.path)
+	last, err := applied.ApplyLogStream(&stream)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), last)
+
+	var archived []uint64
+	require.NoError(t, ReadArchive(&archive, func(l *raft.Log) error {
+		assert.Equal(t, commandLog(l.Index).Data, l.Data)
+		archived = append(archived, l.Index)
+		return nil
+	}))
+	assert.Len(t, archived, 1000)
+
+	resetDictionaries()
+	loaded := testBadgerStore(t)
+	defer loaded.Close()
+	defer os.Remove(loaded.path)
+	require.NoError(t, loaded.LoadBackup(&backup))
+
+	for _, s := range []*BadgerRaftStore{applied, loaded} {
+		for _, idx := range []uint64{1, 1000} {
+			var l raft.Log
+			require.NoError(t, s.GetLog(idx, &l))
+			assert.Equal(t, commandLog(idx).Data, l.Data)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// An error indicating stored data is present but unreadable, for example
+	// because it fails its checksum or can't be decoded
+	ErrCorrupt = errors.New("corrupt data")
+
+	// An error indicating the underlying storage failed to serve a request
+	ErrIO = errors.New("storage I/O error")
+
+	// An error indicating a write was attempted on a read-only store
+	ErrReadOnly = errors.New("store is read-only")
+
+	// An error indicating the store has been closed
+	ErrClosed = errors.New("store is closed")
+
+	// An error indicating a request is too large to be stored
+	ErrTooLarge = errors.New("request too large")
+
+	// An error indicating a transient failure; the request may succeed if
+	// it is retried
+	ErrRetryable = errors.New("transient storage error")
+
+	// An error indicating the disk holding the store is full. It is
+	// retryable once space has been freed.
+	ErrDiskFull = errors.New("disk full")
+
+	// An error indicating an operation did not complete within OpTimeout
+	ErrTimeout = errors.New("operation timed out")
+
+	// An error indicating a log exceeds Options.MaxEntrySize
+	ErrEntryTooLarge = errors.New("entry too large")
+)
+
+// EntryTooLargeError is returned by StoreLogs for a log whose encoded size
+// exceeds Options.MaxEntrySize. It matches ErrEntryTooLarge and ErrTooLarge.
+type EntryTooLargeError struct {
+	Index uint64
+	Size  int
+	Max   int
+}
+
+func (e *EntryTooLargeError) Error() string {
+	return fmt.Sprintf("log %d is %d bytes encoded, more than the maximum of %d", e.Index, e.Size, e.Max)
+}
+
+func (e *EntryTooLargeError) Unwrap() []error {
+	return []error{ErrEntryTooLarge, ErrTooLarge}
+}
+
+// isDiskFull reports whether err is caused by running out of disk space.
+// Badger doesn't always wrap the underlying error, so the message is checked
+// as well.
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || strings.Contains(err.Error(), syscall.ENOSPC.Error())
+}
+
+// storageError wraps an error returned by Badger with the package error
+// describing it. The original error stays in the chain, so both can be
+// matched with errors.Is.
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if isDiskFull(err) {
+		return fmt.Errorf("%w: %w: %w", ErrDiskFull, ErrRetryable, err)
+	}
+
+	var kind error
+	switch {
+	case errors.Is(err, badger.ErrDBClosed):
+		kind = ErrClosed
+	case errors.Is(err, badger.ErrReadOnlyTxn):
+		kind = ErrReadOnly
+	case errors.Is(err, badger.ErrTxnTooBig):
+		kind = ErrTooLarge
+	case errors.Is(err, badger.ErrConflict), errors.Is(err, badger.ErrBlockedWrites):
+		kind = ErrRetryable
+	default:
+		kind = ErrIO
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// readError converts an error returned while reading a key into the package
+// error for it. A missing key is reported as notFound, anything else is
+// wrapped by storageError.
+func readError(err, notFound error) error {
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return notFound
+	}
+	return storageError(err)
+}
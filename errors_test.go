@@ -0,0 +1,121 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_GetLog_Corrupt(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1")}))
+	require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(logKey(1), []byte("garbage"))
+	}))
+
+	err := store.GetLog(1, new(raft.Log))
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.NotErrorIs(t, err, raft.ErrLogNotFound)
+
+	// Missing logs are still reported as such
+	err = store.GetLog(2, new(raft.Log))
+	assert.Equal(t, raft.ErrLogNotFound, err)
+}
+
+func TestBadgerStore_GetUint64_Corrupt(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.Set([]byte("short"), []byte("abc")))
+
+	_, err := store.GetUint64([]byte("short"))
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestBadgerStore_Get_IOError(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.Remove(store.path)
+	require.NoError(t, store.db.Close())
+
+	_, err := store.Get([]byte("key"))
+	assert.ErrorIs(t, err, ErrIO)
+	assert.NotErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestBadgerStore_Closed(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.Remove(store.path)
+	require.NoError(t, store.Close())
+
+	_, err := store.FirstIndex()
+	assert.ErrorIs(t, err, ErrClosed)
+
+	_, err = store.LastIndex()
+	assert.ErrorIs(t, err, ErrClosed)
+
+	err = store.StoreLog(testRaftLog(1, "log1"))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	err = store.DeleteRange(1, 2)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	_, err = store.Get([]byte("key"))
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestBadgerStore_ReadOnly(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.RemoveAll(store.path)
+	require.NoError(t, store.Close())
+
+	db, err := badger.Open(badger.DefaultOptions(store.path).WithReadOnly(true).WithLogger(nil))
+	require.NoError(t, err)
+	readOnly, err := New(db, Options{})
+	require.NoError(t, err)
+	defer readOnly.Close()
+
+	err = readOnly.Set([]byte("key"), []byte("val"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, badger.ErrReadOnlyTxn)
+}
+
+func TestStorageError(t *testing.T) {
+	assert.NoError(t, storageError(nil))
+	assert.ErrorIs(t, storageError(badger.ErrTxnTooBig), ErrTooLarge)
+	assert.ErrorIs(t, storageError(badger.ErrConflict), ErrRetryable)
+	assert.ErrorIs(t, storageError(badger.ErrConflict), badger.ErrConflict)
+	assert.ErrorIs(t, storageError(badger.ErrDBClosed), ErrClosed)
+	assert.ErrorIs(t, storageError(os.ErrPermission), ErrIO)
+}
+
+func TestBadgerStore_MaxEntrySize(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{MaxEntrySize: 100})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "small")))
+
+	big := make([]byte, 200)
+	err := store.StoreLogs([]*raft.Log{testRaftLog(2, "small"), {Index: 3, Data: big}})
+	assert.ErrorIs(t, err, ErrEntryTooLarge)
+	assert.ErrorIs(t, err, ErrTooLarge)
+	var tooLarge *EntryTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, uint64(3), tooLarge.Index)
+	assert.Greater(t, tooLarge.Size, 200)
+	assert.Equal(t, 100, tooLarge.Max)
+	assert.Equal(t, uint64(1), store.Stats().ErrorsByCategory["too_large"])
+
+	// The batch is stored in full or not at all
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), last)
+}
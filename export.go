@@ -0,0 +1,168 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+// jsonLog is the NDJSON representation of a raft log used by ExportJSON.
+// Data and Extensions are base64 encoded.
+type jsonLog struct {
+	Index      uint64    `json:"index"`
+	Term       uint64    `json:"term"`
+	Type       string    `json:"type"`
+	Data       []byte    `json:"data,omitempty"`
+	Extensions []byte    `json:"extensions,omitempty"`
+	AppendedAt time.Time `json:"appended_at,omitzero"`
+}
+
+func newJSONLog(l *raft.Log) jsonLog {
+	return jsonLog{
+		Index:      l.Index,
+		Term:       l.Term,
+		Type:       l.Type.String(),
+		Data:       l.Data,
+		Extensions: l.Extensions,
+		AppendedAt: l.AppendedAt,
+	}
+}
+
+const (
+	// How many imported logs are written per StoreLogs call.
+	importBatchSize = 1000
+)
+
+// logTypes maps the names produced by raft.LogType.String back to types.
+var logTypes = map[string]raft.LogType{
+	raft.LogCommand.String():              raft.LogCommand,
+	raft.LogNoop.String():                 raft.LogNoop,
+	raft.LogAddPeerDeprecated.String():    raft.LogAddPeerDeprecated,
+	raft.LogRemovePeerDeprecated.String(): raft.LogRemovePeerDeprecated,
+	raft.LogBarrier.String():              raft.LogBarrier,
+	raft.LogConfiguration.String():        raft.LogConfiguration,
+}
+
+func parseLogType(s string) (raft.LogType, error) {
+	if t, ok := logTypes[s]; ok {
+		return t, nil
+	}
+	t, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unknown log type %q", s)
+	}
+	return raft.LogType(t), nil
+}
+
+func (j jsonLog) raftLog() (*raft.Log, error) {
+	logType, err := parseLogType(j.Type)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.Log{
+		Index:      j.Index,
+		Term:       j.Term,
+		Type:       logType,
+		Data:       j.Data,
+		Extensions: j.Extensions,
+		AppendedAt: j.AppendedAt,
+	}, nil
+}
+
+// ExportJSON writes the logs between min and max inclusively to w as
+// newline delimited JSON, one decoded log per line.
+func (b *BadgerRaftStore) ExportJSON(w io.Writer, min, max uint64) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	return b.exportJSON(txn, w, min, max)
+}
+
+// exportJSON writes the logs between min and max inclusively, as seen by
+// txn, to w as newline delimited JSON.
+func (b *BadgerRaftStore) exportJSON(txn *badger.Txn, w io.Writer, min, max uint64) error {
+	it := txn.NewIterator(b.rangeIteratorOptions())
+	defer it.Close()
+
+	enc := json.NewEncoder(w)
+	for it.Seek(logKey(min)); it.ValidForPrefix(dbLogs); it.Next() {
+		item := it.Item()
+		if logIndex(item.Key()) > max {
+			break
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return storageError(err)
+		}
+
+		var entry raft.Log
+		if err := decodeLog(val, &entry); err != nil {
+			return fmt.Errorf("%w: log %d: %w", ErrCorrupt, logIndex(item.Key()), err)
+		}
+		if err := enc.Encode(newJSONLog(&entry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSON reads logs in the format written by ExportJSON and stores them.
+// The logs must be contiguous and, unless the store is empty, start right
+// after its last index. Logs are written in batches as they are read, so an
+// import that fails part way leaves a contiguous prefix of the input behind.
+func (b *BadgerRaftStore) ImportJSON(r io.Reader) error {
+	next, err := b.LastIndex()
+	if err != nil {
+		return err
+	}
+	if next > 0 {
+		next++
+	}
+
+	dec := json.NewDecoder(r)
+	batch := make([]*raft.Log, 0, importBatchSize)
+	for {
+		var line jsonLog
+		err := dec.Decode(&line)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		entry, err := line.raftLog()
+		if err != nil {
+			return fmt.Errorf("log %d: %w", line.Index, err)
+		}
+		if next > 0 && entry.Index != next {
+			return fmt.Errorf("%w: log %d is not contiguous, expected index %d", ErrLogGap, entry.Index, next)
+		}
+		next = entry.Index + 1
+
+		batch = append(batch, entry)
+		if len(batch) == importBatchSize {
+			if err := b.StoreLogs(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return b.StoreLogs(batch)
+}
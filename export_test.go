@@ -0,0 +1,86 @@
+package raftbadgerstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_ExportJSON(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	appendedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		{Index: 2, Term: 3, Type: raft.LogConfiguration, Data: []byte("log2"), Extensions: []byte("ext"), AppendedAt: appendedAt},
+		testRaftLog(3, "log3"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	var buf bytes.Buffer
+	require.NoError(t, store.ExportJSON(&buf, 2, 3))
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, map[string]interface{}{
+		"index":       float64(2),
+		"term":        float64(3),
+		"type":        "LogConfiguration",
+		"data":        "bG9nMg==",
+		"extensions":  "ZXh0",
+		"appended_at": "2024-01-02T03:04:05Z",
+	}, lines[0])
+	assert.Equal(t, float64(3), lines[1]["index"])
+	assert.NotContains(t, lines[1], "appended_at")
+}
+
+func TestBadgerStore_ImportJSON(t *testing.T) {
+	source := testBadgerStore(t)
+	defer source.Close()
+	defer os.Remove(source.path)
+
+	logs := []*raft.Log{
+		testRaftLog(5, "log5"),
+		{Index: 6, Term: 2, Type: raft.LogNoop, Extensions: []byte("ext")},
+		testRaftLog(7, "log7"),
+	}
+	require.NoError(t, source.StoreLogs(logs))
+
+	var buf bytes.Buffer
+	require.NoError(t, source.ExportJSON(&buf, 0, 10))
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.ImportJSON(&buf))
+
+	for _, l := range logs {
+		result := new(raft.Log)
+		require.NoError(t, store.GetLog(l.Index, result))
+		assert.Equal(t, l, result)
+	}
+
+	// Logs that don't follow the last index are rejected
+	err := store.ImportJSON(bytes.NewBufferString(`{"index":9,"term":1,"type":"LogCommand"}`))
+	assert.ErrorContains(t, err, "not contiguous")
+
+	err = store.ImportJSON(bytes.NewBufferString(`{"index":8,"term":1,"type":"LogBogus"}`))
+	assert.ErrorContains(t, err, "unknown log type")
+}
@@ -0,0 +1,130 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// An error indicating a failure was injected by Failpoints
+	ErrInjected = errors.New("injected failure")
+)
+
+// Failpoints injects storage failures into a store, so applications can
+// test how they handle them. Create one with NewFailpoints, pass it in
+// Options.Failpoints and arm it at any time while the store is in use.
+// Failpoints are meant for tests only.
+type Failpoints struct {
+	mu sync.Mutex
+
+	commitFailures int
+	commitErr      error
+	readDelay      time.Duration
+	corruptReads   int
+}
+
+// NewFailpoints returns failpoints that are all disarmed.
+func NewFailpoints() *Failpoints {
+	return &Failpoints{}
+}
+
+// FailCommits makes the next n commits of StoreLogs, DeleteRange and Set
+// fail with err, which is passed through the same error mapping as Badger
+// errors. A nil err fails them with ErrInjected, mapped to ErrIO.
+func (f *Failpoints) FailCommits(n int, err error) {
+	if err == nil {
+		err = ErrInjected
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commitFailures = n
+	f.commitErr = err
+}
+
+// DelayReads makes every GetLog and Get wait d before reading. Zero
+// disables the delay.
+func (f *Failpoints) DelayReads(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.readDelay = d
+}
+
+// CorruptReads makes the next n reads of GetLog and Get fail with
+// ErrCorrupt.
+func (f *Failpoints) CorruptReads(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.corruptReads = n
+}
+
+// Reset disarms all failpoints.
+func (f *Failpoints) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commitFailures = 0
+	f.commitErr = nil
+	f.readDelay = 0
+	f.corruptReads = 0
+}
+
+// commit returns the error of an armed commit failpoint.
+func (f *Failpoints) commit() error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.commitFailures <= 0 {
+		return nil
+	}
+	f.commitFailures--
+	return f.commitErr
+}
+
+// read applies the read delay and returns the error of an armed corruption
+// failpoint.
+func (f *Failpoints) read() error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	delay := f.readDelay
+	corrupt := f.corruptReads > 0
+	if corrupt {
+		f.corruptReads--
+	}
+	f.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if corrupt {
+		return fmt.Errorf("%w: %w", ErrCorrupt, ErrInjected)
+	}
+	return nil
+}
+
+// commit commits txn unless a failpoint makes it fail, after any injected
+// commit latency. The caller still owns txn and must discard it.
+func (b *BadgerRaftStore) commit(txn *badger.Txn) error {
+	injectLatency(b.commitLatency)
+	if err := b.failpoints.commit(); err != nil {
+		return err
+	}
+	return b.commitTxn(txn)
+}
+
+// beforeRead is called before reading a log or key. It injects the read
+// latency and returns the error of an armed read failpoint.
+func (b *BadgerRaftStore) beforeRead() error {
+	injectLatency(b.readLatency)
+	return b.failpoints.read()
+}
@@ -0,0 +1,58 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_Failpoints_Commits(t *testing.T) {
+	fp := NewFailpoints()
+	store := testBadgerStoreWithOptions(t, Options{Failpoints: fp})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	fp.FailCommits(2, nil)
+
+	err := store.StoreLog(testRaftLog(1, "log1"))
+	assert.ErrorIs(t, err, ErrIO)
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.ErrorIs(t, store.Set([]byte("key"), []byte("val")), ErrInjected)
+
+	// Only the next n commits fail
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+
+	// Transient errors go through the retry policy like real ones
+	fp.FailCommits(1, badger.ErrConflict)
+	assert.ErrorIs(t, store.DeleteRange(1, 1), ErrRetryable)
+
+	fp.FailCommits(1, nil)
+	assert.ErrorIs(t, store.Health(context.Background()), ErrInjected)
+
+	fp.Reset()
+	require.NoError(t, store.DeleteRange(1, 1))
+}
+
+func TestBadgerStore_Failpoints_Reads(t *testing.T) {
+	fp := NewFailpoints()
+	store := testBadgerStoreWithOptions(t, Options{Failpoints: fp})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+
+	fp.CorruptReads(1)
+	assert.ErrorIs(t, store.GetLog(1, new(raft.Log)), ErrCorrupt)
+	require.NoError(t, store.GetLog(1, new(raft.Log)))
+
+	fp.DelayReads(20 * time.Millisecond)
+	start := time.Now()
+	require.NoError(t, store.GetLog(1, new(raft.Log)))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// An error indicating a log can't be stored without changing it, see
+	// Options.StrictFidelity
+	ErrLossyEncoding = errors.New("log can't be stored unchanged")
+)
+
+// encodeFaithfully encodes l like encodeLog and decodes it again to check
+// that it comes back unchanged. Logs the store's codec can't reproduce, such
+// as those with empty but non-nil data or times outside the range of Unix
+// nanoseconds in CodecBinary, are stored with msgpack instead, which
+// reproduces every field. It returns ErrLossyEncoding if even that fails.
+func (b *BadgerRaftStore) encodeFaithfully(l *raft.Log, enc logEncoding) ([]byte, error) {
+	val, err := encodeLogValue(l, enc)
+	if err != nil {
+		return nil, err
+	}
+	field := lostField(l, val)
+	if field == "" {
+		return val, nil
+	}
+
+	if enc.codec != CodecMsgpack {
+		enc.codec = CodecMsgpack
+		if val, err = encodeLogValue(l, enc); err != nil {
+			return nil, err
+		}
+		if lostField(l, val) == "" {
+			b.stats.fidelityFallbacks.Add(1)
+			log.Debug().Uint64("index", l.Index).Str("field", field).Msg("Storing log with msgpack, as its codec would change it")
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: log %d would change its %s", ErrLossyEncoding, l.Index, field)
+}
+
+// lostField decodes the stored value val of l and returns the name of the
+// first field it doesn't reproduce, or "" if it reproduces all of them.
+// Data and extensions must keep their bytes and whether they are nil, and
+// AppendedAt its instant; its location and monotonic clock reading are
+// never stored.
+func lostField(l *raft.Log, val []byte) string {
+	var got raft.Log
+	if err := decodeLog(val, &got); err != nil {
+		return "encoding"
+	}
+
+	switch {
+	case got.Index != l.Index:
+		return "index"
+	case got.Term != l.Term:
+		return "term"
+	case got.Type != l.Type:
+		return "type"
+	case !sameBytes(got.Data, l.Data):
+		return "data"
+	case !sameBytes(got.Extensions, l.Extensions):
+		return "extensions"
+	case !got.AppendedAt.Equal(l.AppendedAt):
+		return "appended_at"
+	}
+	return ""
+}
+
+// sameBytes reports whether a and b hold the same bytes and are both nil or
+// both non-nil.
+func sameBytes(a, b []byte) bool {
+	return bytes.Equal(a, b) && (a == nil) == (b == nil)
+}
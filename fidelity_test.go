@@ -0,0 +1,95 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fidelityLogs returns logs with the data, extensions and times codecs are
+// most likely to change.
+func fidelityLogs() []*raft.Log {
+	times := []time.Time{
+		{},
+		time.Unix(0, 0),
+		time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.FixedZone("CET", 3600)),
+		time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Date(3000, 1, 1, 0, 0, 0, 1, time.UTC),
+	}
+	payloads := [][]byte{nil, {}, {0}, {envelopeMagic, 0xff}, make([]byte, 1024)}
+
+	var logs []*raft.Log
+	for _, at := range times {
+		for _, data := range payloads {
+			for _, ext := range payloads {
+				logs = append(logs, &raft.Log{
+					Index:      uint64(len(logs) + 1),
+					Term:       3,
+					Type:       raft.LogCommand,
+					Data:       data,
+					Extensions: ext,
+					AppendedAt: at,
+				})
+			}
+		}
+	}
+	return logs
+}
+
+func TestBadgerStore_StrictFidelity(t *testing.T) {
+	for _, options := range []Options{
+		{StrictFidelity: true},
+		{StrictFidelity: true, MsgpackUseNewTimeFormat: true},
+		{StrictFidelity: true, Codec: CodecBinary},
+		{StrictFidelity: true, Codec: CodecBinary, Checksums: true, IdempotentAppends: true},
+		{StrictFidelity: true, Codec: CodecBinary, CompressLogs: true, CompressMinSize: 1},
+	} {
+		t.Run(fmt.Sprintf("%s/%t/%t", options.Codec, options.MsgpackUseNewTimeFormat, options.CompressLogs), func(t *testing.T) {
+			store := testBadgerStoreWithOptions(t, options)
+			defer store.Close()
+			defer os.Remove(store.path)
+
+			logs := fidelityLogs()
+			require.NoError(t, store.StoreLogs(logs))
+
+			for _, want := range logs {
+				var got raft.Log
+				require.NoError(t, store.GetLog(want.Index, &got))
+				assert.True(t, sameBytes(want.Data, got.Data), "data of log %d: %#v", want.Index, got.Data)
+				assert.True(t, sameBytes(want.Extensions, got.Extensions), "extensions of log %d: %#v", want.Index, got.Extensions)
+				assert.True(t, want.AppendedAt.Equal(got.AppendedAt), "time of log %d: %s", want.Index, got.AppendedAt)
+			}
+
+			// Only the binary codec needs msgpack for some logs
+			fallbacks := store.Stats().FidelityFallbacks
+			if options.Codec == CodecBinary {
+				assert.NotZero(t, fallbacks)
+			} else {
+				assert.Zero(t, fallbacks)
+			}
+		})
+	}
+}
+
+func TestLostField(t *testing.T) {
+	l := &raft.Log{Index: 1, Term: 2, Data: []byte{}, AppendedAt: time.Unix(0, 0)}
+
+	val, err := encodeLogValue(l, logEncoding{codec: CodecMsgpack})
+	require.NoError(t, err)
+	assert.Empty(t, lostField(l, val))
+
+	// The binary codec reads empty data as nil and Unix time 0 as unset
+	val, err = encodeLogValue(l, logEncoding{codec: CodecBinary})
+	require.NoError(t, err)
+	assert.Equal(t, "data", lostField(l, val))
+
+	l.Data = nil
+	assert.Equal(t, "appended_at", lostField(l, val))
+
+	assert.Equal(t, "encoding", lostField(l, []byte{envelopeMagic}))
+}
@@ -0,0 +1,209 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Discard ratio of the background GC unless Options.GCDiscardRatio
+	// says otherwise
+	defaultGCDiscardRatio = 0.5
+
+	// How often a paused background GC checks whether appends calmed down
+	gcPauseCheckInterval = time.Second
+
+	// Number of StoreLogs latencies the p99 pausing GC is computed over
+	gcPauseWindow = 100
+)
+
+var (
+	metricGCPauses = []string{"raft", "badgerstore", "gc", "pauses"}
+)
+
+// RunValueLogGCUntilClean runs value log GC cycles with discardRatio until
+// one finds nothing to rewrite, maxRuns cycles ran or ctx is done, and
+// returns how many value log files were rewritten. Each cycle rewrites at
+// most one file, so a single RunValueLogGC rarely reclaims all the space
+// freed by deleting logs. maxRuns of zero or less means no limit.
+//
+// Finding nothing more to rewrite and reaching maxRuns are not errors. If
+// ctx is done between cycles, its error is returned.
+func (b *BadgerRaftStore) RunValueLogGCUntilClean(ctx context.Context, discardRatio float64, maxRuns int) (int, error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	rewritten := 0
+
+	for runs := 0; maxRuns <= 0 || runs < maxRuns; runs++ {
+		if err := ctx.Err(); err != nil {
+			return rewritten, err
+		}
+
+		err := b.runValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return rewritten, nil
+		}
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+	return rewritten, nil
+}
+
+// runGC runs value log GC on every tick until the store is closed.
+func (b *BadgerRaftStore) runGC(shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(b.gcInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			if err := b.backgroundGC(shutdownCh); err != nil && !errors.Is(err, ErrClosed) {
+				log.Warn().Err(err).Msg("Background value log GC failed")
+			}
+		}
+	}
+}
+
+// backgroundGC runs value log GC cycles until there is nothing left to
+// rewrite or the store is closed, pausing while appends are busy.
+func (b *BadgerRaftStore) backgroundGC(shutdownCh <-chan struct{}) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	return b.gcUntilClean(shutdownCh)
+}
+
+// gcUntilClean runs value log GC cycles with the background discard ratio
+// until there is nothing left to rewrite, waiting for calm appends before
+// each.
+func (b *BadgerRaftStore) gcUntilClean(shutdownCh <-chan struct{}) error {
+	for {
+		if err := b.waitForCalmAppends(shutdownCh); err != nil {
+			return err
+		}
+
+		err := b.runValueLogGC(b.gcDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitForCalmAppends returns once appends are no longer busy, see
+// Options.GCPauseAppendRate, or ErrClosed if the store is closed first.
+func (b *BadgerRaftStore) waitForCalmAppends(shutdownCh <-chan struct{}) error {
+	paused := false
+	for {
+		select {
+		case <-shutdownCh:
+			return ErrClosed
+		default:
+		}
+
+		busy := b.gcPause.busy(b.stats.appends.Load(), time.Now())
+		if !busy {
+			if paused {
+				log.Debug().Msg("Resuming value log GC")
+			}
+			return nil
+		}
+		if !paused {
+			paused = true
+			b.gc.pause()
+			metrics.IncrCounter(metricGCPauses, 1)
+			log.Debug().Msg("Pausing value log GC during a write burst")
+		}
+
+		timer := time.NewTimer(gcPauseCheckInterval)
+		select {
+		case <-shutdownCh:
+			timer.Stop()
+			return ErrClosed
+		case <-timer.C:
+		}
+	}
+}
+
+// gcPause tells whether appends are too busy for the background GC to run,
+// from the append rate and StoreLogs latencies since it was last asked.
+type gcPause struct {
+	maxRate    float64
+	maxLatency time.Duration
+
+	mu          sync.Mutex
+	samples     []time.Duration
+	lastAppends uint64
+	lastCheck   time.Time
+}
+
+func newGCPause(maxRate float64, maxLatency time.Duration) *gcPause {
+	if maxRate <= 0 && maxLatency <= 0 {
+		return nil
+	}
+	return &gcPause{
+		maxRate:    maxRate,
+		maxLatency: maxLatency,
+		samples:    make([]time.Duration, 0, gcPauseWindow),
+		lastCheck:  time.Now(),
+	}
+}
+
+// record adds a StoreLogs latency to the window, dropping the oldest if it
+// is full.
+func (p *gcPause) record(d time.Duration) {
+	if p == nil || p.maxLatency <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.samples) == gcPauseWindow {
+		p.samples = slices.Delete(p.samples, 0, 1)
+	}
+	p.samples = append(p.samples, d)
+}
+
+// busy reports whether more than maxRate logs per second were appended, or
+// the p99 StoreLogs latency was above maxLatency, since it was last called.
+// appends is the number of logs appended since the store was opened.
+func (p *gcPause) busy(appends uint64, now time.Time) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := now.Sub(p.lastCheck)
+	rate := float64(appends-p.lastAppends) / elapsed.Seconds()
+	p.lastAppends, p.lastCheck = appends, now
+
+	var p99 time.Duration
+	if len(p.samples) > 0 {
+		slices.Sort(p.samples)
+		p99 = p.samples[(len(p.samples)*99-1)/100]
+		p.samples = p.samples[:0]
+	}
+
+	return (p.maxRate > 0 && elapsed > 0 && rate > p.maxRate) ||
+		(p.maxLatency > 0 && p99 > p.maxLatency)
+}
@@ -0,0 +1,89 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_RunValueLogGCUntilClean(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Nothing to rewrite ends the loop after one cycle without an error
+	rewritten, err := store.RunValueLogGCUntilClean(context.Background(), 0.5, 10)
+	require.NoError(t, err)
+	assert.Zero(t, rewritten)
+	assert.Equal(t, uint64(1), store.GCStats().Cycles)
+
+	// A done context stops it before any cycle
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rewritten, err = store.RunValueLogGCUntilClean(ctx, 0.5, 0)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, rewritten)
+	assert.Equal(t, uint64(1), store.GCStats().Cycles)
+
+	require.NoError(t, store.Close())
+	_, err = store.RunValueLogGCUntilClean(context.Background(), 0.5, 0)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestBadgerStore_BackgroundGC(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{GCInterval: 10 * time.Millisecond})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.Eventually(t, func() bool {
+		return store.GCStats().Cycles > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, store.GCStats().Pauses)
+}
+
+func TestBadgerStore_BackgroundGCPausesDuringWriteBursts(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		GCInterval:        10 * time.Millisecond,
+		GCPauseAppendRate: 1,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 100)
+
+	// GC pauses for the burst and runs once appends stop
+	require.Eventually(t, func() bool {
+		stats := store.GCStats()
+		return stats.Pauses > 0 && stats.Cycles > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestGCPause_Busy(t *testing.T) {
+	assert.Nil(t, newGCPause(0, 0))
+	var p *gcPause
+	p.record(time.Second)
+	assert.False(t, p.busy(1000, time.Now()))
+
+	now := time.Now()
+	p = newGCPause(100, 0)
+	p.lastCheck = now
+	assert.True(t, p.busy(1000, now.Add(time.Second)))
+	assert.False(t, p.busy(1050, now.Add(2*time.Second)))
+
+	p = newGCPause(0, 10*time.Millisecond)
+	for range 99 {
+		p.record(time.Millisecond)
+	}
+	p.record(time.Second)
+	assert.False(t, p.busy(0, now), "a single outlier is below the p99")
+
+	for range 10 {
+		p.record(time.Second)
+	}
+	assert.True(t, p.busy(0, now))
+	assert.False(t, p.busy(0, now), "latencies are only counted once")
+}
@@ -0,0 +1,127 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	metrics "github.com/hashicorp/go-metrics/compat"
+)
+
+var (
+	metricGCCycles         = []string{"raft", "badgerstore", "gc", "cycles"}
+	metricGCFilesRewritten = []string{"raft", "badgerstore", "gc", "files_rewritten"}
+	metricGCBytesReclaimed = []string{"raft", "badgerstore", "gc", "bytes_reclaimed"}
+)
+
+// GCStats describes the value log GC cycles run since the store was opened,
+// to tell whether GC keeps up with the disk space freed by deleting logs.
+type GCStats struct {
+	// Number of GC cycles run, and how many of them rewrote a value log
+	// file. Cycles finding nothing worth rewriting rewrite none.
+	Cycles         uint64 `json:"cycles"`
+	FilesRewritten uint64 `json:"files_rewritten"`
+
+	// Number of times the background GC paused because of a write burst,
+	// see Options.GCPauseAppendRate.
+	Pauses uint64 `json:"pauses"`
+
+	// Number of passes reclaiming the space of deleted logs, see
+	// Options.ReclaimAfterDelete.
+	Reclaims uint64 `json:"reclaims"`
+
+	// Bytes by which rewrites shrank the value log. Badger may delete a
+	// rewritten file only once no reads use it, so this can lag behind.
+	BytesReclaimed int64 `json:"bytes_reclaimed"`
+
+	// When the last cycle ran and when a cycle last rewrote a file.
+	LastRun     time.Time `json:"last_run,omitzero"`
+	LastRewrite time.Time `json:"last_rewrite,omitzero"`
+
+	// Error of the last cycle that failed for another reason than finding
+	// nothing to rewrite.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// gcTracker collects GCStats.
+type gcTracker struct {
+	mu    sync.Mutex
+	stats GCStats
+}
+
+// record records a GC cycle started at start that failed with err, or
+// rewrote a file and shrank the value log by reclaimed bytes.
+func (t *gcTracker) record(start time.Time, err error, reclaimed int64) {
+	metrics.IncrCounter(metricGCCycles, 1)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Cycles++
+	t.stats.LastRun = start
+	switch {
+	case errors.Is(err, badger.ErrNoRewrite):
+	case err != nil:
+		t.stats.LastError = err.Error()
+	default:
+		reclaimed = max(reclaimed, 0)
+		t.stats.FilesRewritten++
+		t.stats.BytesReclaimed += reclaimed
+		t.stats.LastRewrite = start
+		metrics.IncrCounter(metricGCFilesRewritten, 1)
+		metrics.IncrCounter(metricGCBytesReclaimed, float32(reclaimed))
+	}
+}
+
+// pause counts a pause of the background GC.
+func (t *gcTracker) pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Pauses++
+}
+
+// reclaim counts a pass reclaiming the space of deleted logs.
+func (t *gcTracker) reclaim() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Reclaims++
+}
+
+// GCStats returns statistics of the value log GC cycles run since the store
+// was opened, by RunValueLogGC and the store itself.
+func (b *BadgerRaftStore) GCStats() GCStats {
+	b.gc.mu.Lock()
+	defer b.gc.mu.Unlock()
+	return b.gc.stats
+}
+
+// runValueLogGC runs a value log GC cycle and records it in the GC stats.
+func (b *BadgerRaftStore) runValueLogGC(discardRatio float64) error {
+	start := time.Now()
+	before := b.vlogFileSize()
+	err := b.db.RunValueLogGC(discardRatio)
+	b.gc.record(start, err, before-b.vlogFileSize())
+	return err
+}
+
+// vlogFileSize returns the size of the value log files on disk. Unlike
+// Size, which Badger only updates every minute, it reflects rewrites
+// right away.
+func (b *BadgerRaftStore) vlogFileSize() int64 {
+	dir := b.db.Opts().ValueDir
+	if dir == "" {
+		return 0
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.vlog"))
+
+	var size int64
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,53 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_GCStats(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	assert.Equal(t, GCStats{}, store.GCStats())
+
+	// A fresh store has nothing to rewrite
+	start := time.Now()
+	err := store.RunValueLogGC(0.5)
+	require.ErrorIs(t, err, badger.ErrNoRewrite)
+
+	stats := store.GCStats()
+	assert.Equal(t, uint64(1), stats.Cycles)
+	assert.Zero(t, stats.FilesRewritten)
+	assert.Zero(t, stats.BytesReclaimed)
+	assert.False(t, stats.LastRun.Before(start))
+	assert.True(t, stats.LastRewrite.IsZero())
+	assert.Empty(t, stats.LastError)
+	assert.Equal(t, stats, store.Stats().GC)
+}
+
+func TestGCTracker_Record(t *testing.T) {
+	var tracker gcTracker
+	now := time.Now()
+
+	tracker.record(now, nil, 1024)
+	tracker.record(now.Add(time.Second), badger.ErrNoRewrite, 0)
+	tracker.record(now.Add(2*time.Second), nil, -10)
+	tracker.record(now.Add(3*time.Second), errors.New("boom"), 0)
+
+	assert.Equal(t, GCStats{
+		Cycles:         4,
+		FilesRewritten: 2,
+		BytesReclaimed: 1024,
+		LastRun:        now.Add(3 * time.Second),
+		LastRewrite:    now.Add(2 * time.Second),
+		LastError:      "boom",
+	}, tracker.stats)
+}
@@ -0,0 +1,214 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// Ranges with fewer logs than this are decoded by the reading goroutine
+	// alone, as handing them to workers costs more than it saves
+	parallelDecodeThreshold = 64
+)
+
+// GetLogs returns the logs between min and max inclusively, oldest first,
+// and fails with raft.ErrLogNotFound if any of them is missing. A single
+// iterator streams the raw values while a pool of up to GOMAXPROCS workers
+// decodes them, so reading a large range, as when a follower catches up or
+// a store is restored, isn't bound by a single CPU.
+func (b *BadgerRaftStore) GetLogs(min, max uint64) ([]*raft.Log, error) {
+	o := op{name: "GetLogs", min: min, max: max}
+
+	// Only read once do returned successfully, so a timed out read can't
+	// race with the caller
+	var result []*raft.Log
+	err := b.do(o, func() error {
+		logs, err := b.getLogs(min, max)
+		if err == nil {
+			b.stats.reads.Add(uint64(len(logs)))
+			result = logs
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *BadgerRaftStore) getLogs(min, max uint64) ([]*raft.Log, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	if err := b.beforeRead(); err != nil {
+		return nil, err
+	}
+	if max < min {
+		return nil, nil
+	}
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	// Checked up front, so a bogus range can't allocate a huge result
+	meta, err := loadLogMeta(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if meta.LastIndex == 0 || min < meta.FirstIndex || max > meta.LastIndex {
+		return nil, raft.ErrLogNotFound
+	}
+
+	n := max - min + 1
+	entries := make([]raft.Log, n)
+	logs := make([]*raft.Log, n)
+	for i := range logs {
+		logs[i] = &entries[i]
+	}
+
+	workers := 1
+	if n >= parallelDecodeThreshold {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	d := newLogDecoder(workers)
+	err = b.readRange(txn, min, max, logs, d)
+	if derr := d.wait(); err == nil {
+		err = derr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// readRange reads the logs between min and max into logs, handing their
+// values to d to decode. Logs missing from Badger are read from the cold
+// tier if there is one.
+func (b *BadgerRaftStore) readRange(txn *badger.Txn, min, max uint64, logs []*raft.Log, d *logDecoder) error {
+	it := txn.NewIterator(b.rangeIteratorOptions())
+	defer it.Close()
+
+	next := min
+	for it.Seek(logKey(min)); it.ValidForPrefix(dbLogs) && next <= max && !d.failed(); it.Next() {
+		item := it.Item()
+		idx := logIndex(item.Key())
+		if err := b.readMissing(txn, next, idx-1, max, logs[next-min:]); err != nil {
+			return err
+		}
+
+		if idx > max {
+			return nil
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return storageError(err)
+		}
+		d.decode(idx, val, logs[idx-min])
+		next = idx + 1
+	}
+	if d.failed() {
+		return nil
+	}
+	return b.readMissing(txn, next, max, max, logs[next-min:])
+}
+
+// readMissing reads the logs from first through last, capped at max, that
+// weren't found in Badger from the cold tier, or fails with
+// raft.ErrLogNotFound if there is none.
+func (b *BadgerRaftStore) readMissing(txn *badger.Txn, first, last, max uint64, logs []*raft.Log) error {
+	last = min(last, max)
+	if first > last {
+		return nil
+	}
+	if b.cold == nil {
+		return raft.ErrLogNotFound
+	}
+	for idx := first; idx <= last; idx++ {
+		if err := b.readColdLog(txn, idx, logs[idx-first]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logDecoder decodes log values on a pool of workers, or on the calling
+// goroutine if it has just one.
+type logDecoder struct {
+	jobs chan decodeJob
+	wg   sync.WaitGroup
+
+	err     atomic.Pointer[error]
+	errOnce sync.Once
+}
+
+type decodeJob struct {
+	idx uint64
+	val []byte
+	log *raft.Log
+}
+
+func newLogDecoder(workers int) *logDecoder {
+	d := &logDecoder{}
+	if workers <= 1 {
+		return d
+	}
+
+	d.jobs = make(chan decodeJob, workers*4)
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for job := range d.jobs {
+				if !d.failed() {
+					d.run(job)
+				}
+			}
+		}()
+	}
+	return d
+}
+
+// decode decodes val into log, now or on a worker.
+func (d *logDecoder) decode(idx uint64, val []byte, log *raft.Log) {
+	job := decodeJob{idx: idx, val: val, log: log}
+	if d.jobs == nil {
+		d.run(job)
+		return
+	}
+	d.jobs <- job
+}
+
+func (d *logDecoder) run(job decodeJob) {
+	if err := decodeLog(job.val, job.log); err != nil {
+		d.errOnce.Do(func() {
+			err := fmt.Errorf("%w: log %d: %w", ErrCorrupt, job.idx, err)
+			d.err.Store(&err)
+		})
+	}
+}
+
+// failed reports whether a log failed to decode, after which the rest of
+// the range isn't worth reading.
+func (d *logDecoder) failed() bool {
+	return d.err.Load() != nil
+}
+
+// wait waits for the workers to decode every value handed to them and
+// returns the first error.
+func (d *logDecoder) wait() error {
+	if d.jobs != nil {
+		close(d.jobs)
+		d.wg.Wait()
+	}
+	if err := d.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
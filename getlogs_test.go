@@ -0,0 +1,126 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storeTestLogs(t testing.TB, store *BadgerRaftStore, min, max uint64) {
+	var logs []*raft.Log
+	for i := min; i <= max; i++ {
+		logs = append(logs, testRaftLog(i, fmt.Sprintf("log%d", i)))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+}
+
+func TestBadgerStore_GetLogs(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 500)
+
+	// Both below and above the parallel decoding threshold
+	for _, r := range []IndexRange{{Min: 5, Max: 10}, {Min: 1, Max: 500}, {Min: 100, Max: 300}} {
+		logs, err := store.GetLogs(r.Min, r.Max)
+		require.NoError(t, err)
+		require.Len(t, logs, int(r.Max-r.Min+1))
+		for i, log := range logs {
+			idx := r.Min + uint64(i)
+			assert.Equal(t, idx, log.Index)
+			assert.Equal(t, fmt.Sprintf("log%d", idx), string(log.Data))
+		}
+	}
+
+	logs, err := store.GetLogs(10, 9)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+
+	_, err = store.GetLogs(450, 501)
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+	_, err = store.GetLogs(0, 10)
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+}
+
+func TestBadgerStore_GetLogs_Gap(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{AllowLogGaps: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 100)
+	storeTestLogs(t, store, 110, 200)
+
+	_, err := store.GetLogs(50, 150)
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+
+	logs, err := store.GetLogs(110, 200)
+	require.NoError(t, err)
+	assert.Len(t, logs, 91)
+}
+
+func TestBadgerStore_GetLogs_Corrupt(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 500)
+	err := store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(logKey(250), []byte{0xc1})
+	})
+	require.NoError(t, err)
+
+	_, err = store.GetLogs(1, 500)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.ErrorContains(t, err, "log 250")
+}
+
+func TestBadgerStore_GetLogs_ColdTier(t *testing.T) {
+	store, _ := testColdTierStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeAgedLogs(t, store, 1500, time.Now().Add(-time.Hour))
+	_, err := store.MoveToColdTier()
+	require.NoError(t, err)
+
+	logs, err := store.GetLogs(990, 1500)
+	require.NoError(t, err)
+	require.Len(t, logs, 511)
+	for i, log := range logs {
+		assert.Equal(t, uint64(990+i), log.Index)
+	}
+}
+
+func BenchmarkBadgerStore_GetLogs(b *testing.B) {
+	store := testBadgerStore(b)
+	b.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(store.path)
+	})
+
+	data := make([]byte, 1024)
+	logs := make([]*raft.Log, 10000)
+	for i := range logs {
+		logs[i] = &raft.Log{Index: uint64(i + 1), Data: data, AppendedAt: time.Now()}
+	}
+	for i := 0; i < len(logs); i += 1000 {
+		if err := store.StoreLogs(logs[i : i+1000]); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+
+	b.SetBytes(int64(len(logs) * len(data)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := store.GetLogs(1, uint64(len(logs))); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package hclogadapter sends the logs of raftbadgerstore, including the
+// Badger logs it bridges, to an hclog.Logger, the logger hashicorp/raft
+// itself uses.
+//
+// The store logs through the global zerolog logger, so point that at hclog:
+//
+//	log.Logger = hclogadapter.Logger(raftLogger)
+//
+// When passing Options.BadgerOptions, bridge Badger's logger as well:
+//
+//	opts := badger.DefaultOptions(dir).WithLogger(hclogadapter.NewBadgerLogger(raftLogger))
+package hclogadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/go-hclog"
+	"github.com/rs/zerolog"
+)
+
+// Logger returns a zerolog logger writing to l, with its level set to match
+// l's so filtered events aren't even built.
+func Logger(l hclog.Logger) zerolog.Logger {
+	return zerolog.New(NewWriter(l)).Level(zerologLevel(l.GetLevel()))
+}
+
+// Writer converts the JSON events written by a zerolog logger into calls
+// to an hclog.Logger at the matching level, with the event's fields as
+// key/value pairs.
+type Writer struct {
+	logger hclog.Logger
+}
+
+// NewWriter returns a Writer emitting through l.
+func NewWriter(l hclog.Logger) *Writer {
+	return &Writer{logger: l}
+}
+
+// Write implements io.Writer. zerolog writes a single event per call.
+func (w *Writer) Write(p []byte) (int, error) {
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		// Not an event, pass it on as is
+		w.logger.Info(strings.TrimSpace(string(p)))
+		return len(p), nil
+	}
+
+	level, _ := fields[zerolog.LevelFieldName].(string)
+	msg, _ := fields[zerolog.MessageFieldName].(string)
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.MessageFieldName)
+	// hclog adds its own timestamp
+	delete(fields, zerolog.TimestampFieldName)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, k := range keys {
+		args = append(args, k, fields[k])
+	}
+
+	w.logger.Log(hclogLevel(level), msg, args...)
+	return len(p), nil
+}
+
+// hclogLevel maps a zerolog level name to an hclog level. Fatal and panic
+// have no hclog equivalent and are logged as errors.
+func hclogLevel(level string) hclog.Level {
+	switch level {
+	case zerolog.LevelTraceValue:
+		return hclog.Trace
+	case zerolog.LevelDebugValue:
+		return hclog.Debug
+	case zerolog.LevelWarnValue:
+		return hclog.Warn
+	case zerolog.LevelErrorValue, zerolog.LevelFatalValue, zerolog.LevelPanicValue:
+		return hclog.Error
+	default:
+		return hclog.Info
+	}
+}
+
+// zerologLevel maps an hclog level to the zerolog level filtering the same
+// events.
+func zerologLevel(level hclog.Level) zerolog.Level {
+	switch level {
+	case hclog.Trace:
+		return zerolog.TraceLevel
+	case hclog.Debug:
+		return zerolog.DebugLevel
+	case hclog.Warn:
+		return zerolog.WarnLevel
+	case hclog.Error:
+		return zerolog.ErrorLevel
+	case hclog.Off:
+		return zerolog.Disabled
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// badgerLogger implements badger.Logger on top of an hclog.Logger.
+type badgerLogger struct {
+	logger hclog.Logger
+}
+
+// NewBadgerLogger returns a badger.Logger writing Badger's internal logs to
+// l at the matching level.
+func NewBadgerLogger(l hclog.Logger) badger.Logger {
+	return &badgerLogger{logger: l.Named("badger")}
+}
+
+func (l *badgerLogger) Errorf(format string, args ...interface{}) {
+	l.log(hclog.Error, format, args)
+}
+
+func (l *badgerLogger) Warningf(format string, args ...interface{}) {
+	l.log(hclog.Warn, format, args)
+}
+
+func (l *badgerLogger) Infof(format string, args ...interface{}) {
+	l.log(hclog.Info, format, args)
+}
+
+func (l *badgerLogger) Debugf(format string, args ...interface{}) {
+	l.log(hclog.Debug, format, args)
+}
+
+func (l *badgerLogger) log(level hclog.Level, format string, args []interface{}) {
+	// Skip formatting messages the level filters out anyway
+	if l.logger.GetLevel() > level {
+		return
+	}
+	// Badger terminates most of its messages with a newline
+	l.logger.Log(level, strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
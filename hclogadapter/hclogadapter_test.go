@@ -0,0 +1,43 @@
+package hclogadapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLogger(buf *bytes.Buffer, level hclog.Level) hclog.Logger {
+	return hclog.New(&hclog.LoggerOptions{
+		Output:      buf,
+		Level:       level,
+		DisableTime: true,
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger(testLogger(&buf, hclog.Info))
+
+	logger.Debug().Msg("Storing logs")
+	assert.Empty(t, buf.String())
+
+	logger.Warn().Str("op", "StoreLogs").Uint64("max", 4).Msg("Slow store operation")
+	assert.Equal(t, "[WARN]  Slow store operation: max=4 op=StoreLogs\n", buf.String())
+
+	buf.Reset()
+	logger.Error().Msg("Failed to compact logs after snapshot")
+	assert.Equal(t, "[ERROR] Failed to compact logs after snapshot\n", buf.String())
+}
+
+func TestNewBadgerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewBadgerLogger(testLogger(&buf, hclog.Info))
+
+	logger.Debugf("compaction %d\n", 1)
+	assert.Empty(t, buf.String())
+
+	logger.Infof("compaction %d\n", 2)
+	assert.Equal(t, "[INFO]  badger: compaction 2\n", buf.String())
+}
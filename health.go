@@ -0,0 +1,83 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// Key written and read back by Health
+	metaHealth = []byte("health")
+)
+
+// Health checks that the store can serve requests by writing a small value
+// and reading it back. It returns ErrClosed after Close, ErrReadOnly while
+// the store is degraded, and ctx's error if the round trip doesn't finish
+// before ctx is done. It is meant for liveness and readiness probes and
+// doesn't depend on the state of raft.
+func (b *BadgerRaftStore) Health(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.enter(); err != nil {
+		return err
+	}
+	if err := b.checkWritable(); err != nil {
+		b.exit()
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer b.exit()
+		done <- b.healthRoundTrip()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *BadgerRaftStore) healthRoundTrip() error {
+	key := prefixedKey(dbMeta, metaHealth)
+	want := uint64ToBytes(uint64(time.Now().UnixNano()))
+
+	txn := b.newTransaction(b.db, true)
+	defer txn.Discard()
+
+	if err := txn.Set(key, want); err != nil {
+		return storageError(err)
+	}
+	if err := b.commit(txn); err != nil {
+		return b.writeError(err)
+	}
+
+	if err := b.beforeRead(); err != nil {
+		return err
+	}
+
+	var got []byte
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		got, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return readError(err, ErrCorrupt)
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: health check read back %x, wrote %x", ErrCorrupt, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_Health(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{DegradeOnDiskFull: true})
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.Health(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, store.Health(ctx), context.Canceled)
+
+	store.writeError(syscall.ENOSPC)
+	assert.ErrorIs(t, store.Health(context.Background()), ErrReadOnly)
+
+	require.NoError(t, store.Close())
+	assert.ErrorIs(t, store.Health(context.Background()), ErrClosed)
+}
@@ -0,0 +1,36 @@
+package raftbadgerstore
+
+// Hooks holds optional callbacks invoked after mutations of the store
+// succeed, for maintaining derived caches, emitting custom metrics or
+// triggering snapshots without wrapping the store. They run synchronously
+// in the goroutine that made the change, so they must be quick and must not
+// call back into the store.
+type Hooks struct {
+	// OnStoreLogs is called with the range of logs stored by StoreLogs.
+	OnStoreLogs func(min, max uint64)
+
+	// OnDeleteRange is called with the range passed to DeleteRange, once
+	// logs in it were deleted.
+	OnDeleteRange func(min, max uint64)
+
+	// OnSet is called with every key written to the stable store.
+	OnSet func(key []byte)
+}
+
+func (h Hooks) storeLogs(min, max uint64) {
+	if h.OnStoreLogs != nil {
+		h.OnStoreLogs(min, max)
+	}
+}
+
+func (h Hooks) deleteRange(min, max uint64) {
+	if h.OnDeleteRange != nil {
+		h.OnDeleteRange(min, max)
+	}
+}
+
+func (h Hooks) set(key []byte) {
+	if h.OnSet != nil {
+		h.OnSet(key)
+	}
+}
@@ -0,0 +1,41 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_Hooks(t *testing.T) {
+	var stored, deleted [][2]uint64
+	var keys []string
+
+	store := testBadgerStoreWithOptions(t, Options{
+		Hooks: Hooks{
+			OnStoreLogs:   func(min, max uint64) { stored = append(stored, [2]uint64{min, max}) },
+			OnDeleteRange: func(min, max uint64) { deleted = append(deleted, [2]uint64{min, max}) },
+			OnSet:         func(key []byte) { keys = append(keys, string(key)) },
+		},
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(1, "log1"), testRaftLog(2, "log2"), testRaftLog(3, "log3"),
+	}))
+	require.NoError(t, store.DeleteRange(1, 2))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 1))
+
+	// Nothing is left to delete, so the hook isn't called
+	require.NoError(t, store.DeleteRange(1, 2))
+
+	// Failed mutations don't call hooks
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(10, "log10")), ErrLogGap)
+
+	assert.Equal(t, [][2]uint64{{1, 3}}, stored)
+	assert.Equal(t, [][2]uint64{{1, 2}}, deleted)
+	assert.Equal(t, []string{"CurrentTerm"}, keys)
+}
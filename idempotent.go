@@ -0,0 +1,97 @@
+package raftbadgerstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// Size of the content hash in an envelope
+	contentHashSize = 8
+)
+
+var (
+	// An error indicating a log was stored at an index that already holds
+	// a different log, see Options.IdempotentAppends
+	ErrLogConflict = errors.New("conflicting log")
+)
+
+// LogConflictError is returned by StoreLogs with IdempotentAppends set when
+// a log differs from the one already stored at its index. It matches
+// ErrLogConflict.
+type LogConflictError struct {
+	Index uint64
+}
+
+func (e *LogConflictError) Error() string {
+	return fmt.Sprintf("log %d is already stored with different content", e.Index)
+}
+
+func (e *LogConflictError) Unwrap() error {
+	return ErrLogConflict
+}
+
+// contentHash hashes what makes two logs the same: their index, term, type,
+// data and extensions. AppendedAt is left out, so a retry stamped with a
+// later time still matches.
+func contentHash(l *raft.Log) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, n := range []uint64{l.Index, l.Term, uint64(l.Type), uint64(len(l.Data))} {
+		binary.BigEndian.PutUint64(buf[:], n)
+		h.Write(buf[:])
+	}
+	h.Write(l.Data)
+	binary.BigEndian.PutUint64(buf[:], uint64(len(l.Extensions)))
+	h.Write(buf[:])
+	h.Write(l.Extensions)
+	return h.Sum64()
+}
+
+// storedContentHash returns the content hash of a stored log value, from
+// its envelope or, for logs stored without one, by decoding it.
+func storedContentHash(val []byte) (uint64, error) {
+	if storedEncoding(val).hash {
+		if len(val) < envelopeHeaderSize+contentHashSize {
+			return 0, ErrInvalidEnvelope
+		}
+		return binary.BigEndian.Uint64(val[envelopeHeaderSize:]), nil
+	}
+
+	var l raft.Log
+	if err := decodeLog(val, &l); err != nil {
+		return 0, err
+	}
+	return contentHash(&l), nil
+}
+
+// storedDuplicate reports whether the log at key is already stored with the
+// same content as l, failing with a LogConflictError if it is stored with
+// different content.
+func storedDuplicate(txn *badger.Txn, key []byte, l *raft.Log) (bool, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, storageError(err)
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, storageError(err)
+	}
+
+	stored, err := storedContentHash(val)
+	if err != nil {
+		return false, fmt.Errorf("%w: log %d: %w", ErrCorrupt, l.Index, err)
+	}
+	if stored != contentHash(l) {
+		return false, &LogConflictError{Index: l.Index}
+	}
+	return true, nil
+}
@@ -0,0 +1,105 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentHash(t *testing.T) {
+	l := &raft.Log{Index: 1, Term: 2, Type: raft.LogCommand, Data: []byte("ab"), Extensions: []byte("c")}
+	same := *l
+	same.AppendedAt = time.Now()
+	assert.Equal(t, contentHash(l), contentHash(&same))
+
+	for _, other := range []raft.Log{
+		{Index: 2, Term: 2, Type: raft.LogCommand, Data: []byte("ab"), Extensions: []byte("c")},
+		{Index: 1, Term: 3, Type: raft.LogCommand, Data: []byte("ab"), Extensions: []byte("c")},
+		{Index: 1, Term: 2, Type: raft.LogNoop, Data: []byte("ab"), Extensions: []byte("c")},
+		// Moving bytes between data and extensions changes the hash
+		{Index: 1, Term: 2, Type: raft.LogCommand, Data: []byte("a"), Extensions: []byte("bc")},
+	} {
+		assert.NotEqual(t, contentHash(l), contentHash(&other))
+	}
+}
+
+func TestBadgerStore_IdempotentAppends(t *testing.T) {
+	for _, opts := range []Options{
+		{IdempotentAppends: true},
+		{IdempotentAppends: true, Checksums: true, Codec: CodecBinary},
+	} {
+		store := testBadgerStoreWithOptions(t, opts)
+		defer store.Close()
+		defer os.Remove(store.path)
+
+		first := time.Now().Add(-time.Hour)
+		logs := []*raft.Log{
+			{Index: 1, Term: 1, Data: []byte("log1"), AppendedAt: first},
+			{Index: 2, Term: 1, Data: []byte("log2"), AppendedAt: first},
+		}
+		require.NoError(t, store.StoreLogs(logs))
+
+		// A retry, partly overlapping what was stored, is a no-op for the
+		// logs already there
+		retry := []*raft.Log{
+			{Index: 2, Term: 1, Data: []byte("log2"), AppendedAt: time.Now()},
+			{Index: 3, Term: 1, Data: []byte("log3"), AppendedAt: time.Now()},
+		}
+		require.NoError(t, store.StoreLogs(retry))
+
+		var l raft.Log
+		require.NoError(t, store.GetLog(2, &l))
+		assert.Equal(t, first.UnixNano(), l.AppendedAt.UnixNano())
+		require.NoError(t, store.GetLog(3, &l))
+		assert.Equal(t, "log3", string(l.Data))
+
+		// Different content at an existing index fails the whole batch
+		err := store.StoreLogs([]*raft.Log{
+			{Index: 3, Term: 1, Data: []byte("log3")},
+			{Index: 4, Term: 1, Data: []byte("log4")},
+		})
+		require.NoError(t, err)
+		err = store.StoreLogs([]*raft.Log{
+			{Index: 4, Term: 2, Data: []byte("other")},
+			{Index: 5, Term: 2, Data: []byte("log5")},
+		})
+		assert.ErrorIs(t, err, ErrLogConflict)
+		var conflict *LogConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, uint64(4), conflict.Index)
+
+		require.NoError(t, store.GetLog(4, &l))
+		assert.Equal(t, "log4", string(l.Data))
+		last, err := store.LastIndex()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(4), last)
+
+		// Deleted logs can be replaced
+		require.NoError(t, store.DeleteRange(4, 4))
+		require.NoError(t, store.StoreLog(&raft.Log{Index: 4, Term: 2, Data: []byte("other")}))
+		require.NoError(t, store.GetLog(4, &l))
+		assert.Equal(t, "other", string(l.Data))
+
+		report, err := store.VerifyConsistency()
+		require.NoError(t, err)
+		assert.True(t, report.OK())
+	}
+}
+
+func TestBadgerStore_IdempotentAppends_Unhashed(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Logs stored before hashes were enabled are compared by content
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	store.idempotentAppends = true
+
+	require.NoError(t, store.StoreLog(testRaftLog(2, "log2")))
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(2, "other")), ErrLogConflict)
+	assert.Equal(t, uint64(1), store.Stats().ErrorsByCategory["log_conflict"])
+}
@@ -0,0 +1,49 @@
+package raftbadgerstore
+
+import (
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// How many values range reads prefetch unless Options.RangePrefetchSize
+	// says otherwise
+	defaultRangePrefetchSize = 100
+)
+
+// boundaryLogIndex returns the first log index in Badger, or the last one
+// if last is set, or 0 if there are no logs. It reads a single key: the
+// iterator fetches no values and, restricted to the logs prefix, skips
+// tables that hold none.
+func boundaryLogIndex(txn *badger.Txn, last bool) uint64 {
+	if last {
+		return seekLogIndex(txn, lastLogKey, true)
+	}
+	return seekLogIndex(txn, dbLogs, false)
+}
+
+// seekLogIndex returns the index of the first log at or after key, or at or
+// before it if reverse is set, or 0 if there is none.
+func seekLogIndex(txn *badger.Txn, key []byte, reverse bool) uint64 {
+	opts := badger.IteratorOptions{
+		PrefetchSize: 1,
+		Reverse:      reverse,
+		Prefix:       dbLogs,
+	}
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(key)
+	if !it.ValidForPrefix(dbLogs) {
+		return 0
+	}
+	return logIndex(it.Item().Key())
+}
+
+// rangeIteratorOptions returns the options for iterators reading logs in
+// order, prefetching values as Options.RangePrefetchSize says.
+func (b *BadgerRaftStore) rangeIteratorOptions() badger.IteratorOptions {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = b.rangePrefetchSize
+	opts.Prefix = dbLogs
+	return opts
+}
@@ -0,0 +1,54 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundaryLogIndex(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	txn := store.db.NewTransaction(false)
+	assert.Zero(t, boundaryLogIndex(txn, false))
+	assert.Zero(t, boundaryLogIndex(txn, true))
+	txn.Discard()
+
+	storeTestLogs(t, store, 5, 20)
+
+	// Keys sorting right around the logs keyspace must not be taken for logs
+	err := store.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte("log"), []byte("x")); err != nil {
+			return err
+		}
+		return txn.Set([]byte("logt"), []byte("x"))
+	})
+	require.NoError(t, err)
+
+	txn = store.db.NewTransaction(false)
+	defer txn.Discard()
+	assert.Equal(t, uint64(5), boundaryLogIndex(txn, false))
+	assert.Equal(t, uint64(20), boundaryLogIndex(txn, true))
+}
+
+func TestBadgerStore_RangePrefetchSize(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+	assert.Equal(t, defaultRangePrefetchSize, store.rangeIteratorOptions().PrefetchSize)
+
+	store = testBadgerStoreWithOptions(t, Options{RangePrefetchSize: 8})
+	defer store.Close()
+	defer os.Remove(store.path)
+	assert.Equal(t, 8, store.rangeIteratorOptions().PrefetchSize)
+
+	storeTestLogs(t, store, 1, 100)
+	logs, err := store.GetLogs(1, 100)
+	require.NoError(t, err)
+	assert.Len(t, logs, 100)
+}
@@ -0,0 +1,101 @@
+package raftbadgerstore
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/hashicorp/raft"
+)
+
+// keyLayoutVersion identifies the layout of the keys below. Every key starts
+// with the name of its keyspace, such as "logs" or "conf", directly followed
+// by the key within it. Logs are keyed by their index as 8 big-endian bytes,
+// so they sort by index. Version 1 is the layout stores have always been
+// written with, so existing stores need no migration.
+const keyLayoutVersion = 1
+
+const (
+	// Size of an index within a log key
+	logIndexSize = 8
+
+	// Size of a log key
+	logKeySize = len(logsKeyspace) + logIndexSize
+)
+
+// prefixedKey returns key within the keyspace prefix. The result is always
+// a new slice, so keys never share memory with the prefix or each other.
+func prefixedKey(prefix, key []byte) []byte {
+	buf := make([]byte, len(prefix)+len(key))
+	copy(buf, prefix)
+	copy(buf[len(prefix):], key)
+	return buf
+}
+
+// lastLogKey is the key of the largest possible index, which reverse
+// iterations over the logs seek to.
+var lastLogKey = logKey(math.MaxUint64)
+
+// logKey returns the key of the log at idx.
+func logKey(idx uint64) []byte {
+	return appendLogKey(make([]byte, 0, logKeySize), idx)
+}
+
+// appendLogKey appends the key of the log at idx to dst.
+func appendLogKey(dst []byte, idx uint64) []byte {
+	dst = append(dst, dbLogs...)
+	return binary.BigEndian.AppendUint64(dst, idx)
+}
+
+// logKeys returns the keys of the given logs, carved out of a single
+// allocation. Each key is capped at its own length, so appending to one
+// can't overwrite the next.
+func logKeys(logs []*raft.Log) [][]byte {
+	buf := make([]byte, 0, len(logs)*logKeySize)
+	keys := make([][]byte, len(logs))
+	for i, l := range logs {
+		start := len(buf)
+		buf = appendLogKey(buf, l.Index)
+		keys[i] = buf[start:len(buf):len(buf)]
+	}
+	return keys
+}
+
+// logIndex returns the index of the log stored under key.
+func logIndex(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[len(dbLogs):])
+}
+
+// keyBuf is a reusable buffer for the keys of lookups. Badger only holds on
+// to the key passed to Txn.Get while the returned item is in use, so reads
+// can build their keys in a pooled buffer instead of allocating one each
+// time. Writes can't: a transaction keeps the keys it sets until it commits.
+type keyBuf struct {
+	b []byte
+}
+
+var keyBufs = sync.Pool{
+	New: func() any { return &keyBuf{b: make([]byte, 0, 64)} },
+}
+
+// getKeyBuf returns a buffer from the pool. It must be released once the
+// key built in it, and any item looked up with it, are no longer used.
+func getKeyBuf() *keyBuf {
+	return keyBufs.Get().(*keyBuf)
+}
+
+func (k *keyBuf) release() {
+	keyBufs.Put(k)
+}
+
+// logKey builds the key of the log at idx in the buffer.
+func (k *keyBuf) logKey(idx uint64) []byte {
+	k.b = appendLogKey(k.b[:0], idx)
+	return k.b
+}
+
+// prefixedKey builds key within the keyspace prefix in the buffer.
+func (k *keyBuf) prefixedKey(prefix, key []byte) []byte {
+	k.b = append(append(k.b[:0], prefix...), key...)
+	return k.b
+}
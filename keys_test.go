@@ -0,0 +1,55 @@
+package raftbadgerstore
+
+import (
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogKey(t *testing.T) {
+	// The layout stores have always been written with
+	key := logKey(0x0102030405060708)
+	assert.Equal(t, []byte("logs\x01\x02\x03\x04\x05\x06\x07\x08"), key)
+	assert.Equal(t, uint64(0x0102030405060708), logIndex(key))
+
+	// Keys sort by index
+	assert.Less(t, string(logKey(255)), string(logKey(256)))
+}
+
+func TestLogKeys(t *testing.T) {
+	keys := logKeys([]*raft.Log{{Index: 1}, {Index: 2}, {Index: 3}})
+	for i, key := range keys {
+		assert.Equal(t, logKey(uint64(i+1)), key)
+	}
+
+	// Appending to a key doesn't overwrite the next one
+	_ = append(keys[0], 0xff)
+	assert.Equal(t, logKey(2), keys[1])
+}
+
+func TestPrefixedKey(t *testing.T) {
+	a := prefixedKey(dbConf, []byte("a"))
+	b := prefixedKey(dbConf, []byte("b"))
+	assert.Equal(t, []byte("conf"+"a"), a)
+	assert.Equal(t, []byte("conf"+"b"), b)
+
+	// Keys share no memory with the prefix or each other
+	a[len(a)-1] = 'x'
+	assert.Equal(t, []byte("conf"+"b"), b)
+	assert.Equal(t, []byte("conf"), dbConf)
+}
+
+func TestKeyBuf(t *testing.T) {
+	kb := getKeyBuf()
+	defer kb.release()
+
+	assert.Equal(t, logKey(7), kb.logKey(7))
+	assert.Equal(t, prefixedKey(dbConf, []byte("a")), kb.prefixedKey(dbConf, []byte("a")))
+
+	// Building a key reuses the buffer
+	key := kb.logKey(1)
+	kb.logKey(2)
+	assert.Equal(t, logKey(2), key)
+	assert.Equal(t, []byte("conf"), dbConf)
+}
@@ -0,0 +1,74 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// keyspaces are the key prefixes the store writes, by name.
+var keyspaces = [][]byte{
+	dbLogs, dbConf, dbMeta, dbDict, dbCold,
+	dbSnapMeta, dbSnapData, dbSnapManifest,
+	dbSegments, dbShard,
+}
+
+// KeyspaceSize describes the keys of a keyspace.
+type KeyspaceSize struct {
+	Keys uint64 `json:"keys"`
+
+	// Estimated size of the keys and their values, wherever they are
+	// stored, in bytes.
+	Bytes int64 `json:"bytes"`
+}
+
+// KeyspaceSizes returns the number of keys in each keyspace of the store,
+// such as "logs" and "conf", and their estimated size. Keys of no known
+// keyspace, such as those written by other users of a shared database, are
+// counted as "other", and keyspaces without keys are left out. It scans
+// every key, but reads no values.
+func (b *BadgerRaftStore) KeyspaceSizes() (map[string]KeyspaceSize, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	sizes := make(map[string]KeyspaceSize)
+	dbs := []*badger.DB{b.db}
+	if b.stableDB != b.db {
+		dbs = append(dbs, b.stableDB)
+	}
+	for _, db := range dbs {
+		addKeyspaceSizes(b.newTransaction(db, false), sizes)
+	}
+	return sizes, nil
+}
+
+// addKeyspaceSizes adds the keys seen by txn to sizes and discards txn.
+func addKeyspaceSizes(txn *badger.Txn, sizes map[string]KeyspaceSize) {
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		name := keyspaceName(item.Key())
+		size := sizes[name]
+		size.Keys++
+		size.Bytes += item.EstimatedSize()
+		sizes[name] = size
+	}
+}
+
+// keyspaceName returns the name of the keyspace key belongs to.
+func keyspaceName(key []byte) string {
+	for _, prefix := range keyspaces {
+		if bytes.HasPrefix(key, prefix) {
+			return string(prefix)
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,32 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_KeyspaceSizes(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 10)
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 3))
+	err := store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("app/key"), []byte("value"))
+	})
+	require.NoError(t, err)
+
+	sizes, err := store.KeyspaceSizes()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), sizes["logs"].Keys)
+	assert.Positive(t, sizes["logs"].Bytes)
+	assert.Equal(t, uint64(1), sizes["conf"].Keys)
+	assert.Equal(t, uint64(1), sizes["other"].Keys)
+	assert.NotZero(t, sizes["meta"].Keys)
+	assert.NotContains(t, sizes, "snapdata")
+}
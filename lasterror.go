@@ -0,0 +1,128 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+)
+
+var (
+	metricErrors = []string{"raft", "badgerstore", "errors"}
+)
+
+// errorCategory is the kind of a failed operation's error.
+type errorCategory int
+
+const (
+	categoryDiskFull errorCategory = iota
+	categoryTimeout
+	categoryClosed
+	categoryReadOnly
+	categoryCorrupt
+	categoryTooLarge
+	categoryLogGap
+	categoryRetainIndex
+	categoryBackpressure
+	categoryLogConflict
+	categoryOverwrite
+	categoryTermRegression
+	categoryRetryable
+	categoryIO
+	categoryOther
+	numErrorCategories
+)
+
+// Names of the error categories in Stats and metric labels
+var errorCategoryNames = [numErrorCategories]string{
+	categoryDiskFull:       "disk_full",
+	categoryTimeout:        "timeout",
+	categoryClosed:         "closed",
+	categoryReadOnly:       "read_only",
+	categoryCorrupt:        "corrupt",
+	categoryTooLarge:       "too_large",
+	categoryLogGap:         "log_gap",
+	categoryRetainIndex:    "retain_index",
+	categoryBackpressure:   "backpressure",
+	categoryLogConflict:    "log_conflict",
+	categoryOverwrite:      "overwrite",
+	categoryTermRegression: "term_regression",
+	categoryRetryable:      "retryable",
+	categoryIO:             "io",
+	categoryOther:          "other",
+}
+
+// categorize returns the category of err. Errors matching several package
+// errors, such as ErrDiskFull which is also ErrRetryable, get the most
+// specific one.
+func categorize(err error) errorCategory {
+	for _, c := range []struct {
+		target   error
+		category errorCategory
+	}{
+		{ErrDiskFull, categoryDiskFull},
+		{ErrTimeout, categoryTimeout},
+		{ErrClosed, categoryClosed},
+		{ErrReadOnly, categoryReadOnly},
+		{ErrCorrupt, categoryCorrupt},
+		{ErrTooLarge, categoryTooLarge},
+		{ErrLogGap, categoryLogGap},
+		{ErrRetainIndex, categoryRetainIndex},
+		{ErrBackpressure, categoryBackpressure},
+		{ErrLogConflict, categoryLogConflict},
+		{ErrOverwrite, categoryOverwrite},
+		{ErrTermRegression, categoryTermRegression},
+		{ErrRetryable, categoryRetryable},
+		{ErrIO, categoryIO},
+	} {
+		if errors.Is(err, c.target) {
+			return c.category
+		}
+	}
+	return categoryOther
+}
+
+// errorTracker remembers the most recent failure and counts failures by
+// category.
+type errorTracker struct {
+	mu   sync.Mutex
+	at   time.Time
+	last error
+
+	counts [numErrorCategories]atomic.Uint64
+}
+
+// record counts err and remembers it as the most recent failure.
+func (t *errorTracker) record(err error) {
+	category := categorize(err)
+	t.counts[category].Add(1)
+	metrics.IncrCounterWithLabels(metricErrors, 1, []metrics.Label{{Name: "category", Value: errorCategoryNames[category]}})
+
+	t.mu.Lock()
+	t.at, t.last = time.Now(), err
+	t.mu.Unlock()
+}
+
+// byCategory returns the non-zero failure counts keyed by category name.
+func (t *errorTracker) byCategory() map[string]uint64 {
+	counts := make(map[string]uint64)
+	for category := range numErrorCategories {
+		if n := t.counts[category].Load(); n > 0 {
+			counts[errorCategoryNames[category]] = n
+		}
+	}
+	return counts
+}
+
+// LastError returns the most recent error a store operation failed with
+// and when it happened, or a zero time and nil if none has failed since the
+// store was opened. Like Stats.Errors it ignores lookups of missing logs
+// and keys. Monitoring can use it to alert on a store that fails
+// intermittently even though raft recovers from each failure.
+func (b *BadgerRaftStore) LastError() (time.Time, error) {
+	b.errors.mu.Lock()
+	defer b.errors.mu.Unlock()
+	return b.errors.at, b.errors.last
+}
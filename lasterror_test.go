@@ -0,0 +1,46 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_LastError(t *testing.T) {
+	fp := NewFailpoints()
+	store := testBadgerStoreWithOptions(t, Options{Failpoints: fp})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	at, err := store.LastError()
+	assert.True(t, at.IsZero())
+	assert.NoError(t, err)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(5, "log5")), ErrLogGap)
+
+	at, err = store.LastError()
+	assert.ErrorIs(t, err, ErrLogGap)
+	assert.WithinDuration(t, time.Now(), at, time.Second)
+
+	fp.CorruptReads(1)
+	assert.ErrorIs(t, store.GetLog(1, new(raft.Log)), ErrCorrupt)
+
+	// Missing logs are not failures
+	assert.ErrorIs(t, store.GetLog(10, new(raft.Log)), raft.ErrLogNotFound)
+
+	_, err = store.LastError()
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.Equal(t, map[string]uint64{"log_gap": 1, "corrupt": 1}, store.Stats().ErrorsByCategory)
+}
+
+func TestCategorize(t *testing.T) {
+	assert.Equal(t, categoryDiskFull, categorize(storageError(syscall.ENOSPC)))
+	assert.Equal(t, categoryRetryable, categorize(ErrRetryable))
+	assert.Equal(t, categoryOther, categorize(os.ErrPermission))
+}
@@ -0,0 +1,52 @@
+package raftbadgerstore
+
+import (
+	"github.com/hashicorp/raft"
+)
+
+// LastLogEntry returns the last log, or raft.ErrLogNotFound if there are
+// none. It reads the log in the same transaction that finds it, so it can't
+// miss a log that was deleted or appended concurrently, as LastIndex
+// followed by GetLog could.
+func (b *BadgerRaftStore) LastLogEntry() (*raft.Log, error) {
+	o := op{name: "LastLogEntry"}
+
+	var result *raft.Log
+	err := b.do(o, func() error {
+		log, err := b.lastLogEntry()
+		if err == nil {
+			result = log
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *BadgerRaftStore) lastLogEntry() (*raft.Log, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	if err := b.beforeRead(); err != nil {
+		return nil, err
+	}
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	idx := boundaryLogIndex(txn, true)
+	if idx == 0 {
+		return nil, raft.ErrLogNotFound
+	}
+	log := &raft.Log{}
+	if err := readLog(txn, idx, log); err != nil {
+		return nil, err
+	}
+
+	b.stats.reads.Add(1)
+	return log, nil
+}
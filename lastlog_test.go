@@ -0,0 +1,87 @@
+package raftbadgerstore
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_LastLogEntry(t *testing.T) {
+	cases := []struct {
+		name    string
+		indexes []uint64
+	}{
+		{"single", []uint64{1}},
+		{"several", []uint64{1, 2, 3}},
+		// Indexes whose key continues with 0xFF sort after End(dbLogs)
+		{"below 0xFF byte", []uint64{1, 0xFEFFFFFFFFFFFFFF}},
+		{"0xFF byte", []uint64{1, 0xFF00000000000000}},
+		{"max index", []uint64{1, math.MaxUint64 - 1, math.MaxUint64}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := testBadgerStoreWithOptions(t, Options{AllowLogGaps: true})
+			defer store.Close()
+			defer os.Remove(store.path)
+
+			for _, idx := range c.indexes {
+				require.NoError(t, store.StoreLog(testRaftLog(idx, "log")))
+			}
+			last := c.indexes[len(c.indexes)-1]
+
+			log, err := store.LastLogEntry()
+			require.NoError(t, err)
+			assert.Equal(t, last, log.Index)
+			assert.Equal(t, "log", string(log.Data))
+
+			idx, err := store.LastIndex()
+			require.NoError(t, err)
+			assert.Equal(t, last, idx)
+			idx, err = store.FirstIndex()
+			require.NoError(t, err)
+			assert.Equal(t, c.indexes[0], idx)
+		})
+	}
+}
+
+func TestBadgerStore_LastLogEntry_Empty(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	_, err := store.LastLogEntry()
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+
+	// Keys sorting after the logs keyspace are not logs
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("logt"), []byte("x"))
+	})
+	require.NoError(t, err)
+	_, err = store.LastLogEntry()
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+
+	// Nor is anything left once the logs were deleted
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	require.NoError(t, store.DeleteRange(1, 2))
+	_, err = store.LastLogEntry()
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+}
+
+func TestBadgerStore_LastLogEntry_AfterTruncation(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 10)
+	require.NoError(t, store.DeleteRange(8, 10))
+
+	log, err := store.LastLogEntry()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), log.Index)
+	assert.Equal(t, "log7", string(log.Data))
+}
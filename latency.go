@@ -0,0 +1,77 @@
+package raftbadgerstore
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Latency is a distribution of delays injected into store operations to
+// simulate a slow disk. See Options.CommitLatency and Options.ReadLatency.
+type Latency interface {
+	// Next returns the delay to inject into the next operation.
+	Next() time.Duration
+}
+
+// FixedLatency delays every operation by d.
+func FixedLatency(d time.Duration) Latency {
+	return fixedLatency(d)
+}
+
+type fixedLatency time.Duration
+
+func (l fixedLatency) Next() time.Duration {
+	return time.Duration(l)
+}
+
+// seededLatency draws delays from a random source seeded for reproducible
+// runs.
+type seededLatency struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+	next func(r *rand.Rand) time.Duration
+}
+
+func newSeededLatency(seed uint64, next func(r *rand.Rand) time.Duration) *seededLatency {
+	return &seededLatency{rand: rand.New(rand.NewPCG(seed, seed)), next: next}
+}
+
+func (l *seededLatency) Next() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next(l.rand)
+}
+
+// UniformLatency delays operations by a duration drawn uniformly from
+// [min, max]. The same seed produces the same sequence of delays.
+func UniformLatency(min, max time.Duration, seed uint64) Latency {
+	return newSeededLatency(seed, func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int64N(int64(max-min)+1))
+	})
+}
+
+// StallLatency delays operations by base, and with probability p by stall
+// instead, reproducing the occasional fsync stalls that make followers fall
+// behind and leaders lose their lease. The same seed produces the same
+// sequence of delays.
+func StallLatency(base, stall time.Duration, p float64, seed uint64) Latency {
+	return newSeededLatency(seed, func(r *rand.Rand) time.Duration {
+		if r.Float64() < p {
+			return stall
+		}
+		return base
+	})
+}
+
+// injectLatency sleeps for the next delay of l, if any.
+func injectLatency(l Latency) {
+	if l == nil {
+		return
+	}
+	if d := l.Next(); d > 0 {
+		time.Sleep(d)
+	}
+}
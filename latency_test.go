@@ -0,0 +1,48 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatency_Deterministic(t *testing.T) {
+	a := UniformLatency(time.Millisecond, 10*time.Millisecond, 42)
+	b := UniformLatency(time.Millisecond, 10*time.Millisecond, 42)
+	for range 100 {
+		d := a.Next()
+		assert.Equal(t, d, b.Next())
+		assert.GreaterOrEqual(t, d, time.Millisecond)
+		assert.LessOrEqual(t, d, 10*time.Millisecond)
+	}
+
+	stalls := 0
+	l := StallLatency(0, time.Second, 0.1, 7)
+	for range 1000 {
+		if l.Next() == time.Second {
+			stalls++
+		}
+	}
+	assert.InDelta(t, 100, stalls, 40)
+}
+
+func TestBadgerStore_CommitLatency(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		CommitLatency: FixedLatency(20 * time.Millisecond),
+		ReadLatency:   FixedLatency(10 * time.Millisecond),
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	start := time.Now()
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	start = time.Now()
+	require.NoError(t, store.GetLog(1, new(raft.Log)))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+}
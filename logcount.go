@@ -0,0 +1,135 @@
+package raftbadgerstore
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// Key of the number of logs in the store
+	metaLogCount = []byte("log_count")
+)
+
+// LogCount returns the number of logs in the store, including those moved
+// to the cold tier. The count is kept up to date by the transactions that
+// write and delete logs, so unlike counting from the first and last index
+// it is exact when the log has gaps, and unlike scanning the logs it takes
+// a single read. Repair rebuilds it from the logs if it ever gets out of
+// sync.
+func (b *BadgerRaftStore) LogCount() (uint64, error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	count, err := loadLogCount(txn)
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return count, nil
+}
+
+// readLogCount reads the persisted log count. ok is false if the store has
+// none, because it was opened read-only since being created by an older
+// version.
+func readLogCount(txn *badger.Txn) (count uint64, ok bool, err error) {
+	item, err := txn.Get(prefixedKey(dbMeta, metaLogCount))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return bytesToUint64(val), true, nil
+}
+
+// loadLogCount returns the persisted log count, falling back to counting
+// the logs if there is none.
+func loadLogCount(txn *badger.Txn) (uint64, error) {
+	count, ok, err := readLogCount(txn)
+	if err != nil || ok {
+		return count, err
+	}
+	return countLogs(txn)
+}
+
+func writeLogCount(txn *badger.Txn, count uint64) error {
+	return txn.Set(prefixedKey(dbMeta, metaLogCount), uint64ToBytes(count))
+}
+
+// adjustLogCount adds added and subtracts removed from the persisted log
+// count. Stores without a count are left alone.
+func adjustLogCount(txn *badger.Txn, added, removed uint64) error {
+	if added == removed {
+		return nil
+	}
+	count, ok, err := readLogCount(txn)
+	if err != nil || !ok {
+		return err
+	}
+	count += added
+	count -= min(count, removed)
+	return writeLogCount(txn, count)
+}
+
+// countLogs counts the logs in Badger and in the cold tier.
+func countLogs(txn *badger.Txn) (uint64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = dbLogs
+	it := txn.NewIterator(opts)
+
+	var count uint64
+	for it.Seek(dbLogs); it.ValidForPrefix(dbLogs); it.Next() {
+		count++
+	}
+	it.Close()
+
+	it = txn.NewIterator(badger.IteratorOptions{Prefix: dbCold})
+	defer it.Close()
+	for it.Seek(dbCold); it.ValidForPrefix(dbCold); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return 0, err
+		}
+		segment, err := parseColdSegment(it.Item().Key(), val)
+		if err != nil {
+			return 0, err
+		}
+		count += segment.last - segment.first + 1
+	}
+	return count, nil
+}
+
+// rebuildLogCount counts the logs and persists the count.
+func rebuildLogCount(txn *badger.Txn) (uint64, error) {
+	count, err := countLogs(txn)
+	if err != nil {
+		return 0, err
+	}
+	return count, writeLogCount(txn, count)
+}
+
+// initLogCount counts the logs of a store that has no persisted count yet,
+// because it was created by an older version.
+func (b *BadgerRaftStore) initLogCount() error {
+	if b.db.Opts().ReadOnly {
+		return nil
+	}
+	return b.update(b.db, func(txn *badger.Txn) error {
+		_, ok, err := readLogCount(txn)
+		if err != nil || ok {
+			return err
+		}
+		_, err = rebuildLogCount(txn)
+		return err
+	})
+}
@@ -0,0 +1,87 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireLogCount(t *testing.T, store *BadgerRaftStore, want uint64) {
+	t.Helper()
+	count, err := store.LogCount()
+	require.NoError(t, err)
+	assert.Equal(t, want, count)
+}
+
+func TestBadgerStore_LogCount(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	requireLogCount(t, store, 0)
+
+	storeTestLogs(t, store, 1, 100)
+	requireLogCount(t, store, 100)
+
+	// Overwriting logs doesn't count them twice
+	storeTestLogs(t, store, 91, 110)
+	requireLogCount(t, store, 110)
+
+	require.NoError(t, store.DeleteRange(1, 30))
+	requireLogCount(t, store, 80)
+
+	require.NoError(t, store.DeleteRange(101, 110))
+	requireLogCount(t, store, 70)
+
+	report, err := store.Repair()
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+	assert.Equal(t, uint64(70), report.LogCountAfter)
+}
+
+func TestBadgerStore_LogCountWithGaps(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{AllowLogGaps: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "a"), testRaftLog(5, "b"), testRaftLog(10, "c")}))
+	requireLogCount(t, store, 3)
+
+	// Filling a gap counts, overwriting doesn't
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(3, "d"), testRaftLog(5, "e")}))
+	requireLogCount(t, store, 4)
+}
+
+func TestBadgerStore_LogCountRebuild(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 50)
+
+	// Stores created by older versions have no count until opened
+	err := store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(prefixedKey(dbMeta, metaLogCount))
+	})
+	require.NoError(t, err)
+	requireLogCount(t, store, 50)
+	require.NoError(t, store.initLogCount())
+	requireLogCount(t, store, 50)
+
+	// Repair fixes a count out of sync with the logs
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return writeLogCount(txn, 7)
+	})
+	require.NoError(t, err)
+	report, err := store.Repair()
+	require.NoError(t, err)
+	assert.True(t, report.Changed())
+	require.NotNil(t, report.LogCountBefore)
+	assert.Equal(t, uint64(7), *report.LogCountBefore)
+	assert.Equal(t, uint64(50), report.LogCountAfter)
+	requireLogCount(t, store, 50)
+}
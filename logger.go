@@ -0,0 +1,47 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog"
+)
+
+// badgerLogger implements badger.Logger on top of a zerolog logger.
+type badgerLogger struct {
+	logger zerolog.Logger
+}
+
+// NewBadgerLogger returns a badger.Logger that writes Badger's internal logs,
+// such as compactions and value log GC, to logger at the matching level, so
+// the logger's level decides which of them are kept. Open uses it with the
+// global zerolog logger unless Options.BadgerOptions is set.
+func NewBadgerLogger(logger zerolog.Logger) badger.Logger {
+	return &badgerLogger{logger: logger.With().Str("component", "badger").Logger()}
+}
+
+func (l *badgerLogger) Errorf(format string, args ...interface{}) {
+	l.log(l.logger.Error(), format, args)
+}
+
+func (l *badgerLogger) Warningf(format string, args ...interface{}) {
+	l.log(l.logger.Warn(), format, args)
+}
+
+func (l *badgerLogger) Infof(format string, args ...interface{}) {
+	l.log(l.logger.Info(), format, args)
+}
+
+func (l *badgerLogger) Debugf(format string, args ...interface{}) {
+	l.log(l.logger.Debug(), format, args)
+}
+
+func (l *badgerLogger) log(event *zerolog.Event, format string, args []interface{}) {
+	// Skip formatting messages the level filters out anyway
+	if event == nil {
+		return
+	}
+	// Badger terminates most of its messages with a newline
+	event.Msg(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,28 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBadgerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewBadgerLogger(zerolog.New(&buf).Level(zerolog.InfoLevel))
+
+	logger.Debugf("compaction %d\n", 1)
+	assert.Empty(t, buf.String())
+
+	logger.Infof("compaction %d\n", 2)
+	assert.Equal(t, `{"level":"info","component":"badger","message":"compaction 2"}`+"\n", buf.String())
+
+	buf.Reset()
+	logger.Warningf("slow")
+	assert.Contains(t, buf.String(), `"level":"warn"`)
+
+	buf.Reset()
+	logger.Errorf("failed")
+	assert.Contains(t, buf.String(), `"level":"error"`)
+}
@@ -0,0 +1,174 @@
+package raftbadgerstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/raft"
+)
+
+var (
+	// Header every log stream starts with, followed by its format version
+	logStreamMagic = []byte("RBSLOGS")
+
+	// An error indicating a log stream can't be read
+	ErrInvalidLogStream = errors.New("invalid log stream")
+)
+
+const logStreamVersion = 1
+
+// StreamLogs writes the logs from fromIndex up to the last index to w, and
+// returns the index of the last log it wrote. Logs are read at a single
+// version, so raft can keep writing while they are streamed.
+//
+// The stream starts with a header, followed by a frame per log holding a
+// big endian uint32 length and the log as it is stored, and ends with an
+// empty frame, so truncated streams are detected. Logs compressed with a
+// trained dictionary are recompressed without it, so any store can read
+// the stream. ApplyLogStream stores the
+// logs of a stream in another store, for example to seed the log of a new
+// follower faster than raft replicates it.
+//
+// It fails with raft.ErrLogNotFound if the log at fromIndex doesn't exist,
+// and with ErrLogGap if the logs aren't contiguous.
+func (b *BadgerRaftStore) StreamLogs(fromIndex uint64, w io.Writer) (last uint64, err error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(b.rangeIteratorOptions())
+	defer it.Close()
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(logStreamMagic); err != nil {
+		return 0, err
+	}
+	if err := bw.WriteByte(logStreamVersion); err != nil {
+		return 0, err
+	}
+
+	next := fromIndex
+	var size [4]byte
+	for it.Seek(logKey(fromIndex)); it.ValidForPrefix(dbLogs); it.Next() {
+		item := it.Item()
+		idx := logIndex(item.Key())
+		if idx != next {
+			if next == fromIndex {
+				break
+			}
+			return 0, fmt.Errorf("%w: log %d follows log %d", ErrLogGap, idx, next-1)
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return 0, storageError(err)
+		}
+		if val, err = portableLogValue(val); err != nil {
+			return 0, fmt.Errorf("%w: log %d: %w", ErrCorrupt, idx, err)
+		}
+		binary.BigEndian.PutUint32(size[:], uint32(len(val)))
+		if _, err := bw.Write(size[:]); err != nil {
+			return 0, err
+		}
+		if _, err := bw.Write(val); err != nil {
+			return 0, err
+		}
+		next++
+	}
+	if next == fromIndex {
+		return 0, fmt.Errorf("%w: log %d", raft.ErrLogNotFound, fromIndex)
+	}
+
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := bw.Write(size[:]); err != nil {
+		return 0, err
+	}
+	return next - 1, bw.Flush()
+}
+
+// ApplyLogStream stores the logs of a stream written by StreamLogs, and
+// returns the index of the last log it stored. Unless the store is empty,
+// the stream must start right after its last index. Logs are decoded,
+// checked and stored in batches as they are read, so a stream that fails
+// part way leaves a contiguous prefix of it behind.
+func (b *BadgerRaftStore) ApplyLogStream(r io.Reader) (last uint64, err error) {
+	last, err = b.LastIndex()
+	if err != nil {
+		return 0, err
+	}
+	next := last + 1
+	if last == 0 {
+		next = 0
+	}
+
+	br := bufio.NewReader(r)
+	header := make([]byte, len(logStreamMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 0, fmt.Errorf("%w: reading header: %w", ErrInvalidLogStream, err)
+	}
+	if !bytes.Equal(header[:len(logStreamMagic)], logStreamMagic) {
+		return 0, fmt.Errorf("%w: bad header", ErrInvalidLogStream)
+	}
+	if v := header[len(logStreamMagic)]; v != logStreamVersion {
+		return 0, fmt.Errorf("%w: unsupported version %d", ErrInvalidLogStream, v)
+	}
+
+	batch := make([]*raft.Log, 0, importBatchSize)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			return last, fmt.Errorf("%w: %w", ErrInvalidLogStream, noEOF(err))
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n == 0 {
+			break
+		}
+
+		val := make([]byte, n)
+		if _, err := io.ReadFull(br, val); err != nil {
+			return last, fmt.Errorf("%w: %w", ErrInvalidLogStream, noEOF(err))
+		}
+		entry := new(raft.Log)
+		if err := decodeLog(val, entry); err != nil {
+			return last, fmt.Errorf("%w: log after %d: %w", ErrCorrupt, last, err)
+		}
+		if next > 0 && entry.Index != next {
+			return last, fmt.Errorf("%w: log %d is not contiguous, expected index %d", ErrLogGap, entry.Index, next)
+		}
+		next = entry.Index + 1
+
+		batch = append(batch, entry)
+		if len(batch) == importBatchSize {
+			if err := b.StoreLogs(batch); err != nil {
+				return last, err
+			}
+			last = batch[len(batch)-1].Index
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := b.StoreLogs(batch); err != nil {
+			return last, err
+		}
+		last = batch[len(batch)-1].Index
+	}
+	return last, nil
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF, for streams that end before
+// their end marker.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
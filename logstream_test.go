@@ -0,0 +1,95 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStoreRange(t *testing.T, store *BadgerRaftStore, first, last uint64) {
+	var logs []*raft.Log
+	for idx := first; idx <= last; idx++ {
+		logs = append(logs, testRaftLog(idx, fmt.Sprintf("log%d", idx)))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+}
+
+func TestBadgerStore_StreamLogs(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{Checksums: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+	testStoreRange(t, store, 1, 2500)
+
+	var buf bytes.Buffer
+	last, err := store.StreamLogs(1001, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2500), last)
+
+	// A new follower is seeded with the tail of the log
+	follower := testBadgerStore(t)
+	defer follower.Close()
+	defer os.Remove(follower.path)
+	last, err = follower.ApplyLogStream(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2500), last)
+
+	first, err := follower.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1001), first)
+	var l raft.Log
+	require.NoError(t, follower.GetLog(1500, &l))
+	assert.Equal(t, "log1500", string(l.Data))
+
+	// And caught up again later
+	testStoreRange(t, store, 2501, 2600)
+	buf.Reset()
+	_, err = store.StreamLogs(2501, &buf)
+	require.NoError(t, err)
+	last, err = follower.ApplyLogStream(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2600), last)
+}
+
+func TestBadgerStore_StreamLogs_Errors(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+	testStoreRange(t, store, 10, 20)
+
+	_, err := store.StreamLogs(5, io.Discard)
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+	_, err = store.StreamLogs(21, io.Discard)
+	assert.ErrorIs(t, err, raft.ErrLogNotFound)
+
+	var buf bytes.Buffer
+	_, err = store.StreamLogs(15, &buf)
+	require.NoError(t, err)
+	stream := buf.Bytes()
+
+	// The follower must end right before the stream
+	follower := testBadgerStore(t)
+	defer follower.Close()
+	defer os.Remove(follower.path)
+	testStoreRange(t, follower, 1, 10)
+	_, err = follower.ApplyLogStream(bytes.NewReader(stream))
+	assert.ErrorIs(t, err, ErrLogGap)
+
+	empty := testBadgerStore(t)
+	defer empty.Close()
+	defer os.Remove(empty.path)
+
+	// Truncated streams store what they hold, then fail
+	last, err := empty.ApplyLogStream(bytes.NewReader(stream[:len(stream)-10]))
+	assert.ErrorIs(t, err, ErrInvalidLogStream)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, uint64(0), last)
+
+	_, err = empty.ApplyLogStream(bytes.NewReader([]byte("not a stream")))
+	assert.ErrorIs(t, err, ErrInvalidLogStream)
+}
@@ -0,0 +1,53 @@
+package raftbadgerstore
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Bounds of the memtable size derived from a memory budget. Badger
+	// needs room in a memtable for a whole transaction batch, and gains
+	// nothing from memtables larger than its default.
+	minBudgetMemTableSize = 1 << 20
+	maxBudgetMemTableSize = 64 << 20
+
+	// Budgets below this get fewer memtables and compactors
+	smallMemoryBudget = 256 << 20
+)
+
+// applyMemoryBudget derives the sizes of Badger's memtables and caches from
+// budget bytes: about 40% for the memtables, 30% for the block cache and
+// 15% for the index cache, leaving the rest for compaction and iterators.
+// Small budgets also get fewer memtables and compactors. ValueThreshold is
+// lowered if it no longer fits in a transaction batch.
+func applyMemoryBudget(opts badger.Options, budget int64) badger.Options {
+	if budget < smallMemoryBudget {
+		opts.NumMemtables = 2
+		opts.NumCompactors = 2
+	} else {
+		opts.NumMemtables = 5
+		opts.NumCompactors = 4
+	}
+
+	// Every memtable's arena also holds up to a batch, 15% of its size, on
+	// top of the memtable itself
+	memTable := budget * 40 / 100 * 100 / 115 / int64(opts.NumMemtables)
+	opts.MemTableSize = min(max(memTable, minBudgetMemTableSize), maxBudgetMemTableSize)
+
+	opts.BlockCacheSize = budget * 30 / 100
+	opts.IndexCacheSize = budget * 15 / 100
+
+	if maxBatch := opts.MemTableSize * 15 / 100; opts.ValueThreshold > maxBatch {
+		opts.ValueThreshold = maxBatch
+	}
+
+	log.Info().
+		Int64("memory_budget", budget).
+		Int("num_memtables", opts.NumMemtables).
+		Int64("memtable_size", opts.MemTableSize).
+		Int64("block_cache_size", opts.BlockCacheSize).
+		Int64("index_cache_size", opts.IndexCacheSize).
+		Msg("Derived Badger options from the memory budget")
+	return opts
+}
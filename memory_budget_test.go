@@ -0,0 +1,43 @@
+package raftbadgerstore
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMemoryBudget(t *testing.T) {
+	opts := applyMemoryBudget(badger.DefaultOptions(""), 64<<20)
+	assert.Equal(t, 2, opts.NumMemtables)
+	assert.Equal(t, 2, opts.NumCompactors)
+	assert.Less(t, int64(opts.NumMemtables)*opts.MemTableSize, int64(64<<20)*40/100)
+	assert.Equal(t, int64(64<<20)*30/100, opts.BlockCacheSize)
+	assert.Equal(t, int64(64<<20)*15/100, opts.IndexCacheSize)
+	assert.LessOrEqual(t, opts.ValueThreshold, opts.MemTableSize*15/100)
+
+	// Memtables stay within Badger's limits
+	opts = applyMemoryBudget(badger.DefaultOptions(""), 1<<20)
+	assert.Equal(t, int64(minBudgetMemTableSize), opts.MemTableSize)
+
+	opts = applyMemoryBudget(badger.DefaultOptions(""), 8<<30)
+	assert.Equal(t, 5, opts.NumMemtables)
+	assert.Equal(t, int64(maxBudgetMemTableSize), opts.MemTableSize)
+	assert.Equal(t, badger.DefaultOptions("").ValueThreshold, opts.ValueThreshold)
+}
+
+func TestOpen_MemoryBudget(t *testing.T) {
+	store, err := Open(t.TempDir(), Options{MemoryBudget: 32 << 20})
+	require.NoError(t, err)
+	defer store.Close()
+
+	assert.Equal(t, 2, store.db.Opts().NumMemtables)
+	assert.Equal(t, int64(32<<20)*30/100, store.db.Opts().BlockCacheSize)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+	var log raft.Log
+	require.NoError(t, store.GetLog(1, &log))
+	assert.Equal(t, "log1", string(log.Data))
+}
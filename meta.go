@@ -0,0 +1,175 @@
+package raftbadgerstore
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// Keys of the metadata derived from the logs keyspace
+	metaFirstIndex = []byte("first_index")
+	metaLastIndex  = []byte("last_index")
+)
+
+// LogMetadata is the metadata derived from the logs keyspace. The store keeps it
+// up to date in the same transactions that write and delete logs, and Repair
+// rebuilds it from the logs if it ever gets out of sync.
+type LogMetadata struct {
+	FirstIndex uint64 `json:"first_index"`
+	LastIndex  uint64 `json:"last_index"`
+}
+
+// extend updates the metadata after logs min through max were stored.
+func (m *LogMetadata) extend(min, max uint64) {
+	if m.FirstIndex == 0 || min < m.FirstIndex {
+		m.FirstIndex = min
+	}
+	if max > m.LastIndex {
+		m.LastIndex = max
+	}
+}
+
+// afterDelete returns the metadata after the logs between first and last
+// inclusively were deleted in txn by a DeleteRange call starting at min.
+// Unlike scanLogMeta it only seeks next to the deleted logs, so it doesn't
+// walk the tombstones left by deleting the head of a long log.
+func (m LogMetadata) afterDelete(txn *badger.Txn, min, first, last uint64) LogMetadata {
+	switch {
+	case first <= m.FirstIndex:
+		next := seekLogIndex(txn, logKey(last+1), false)
+		if next == 0 {
+			return LogMetadata{}
+		}
+		m.FirstIndex = next
+	case last >= m.LastIndex:
+		m.LastIndex = 0
+		if min > 0 {
+			m.LastIndex = seekLogIndex(txn, logKey(min-1), true)
+		}
+	}
+	return m
+}
+
+// readLogMeta reads the persisted metadata. ok is false if the store has no
+// metadata yet, for example because it was created by an older version.
+func readLogMeta(txn *badger.Txn) (meta LogMetadata, ok bool, err error) {
+	for _, field := range []struct {
+		key []byte
+		val *uint64
+	}{
+		{metaFirstIndex, &meta.FirstIndex},
+		{metaLastIndex, &meta.LastIndex},
+	} {
+		item, err := txn.Get(prefixedKey(dbMeta, field.key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return LogMetadata{}, false, nil
+		}
+		if err != nil {
+			return LogMetadata{}, false, err
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return LogMetadata{}, false, err
+		}
+		*field.val = bytesToUint64(val)
+	}
+	return meta, true, nil
+}
+
+// loadLogMeta returns the persisted metadata, falling back to scanning the
+// logs if there is none.
+func loadLogMeta(txn *badger.Txn) (LogMetadata, error) {
+	meta, ok, err := readLogMeta(txn)
+	if err != nil || ok {
+		return meta, err
+	}
+	return scanLogMeta(txn)
+}
+
+// scanLogMeta derives the metadata from the logs keyspace.
+func scanLogMeta(txn *badger.Txn) (LogMetadata, error) {
+	first, err := firstIndex(txn)
+	if err != nil {
+		return LogMetadata{}, err
+	}
+	last, err := lastIndex(txn)
+	if err != nil {
+		return LogMetadata{}, err
+	}
+	return LogMetadata{FirstIndex: first, LastIndex: last}, nil
+}
+
+func writeLogMeta(txn *badger.Txn, meta LogMetadata) error {
+	if err := txn.Set(prefixedKey(dbMeta, metaFirstIndex), uint64ToBytes(meta.FirstIndex)); err != nil {
+		return err
+	}
+	return txn.Set(prefixedKey(dbMeta, metaLastIndex), uint64ToBytes(meta.LastIndex))
+}
+
+// RepairReport describes what Repair changed.
+type RepairReport struct {
+	// Before is the metadata found before the repair, or nil if the store
+	// had none.
+	Before *LogMetadata `json:"before,omitempty"`
+
+	// After is the metadata rebuilt from the logs.
+	After LogMetadata `json:"after"`
+
+	// LogCountBefore is the log count found before the repair, or nil if
+	// the store had none, and LogCountAfter the count of the logs.
+	LogCountBefore *uint64 `json:"log_count_before,omitempty"`
+	LogCountAfter  uint64  `json:"log_count_after"`
+}
+
+// Changed reports whether Repair had to rewrite any metadata.
+func (r *RepairReport) Changed() bool {
+	return r.Before == nil || *r.Before != r.After ||
+		r.LogCountBefore == nil || *r.LogCountBefore != r.LogCountAfter
+}
+
+// Repair rescans the logs keyspace and rebuilds all metadata derived from
+// it, such as the first and last index and the log count. It is safe to run at any time.
+func (b *BadgerRaftStore) Repair() (*RepairReport, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+
+	report := &RepairReport{}
+	err := b.update(b.db, func(txn *badger.Txn) error {
+		before, ok, err := readLogMeta(txn)
+		if err != nil {
+			return err
+		}
+		if ok {
+			report.Before = &before
+		}
+
+		report.After, err = scanLogMeta(txn)
+		if err != nil {
+			return err
+		}
+		if err := writeLogMeta(txn, report.After); err != nil {
+			return err
+		}
+
+		count, ok, err := readLogCount(txn)
+		if err != nil {
+			return err
+		}
+		if ok {
+			report.LogCountBefore = &count
+		}
+		report.LogCountAfter, err = rebuildLogCount(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,82 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLogMetadata(t *testing.T, store *BadgerRaftStore) LogMetadata {
+	var meta LogMetadata
+	err := store.db.View(func(txn *badger.Txn) error {
+		var ok bool
+		var err error
+		meta, ok, err = readLogMeta(txn)
+		require.True(t, ok)
+		return err
+	})
+	require.NoError(t, err)
+	return meta
+}
+
+func TestBadgerStore_LogMetadata(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+		testRaftLog(4, "log4"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	assert.Equal(t, LogMetadata{FirstIndex: 1, LastIndex: 4}, testLogMetadata(t, store))
+
+	require.NoError(t, store.DeleteRange(1, 2))
+	assert.Equal(t, LogMetadata{FirstIndex: 3, LastIndex: 4}, testLogMetadata(t, store))
+
+	require.NoError(t, store.DeleteRange(4, 4))
+	assert.Equal(t, LogMetadata{FirstIndex: 3, LastIndex: 3}, testLogMetadata(t, store))
+}
+
+func TestBadgerStore_Repair(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Corrupt the metadata behind the store's back
+	require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return writeLogMeta(txn, LogMetadata{FirstIndex: 2, LastIndex: 9})
+	}))
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.MetadataMismatch)
+
+	repair, err := store.Repair()
+	require.NoError(t, err)
+	assert.True(t, repair.Changed())
+	assert.Equal(t, &LogMetadata{FirstIndex: 2, LastIndex: 9}, repair.Before)
+	assert.Equal(t, LogMetadata{FirstIndex: 1, LastIndex: 3}, repair.After)
+
+	report, err = store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+
+	// A second repair finds nothing to do
+	repair, err = store.Repair()
+	require.NoError(t, err)
+	assert.False(t, repair.Changed())
+}
@@ -0,0 +1,99 @@
+package raftbadgerstore
+
+import (
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	metricAppendLatency = []string{"raft", "badgerstore", "append_latency"}
+	metricEntrySize     = []string{"raft", "badgerstore", "entry_size"}
+
+	// Gauges set by the metrics reporter
+	metricLSMSize            = []string{"raft", "badgerstore", "lsm_size"}
+	metricVlogSize           = []string{"raft", "badgerstore", "vlog_size"}
+	metricFirstIndex         = []string{"raft", "badgerstore", "first_index"}
+	metricLastIndex          = []string{"raft", "badgerstore", "last_index"}
+	metricLogCount           = []string{"raft", "badgerstore", "log_count"}
+	metricPendingCompactions = []string{"raft", "badgerstore", "pending_compaction_bytes"}
+)
+
+// recordAppendLatency samples, for every log, the time between the leader
+// appending it, as recorded in AppendedAt, and committedAt, when it became
+// durable in this store. On followers this covers replication as well as
+// the write. Logs without AppendedAt, and logs that appear to come from the
+// future because of clock skew between nodes, are skipped.
+func recordAppendLatency(logs []*raft.Log, committedAt time.Time) {
+	for _, l := range logs {
+		if l.AppendedAt.IsZero() {
+			continue
+		}
+		latency := committedAt.Sub(l.AppendedAt)
+		if latency < 0 {
+			continue
+		}
+		metrics.AddSample(metricAppendLatency, float32(latency)/float32(time.Millisecond))
+	}
+}
+
+// recordEntrySize samples the encoded size of a log and warns if it is
+// larger than the configured threshold. Oversized logs are a common cause
+// of ErrTooLarge and of replication stalls.
+func (b *BadgerRaftStore) recordEntrySize(idx uint64, size int) {
+	metrics.AddSample(metricEntrySize, float32(size))
+
+	if b.largeEntryThreshold > 0 && size > b.largeEntryThreshold {
+		log.Warn().Uint64("index", idx).Int("size", size).Int("threshold", b.largeEntryThreshold).Msg("Large log entry")
+	}
+}
+
+// runMetricsReporter reports the store's gauges on every tick until the
+// store is closed.
+func (b *BadgerRaftStore) runMetricsReporter(shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(b.metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			if err := b.reportMetrics(); err != nil {
+				log.Warn().Err(err).Msg("Failed to report store metrics")
+			}
+		}
+	}
+}
+
+// reportMetrics sets the gauges describing the store: the sizes of the LSM
+// tree and value log, the first and last index, the number of logs and how
+// far compaction is behind.
+func (b *BadgerRaftStore) reportMetrics() error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	meta, err := loadLogMeta(txn)
+	var count uint64
+	if err == nil {
+		count, err = loadLogCount(txn)
+	}
+	txn.Discard()
+	if err != nil {
+		return storageError(err)
+	}
+
+	lsm, vlog := b.Size()
+	metrics.SetGauge(metricLSMSize, float32(lsm))
+	metrics.SetGauge(metricVlogSize, float32(vlog))
+	metrics.SetGauge(metricFirstIndex, float32(meta.FirstIndex))
+	metrics.SetGauge(metricLastIndex, float32(meta.LastIndex))
+	metrics.SetGauge(metricLogCount, float32(count))
+	metrics.SetGauge(metricPendingCompactions, float32(b.pendingCompactionBytes()))
+	return nil
+}
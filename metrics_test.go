@@ -0,0 +1,111 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMetricsSink routes go-metrics to an in-memory sink for the duration
+// of the test.
+func testMetricsSink(t *testing.T) *metrics.InmemSink {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	conf := metrics.DefaultConfig("")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	conf.EnableServiceLabel = false
+	_, err := metrics.NewGlobal(conf, sink)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		metrics.NewGlobal(metrics.DefaultConfig(""), &metrics.BlackholeSink{})
+	})
+	return sink
+}
+
+func TestBadgerStore_AppendLatency(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	appended := testRaftLog(1, "log1")
+	appended.AppendedAt = time.Now().Add(-time.Second)
+	future := testRaftLog(2, "log2")
+	future.AppendedAt = time.Now().Add(time.Hour)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{appended, future, testRaftLog(3, "log3")}))
+
+	// Only the log with a sensible AppendedAt is sampled
+	sample := sink.Data()[0].Samples["raft.badgerstore.append_latency"]
+	assert.Equal(t, 1, sample.Count)
+	assert.GreaterOrEqual(t, sample.Max, 1000.0)
+}
+
+func TestBadgerStore_LargeEntryThreshold(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	store := testBadgerStoreWithOptions(t, Options{LargeEntryThreshold: 100})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "small")))
+	assert.NotContains(t, buf.String(), "Large log entry")
+
+	require.NoError(t, store.StoreLog(testRaftLog(2, strings.Repeat("x", 200))))
+	assert.Contains(t, buf.String(), `"index":2`)
+	assert.Contains(t, buf.String(), "Large log entry")
+
+	sample := sink.Data()[0].Samples["raft.badgerstore.entry_size"]
+	assert.Equal(t, 2, sample.Count)
+	assert.Greater(t, sample.Max, 200.0)
+}
+
+func TestBadgerStore_MetricsReporter(t *testing.T) {
+	sink := testMetricsSink(t)
+	store := testBadgerStoreWithOptions(t, Options{MetricsInterval: 10 * time.Millisecond})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 20)
+	require.NoError(t, store.DeleteRange(1, 5))
+
+	gauge := func(name string) (float32, bool) {
+		for _, interval := range sink.Data() {
+			interval.RLock()
+			g, ok := interval.Gauges["raft.badgerstore."+name]
+			interval.RUnlock()
+			if ok {
+				return g.Value, true
+			}
+		}
+		return 0, false
+	}
+	require.Eventually(t, func() bool {
+		count, ok := gauge("log_count")
+		return ok && count == 15
+	}, 5*time.Second, 10*time.Millisecond)
+
+	first, _ := gauge("first_index")
+	assert.Equal(t, float32(6), first)
+	last, _ := gauge("last_index")
+	assert.Equal(t, float32(20), last)
+	for _, name := range []string{"lsm_size", "vlog_size", "pending_compaction_bytes"} {
+		_, ok := gauge(name)
+		assert.True(t, ok, name)
+	}
+}
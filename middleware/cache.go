@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/hashicorp/raft"
+)
+
+// WithCache keeps the most recently stored logs and the stable store in
+// memory, so raft's reads of fresh logs while replicating them and of its
+// term and vote don't reach the disk. Up to capacity logs are cached in a
+// ring indexed by log index, like raft.LogCache. DeleteRange empties the
+// log cache. A capacity below one disables the log cache, leaving only the
+// write-through cache of stable store values, which is how hot keys such as
+// CurrentTerm and LastVote are kept out of Badger.
+//
+// The cache assumes every write to the wrapped store goes through it. Build
+// a new chain after loading a backup or state into the store, or restoring
+// an earlier version of it, and don't use it on a database other writers
+// share.
+func WithCache(capacity int) Middleware {
+	return func(next Store) Store {
+		s := &cacheStore{
+			Base:   Base{Next: next},
+			values: make(map[string][]byte),
+			uints:  make(map[string]uint64),
+		}
+		if capacity > 0 {
+			s.logs = make([]*raft.Log, capacity)
+		}
+		return s
+	}
+}
+
+type cacheStore struct {
+	Base
+
+	logsMu sync.RWMutex
+	logs   []*raft.Log
+
+	// stableMu is held across stable store writes, so the cache is updated
+	// in the same order as the store.
+	stableMu sync.Mutex
+	values   map[string][]byte
+	uints    map[string]uint64
+}
+
+func (s *cacheStore) GetLog(index uint64, log *raft.Log) error {
+	if len(s.logs) > 0 {
+		s.logsMu.RLock()
+		cached := s.logs[index%uint64(len(s.logs))]
+		s.logsMu.RUnlock()
+
+		if cached != nil && cached.Index == index {
+			*log = *cached
+			return nil
+		}
+	}
+	return s.Next.GetLog(index, log)
+}
+
+func (s *cacheStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+func (s *cacheStore) StoreLogs(logs []*raft.Log) error {
+	if err := s.Next.StoreLogs(logs); err != nil {
+		return err
+	}
+	if len(s.logs) == 0 {
+		return nil
+	}
+
+	s.logsMu.Lock()
+	defer s.logsMu.Unlock()
+
+	for _, l := range logs {
+		s.logs[l.Index%uint64(len(s.logs))] = l
+	}
+	return nil
+}
+
+func (s *cacheStore) DeleteRange(min, max uint64) error {
+	// Clear first, so a failed deletion can't leave deleted logs cached
+	s.logsMu.Lock()
+	clear(s.logs)
+	s.logsMu.Unlock()
+
+	return s.Next.DeleteRange(min, max)
+}
+
+func (s *cacheStore) Set(key, val []byte) error {
+	s.stableMu.Lock()
+	defer s.stableMu.Unlock()
+
+	delete(s.uints, string(key))
+	if err := s.Next.Set(key, val); err != nil {
+		delete(s.values, string(key))
+		return err
+	}
+	s.values[string(key)] = slices.Clone(val)
+	return nil
+}
+
+func (s *cacheStore) Get(key []byte) ([]byte, error) {
+	s.stableMu.Lock()
+	val, ok := s.values[string(key)]
+	s.stableMu.Unlock()
+
+	if ok {
+		return slices.Clone(val), nil
+	}
+
+	val, err := s.Next.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't replace a value a concurrent Set cached meanwhile
+	s.stableMu.Lock()
+	if _, ok := s.values[string(key)]; !ok {
+		s.values[string(key)] = slices.Clone(val)
+	}
+	s.stableMu.Unlock()
+	return val, nil
+}
+
+func (s *cacheStore) SetUint64(key []byte, val uint64) error {
+	s.stableMu.Lock()
+	defer s.stableMu.Unlock()
+
+	delete(s.values, string(key))
+	if err := s.Next.SetUint64(key, val); err != nil {
+		delete(s.uints, string(key))
+		return err
+	}
+	s.uints[string(key)] = val
+	return nil
+}
+
+func (s *cacheStore) GetUint64(key []byte) (uint64, error) {
+	s.stableMu.Lock()
+	val, ok := s.uints[string(key)]
+	s.stableMu.Unlock()
+
+	if ok {
+		return val, nil
+	}
+
+	val, err := s.Next.GetUint64(key)
+	if err != nil {
+		return 0, err
+	}
+
+	s.stableMu.Lock()
+	if _, ok := s.uints[string(key)]; !ok {
+		s.uints[string(key)] = val
+	}
+	s.stableMu.Unlock()
+	return val, nil
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the reads that reach the wrapped store.
+type countingStore struct {
+	Base
+	reads int
+}
+
+func (s *countingStore) GetLog(index uint64, log *raft.Log) error {
+	s.reads++
+	return s.Next.GetLog(index, log)
+}
+
+func (s *countingStore) Get(key []byte) ([]byte, error) {
+	s.reads++
+	return s.Next.Get(key)
+}
+
+func (s *countingStore) GetUint64(key []byte) (uint64, error) {
+	s.reads++
+	return s.Next.GetUint64(key)
+}
+
+func TestWithCache(t *testing.T) {
+	counter := &countingStore{Base: Base{Next: testStore(t, t.TempDir())}}
+	store := WithCache(2)(counter)
+	defer store.Close()
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testLog(1, "log1"), testLog(2, "log2"), testLog(3, "log3")}))
+
+	// The newest logs are served from the cache
+	var log raft.Log
+	require.NoError(t, store.GetLog(3, &log))
+	assert.Equal(t, []byte("log3"), log.Data)
+	require.NoError(t, store.GetLog(2, &log))
+	assert.Equal(t, 0, counter.reads)
+
+	// Older ones are read from the store
+	require.NoError(t, store.GetLog(1, &log))
+	assert.Equal(t, []byte("log1"), log.Data)
+	assert.Equal(t, 1, counter.reads)
+
+	// Deleting logs empties the cache
+	require.NoError(t, store.DeleteRange(3, 3))
+	assert.ErrorIs(t, store.GetLog(3, &log), raft.ErrLogNotFound)
+	assert.Equal(t, 2, counter.reads)
+
+	// Stable store values are cached once written
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 4))
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), term)
+
+	require.NoError(t, store.Set([]byte("LastVoteCand"), []byte("node1")))
+	val, err := store.Get([]byte("LastVoteCand"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("node1"), val)
+	assert.Equal(t, 2, counter.reads)
+
+	// Overwriting a uint64 with raw bytes drops the cached uint64
+	require.NoError(t, store.Set([]byte("CurrentTerm"), []byte("x")))
+	_, err = store.GetUint64([]byte("CurrentTerm"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, counter.reads)
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/hashicorp/raft"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// WithMetrics measures the duration of every operation as a go-metrics
+// sample named after it under prefix, such as prefix.store_logs, and
+// counts failures in prefix.errors, labelled with the operation. Lookups of
+// missing logs and keys are not counted as failures.
+func WithMetrics(prefix []string) Middleware {
+	prefix = slices.Clone(prefix)
+	return func(next Store) Store {
+		return &metricsStore{Base: Base{Next: next}, prefix: prefix}
+	}
+}
+
+type metricsStore struct {
+	Base
+	prefix []string
+}
+
+// observe is deferred by every operation with its named error result.
+func (s *metricsStore) observe(name string, start time.Time, err *error) {
+	metrics.MeasureSince(slices.Concat(s.prefix, []string{name}), start)
+
+	if *err != nil && !errors.Is(*err, raft.ErrLogNotFound) && !errors.Is(*err, raftbadgerstore.ErrKeyNotFound) {
+		metrics.IncrCounterWithLabels(slices.Concat(s.prefix, []string{"errors"}), 1,
+			[]metrics.Label{{Name: "op", Value: name}})
+	}
+}
+
+func (s *metricsStore) FirstIndex() (idx uint64, err error) {
+	defer s.observe("first_index", time.Now(), &err)
+	return s.Next.FirstIndex()
+}
+
+func (s *metricsStore) LastIndex() (idx uint64, err error) {
+	defer s.observe("last_index", time.Now(), &err)
+	return s.Next.LastIndex()
+}
+
+func (s *metricsStore) GetLog(index uint64, log *raft.Log) (err error) {
+	defer s.observe("get_log", time.Now(), &err)
+	return s.Next.GetLog(index, log)
+}
+
+func (s *metricsStore) StoreLog(log *raft.Log) (err error) {
+	defer s.observe("store_logs", time.Now(), &err)
+	return s.Next.StoreLog(log)
+}
+
+func (s *metricsStore) StoreLogs(logs []*raft.Log) (err error) {
+	defer s.observe("store_logs", time.Now(), &err)
+	return s.Next.StoreLogs(logs)
+}
+
+func (s *metricsStore) DeleteRange(min, max uint64) (err error) {
+	defer s.observe("delete_range", time.Now(), &err)
+	return s.Next.DeleteRange(min, max)
+}
+
+func (s *metricsStore) Set(key, val []byte) (err error) {
+	defer s.observe("set", time.Now(), &err)
+	return s.Next.Set(key, val)
+}
+
+func (s *metricsStore) Get(key []byte) (val []byte, err error) {
+	defer s.observe("get", time.Now(), &err)
+	return s.Next.Get(key)
+}
+
+func (s *metricsStore) SetUint64(key []byte, val uint64) (err error) {
+	defer s.observe("set", time.Now(), &err)
+	return s.Next.SetUint64(key, val)
+}
+
+func (s *metricsStore) GetUint64(key []byte) (val uint64, err error) {
+	defer s.observe("get", time.Now(), &err)
+	return s.Next.GetUint64(key)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMetrics(t *testing.T) {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	conf := metrics.DefaultConfig("svc")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	_, err := metrics.NewGlobal(conf, sink)
+	require.NoError(t, err)
+	defer metrics.NewGlobal(metrics.DefaultConfig(""), &metrics.BlackholeSink{})
+
+	store := WithMetrics([]string{"raft", "store"})(testStore(t, t.TempDir()))
+	defer store.Close()
+
+	require.NoError(t, store.StoreLog(testLog(1, "log1")))
+	assert.Error(t, store.StoreLog(testLog(5, "log5")))
+
+	// Missing logs aren't failures
+	assert.ErrorIs(t, store.GetLog(10, new(raft.Log)), raft.ErrLogNotFound)
+
+	data := sink.Data()
+	require.NotEmpty(t, data)
+	assert.Equal(t, 2, data[0].Samples["svc.raft.store.store_logs"].Count)
+	assert.Equal(t, 1, data[0].Samples["svc.raft.store.get_log"].Count)
+	assert.Equal(t, 1, data[0].Counters["svc.raft.store.errors;op=store_logs"].Count)
+	assert.NotContains(t, data[0].Counters, "svc.raft.store.errors;op=get_log")
+}
@@ -0,0 +1,116 @@
+// Package middleware layers cross-cutting concerns such as metrics,
+// tracing, retries and caching over a raft store without forking it.
+//
+// Wrappers are applied with Chain, the first one being the outermost:
+//
+//	store := middleware.Chain(badgerStore,
+//		middleware.WithMetrics([]string{"raft", "store"}),
+//		middleware.WithRetry(policy),
+//		middleware.WithCache(1024),
+//	)
+//
+// Wrapped stores forward raft.MonotonicLogStore from the store they wrap.
+// The store's optional interfaces, such as raftbadgerstore.Verifier, are
+// reached through the wrappers with raftbadgerstore.As:
+//
+//	if v, ok := raftbadgerstore.As[raftbadgerstore.Verifier](store); ok {
+//		report, err := v.VerifyConsistency()
+//	}
+//
+// Calls made that way bypass the middleware, so operations that change the
+// log, such as raftbadgerstore.Compactor's, leave WithCache stale.
+package middleware
+
+import (
+	"github.com/hashicorp/raft"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// Store is the behavior middleware wraps: a raft log store and stable
+// store that can be closed. *raftbadgerstore.BadgerRaftStore implements it.
+type Store = raftbadgerstore.Store
+
+// Middleware wraps a store, adding behavior around its operations.
+type Middleware func(next Store) Store
+
+// Chain wraps store with every middleware, so that the first one sees
+// operations first.
+func Chain(store Store, middleware ...Middleware) Store {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		store = middleware[i](store)
+	}
+	return store
+}
+
+// Base forwards every operation to Next. Middleware embeds it and overrides
+// the operations it's interested in.
+type Base struct {
+	Next Store
+}
+
+// FirstIndex forwards to Next.
+func (b Base) FirstIndex() (uint64, error) {
+	return b.Next.FirstIndex()
+}
+
+// LastIndex forwards to Next.
+func (b Base) LastIndex() (uint64, error) {
+	return b.Next.LastIndex()
+}
+
+// GetLog forwards to Next.
+func (b Base) GetLog(index uint64, log *raft.Log) error {
+	return b.Next.GetLog(index, log)
+}
+
+// StoreLog forwards to Next.
+func (b Base) StoreLog(log *raft.Log) error {
+	return b.Next.StoreLog(log)
+}
+
+// StoreLogs forwards to Next.
+func (b Base) StoreLogs(logs []*raft.Log) error {
+	return b.Next.StoreLogs(logs)
+}
+
+// DeleteRange forwards to Next.
+func (b Base) DeleteRange(min, max uint64) error {
+	return b.Next.DeleteRange(min, max)
+}
+
+// Set forwards to Next.
+func (b Base) Set(key, val []byte) error {
+	return b.Next.Set(key, val)
+}
+
+// Get forwards to Next.
+func (b Base) Get(key []byte) ([]byte, error) {
+	return b.Next.Get(key)
+}
+
+// SetUint64 forwards to Next.
+func (b Base) SetUint64(key []byte, val uint64) error {
+	return b.Next.SetUint64(key, val)
+}
+
+// GetUint64 forwards to Next.
+func (b Base) GetUint64(key []byte) (uint64, error) {
+	return b.Next.GetUint64(key)
+}
+
+// Close forwards to Next.
+func (b Base) Close() error {
+	return b.Next.Close()
+}
+
+// Unwrap returns Next, so raftbadgerstore.As finds the optional interfaces
+// of the stores middleware wraps.
+func (b Base) Unwrap() Store {
+	return b.Next
+}
+
+// IsMonotonic reports whether Next is a monotonic log store.
+func (b Base) IsMonotonic() bool {
+	m, ok := b.Next.(raft.MonotonicLogStore)
+	return ok && m.IsMonotonic()
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+	"github.com/kgantsov/raft-badgerstore/raftstoretest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func testStore(t testing.TB, dir string) *raftbadgerstore.BadgerRaftStore {
+	opts := badger.DefaultOptions(dir).WithLogger(nil)
+	store, err := raftbadgerstore.Open(dir, raftbadgerstore.Options{BadgerOptions: &opts})
+	require.NoError(t, err)
+	return store
+}
+
+func testLog(idx uint64, data string) *raft.Log {
+	return &raft.Log{Index: idx, Data: []byte(data)}
+}
+
+func TestChain_Conformance(t *testing.T) {
+	raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+		return Chain(testStore(t, dir),
+			WithMetrics([]string{"test"}),
+			WithTracing(noop.NewTracerProvider().Tracer("test")),
+			WithRetry(raftbadgerstore.RetryPolicy{Attempts: 3}),
+			WithCache(4),
+		)
+	})
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next Store) Store {
+			return &recordingStore{Base: Base{Next: next}, name: name, order: &order}
+		}
+	}
+
+	store := Chain(testStore(t, t.TempDir()), record("outer"), record("inner"))
+	defer store.Close()
+
+	_, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner"}, order)
+}
+
+func TestBase_IsMonotonic(t *testing.T) {
+	store := WithCache(1)(testStore(t, t.TempDir()))
+	defer store.Close()
+
+	m, ok := store.(raft.MonotonicLogStore)
+	require.True(t, ok)
+	assert.True(t, m.IsMonotonic())
+
+	dir := t.TempDir()
+	opts := badger.DefaultOptions(dir).WithLogger(nil)
+	gaps, err := raftbadgerstore.Open(dir, raftbadgerstore.Options{BadgerOptions: &opts, AllowLogGaps: true})
+	require.NoError(t, err)
+	wrapped := WithCache(1)(gaps)
+	defer wrapped.Close()
+	assert.False(t, wrapped.(raft.MonotonicLogStore).IsMonotonic())
+}
+
+func TestBase_Unwrap(t *testing.T) {
+	inner := testStore(t, t.TempDir())
+	store := Chain(inner, WithMetrics([]string{"test"}), WithCache(1))
+	defer store.Close()
+
+	_, ok := store.(raftbadgerstore.Verifier)
+	assert.False(t, ok)
+	v, ok := raftbadgerstore.As[raftbadgerstore.Verifier](store)
+	require.True(t, ok)
+	assert.Same(t, inner, v)
+
+	_, ok = raftbadgerstore.As[raftbadgerstore.Verifier](nil)
+	assert.False(t, ok)
+}
+
+type recordingStore struct {
+	Base
+	name  string
+	order *[]string
+}
+
+func (s *recordingStore) FirstIndex() (uint64, error) {
+	*s.order = append(*s.order, s.name)
+	return s.Next.FirstIndex()
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+)
+
+// WithRetry retries operations failing with an error for which
+// raftbadgerstore.IsRetryable reports true, according to policy. Unlike
+// Options.Retry it also covers the stable store and works for any store.
+func WithRetry(policy raftbadgerstore.RetryPolicy) Middleware {
+	return func(next Store) Store {
+		return &retryStore{Base: Base{Next: next}, policy: policy}
+	}
+}
+
+type retryStore struct {
+	Base
+	policy raftbadgerstore.RetryPolicy
+}
+
+// do runs fn until it succeeds, fails with an error that can't be retried,
+// or runs out of attempts.
+func (s *retryStore) do(fn func() error) error {
+	err := fn()
+	for retry := 1; retry < s.policy.Attempts && raftbadgerstore.IsRetryable(err); retry++ {
+		time.Sleep(s.policy.Delay(retry))
+		err = fn()
+	}
+	return err
+}
+
+func (s *retryStore) FirstIndex() (idx uint64, err error) {
+	err = s.do(func() error {
+		idx, err = s.Next.FirstIndex()
+		return err
+	})
+	return idx, err
+}
+
+func (s *retryStore) LastIndex() (idx uint64, err error) {
+	err = s.do(func() error {
+		idx, err = s.Next.LastIndex()
+		return err
+	})
+	return idx, err
+}
+
+func (s *retryStore) GetLog(index uint64, log *raft.Log) error {
+	return s.do(func() error {
+		return s.Next.GetLog(index, log)
+	})
+}
+
+func (s *retryStore) StoreLog(log *raft.Log) error {
+	return s.do(func() error {
+		return s.Next.StoreLog(log)
+	})
+}
+
+func (s *retryStore) StoreLogs(logs []*raft.Log) error {
+	return s.do(func() error {
+		return s.Next.StoreLogs(logs)
+	})
+}
+
+func (s *retryStore) DeleteRange(min, max uint64) error {
+	return s.do(func() error {
+		return s.Next.DeleteRange(min, max)
+	})
+}
+
+func (s *retryStore) Set(key, val []byte) error {
+	return s.do(func() error {
+		return s.Next.Set(key, val)
+	})
+}
+
+func (s *retryStore) Get(key []byte) (val []byte, err error) {
+	err = s.do(func() error {
+		val, err = s.Next.Get(key)
+		return err
+	})
+	return val, err
+}
+
+func (s *retryStore) SetUint64(key []byte, val uint64) error {
+	return s.do(func() error {
+		return s.Next.SetUint64(key, val)
+	})
+}
+
+func (s *retryStore) GetUint64(key []byte) (val uint64, err error) {
+	err = s.do(func() error {
+		val, err = s.Next.GetUint64(key)
+		return err
+	})
+	return val, err
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftbadgerstore "github.com/kgantsov/raft-badgerstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStore fails StoreLogs with err the first failures times.
+type flakyStore struct {
+	Base
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *flakyStore) StoreLogs(logs []*raft.Log) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return s.err
+	}
+	return s.Next.StoreLogs(logs)
+}
+
+func TestWithRetry(t *testing.T) {
+	retryable := fmt.Errorf("%w: conflict", raftbadgerstore.ErrRetryable)
+	policy := raftbadgerstore.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	flaky := &flakyStore{Base: Base{Next: testStore(t, t.TempDir())}, failures: 2, err: retryable}
+	store := WithRetry(policy)(flaky)
+	defer store.Close()
+
+	// Transient errors are retried until they succeed
+	require.NoError(t, store.StoreLogs([]*raft.Log{testLog(1, "log1")}))
+	assert.Equal(t, 3, flaky.calls)
+
+	// Retries give up after the configured attempts
+	flaky.calls, flaky.failures = 0, 10
+	assert.ErrorIs(t, store.StoreLogs([]*raft.Log{testLog(2, "log2")}), raftbadgerstore.ErrRetryable)
+	assert.Equal(t, 3, flaky.calls)
+
+	// Permanent errors are not retried
+	flaky.calls, flaky.err = 0, errors.New("boom")
+	assert.Error(t, store.StoreLogs([]*raft.Log{testLog(2, "log2")}))
+	assert.Equal(t, 1, flaky.calls)
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/hashicorp/raft"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracing records an OpenTelemetry span for every operation, named
+// after it, such as raftbadgerstore.StoreLogs, with the log indexes it
+// touches as attributes. raft doesn't pass a context to its stores, so the
+// spans are roots of their own traces.
+func WithTracing(tracer trace.Tracer) Middleware {
+	return func(next Store) Store {
+		return &tracingStore{Base: Base{Next: next}, tracer: tracer}
+	}
+}
+
+type tracingStore struct {
+	Base
+	tracer trace.Tracer
+}
+
+// start starts the span of an operation. The returned function ends it,
+// recording err.
+func (s *tracingStore) start(name string, attrs ...attribute.KeyValue) func(err *error) {
+	_, span := s.tracer.Start(context.Background(), "raftbadgerstore."+name, trace.WithAttributes(attrs...))
+	return func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+func indexAttrs(min, max uint64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("raft.index.min", int64(min)),
+		attribute.Int64("raft.index.max", int64(max)),
+	}
+}
+
+func (s *tracingStore) FirstIndex() (idx uint64, err error) {
+	defer s.start("FirstIndex")(&err)
+	return s.Next.FirstIndex()
+}
+
+func (s *tracingStore) LastIndex() (idx uint64, err error) {
+	defer s.start("LastIndex")(&err)
+	return s.Next.LastIndex()
+}
+
+func (s *tracingStore) GetLog(index uint64, log *raft.Log) (err error) {
+	defer s.start("GetLog", indexAttrs(index, index)...)(&err)
+	return s.Next.GetLog(index, log)
+}
+
+func (s *tracingStore) StoreLog(log *raft.Log) (err error) {
+	defer s.start("StoreLogs", indexAttrs(log.Index, log.Index)...)(&err)
+	return s.Next.StoreLog(log)
+}
+
+func (s *tracingStore) StoreLogs(logs []*raft.Log) (err error) {
+	var attrs []attribute.KeyValue
+	if len(logs) > 0 {
+		attrs = indexAttrs(logs[0].Index, logs[len(logs)-1].Index)
+	}
+	defer s.start("StoreLogs", append(attrs, attribute.Int("raft.batch", len(logs)))...)(&err)
+	return s.Next.StoreLogs(logs)
+}
+
+func (s *tracingStore) DeleteRange(min, max uint64) (err error) {
+	defer s.start("DeleteRange", indexAttrs(min, max)...)(&err)
+	return s.Next.DeleteRange(min, max)
+}
+
+func (s *tracingStore) Set(key, val []byte) (err error) {
+	defer s.start("Set")(&err)
+	return s.Next.Set(key, val)
+}
+
+func (s *tracingStore) Get(key []byte) (val []byte, err error) {
+	defer s.start("Get")(&err)
+	return s.Next.Get(key)
+}
+
+func (s *tracingStore) SetUint64(key []byte, val uint64) (err error) {
+	defer s.start("Set")(&err)
+	return s.Next.SetUint64(key, val)
+}
+
+func (s *tracingStore) GetUint64(key []byte) (val uint64, err error) {
+	defer s.start("Get")(&err)
+	return s.Next.GetUint64(key)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingTracer records the names of the spans it starts.
+type recordingTracer struct {
+	noop.Tracer
+	spans []string
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.spans = append(t.spans, name)
+	return t.Tracer.Start(ctx, name, opts...)
+}
+
+func TestWithTracing(t *testing.T) {
+	tracer := &recordingTracer{}
+	store := WithTracing(tracer)(testStore(t, t.TempDir()))
+	defer store.Close()
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testLog(1, "log1")}))
+	require.NoError(t, store.GetLog(1, new(raft.Log)))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 1))
+
+	assert.Equal(t, []string{"raftbadgerstore.StoreLogs", "raftbadgerstore.GetLog", "raftbadgerstore.Set"}, tracer.spans)
+}
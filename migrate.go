@@ -0,0 +1,181 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Number of keys a migration rewrites per transaction
+	migrationBatchSize = 1000
+)
+
+var (
+	// Key of the key layout version the store is written with
+	metaKeyLayout = []byte("key_layout_version")
+
+	// Key of the position an interrupted migration resumes from
+	metaMigrationCursor = []byte("migration_cursor")
+
+	// An error indicating the store was written by a newer version with a
+	// key layout this version can't read
+	ErrUnsupportedLayout = errors.New("unsupported key layout")
+)
+
+// migration upgrades the key layout of a store to version.
+type migration struct {
+	version uint64
+
+	// migrate rewrites up to limit keys in txn, starting at cursor, which
+	// is nil for the first batch. It returns the cursor the next batch
+	// starts at, or nil once there is nothing left to rewrite, and the
+	// number of keys it rewrote. Migrations that only bump the version
+	// leave it nil.
+	migrate func(txn *badger.Txn, cursor []byte, limit int) (next []byte, n int, err error)
+}
+
+// keyLayoutMigrations upgrade stores to keyLayoutVersion, oldest first.
+// Stores written before the layout was versioned are at version 0, which
+// has the same layout as version 1.
+var keyLayoutMigrations = []migration{
+	{version: keyLayoutVersion},
+}
+
+// migrateKeyLayout brings the store up to the newest version of migrations.
+// Each batch of a migration commits together with the cursor to resume it
+// from, so a migration interrupted by a crash picks up where it left off
+// the next time the store is opened.
+func (b *BadgerRaftStore) migrateKeyLayout(migrations []migration) error {
+	txn := b.newTransaction(b.db, false)
+	version, cursor, err := readKeyLayout(txn)
+	txn.Discard()
+	if err != nil {
+		return storageError(err)
+	}
+
+	latest := migrations[len(migrations)-1].version
+	if version > latest {
+		return fmt.Errorf("%w: the store uses key layout %d, this version only supports up to %d", ErrUnsupportedLayout, version, latest)
+	}
+
+	readOnly := b.db.Opts().ReadOnly
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if readOnly {
+			if m.migrate != nil {
+				return fmt.Errorf("%w: the store uses key layout %d and must be opened read-write once to migrate it to %d", ErrUnsupportedLayout, version, latest)
+			}
+			continue
+		}
+
+		if cursor != nil {
+			log.Info().Uint64("version", m.version).Msg("Resuming interrupted key layout migration")
+		} else if m.migrate != nil {
+			log.Info().Uint64("from", version).Uint64("version", m.version).Msg("Migrating key layout")
+		}
+		if err := b.runMigration(m, cursor); err != nil {
+			return fmt.Errorf("migrating key layout to version %d: %w", m.version, err)
+		}
+		version, cursor = m.version, nil
+	}
+	return nil
+}
+
+// runMigration runs m in batches from cursor on, and records m.version in
+// the same transaction as its last batch.
+func (b *BadgerRaftStore) runMigration(m migration, cursor []byte) error {
+	total := 0
+	for {
+		txn := b.newTransaction(b.db, true)
+
+		var next []byte
+		var n int
+		var err error
+		if m.migrate != nil {
+			next, n, err = m.migrate(txn, cursor, migrationBatchSize)
+		}
+		if err == nil {
+			err = writeMigrationState(txn, m.version, next)
+		}
+		if err != nil {
+			txn.Discard()
+			return storageError(err)
+		}
+
+		err = b.commit(txn)
+		txn.Discard()
+		if err != nil {
+			return b.writeError(err)
+		}
+
+		total += n
+		if next == nil {
+			if m.migrate != nil {
+				log.Info().Uint64("version", m.version).Int("keys", total).Msg("Migrated key layout")
+			}
+			return nil
+		}
+		log.Info().Uint64("version", m.version).Int("keys", total).Msg("Migrating key layout")
+		cursor = next
+	}
+}
+
+// readKeyLayout returns the key layout version of the store, and the cursor
+// of an interrupted migration to the next version if there is one.
+func readKeyLayout(txn *badger.Txn) (version uint64, cursor []byte, err error) {
+	item, err := txn.Get(prefixedKey(dbMeta, metaKeyLayout))
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			version = bytesToUint64(val)
+			return nil
+		})
+	}
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil, err
+	}
+
+	item, err = txn.Get(prefixedKey(dbMeta, metaMigrationCursor))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return version, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	cursor, err = item.ValueCopy(nil)
+	return version, cursor, err
+}
+
+// writeMigrationState records the cursor a migration to version resumes
+// from, or once cursor is nil, that the store is at version.
+func writeMigrationState(txn *badger.Txn, version uint64, cursor []byte) error {
+	if cursor != nil {
+		return txn.Set(prefixedKey(dbMeta, metaMigrationCursor), cursor)
+	}
+	if err := txn.Delete(prefixedKey(dbMeta, metaMigrationCursor)); err != nil {
+		return err
+	}
+	return txn.Set(prefixedKey(dbMeta, metaKeyLayout), uint64ToBytes(version))
+}
+
+// KeyLayoutVersion returns the version of the key layout the store is
+// written with.
+func (b *BadgerRaftStore) KeyLayoutVersion() (uint64, error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	version, _, err := readKeyLayout(txn)
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return version, nil
+}
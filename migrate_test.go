@@ -0,0 +1,112 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMoveMigration moves every key under "old" to "new".
+func testMoveMigration(txn *badger.Txn, cursor []byte, limit int) ([]byte, int, error) {
+	from := []byte("old")
+	if cursor == nil {
+		cursor = from
+	}
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	n := 0
+	for it.Seek(cursor); it.ValidForPrefix(from); it.Next() {
+		if n == limit {
+			return it.Item().KeyCopy(nil), n, nil
+		}
+
+		key := it.Item().KeyCopy(nil)
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := txn.Set(prefixedKey([]byte("new"), key[len(from):]), val); err != nil {
+			return nil, 0, err
+		}
+		if err := txn.Delete(key); err != nil {
+			return nil, 0, err
+		}
+		n++
+	}
+	return nil, n, nil
+}
+
+func TestBadgerStore_KeyLayoutVersion(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.Remove(store.path)
+
+	version, err := store.KeyLayoutVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(keyLayoutVersion), version)
+
+	// A store written by a newer version is refused
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(prefixedKey(dbMeta, metaKeyLayout), uint64ToBytes(keyLayoutVersion+1))
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	_, err = NewBadgerRaftStore(store.path)
+	assert.ErrorIs(t, err, ErrUnsupportedLayout)
+}
+
+func TestBadgerStore_MigrateKeyLayout_Resume(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	keys := 2500
+	err := store.db.Update(func(txn *badger.Txn) error {
+		for i := range keys {
+			if err := txn.Set([]byte(fmt.Sprintf("old%05d", i)), []byte("val")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Crash in the middle of the migration
+	batches := 0
+	failing := append(keyLayoutMigrations, migration{
+		version: keyLayoutVersion + 1,
+		migrate: func(txn *badger.Txn, cursor []byte, limit int) ([]byte, int, error) {
+			if batches++; batches > 1 {
+				return nil, 0, errors.New("crash")
+			}
+			return testMoveMigration(txn, cursor, limit)
+		},
+	})
+	require.Error(t, store.migrateKeyLayout(failing))
+	assert.Equal(t, migrationBatchSize, countKeys(t, store, []byte("new")))
+
+	version, err := store.KeyLayoutVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(keyLayoutVersion), version)
+
+	// The next attempt resumes where the first one stopped
+	migrations := append(keyLayoutMigrations, migration{
+		version: keyLayoutVersion + 1,
+		migrate: testMoveMigration,
+	})
+	require.NoError(t, store.migrateKeyLayout(migrations))
+	assert.Equal(t, 0, countKeys(t, store, []byte("old")))
+	assert.Equal(t, keys, countKeys(t, store, []byte("new")))
+	assert.Equal(t, 0, countKeys(t, store, prefixedKey(dbMeta, metaMigrationCursor)))
+
+	version, err = store.KeyLayoutVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(keyLayoutVersion+1), version)
+}
@@ -0,0 +1,86 @@
+package raftbadgerstore
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+// NodeStore keeps everything a raft node persists in a single Badger
+// database: it is a LogStore, a StableStore and a SnapshotStore at once, so
+// it can be passed to raft.NewRaft for all three. The parts share Close and
+// Stats, and if TrailingLogs is set, logs are compacted as soon as a
+// snapshot covering them is persisted.
+type NodeStore struct {
+	*BadgerRaftStore
+	*SnapshotStore
+}
+
+var _ raft.LogStore = (*NodeStore)(nil)
+var _ raft.StableStore = (*NodeStore)(nil)
+var _ raft.SnapshotStore = (*NodeStore)(nil)
+
+// NewNodeStore opens the store in path like Open and returns a NodeStore
+// keeping the snapshots selected by RetainSnapshots and RetainSnapshotsFor
+// in it.
+func NewNodeStore(path string, options Options) (*NodeStore, error) {
+	store, err := Open(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	retention := SnapshotRetention{Count: options.RetainSnapshots, MaxAge: options.RetainSnapshotsFor}
+	if retention.Count <= 0 && retention.MaxAge <= 0 {
+		retention.Count = defaultRetainSnapshots
+	}
+	snapshots, err := NewSnapshotStoreWithRetention(store, retention)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if options.TrailingLogs > 0 {
+		snapshots.onPersist = store.OnSnapshotPersisted
+	}
+
+	return &NodeStore{BadgerRaftStore: store, SnapshotStore: snapshots}, nil
+}
+
+// NewRaftNode opens a NodeStore in dir and starts a raft node using it for
+// logs, the stable store and snapshots. Unless options.BadgerOptions is
+// set, the database is opened with the settings recommended for raft: see
+// RecommendedBadgerOptions. options.TrailingLogs defaults to
+// conf.TrailingLogs.
+//
+// The store must be closed after the node is shut down.
+func NewRaftNode(conf *raft.Config, fsm raft.FSM, trans raft.Transport, dir string, options Options) (*raft.Raft, *NodeStore, error) {
+	if options.BadgerOptions == nil {
+		opts := RecommendedBadgerOptions(dir, options.NoSync)
+		options.BadgerOptions = &opts
+	}
+	if options.TrailingLogs == 0 {
+		options.TrailingLogs = conf.TrailingLogs
+	}
+
+	store, err := NewNodeStore(dir, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := raft.NewRaft(conf, fsm, store, store, store, trans)
+	if err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+	return r, store, nil
+}
+
+// RecommendedBadgerOptions returns the Badger options recommended for a
+// raft node's store in path. Writes are synced unless noSync is set, since
+// raft assumes acknowledged logs survive a crash, and conflict detection is
+// disabled because raft is the only writer.
+func RecommendedBadgerOptions(path string, noSync bool) badger.Options {
+	return badger.DefaultOptions(path).
+		WithLogger(NewBadgerLogger(log.Logger)).
+		WithSyncWrites(!noSync).
+		WithDetectConflicts(false)
+}
@@ -0,0 +1,112 @@
+package raftbadgerstore
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewNodeStore(dir, Options{TrailingLogs: 2})
+	require.NoError(t, err)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 10; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 1))
+
+	// Persisting a snapshot compacts the logs it covers, except the
+	// trailing ones
+	testCreateSnapshot(t, store.SnapshotStore, 8, []byte("state"))
+	assert.Eventually(t, func() bool {
+		idx, err := store.FirstIndex()
+		return err == nil && idx == 7
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, store.Close())
+
+	store, err = NewNodeStore(dir, Options{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	list, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, uint64(8), list[0].Index)
+
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), term)
+}
+
+type testFSM struct {
+	applied [][]byte
+}
+
+func (f *testFSM) Apply(l *raft.Log) interface{} {
+	f.applied = append(f.applied, l.Data)
+	return nil
+}
+
+func (f *testFSM) Snapshot() (raft.FSMSnapshot, error) {
+	return &testFSMSnapshot{}, nil
+}
+
+func (f *testFSM) Restore(r io.ReadCloser) error {
+	return r.Close()
+}
+
+type testFSMSnapshot struct{}
+
+func (s *testFSMSnapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write([]byte("state")); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *testFSMSnapshot) Release() {}
+
+func TestNewRaftNode(t *testing.T) {
+	conf := raft.DefaultConfig()
+	conf.LocalID = "node1"
+	conf.HeartbeatTimeout = 50 * time.Millisecond
+	conf.ElectionTimeout = 50 * time.Millisecond
+	conf.LeaderLeaseTimeout = 50 * time.Millisecond
+	conf.CommitTimeout = 5 * time.Millisecond
+
+	addr, trans := raft.NewInmemTransport("")
+	fsm := &testFSM{}
+
+	r, store, err := NewRaftNode(conf, fsm, trans, t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer store.Close()
+	defer r.Shutdown()
+
+	assert.True(t, store.db.Opts().SyncWrites)
+	assert.Equal(t, conf.TrailingLogs, store.trailingLogs)
+
+	err = r.BootstrapCluster(raft.Configuration{
+		Servers: []raft.Server{{ID: conf.LocalID, Address: addr}},
+	}).Error()
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return r.State() == raft.Leader
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, r.Apply([]byte("cmd"), time.Second).Error())
+	require.NoError(t, r.Snapshot().Error())
+
+	list, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
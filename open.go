@@ -0,0 +1,212 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	badgeroptions "github.com/dgraph-io/badger/v4/options"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Name of the file next to Badger's LOCK file recording who holds it
+	lockInfoFile = "raft-badgerstore.lock-info"
+
+	// How often Open retries while the directory is locked
+	openRetryInterval = 100 * time.Millisecond
+
+	// Size above which Open stores values in the value log by default.
+	// Typical log entries stay in the LSM tree, which spares them value log
+	// GC, while large FSM commands don't bloat compactions.
+	defaultValueThreshold = 64 << 10
+)
+
+var (
+	// An error indicating the database directory is locked by another
+	// process
+	ErrLocked = errors.New("database directory is locked")
+)
+
+// LockInfo describes the process holding a store's directory lock.
+type LockInfo struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+// Open opens the Badger database in path and returns a store using it, like
+// New. options.BadgerOptions configures the database and defaults to
+// badger.DefaultOptions(path) logging through the global zerolog logger,
+// with a ValueThreshold of 64 KiB and writes synced unless options.NoSync
+// is set.
+//
+// If another process holds the directory lock, Open retries for up to
+// options.OpenRetryTimeout before failing with ErrLocked, which names the
+// process holding the lock when it is known.
+func Open(path string, options Options) (*BadgerRaftStore, error) {
+	if err := checkNotOpen(path); err != nil {
+		return nil, err
+	}
+
+	badgerOpts := badger.DefaultOptions(path).
+		WithLogger(NewBadgerLogger(log.Logger)).
+		WithSyncWrites(!options.NoSync).
+		WithValueThreshold(defaultValueThreshold)
+	if options.BadgerOptions != nil {
+		badgerOpts = *options.BadgerOptions
+		badgerOpts.Dir = path
+		if badgerOpts.ValueDir == "" {
+			badgerOpts.ValueDir = path
+		}
+	}
+	if options.ValueThreshold > 0 {
+		badgerOpts.ValueThreshold = options.ValueThreshold
+	}
+	if options.MemoryBudget > 0 {
+		badgerOpts = applyMemoryBudget(badgerOpts, options.MemoryBudget)
+	}
+	if options.VerifyValueChecksum {
+		badgerOpts.VerifyValueChecksum = true
+	}
+	if options.ChecksumVerificationMode != badgeroptions.NoVerification {
+		badgerOpts.ChecksumVerificationMode = options.ChecksumVerificationMode
+	}
+
+	if options.KeepVersions > 0 {
+		badgerOpts.NumVersionsToKeep = options.KeepVersions
+	}
+
+	db, err := openWithRetry(badgerOpts, options.OpenRetryTimeout, options.KeepVersions > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := New(db, options)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if !badgerOpts.ReadOnly && !badgerOpts.InMemory {
+		if err := writeLockInfo(path); err != nil {
+			log.Warn().Err(err).Msg("Failed to write lock info")
+		} else {
+			store.lockInfoPath = filepath.Join(path, lockInfoFile)
+		}
+	}
+	return store, nil
+}
+
+// openWithRetry opens the database, retrying for up to timeout while its
+// directory is locked.
+func openWithRetry(opts badger.Options, timeout time.Duration, managed bool) (*badger.DB, error) {
+	open := badger.Open
+	if managed {
+		open = badger.OpenManaged
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		db, err := open(opts)
+		if err == nil {
+			return db, nil
+		}
+		if !isLocked(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, lockedError(opts.Dir, err)
+		}
+
+		log.Debug().Str("path", opts.Dir).Msg("Database directory is locked, retrying")
+		time.Sleep(openRetryInterval)
+	}
+}
+
+// isLocked reports whether err is Badger failing to acquire the directory
+// lock. Badger doesn't wrap the underlying error, so the message is matched.
+func isLocked(err error) bool {
+	return strings.Contains(err.Error(), "Cannot acquire directory lock")
+}
+
+// lockedError wraps a lock failure with the lock holder recorded in path.
+func lockedError(path string, err error) error {
+	info, rerr := ReadLockInfo(path)
+	if rerr != nil {
+		return fmt.Errorf("%w: %w", ErrLocked, err)
+	}
+	return fmt.Errorf(
+		"%w: held by pid %d on %s since %s: %w",
+		ErrLocked, info.PID, info.Hostname, info.OpenedAt.Format(time.RFC3339), err,
+	)
+}
+
+// ReadLockInfo returns the lock info recorded by the process that last
+// opened the store in path for writing.
+func ReadLockInfo(path string) (*LockInfo, error) {
+	data, err := os.ReadFile(filepath.Join(path, lockInfoFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func writeLockInfo(path string) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(LockInfo{
+		PID:      os.Getpid(),
+		Hostname: hostname,
+		OpenedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, lockInfoFile), data, dbFileMode)
+}
+
+// openStableDB opens the separate stable store database configured in
+// options.
+func openStableDB(options Options) (*badger.DB, error) {
+	dir := options.SeparateStableDir
+	if err := register(dir); err != nil {
+		return nil, err
+	}
+
+	opts := badger.DefaultOptions(dir).
+		WithLogger(NewBadgerLogger(log.Logger)).
+		WithSyncWrites(!options.NoSync).
+		WithMemTableSize(4 << 20).
+		WithNumMemtables(2).
+		WithValueThreshold(defaultValueThreshold)
+	if options.StableBadgerOptions != nil {
+		opts = *options.StableBadgerOptions
+		opts.Dir = dir
+		opts.ValueDir = dir
+	}
+
+	if options.KeepVersions > 0 {
+		opts.NumVersionsToKeep = options.KeepVersions
+	}
+
+	db, err := openWithRetry(opts, options.OpenRetryTimeout, options.KeepVersions > 0)
+	if err != nil {
+		unregister(dir)
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,147 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_BadgerOptions(t *testing.T) {
+	dir := t.TempDir()
+	opts := badger.DefaultOptions("").WithLogger(nil).WithNumVersionsToKeep(2)
+
+	store, err := Open(dir, Options{BadgerOptions: &opts})
+	require.NoError(t, err)
+	defer store.Close()
+
+	assert.Equal(t, dir, store.Path())
+	assert.Equal(t, 2, store.db.Opts().NumVersionsToKeep)
+}
+
+func TestOpen_ValueThreshold(t *testing.T) {
+	store, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(defaultValueThreshold), store.db.Opts().ValueThreshold)
+	require.NoError(t, store.Close())
+
+	// Explicit Badger options are kept, unless ValueThreshold is set
+	opts := badger.DefaultOptions("").WithLogger(nil).WithValueThreshold(1 << 10)
+	store, err = Open(t.TempDir(), Options{BadgerOptions: &opts})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<10), store.db.Opts().ValueThreshold)
+	require.NoError(t, store.Close())
+
+	store, err = Open(t.TempDir(), Options{BadgerOptions: &opts, ValueThreshold: 4 << 10})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, int64(4<<10), store.db.Opts().ValueThreshold)
+
+	// Logs on either side of the threshold read back the same
+	small := testRaftLog(1, "small")
+	large := testRaftLog(2, string(make([]byte, 8<<10)))
+	require.NoError(t, store.StoreLogs([]*raft.Log{small, large}))
+
+	var log raft.Log
+	require.NoError(t, store.GetLog(1, &log))
+	assert.Equal(t, small.Data, log.Data)
+	require.NoError(t, store.GetLog(2, &log))
+	assert.Equal(t, large.Data, log.Data)
+}
+
+func TestOpen_LockInfo(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir, Options{})
+	require.NoError(t, err)
+
+	info, err := ReadLockInfo(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), info.PID)
+
+	// Pretend the store belongs to another process
+	unregister(dir)
+
+	_, err = Open(dir, Options{})
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorContains(t, err, "held by pid")
+
+	require.NoError(t, store.Close())
+	_, err = os.Stat(filepath.Join(dir, lockInfoFile))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpen_RetryTimeout(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir, Options{})
+	require.NoError(t, err)
+	unregister(dir)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		store.Close()
+	}()
+
+	reopened, err := Open(dir, Options{OpenRetryTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+}
+
+func TestOpen_NoSync(t *testing.T) {
+	store, err := Open(t.TempDir(), Options{SeparateStableDir: t.TempDir()})
+	require.NoError(t, err)
+	assert.True(t, store.db.Opts().SyncWrites)
+	assert.True(t, store.stableDB.Opts().SyncWrites)
+	require.NoError(t, store.Close())
+
+	store, err = Open(t.TempDir(), Options{NoSync: true, SeparateStableDir: t.TempDir()})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.False(t, store.db.Opts().SyncWrites)
+	assert.False(t, store.stableDB.Opts().SyncWrites)
+}
+
+func TestNewBadgerRaftStore_NoSync(t *testing.T) {
+	store, err := NewBadgerRaftStore(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	assert.False(t, store.db.Opts().SyncWrites)
+}
+
+func TestOpen_SeparateStableDir(t *testing.T) {
+	dir := t.TempDir()
+	stableDir := t.TempDir()
+
+	store, err := Open(dir, Options{SeparateStableDir: stableDir})
+	require.NoError(t, err)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 3))
+
+	// The stable store isn't in the log database
+	err = store.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(prefixedKey(dbConf, []byte("CurrentTerm")))
+		return err
+	})
+	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+
+	// Opening the stable directory twice fails
+	_, err = Open(t.TempDir(), Options{SeparateStableDir: stableDir})
+	assert.ErrorIs(t, err, ErrAlreadyOpen)
+
+	require.NoError(t, store.Close())
+
+	store, err = Open(dir, Options{SeparateStableDir: stableDir})
+	require.NoError(t, err)
+	defer store.Close()
+
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), term)
+}
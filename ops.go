@@ -0,0 +1,89 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+// op describes a store operation for timeouts, retries and logging.
+type op struct {
+	name string
+
+	// min and max are the range of log indexes the operation touches, and
+	// batch the number of logs it writes, if any.
+	min, max uint64
+	batch    int
+}
+
+func (o op) String() string {
+	return o.name
+}
+
+// do runs the store operation o, retrying transient failures according to
+// the retry policy and failing with ErrTimeout if it takes longer than the
+// configured OpTimeout overall.
+func (b *BadgerRaftStore) do(o op, fn func() error) error {
+	return b.doContext(context.Background(), o, func(context.Context) error {
+		return fn()
+	})
+}
+
+// doContext is do for operations that can stop part way once ctx is done.
+// The context passed to fn is also cancelled when the operation times out,
+// so it doesn't carry on after ErrTimeout was returned.
+func (b *BadgerRaftStore) doContext(ctx context.Context, o op, fn func(ctx context.Context) error) (err error) {
+	defer b.observe(o, time.Now(), &err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	run := func() error {
+		return fn(ctx)
+	}
+
+	if b.opTimeout <= 0 {
+		return b.withRetry(o, run)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.withRetry(o, run)
+	}()
+
+	timer := time.NewTimer(b.opTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w: %s did not complete within %s", ErrTimeout, o, b.opTimeout)
+	}
+}
+
+// observe is deferred by store operations to count their failures and log
+// them if they were slow. err points at the operation's named result.
+func (b *BadgerRaftStore) observe(o op, start time.Time, err *error) {
+	if *err != nil && !errors.Is(*err, raft.ErrLogNotFound) && !errors.Is(*err, ErrKeyNotFound) {
+		b.stats.errors.Add(1)
+		b.errors.record(*err)
+	}
+
+	elapsed := time.Since(start)
+	if b.slowOpThreshold <= 0 || elapsed < b.slowOpThreshold {
+		return
+	}
+
+	event := log.Warn().Str("op", o.name).Dur("duration", elapsed)
+	if o.max > 0 {
+		event = event.Uint64("min", o.min).Uint64("max", o.max)
+	}
+	if o.batch > 0 {
+		event = event.Int("batch", o.batch)
+	}
+	event.Msg("Slow store operation")
+}
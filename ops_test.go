@@ -0,0 +1,134 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_OpTimeout(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{OpTimeout: 50 * time.Millisecond})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Operations that complete in time behave as usual
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1")}))
+
+	result := new(raft.Log)
+	require.NoError(t, store.GetLog(1, result))
+	assert.Equal(t, testRaftLog(1, "log1"), result)
+
+	assert.Equal(t, raft.ErrLogNotFound, store.GetLog(2, result))
+	require.NoError(t, store.DeleteRange(1, 1))
+
+	// A hung operation fails with ErrTimeout
+	release := make(chan struct{})
+	defer close(release)
+	err := store.do(op{name: "Hang"}, func() error {
+		<-release
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+// stalls delays the next operations by the delays it was armed with.
+type stalls struct {
+	mu   sync.Mutex
+	next []time.Duration
+}
+
+func (l *stalls) arm(delays ...time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next = delays
+}
+
+func (l *stalls) Next() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.next) == 0 {
+		return 0
+	}
+	d := l.next[0]
+	l.next = l.next[1:]
+	return d
+}
+
+func TestBadgerStore_OpTimeout_RetriedWrite(t *testing.T) {
+	stall := &stalls{}
+	store := testBadgerStoreWithOptions(t, Options{OpTimeout: 200 * time.Millisecond, CommitLatency: stall})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// The first write times out but commits while the retry is still
+	// running
+	stall.arm(250*time.Millisecond, 100*time.Millisecond)
+	logs := []*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2"), testRaftLog(3, "log3")}
+	require.ErrorIs(t, store.StoreLogs(logs), ErrTimeout)
+
+	// Retrying the same logs waits for it instead of conflicting with it
+	require.NoError(t, store.StoreLogs(logs))
+	requireLogCount(t, store, 3)
+
+	report, err := store.Repair()
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+}
+
+func TestBadgerStore_OpTimeout_DeleteRange(t *testing.T) {
+	stall := &stalls{}
+	store := testBadgerStoreWithOptions(t, Options{OpTimeout: 200 * time.Millisecond, CommitLatency: stall, DeleteBatchSize: 2})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 10)
+
+	// Truncating the suffix times out in its first batch
+	stall.arm(250 * time.Millisecond)
+	require.ErrorIs(t, store.DeleteRange(5, 10), ErrTimeout)
+
+	// The logs raft stores next aren't deleted by the rest of the range
+	var logs []*raft.Log
+	for i := uint64(5); i <= 10; i++ {
+		logs = append(logs, testRaftLog(i, "new"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	time.Sleep(100 * time.Millisecond)
+
+	for i := uint64(5); i <= 10; i++ {
+		var log raft.Log
+		require.NoError(t, store.GetLog(i, &log))
+		assert.Equal(t, "new", string(log.Data))
+	}
+	requireLogCount(t, store, 10)
+}
+
+func TestBadgerStore_SlowOpThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	store := testBadgerStoreWithOptions(t, Options{SlowOpThreshold: 10 * time.Millisecond})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	assert.NotContains(t, buf.String(), "Slow store operation")
+
+	err := store.do(op{name: "StoreLogs", min: 3, max: 4, batch: 2}, func() error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"op":"StoreLogs"`)
+	assert.Contains(t, buf.String(), `"min":3,"max":4,"batch":2`)
+	assert.Contains(t, buf.String(), "Slow store operation")
+}
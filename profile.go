@@ -0,0 +1,167 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Number of StoreLogs latencies the p99 is computed over
+	profileWindow = 100
+
+	// How long the CPU profile runs if no duration is configured
+	defaultProfileDuration = 10 * time.Second
+
+	// Minimum time between two profiles if no cooldown is configured
+	defaultProfileCooldown = 10 * time.Minute
+)
+
+// profiler captures CPU and allocation profiles when the p99 latency of
+// StoreLogs exceeds a threshold.
+type profiler struct {
+	threshold time.Duration
+	duration  time.Duration
+	cooldown  time.Duration
+	dir       string
+
+	// mu guards the window of recent latencies.
+	mu      sync.Mutex
+	samples []time.Duration
+
+	// triggerCh wakes the background task that captures profiles.
+	triggerCh chan time.Duration
+}
+
+func newProfiler(options Options, path string) *profiler {
+	if options.ProfileP99Threshold <= 0 {
+		return nil
+	}
+
+	dir := options.ProfileDir
+	if dir == "" {
+		if path == "" {
+			log.Warn().Msg("Profiling disabled: in-memory store and no ProfileDir")
+			return nil
+		}
+		dir = filepath.Join(path, "profiles")
+	}
+
+	p := &profiler{
+		threshold: options.ProfileP99Threshold,
+		duration:  options.ProfileDuration,
+		cooldown:  options.ProfileCooldown,
+		dir:       dir,
+		samples:   make([]time.Duration, 0, profileWindow),
+		triggerCh: make(chan time.Duration, 1),
+	}
+	if p.duration <= 0 {
+		p.duration = defaultProfileDuration
+	}
+	if p.cooldown <= 0 {
+		p.cooldown = defaultProfileCooldown
+	}
+	return p
+}
+
+// record adds a StoreLogs latency to the window and triggers a profile if
+// the p99 of a full window is above the threshold.
+func (p *profiler) record(d time.Duration) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.samples = append(p.samples, d)
+	if len(p.samples) < profileWindow {
+		p.mu.Unlock()
+		return
+	}
+	slices.Sort(p.samples)
+	p99 := p.samples[len(p.samples)*99/100-1]
+	p.samples = p.samples[:0]
+	p.mu.Unlock()
+
+	if p99 <= p.threshold {
+		return
+	}
+
+	select {
+	case p.triggerCh <- p99:
+	default:
+	}
+}
+
+// runProfiler captures profiles when triggered until the store is closed.
+func (b *BadgerRaftStore) runProfiler(shutdownCh <-chan struct{}) {
+	var last time.Time
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case p99 := <-b.profiler.triggerCh:
+			if !last.IsZero() && time.Since(last) < b.profiler.cooldown {
+				continue
+			}
+			last = time.Now()
+
+			if err := b.profiler.capture(p99, shutdownCh); err != nil {
+				log.Error().Err(err).Msg("Failed to capture StoreLogs profile")
+			}
+		}
+	}
+}
+
+// capture writes a CPU profile covering the configured duration, followed
+// by an allocation profile, to the profile directory.
+func (p *profiler) capture(p99 time.Duration, shutdownCh <-chan struct{}) (err error) {
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("storelogs-%s", time.Now().UTC().Format("20060102T150405.000000000"))
+	log.Warn().
+		Dur("p99", p99).
+		Dur("threshold", p.threshold).
+		Str("dir", p.dir).
+		Str("name", name).
+		Msg("StoreLogs p99 above threshold, capturing profiles")
+
+	cpu, err := os.Create(filepath.Join(p.dir, name+".cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cpu.Close())
+	}()
+
+	// Only one CPU profile can run per process, so this fails if the
+	// application is profiling itself.
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(p.duration)
+	select {
+	case <-timer.C:
+	case <-shutdownCh:
+		timer.Stop()
+	}
+	pprof.StopCPUProfile()
+
+	allocs, err := os.Create(filepath.Join(p.dir, name+".alloc.pprof"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, allocs.Close())
+	}()
+	return pprof.Lookup("allocs").WriteTo(allocs, 0)
+}
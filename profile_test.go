@@ -0,0 +1,55 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_ProfileP99Threshold(t *testing.T) {
+	dir := t.TempDir()
+	store := testBadgerStoreWithOptions(t, Options{
+		ProfileP99Threshold: time.Nanosecond,
+		ProfileDir:          dir,
+		ProfileDuration:     10 * time.Millisecond,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	for i := uint64(1); i <= profileWindow; i++ {
+		require.NoError(t, store.StoreLog(testRaftLog(i, "log")))
+	}
+
+	require.Eventually(t, func() bool {
+		allocs, _ := filepath.Glob(filepath.Join(dir, "storelogs-*.alloc.pprof"))
+		return len(allocs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cpu, err := filepath.Glob(filepath.Join(dir, "storelogs-*.cpu.pprof"))
+	require.NoError(t, err)
+	assert.Len(t, cpu, 1)
+}
+
+func TestProfiler_BelowThreshold(t *testing.T) {
+	p := newProfiler(Options{ProfileP99Threshold: time.Second, ProfileDir: t.TempDir()}, "")
+	for range profileWindow {
+		p.record(time.Millisecond)
+	}
+	assert.Empty(t, p.triggerCh)
+
+	// A single slow call among a hundred doesn't move the p99
+	for range profileWindow - 1 {
+		p.record(time.Millisecond)
+	}
+	p.record(time.Minute)
+	assert.Empty(t, p.triggerCh)
+
+	for range profileWindow {
+		p.record(2 * time.Second)
+	}
+	assert.Len(t, p.triggerCh, 1)
+}
@@ -0,0 +1,224 @@
+// Package raftstoretest provides conformance tests for raft log and stable
+// stores, so raftbadgerstore, its forks and other backends can be checked
+// against the same expectations:
+//
+//	func TestConformance(t *testing.T) {
+//		raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+//			store, err := raftbadgerstore.Open(dir, raftbadgerstore.Options{})
+//			require.NoError(t, err)
+//			return store
+//		})
+//	}
+package raftstoretest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Store is what the suite needs from a store under test.
+type Store interface {
+	raft.LogStore
+	raft.StableStore
+	io.Closer
+}
+
+// Factory opens the store kept in dir, creating it if dir is empty. The
+// suite closes every store it opens.
+type Factory func(t testing.TB, dir string) Store
+
+// Run runs every conformance test as a subtest of t.
+func Run(t *testing.T, open Factory) {
+	t.Run("Empty", func(t *testing.T) { TestEmpty(t, open) })
+	t.Run("StoreAndGet", func(t *testing.T) { TestStoreAndGet(t, open) })
+	t.Run("Contiguity", func(t *testing.T) { TestContiguity(t, open) })
+	t.Run("Overwrite", func(t *testing.T) { TestOverwrite(t, open) })
+	t.Run("Truncation", func(t *testing.T) { TestTruncation(t, open) })
+	t.Run("StableStore", func(t *testing.T) { TestStableStore(t, open) })
+	t.Run("Persistence", func(t *testing.T) { TestPersistence(t, open) })
+}
+
+func testLogs(min, max uint64) []*raft.Log {
+	logs := make([]*raft.Log, 0, max-min+1)
+	for i := min; i <= max; i++ {
+		logs = append(logs, &raft.Log{
+			Index: i,
+			Term:  1,
+			Type:  raft.LogCommand,
+			Data:  []byte(fmt.Sprintf("log%d", i)),
+		})
+	}
+	return logs
+}
+
+func openStore(t *testing.T, open Factory, dir string) Store {
+	store := open(t, dir)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func requireRange(t *testing.T, store raft.LogStore, first, last uint64) {
+	t.Helper()
+
+	idx, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, first, idx, "first index")
+
+	idx, err = store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, last, idx, "last index")
+}
+
+func requireLog(t *testing.T, store raft.LogStore, want *raft.Log) {
+	t.Helper()
+
+	var got raft.Log
+	require.NoError(t, store.GetLog(want.Index, &got))
+	assert.Equal(t, want.Index, got.Index)
+	assert.Equal(t, want.Term, got.Term)
+	assert.Equal(t, want.Type, got.Type)
+	assert.Equal(t, want.Data, got.Data)
+}
+
+// TestEmpty checks that a new store reports no logs.
+func TestEmpty(t *testing.T, open Factory) {
+	store := openStore(t, open, t.TempDir())
+
+	requireRange(t, store, 0, 0)
+	assert.ErrorIs(t, store.GetLog(1, new(raft.Log)), raft.ErrLogNotFound)
+}
+
+// TestStoreAndGet checks that stored logs are returned unchanged.
+func TestStoreAndGet(t *testing.T, open Factory) {
+	store := openStore(t, open, t.TempDir())
+
+	logs := testLogs(1, 10)
+	require.NoError(t, store.StoreLog(logs[0]))
+	require.NoError(t, store.StoreLogs(logs[1:]))
+
+	requireRange(t, store, 1, 10)
+	for _, log := range logs {
+		requireLog(t, store, log)
+	}
+	assert.ErrorIs(t, store.GetLog(11, new(raft.Log)), raft.ErrLogNotFound)
+}
+
+// TestContiguity checks that the store doesn't have to start at index 1, and
+// that a store reporting itself as a raft.MonotonicLogStore rejects logs that
+// would leave a gap.
+func TestContiguity(t *testing.T, open Factory) {
+	store := openStore(t, open, t.TempDir())
+
+	require.NoError(t, store.StoreLogs(testLogs(100, 110)))
+	requireRange(t, store, 100, 110)
+
+	if monotonic, ok := store.(raft.MonotonicLogStore); ok && monotonic.IsMonotonic() {
+		assert.Error(t, store.StoreLogs(testLogs(112, 115)), "gap after last index")
+		requireRange(t, store, 100, 110)
+	}
+}
+
+// TestOverwrite checks that storing an existing index replaces the log, as
+// raft does after removing a conflicting suffix.
+func TestOverwrite(t *testing.T, open Factory) {
+	store := openStore(t, open, t.TempDir())
+
+	require.NoError(t, store.StoreLogs(testLogs(1, 5)))
+
+	replacement := testLogs(4, 6)
+	for _, log := range replacement {
+		log.Term = 2
+		log.Data = bytes.ToUpper(log.Data)
+	}
+	require.NoError(t, store.StoreLogs(replacement))
+
+	requireRange(t, store, 1, 6)
+	requireLog(t, store, testLogs(3, 3)[0])
+	for _, log := range replacement {
+		requireLog(t, store, log)
+	}
+}
+
+// TestTruncation checks deleting logs from the head and the tail.
+func TestTruncation(t *testing.T, open Factory) {
+	store := openStore(t, open, t.TempDir())
+
+	logs := testLogs(1, 20)
+	require.NoError(t, store.StoreLogs(logs))
+
+	require.NoError(t, store.DeleteRange(1, 5))
+	requireRange(t, store, 6, 20)
+	assert.ErrorIs(t, store.GetLog(5, new(raft.Log)), raft.ErrLogNotFound)
+	requireLog(t, store, logs[5])
+
+	require.NoError(t, store.DeleteRange(16, 20))
+	requireRange(t, store, 6, 15)
+	assert.ErrorIs(t, store.GetLog(16, new(raft.Log)), raft.ErrLogNotFound)
+
+	// Logs can be appended after the truncated tail
+	require.NoError(t, store.StoreLogs(testLogs(16, 17)))
+	requireRange(t, store, 6, 17)
+
+	require.NoError(t, store.DeleteRange(6, 17))
+	requireRange(t, store, 0, 0)
+}
+
+// TestStableStore checks the key/value operations raft relies on, including
+// the "not found" error raft expects for missing keys.
+func TestStableStore(t *testing.T, open Factory) {
+	store := openStore(t, open, t.TempDir())
+
+	_, err := store.Get([]byte("missing"))
+	require.Error(t, err)
+	assert.Equal(t, "not found", err.Error())
+
+	require.NoError(t, store.Set([]byte("key"), []byte("val")))
+	val, err := store.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("val"), val)
+
+	require.NoError(t, store.Set([]byte("key"), []byte("val2")))
+	val, err = store.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("val2"), val)
+
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 42))
+	n, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), n)
+}
+
+// TestPersistence checks that logs and stable keys survive closing and
+// reopening the store.
+func TestPersistence(t *testing.T, open Factory) {
+	dir := t.TempDir()
+	store := open(t, dir)
+
+	logs := testLogs(1, 10)
+	require.NoError(t, store.StoreLogs(logs))
+	require.NoError(t, store.DeleteRange(1, 3))
+	require.NoError(t, store.Set([]byte("key"), []byte("val")))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 7))
+	require.NoError(t, store.Close())
+
+	store = openStore(t, open, dir)
+
+	requireRange(t, store, 4, 10)
+	for _, log := range logs[3:] {
+		requireLog(t, store, log)
+	}
+
+	val, err := store.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("val"), val)
+
+	n, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), n)
+}
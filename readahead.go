@@ -0,0 +1,149 @@
+package raftbadgerstore
+
+import (
+	"sync"
+
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How many GetLog calls in a row must read consecutive indexes before
+	// the logs after them are read ahead
+	readaheadTrigger = 3
+)
+
+// readahead detects GetLog calls reading consecutive indexes, as when a
+// follower catches up, and reads the logs after them ahead of time on
+// another goroutine. Read ahead logs are handed out once and then
+// forgotten, so at most size of them are held at a time.
+type readahead struct {
+	size int
+
+	mu sync.Mutex
+
+	// next is the index a sequential read reads next, and run the number
+	// of sequential reads up to it.
+	next uint64
+	run  int
+
+	// logs are the logs read ahead and not handed out yet, up to through.
+	// fetching is set while a goroutine reads more of them.
+	logs     map[uint64]*raft.Log
+	through  uint64
+	fetching bool
+
+	// gen changes whenever logs are written, so logs read ahead before
+	// aren't kept.
+	gen uint64
+}
+
+// newReadahead returns a readahead of size logs, or nil if size is not
+// positive.
+func newReadahead(size int) *readahead {
+	if size <= 0 {
+		return nil
+	}
+	return &readahead{size: size, logs: make(map[uint64]*raft.Log)}
+}
+
+// take returns the log at idx if it was read ahead.
+func (r *readahead) take(idx uint64) (*raft.Log, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log, ok := r.logs[idx]
+	delete(r.logs, idx)
+	return log, ok
+}
+
+// read records a read of idx. Once reads are sequential and fewer than
+// half of size logs are left read ahead, it returns the range of logs to
+// read ahead next and the generation to store them with.
+func (r *readahead) read(idx uint64) (first, last, gen uint64, ok bool) {
+	if r == nil {
+		return 0, 0, 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if idx == r.next {
+		r.run++
+	} else {
+		// Whatever was read ahead won't be read
+		r.run = 1
+		clear(r.logs)
+		r.through = 0
+	}
+	r.next = idx + 1
+
+	if r.run < readaheadTrigger || r.fetching {
+		return 0, 0, 0, false
+	}
+	first = max(idx+1, r.through+1)
+	last = idx + uint64(r.size)
+	if last < first || last-first+1 < uint64(r.size)/2 {
+		return 0, 0, 0, false
+	}
+	r.fetching = true
+	return first, last, r.gen, true
+}
+
+// fill stores logs read ahead, unless logs were written since the
+// generation gen started.
+func (r *readahead) fill(logs []*raft.Log, gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fetching = false
+	if gen != r.gen {
+		return
+	}
+	for _, l := range logs {
+		r.logs[l.Index] = l
+		r.through = l.Index
+	}
+}
+
+// invalidate forgets every log read ahead, including those being read.
+func (r *readahead) invalidate() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gen++
+	clear(r.logs)
+	r.through = 0
+}
+
+// fetchAhead reads the logs from first through last, or up to the last log
+// stored, into the readahead.
+func (b *BadgerRaftStore) fetchAhead(first, last, gen uint64) {
+	var logs []*raft.Log
+	defer func() {
+		b.readahead.fill(logs, gen)
+	}()
+
+	if err := b.enter(); err != nil {
+		return
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	meta, err := loadLogMeta(txn)
+	txn.Discard()
+	if err != nil || meta.LastIndex < first {
+		return
+	}
+	last = min(last, meta.LastIndex)
+
+	logs, err = b.getLogs(first, last)
+	if err != nil {
+		log.Debug().Err(err).Uint64("min", first).Uint64("max", last).Msg("Reading logs ahead failed")
+	}
+}
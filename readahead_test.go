@@ -0,0 +1,117 @@
+package raftbadgerstore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadahead_Read(t *testing.T) {
+	assert.Nil(t, newReadahead(0))
+
+	r := newReadahead(10)
+	_, _, _, ok := r.read(1)
+	assert.False(t, ok)
+	_, _, _, ok = r.read(2)
+	assert.False(t, ok)
+
+	first, last, gen, ok := r.read(3)
+	require.True(t, ok)
+	assert.Equal(t, uint64(4), first)
+	assert.Equal(t, uint64(13), last)
+
+	// Only one read ahead runs at a time
+	_, _, _, ok = r.read(4)
+	assert.False(t, ok)
+
+	r.fill([]*raft.Log{{Index: 4}, {Index: 5}, {Index: 6}}, gen)
+	log, ok := r.take(4)
+	require.True(t, ok)
+	assert.Equal(t, uint64(4), log.Index)
+	_, ok = r.take(4)
+	assert.False(t, ok, "logs are handed out once")
+
+	// Reading on continues after what was read ahead
+	first, last, _, ok = r.read(5)
+	require.True(t, ok)
+	assert.Equal(t, uint64(7), first)
+	assert.Equal(t, uint64(15), last)
+
+	// A jump drops what was read ahead and starts over
+	_, _, _, ok = r.read(100)
+	assert.False(t, ok)
+	_, ok = r.take(6)
+	assert.False(t, ok)
+}
+
+func TestReadahead_Invalidate(t *testing.T) {
+	r := newReadahead(10)
+	r.read(1)
+	r.read(2)
+	_, _, gen, ok := r.read(3)
+	require.True(t, ok)
+
+	// Logs read before a write are dropped
+	r.invalidate()
+	r.fill([]*raft.Log{{Index: 4}}, gen)
+	_, ok = r.take(4)
+	assert.False(t, ok)
+
+	first, _, _, ok := r.read(4)
+	require.True(t, ok)
+	assert.Equal(t, uint64(5), first)
+}
+
+func TestBadgerStore_GetLog_Readahead(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{ReadaheadSize: 50})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 200)
+
+	for idx := uint64(1); idx <= 200; idx++ {
+		var log raft.Log
+		require.NoError(t, store.GetLog(idx, &log))
+		assert.Equal(t, idx, log.Index)
+		assert.Equal(t, fmt.Sprintf("log%d", idx), string(log.Data))
+
+		// Give the read ahead a chance to land
+		if idx%10 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	stats := store.Stats()
+	assert.Greater(t, stats.ReadaheadHits, uint64(100))
+	assert.Equal(t, uint64(200), stats.Reads)
+}
+
+func TestBadgerStore_GetLog_ReadaheadOverwrite(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{ReadaheadSize: 50})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 100)
+
+	var log raft.Log
+	for idx := uint64(1); idx <= 3; idx++ {
+		require.NoError(t, store.GetLog(idx, &log))
+	}
+	require.Eventually(t, func() bool {
+		store.readahead.mu.Lock()
+		defer store.readahead.mu.Unlock()
+		return len(store.readahead.logs) > 0
+	}, time.Second, time.Millisecond)
+
+	// A new leader overwrites the logs read ahead
+	require.NoError(t, store.DeleteRange(4, 100))
+	require.NoError(t, store.StoreLog(testRaftLog(4, "rewritten")))
+
+	require.NoError(t, store.GetLog(4, &log))
+	assert.Equal(t, "rewritten", string(log.Data))
+	assert.ErrorIs(t, store.GetLog(5, &log), raft.ErrLogNotFound)
+}
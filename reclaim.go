@@ -0,0 +1,70 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	metricReclaims = []string{"raft", "badgerstore", "gc", "reclaims"}
+)
+
+// scheduleReclaim wakes the background task reclaiming the space of deleted
+// logs. Deletes while a pass is already pending share it.
+func (b *BadgerRaftStore) scheduleReclaim() {
+	select {
+	case b.reclaimCh <- struct{}{}:
+	default:
+	}
+}
+
+// runReclaim reclaims the space of deleted logs when woken until the store
+// is closed.
+func (b *BadgerRaftStore) runReclaim(shutdownCh <-chan struct{}) {
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-b.reclaimCh:
+			if err := b.reclaim(shutdownCh); err != nil && !errors.Is(err, ErrClosed) {
+				log.Warn().Err(err).Msg("Failed to reclaim the space of deleted logs")
+			}
+		}
+	}
+}
+
+// reclaim flattens the LSM tree, so compaction drops deleted logs and
+// records how much of the value log they occupied, and then runs value log
+// GC until there is nothing left to rewrite, pausing during write bursts
+// like the background GC.
+func (b *BadgerRaftStore) reclaim(shutdownCh <-chan struct{}) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	start := time.Now()
+	vlogBefore := b.vlogFileSize()
+	b.gc.reclaim()
+	metrics.IncrCounter(metricReclaims, 1)
+
+	if err := b.waitForCalmAppends(shutdownCh); err != nil {
+		return err
+	}
+	if err := b.db.Flatten(1); err != nil {
+		return err
+	}
+	if err := b.gcUntilClean(shutdownCh); err != nil {
+		return err
+	}
+
+	log.Info().
+		Dur("duration", time.Since(start)).
+		Int64("vlog_before", vlogBefore).
+		Int64("vlog_after", b.vlogFileSize()).
+		Msg("Reclaimed the space of deleted logs")
+	return nil
+}
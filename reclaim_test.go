@@ -0,0 +1,33 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_ReclaimAfterDelete(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{ReclaimAfterDelete: 50})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 100)
+
+	// Small deletes don't trigger a pass
+	require.NoError(t, store.DeleteRange(1, 10))
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, store.GCStats().Reclaims)
+
+	require.NoError(t, store.DeleteRange(11, 80))
+	require.Eventually(t, func() bool {
+		stats := store.GCStats()
+		return stats.Reclaims == 1 && stats.Cycles > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(81), first)
+}
@@ -0,0 +1,81 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// An error indicating a store for the same directory is already open in
+	// this process
+	ErrAlreadyOpen = errors.New("store is already open")
+)
+
+// openStores tracks the directories of the stores open in this process, so
+// opening one twice fails early instead of with Badger's LOCK error, or not
+// at all when the same *badger.DB is passed to New twice.
+var openStores = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+// registryKey returns the key a store directory is registered under. It is
+// empty for in-memory databases, which aren't tracked.
+func registryKey(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// checkNotOpen returns ErrAlreadyOpen if a store for path is open.
+func checkNotOpen(path string) error {
+	key := registryKey(path)
+	if key == "" {
+		return nil
+	}
+
+	openStores.Lock()
+	defer openStores.Unlock()
+
+	if _, ok := openStores.paths[key]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyOpen, key)
+	}
+	return nil
+}
+
+// register records that a store for path is open. It returns
+// ErrAlreadyOpen if one already is.
+func register(path string) error {
+	key := registryKey(path)
+	if key == "" {
+		return nil
+	}
+
+	openStores.Lock()
+	defer openStores.Unlock()
+
+	if _, ok := openStores.paths[key]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyOpen, key)
+	}
+	openStores.paths[key] = struct{}{}
+	return nil
+}
+
+// unregister records that the store for path was closed.
+func unregister(path string) {
+	key := registryKey(path)
+	if key == "" {
+		return
+	}
+
+	openStores.Lock()
+	defer openStores.Unlock()
+
+	delete(openStores.paths, key)
+}
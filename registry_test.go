@@ -0,0 +1,41 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_AlreadyOpen(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.RemoveAll(store.path)
+
+	_, err := NewBadgerRaftStore(store.path)
+	assert.ErrorIs(t, err, ErrAlreadyOpen)
+
+	// The same db can't back two stores either
+	_, err = New(store.db, Options{})
+	assert.ErrorIs(t, err, ErrAlreadyOpen)
+
+	// The path is released on Close
+	require.NoError(t, store.Close())
+	reopened, err := NewBadgerRaftStore(store.path)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+}
+
+func TestBadgerStore_InMemoryNotRegistered(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	require.NoError(t, err)
+
+	first, err := New(db, Options{})
+	require.NoError(t, err)
+	second, err := New(db, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, second.Close())
+	require.NoError(t, first.Close())
+}
@@ -0,0 +1,208 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// An error indicating the versions needed to restore the store to a
+	// point in time were already discarded, see Options.VersionRetention
+	ErrVersionDiscarded = errors.New("version already discarded")
+)
+
+// RestoreReport describes what RestoreToVersion changed.
+type RestoreReport struct {
+	// Version is the Badger version the logs were restored to.
+	Version uint64 `json:"version"`
+
+	// Before is the log metadata before the restore.
+	Before LogMetadata `json:"before"`
+
+	// After is the log metadata after the restore.
+	After LogMetadata `json:"after"`
+
+	// Discarded are the ranges of logs that were deleted because they
+	// didn't exist at Version, oldest first.
+	Discarded []IndexRange `json:"discarded,omitempty"`
+
+	// Restored is the number of logs that were written back because they
+	// were deleted or overwritten after Version.
+	Restored int `json:"restored"`
+}
+
+// RestoreToTime rolls the logs back to how they were at the given time, see
+// RestoreToVersion.
+func (b *BadgerRaftStore) RestoreToTime(at time.Time) (*RestoreReport, error) {
+	return b.RestoreToVersion(uint64(at.UnixNano()))
+}
+
+// RestoreToVersion rolls the logs back to how they were at the given Badger
+// version, as listed by LogVersions, to undo an operational mistake such as
+// a bad truncation. Logs written since are deleted and reported, and logs
+// deleted or overwritten since are written back. The current term and vote
+// are left alone, since rolling them back could let the node vote twice in
+// the same term.
+//
+// Versions superseded longer than VersionRetention ago are discarded, so
+// restoring further back fails with ErrVersionDiscarded. It fails with
+// ErrVersionsDisabled unless KeepVersions is set. The node must be stopped
+// while the logs are restored.
+func (b *BadgerRaftStore) RestoreToVersion(version uint64) (*RestoreReport, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	if b.clock == nil {
+		return nil, ErrVersionsDisabled
+	}
+	if err := b.checkWritable(); err != nil {
+		return nil, err
+	}
+	if version < b.clock.discardTs.Load() {
+		return nil, fmt.Errorf("%w: versions before %d may be discarded", ErrVersionDiscarded, b.clock.discardTs.Load())
+	}
+
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+
+	report := &RestoreReport{}
+	err := b.clock.commit(func(commitTs uint64) error {
+		// Commits are serialized, so nothing is written while the logs are
+		// compared, and all changes become visible together
+		readTs := b.clock.readTs()
+		report.Version = min(version, readTs)
+
+		cur := b.db.NewTransactionAt(readTs, false)
+		defer cur.Discard()
+		old := b.db.NewTransactionAt(report.Version, false)
+		defer old.Discard()
+
+		meta, err := loadLogMeta(cur)
+		if err != nil {
+			return err
+		}
+		report.Before = meta
+
+		wb := b.db.NewManagedWriteBatch()
+		if err := restoreLogs(cur, old, wb, commitTs, report); err != nil {
+			wb.Cancel()
+			return err
+		}
+		return wb.Flush()
+	})
+	if err != nil {
+		return nil, b.writeError(err)
+	}
+	b.readahead.invalidate()
+
+	if err := b.rebuildLogMeta(); err != nil {
+		return nil, err
+	}
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+	if report.After, err = loadLogMeta(txn); err != nil {
+		return nil, storageError(err)
+	}
+
+	log.Warn().
+		Uint64("version", report.Version).
+		Interface("discarded", report.Discarded).
+		Int("restored", report.Restored).
+		Uint64("first_index", report.After.FirstIndex).
+		Uint64("last_index", report.After.LastIndex).
+		Msg("Restored logs to an earlier version")
+	return report, nil
+}
+
+// restoreLogs walks the logs as they are in cur and as they were in old side
+// by side, and writes what it takes to turn the former into the latter to wb
+// at commitTs.
+func restoreLogs(cur, old *badger.Txn, wb *badger.WriteBatch, commitTs uint64, report *RestoreReport) error {
+	curIt := cur.NewIterator(badger.DefaultIteratorOptions)
+	defer curIt.Close()
+	oldIt := old.NewIterator(badger.DefaultIteratorOptions)
+	defer oldIt.Close()
+
+	curIt.Seek(dbLogs)
+	oldIt.Seek(dbLogs)
+	for {
+		curValid, oldValid := curIt.ValidForPrefix(dbLogs), oldIt.ValidForPrefix(dbLogs)
+		if !curValid && !oldValid {
+			return nil
+		}
+
+		cmp := 0
+		switch {
+		case !oldValid:
+			cmp = -1
+		case !curValid:
+			cmp = 1
+		default:
+			cmp = bytes.Compare(curIt.Item().Key(), oldIt.Item().Key())
+		}
+
+		switch {
+		case cmp < 0:
+			// Written since version
+			key := curIt.Item().KeyCopy(nil)
+			if err := wb.DeleteAt(key, commitTs); err != nil {
+				return err
+			}
+			report.Discarded = appendIndex(report.Discarded, logIndex(key))
+			curIt.Next()
+		case cmp > 0:
+			// Deleted since version
+			if err := restoreItem(oldIt.Item(), wb, commitTs); err != nil {
+				return err
+			}
+			report.Restored++
+			oldIt.Next()
+		default:
+			// Only logs rewritten since version can differ
+			if curIt.Item().Version() != oldIt.Item().Version() {
+				curVal, err := curIt.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				oldVal, err := oldIt.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(curVal, oldVal) {
+					if err := restoreItem(oldIt.Item(), wb, commitTs); err != nil {
+						return err
+					}
+					report.Restored++
+				}
+			}
+			curIt.Next()
+			oldIt.Next()
+		}
+	}
+}
+
+// restoreItem writes item back to wb at commitTs.
+func restoreItem(item *badger.Item, wb *badger.WriteBatch, commitTs uint64) error {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	return wb.SetEntryAt(badger.NewEntry(item.KeyCopy(nil), val), commitTs)
+}
+
+// appendIndex adds idx to ranges, which are sorted, extending the last range
+// if idx follows it.
+func appendIndex(ranges []IndexRange, idx uint64) []IndexRange {
+	if n := len(ranges); n > 0 && ranges[n-1].Max+1 == idx {
+		ranges[n-1].Max = idx
+		return ranges
+	}
+	return append(ranges, IndexRange{Min: idx, Max: idx})
+}
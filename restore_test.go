@@ -0,0 +1,85 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_RestoreToTime(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(1, "a"), testRaftLog(2, "b"), testRaftLog(3, "c"),
+	}))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 1))
+	goodTime := time.Now()
+
+	// A mistaken truncation, an overwrite and appends after it
+	require.NoError(t, store.DeleteRange(1, 1))
+	require.NoError(t, store.DeleteRange(3, 3))
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(3, "x"), testRaftLog(4, "y"), testRaftLog(5, "z"),
+	}))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 2))
+
+	report, err := store.RestoreToTime(goodTime)
+	require.NoError(t, err)
+	assert.Equal(t, LogMetadata{FirstIndex: 2, LastIndex: 5}, report.Before)
+	assert.Equal(t, LogMetadata{FirstIndex: 1, LastIndex: 3}, report.After)
+	assert.Equal(t, []IndexRange{{Min: 4, Max: 5}}, report.Discarded)
+	assert.Equal(t, 2, report.Restored)
+
+	for idx, data := range map[uint64]string{1: "a", 2: "b", 3: "c"} {
+		var l raft.Log
+		require.NoError(t, store.GetLog(idx, &l))
+		assert.Equal(t, data, string(l.Data))
+	}
+	var l raft.Log
+	assert.ErrorIs(t, store.GetLog(4, &l), raft.ErrLogNotFound)
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), last)
+
+	// The term is never rolled back
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), term)
+
+	// Restoring to the same moment again changes nothing
+	report, err = store.RestoreToTime(goodTime)
+	require.NoError(t, err)
+	assert.Empty(t, report.Discarded)
+	assert.Zero(t, report.Restored)
+}
+
+func TestBadgerStore_RestoreToVersion_Discarded(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "a")))
+	store.clock.discardTs.Store(store.clock.readTs())
+
+	_, err := store.RestoreToVersion(1)
+	assert.ErrorIs(t, err, ErrVersionDiscarded)
+}
+
+func TestBadgerStore_RestoreToVersion_VersionsDisabled(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	_, err := store.RestoreToVersion(1)
+	assert.ErrorIs(t, err, ErrVersionsDisabled)
+}
@@ -0,0 +1,230 @@
+package raftbadgerstore
+
+import (
+	"math"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How often the retention policy runs if no interval is configured.
+	defaultRetentionInterval = time.Minute
+)
+
+// retentionPolicy holds the limits enforced by the background retention task.
+type retentionPolicy struct {
+	maxAge   time.Duration
+	maxBytes int64
+	interval time.Duration
+}
+
+func (p retentionPolicy) enabled() bool {
+	return p.maxAge > 0 || p.maxBytes > 0
+}
+
+// runRetention enforces the retention policy on every tick until the store
+// is closed.
+func (b *BadgerRaftStore) runRetention(shutdownCh <-chan struct{}) {
+	interval := b.retention.interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			if err := b.EnforceRetention(); err != nil {
+				log.Error().Err(err).Msg("Failed to enforce log retention")
+			}
+		}
+	}
+}
+
+// EnforceRetention deletes the logs that fall outside the configured
+// retention policy. The most recent log is always kept so raft can recover
+// its last index and term. It is called periodically in the background when
+// a policy is configured, but can also be invoked directly.
+func (b *BadgerRaftStore) EnforceRetention() error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	if !b.retention.enabled() {
+		return nil
+	}
+
+	first, err := b.FirstIndex()
+	if err != nil {
+		return err
+	}
+	last, err := b.LastIndex()
+	if err != nil {
+		return err
+	}
+	if last == 0 {
+		return nil
+	}
+
+	var max uint64
+	if b.retention.maxAge > 0 {
+		max, err = b.lastAppendedBefore(time.Now().Add(-b.retention.maxAge))
+		if err != nil {
+			return err
+		}
+	}
+	if b.retention.maxBytes > 0 {
+		overBudget, err := b.lastOverBudget(b.retention.maxBytes)
+		if err != nil {
+			return err
+		}
+		if overBudget > max {
+			max = overBudget
+		}
+	}
+	if max >= last {
+		max = last - 1
+	}
+	max = min(max, b.minRetainIndex.Load())
+	if max < first {
+		return nil
+	}
+
+	log.Debug().Uint64("min", first).Uint64("max", max).Msg("Deleting logs outside retention")
+	return b.DeleteRange(first, max)
+}
+
+// lastAppendedBefore scans from the oldest log and returns the index of the
+// last entry appended before cutoff. Scanning stops at the first entry that
+// is newer or carries no AppendedAt timestamp, so 0 means nothing expired.
+func (b *BadgerRaftStore) lastAppendedBefore(cutoff time.Time) (uint64, error) {
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var max uint64
+	for it.Seek(dbLogs); it.ValidForPrefix(dbLogs); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return 0, storageError(err)
+		}
+
+		var entry raft.Log
+		if err := decodeLog(val, &entry); err != nil {
+			return 0, err
+		}
+
+		if entry.AppendedAt.IsZero() || !entry.AppendedAt.Before(cutoff) {
+			break
+		}
+		max = entry.Index
+	}
+	return max, nil
+}
+
+// lastOverBudget returns the index of the newest log that has to be removed,
+// oldest first, for the logs keyspace to fit into budget bytes. It returns 0
+// if the keyspace is already within budget.
+func (b *BadgerRaftStore) lastOverBudget(budget int64) (uint64, error) {
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	var total int64
+	it := txn.NewIterator(opts)
+	for it.Seek(dbLogs); it.ValidForPrefix(dbLogs); it.Next() {
+		total += it.Item().EstimatedSize()
+	}
+	it.Close()
+
+	if total <= budget {
+		return 0, nil
+	}
+
+	it = txn.NewIterator(opts)
+	defer it.Close()
+
+	var max uint64
+	for it.Seek(dbLogs); it.ValidForPrefix(dbLogs) && total > budget; it.Next() {
+		item := it.Item()
+		total -= item.EstimatedSize()
+		max = logIndex(item.Key())
+	}
+	return max, nil
+}
+
+// OnSnapshotPersisted tells the store that a snapshot covering logs up to
+// index has been durably persisted. Logs up to index minus TrailingLogs are
+// then deleted in the background. If a minimum retain index is set, it is
+// advanced to index first so the compaction is allowed.
+func (b *BadgerRaftStore) OnSnapshotPersisted(index uint64) {
+	for {
+		guard := b.minRetainIndex.Load()
+		if guard == math.MaxUint64 || guard >= index || b.minRetainIndex.CompareAndSwap(guard, index) {
+			break
+		}
+	}
+
+	b.truncateMu.Lock()
+	if index > b.pendingTruncate {
+		b.pendingTruncate = index
+	}
+	b.truncateMu.Unlock()
+
+	select {
+	case b.truncateCh <- struct{}{}:
+	default:
+	}
+}
+
+// runTruncation compacts logs behind persisted snapshots until the store is
+// closed.
+func (b *BadgerRaftStore) runTruncation(shutdownCh <-chan struct{}) {
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-b.truncateCh:
+			b.truncateMu.Lock()
+			index := b.pendingTruncate
+			b.truncateMu.Unlock()
+
+			if err := b.compactTo(index); err != nil {
+				log.Error().Err(err).Uint64("index", index).Msg("Failed to compact logs after snapshot")
+			}
+		}
+	}
+}
+
+// compactTo deletes logs up to TrailingLogs before snapIdx, so that many
+// logs are kept behind the snapshot.
+func (b *BadgerRaftStore) compactTo(snapIdx uint64) error {
+	if snapIdx <= b.trailingLogs {
+		return nil
+	}
+	first, err := b.FirstIndex()
+	if err != nil {
+		return err
+	}
+
+	max := min(snapIdx-b.trailingLogs, b.minRetainIndex.Load())
+	if first == 0 || first > max {
+		return nil
+	}
+	return b.DeleteRange(first, max)
+}
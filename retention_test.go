@@ -0,0 +1,143 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_EnforceRetention_MaxAge(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		RetentionMaxAge:   time.Hour,
+		RetentionInterval: time.Hour,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	now := time.Now()
+	logs := []*raft.Log{
+		{Index: 1, Data: []byte("log1"), AppendedAt: now.Add(-3 * time.Hour)},
+		{Index: 2, Data: []byte("log2"), AppendedAt: now.Add(-2 * time.Hour)},
+		{Index: 3, Data: []byte("log3"), AppendedAt: now},
+		{Index: 4, Data: []byte("log4"), AppendedAt: now.Add(-4 * time.Hour)},
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	require.NoError(t, store.EnforceRetention())
+
+	// Only the contiguous run of expired logs at the head is removed
+	idx, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), idx)
+
+	idx, err = store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), idx)
+}
+
+func TestBadgerStore_EnforceRetention_KeepsLastLog(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		RetentionMaxAge:   time.Minute,
+		RetentionInterval: time.Hour,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	old := time.Now().Add(-time.Hour)
+	logs := []*raft.Log{
+		{Index: 1, Data: []byte("log1"), AppendedAt: old},
+		{Index: 2, Data: []byte("log2"), AppendedAt: old},
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	require.NoError(t, store.EnforceRetention())
+
+	idx, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), idx)
+}
+
+func TestBadgerStore_EnforceRetention_MaxBytes(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		RetentionMaxBytes: 1,
+		RetentionInterval: time.Hour,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	require.NoError(t, store.EnforceRetention())
+
+	// The budget can't fit anything, but the last log is always kept
+	idx, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), idx)
+
+	// A generous budget keeps every log
+	store.retention.maxBytes = 1 << 20
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(4, "log4")}))
+	require.NoError(t, store.EnforceRetention())
+
+	idx, err = store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), idx)
+}
+
+func TestBadgerStore_OnSnapshotPersisted(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		TrailingLogs: 2,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 10; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	store.SetMinRetainIndex(3)
+	store.OnSnapshotPersisted(6)
+
+	// Logs up to the trailing logs behind the snapshot are compacted and
+	// the guard is advanced
+	assert.Eventually(t, func() bool {
+		idx, err := store.FirstIndex()
+		return err == nil && idx == 5
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(6), store.minRetainIndex.Load())
+
+	store.OnSnapshotPersisted(10)
+	assert.Eventually(t, func() bool {
+		idx, err := store.FirstIndex()
+		return err == nil && idx == 9
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestBadgerStore_OnSnapshotPersisted_TrailingLogs(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		TrailingLogs: 10,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 200)
+
+	// Trailing logs are kept behind the snapshot, not the last log
+	store.OnSnapshotPersisted(100)
+	assert.Eventually(t, func() bool {
+		idx, err := store.FirstIndex()
+		return err == nil && idx == 91
+	}, time.Second, 10*time.Millisecond)
+	requireLogCount(t, store, 110)
+}
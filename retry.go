@@ -0,0 +1,79 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	metricRetries          = []string{"raft", "badgerstore", "retries"}
+	metricRetriesExhausted = []string{"raft", "badgerstore", "retries_exhausted"}
+	metricConflicts        = []string{"raft", "badgerstore", "conflicts"}
+)
+
+// RetryPolicy configures how operations that fail with a transient error,
+// such as a transaction conflict, are retried.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times an operation is tried. Zero
+	// or one disables retries.
+	Attempts int
+
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between retries. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Jitter randomizes every delay by up to this fraction of it, so
+	// retries of concurrent operations spread out. It must be between 0
+	// and 1.
+	Jitter float64
+}
+
+// Delay returns how long to wait before the given retry, starting at 1.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := p.Backoff << (retry - 1)
+	if d < p.Backoff || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// IsRetryable reports whether an operation that failed with err may succeed
+// if it's tried again. Running out of disk space is left to the degraded
+// mode rather than retried.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRetryable) && !errors.Is(err, ErrDiskFull)
+}
+
+// withRetry runs fn, retrying transient failures according to the retry
+// policy.
+func (b *BadgerRaftStore) withRetry(o op, fn func() error) error {
+	err := fn()
+	for retry := 1; retry < b.retry.Attempts && IsRetryable(err); retry++ {
+		b.stats.retries.Add(1)
+		metrics.IncrCounter(metricRetries, 1)
+		log.Debug().Err(err).Str("op", o.name).Int("retry", retry).Msg("Retrying operation")
+
+		select {
+		case <-b.shutdownCh:
+			return err
+		case <-time.After(b.retry.Delay(retry)):
+		}
+		err = fn()
+	}
+
+	if b.retry.Attempts > 1 && IsRetryable(err) {
+		b.stats.retriesExhausted.Add(1)
+		metrics.IncrCounter(metricRetriesExhausted, 1)
+	}
+	return err
+}
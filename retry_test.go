@@ -0,0 +1,93 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 35 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.Delay(1))
+	assert.Equal(t, 20*time.Millisecond, p.Delay(2))
+	assert.Equal(t, 35*time.Millisecond, p.Delay(3))
+	assert.Equal(t, 35*time.Millisecond, p.Delay(100))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Delay(1)
+		assert.GreaterOrEqual(t, d, 5*time.Millisecond)
+		assert.LessOrEqual(t, d, 15*time.Millisecond)
+	}
+}
+
+func TestBadgerStore_Retry(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{
+		Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Transient errors are retried until they succeed
+	calls := 0
+	err := store.do(op{name: "Test"}, func() error {
+		calls++
+		if calls < 3 {
+			return storageError(badger.ErrConflict)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Retries give up after the configured attempts
+	calls = 0
+	err = store.do(op{name: "Test"}, func() error {
+		calls++
+		return storageError(badger.ErrConflict)
+	})
+	assert.ErrorIs(t, err, ErrRetryable)
+	assert.Equal(t, 3, calls)
+
+	// Permanent errors are not retried
+	calls = 0
+	err = store.do(op{name: "Test"}, func() error {
+		calls++
+		return fmt.Errorf("%w: %w", ErrCorrupt, errors.New("boom"))
+	})
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBadgerStore_RetryStats(t *testing.T) {
+	fp := NewFailpoints()
+	store := testBadgerStoreWithOptions(t, Options{
+		Retry:      RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+		Failpoints: fp,
+	})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Conflicts are counted and retried until the commit succeeds
+	fp.FailCommits(2, badger.ErrConflict)
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(2), stats.Conflicts)
+	assert.Equal(t, uint64(2), stats.Retries)
+	assert.Equal(t, uint64(0), stats.RetriesExhausted)
+
+	fp.FailCommits(3, badger.ErrConflict)
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(2, "log2")), ErrRetryable)
+
+	stats = store.Stats()
+	assert.Equal(t, uint64(5), stats.Conflicts)
+	assert.Equal(t, uint64(4), stats.Retries)
+	assert.Equal(t, uint64(1), stats.RetriesExhausted)
+}
@@ -0,0 +1,108 @@
+package raftbadgerstore
+
+import (
+	"github.com/hashicorp/raft"
+)
+
+// LogStore is a store that only gives access to the raft log. It is meant
+// for mixing backends, for example Badger logs with an existing stable
+// store, with the guarantee that the stable store keyspace isn't touched.
+type LogStore struct {
+	store *BadgerRaftStore
+}
+
+var _ raft.LogStore = (*LogStore)(nil)
+var _ raft.MonotonicLogStore = (*LogStore)(nil)
+
+// NewLogStore opens the store in path like Open and returns a LogStore
+// using it.
+func NewLogStore(path string, options Options) (*LogStore, error) {
+	store, err := Open(path, options)
+	if err != nil {
+		return nil, err
+	}
+	return &LogStore{store: store}, nil
+}
+
+// FirstIndex returns the first index written. 0 for no entries.
+func (s *LogStore) FirstIndex() (uint64, error) {
+	return s.store.FirstIndex()
+}
+
+// LastIndex returns the last index written. 0 for no entries.
+func (s *LogStore) LastIndex() (uint64, error) {
+	return s.store.LastIndex()
+}
+
+// GetLog gets a log entry at a given index.
+func (s *LogStore) GetLog(idx uint64, log *raft.Log) error {
+	return s.store.GetLog(idx, log)
+}
+
+// StoreLog stores a log entry.
+func (s *LogStore) StoreLog(log *raft.Log) error {
+	return s.store.StoreLog(log)
+}
+
+// StoreLogs stores multiple log entries.
+func (s *LogStore) StoreLogs(logs []*raft.Log) error {
+	return s.store.StoreLogs(logs)
+}
+
+// DeleteRange deletes a range of log entries. The range is inclusive.
+func (s *LogStore) DeleteRange(min, max uint64) error {
+	return s.store.DeleteRange(min, max)
+}
+
+// IsMonotonic reports whether logs must be stored without gaps.
+func (s *LogStore) IsMonotonic() bool {
+	return s.store.IsMonotonic()
+}
+
+// Close closes the store.
+func (s *LogStore) Close() error {
+	return s.store.Close()
+}
+
+// StableStore is a store that only gives access to the stable store
+// keyspace, holding raft's votes and terms.
+type StableStore struct {
+	store *BadgerRaftStore
+}
+
+var _ raft.StableStore = (*StableStore)(nil)
+
+// NewStableStore opens the store in path like Open and returns a
+// StableStore using it.
+func NewStableStore(path string, options Options) (*StableStore, error) {
+	store, err := Open(path, options)
+	if err != nil {
+		return nil, err
+	}
+	return &StableStore{store: store}, nil
+}
+
+// Set is used to set a key value set outside of the raft log.
+func (s *StableStore) Set(k, v []byte) error {
+	return s.store.Set(k, v)
+}
+
+// Get is used to retrieve a value from the k/v store by key.
+func (s *StableStore) Get(k []byte) ([]byte, error) {
+	return s.store.Get(k)
+}
+
+// SetUint64 is like Set, but handles uint64 values.
+func (s *StableStore) SetUint64(key []byte, val uint64) error {
+	return s.store.SetUint64(key, val)
+}
+
+// GetUint64 is like Get, but handles uint64 values.
+func (s *StableStore) GetUint64(key []byte) (uint64, error) {
+	return s.store.GetUint64(key)
+}
+
+// Close closes the store.
+func (s *StableStore) Close() error {
+	return s.store.Close()
+}
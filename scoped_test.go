@@ -0,0 +1,37 @@
+package raftbadgerstore
+
+import (
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogStore_StableStore(t *testing.T) {
+	logs, err := NewLogStore(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer logs.Close()
+
+	stable, err := NewStableStore(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer stable.Close()
+
+	// Mixing them gives a complete raft backend
+	var _ raft.LogStore = logs
+	var _ raft.StableStore = stable
+	_, ok := interface{}(logs).(raft.StableStore)
+	assert.False(t, ok)
+	_, ok = interface{}(stable).(raft.LogStore)
+	assert.False(t, ok)
+
+	require.NoError(t, logs.StoreLog(testRaftLog(1, "log1")))
+	var log raft.Log
+	require.NoError(t, logs.GetLog(1, &log))
+	assert.Equal(t, []byte("log1"), log.Data)
+
+	require.NoError(t, stable.SetUint64([]byte("CurrentTerm"), 2))
+	term, err := stable.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), term)
+}
@@ -0,0 +1,623 @@
+package raftbadgerstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// Size at which a new segment is started if no size is configured
+	defaultSegmentSize = 64 << 20
+
+	// Size of a segment record header: payload length and CRC32
+	segmentRecordHeaderSize = 8
+
+	// File name suffix of segment files
+	segmentFileSuffix = ".seg"
+)
+
+var (
+	// Keyspace holding the first live index of every segment, keyed by the
+	// segment's base index
+	dbSegments = []byte("segments")
+)
+
+// SegmentOptions configures a SegmentedStore.
+type SegmentOptions struct {
+	// SegmentSize is the size in bytes after which a new segment file is
+	// started. Defaults to 64 MiB.
+	SegmentSize int64
+
+	// NoSync skips the fsync after every append. This is unsafe, a crash
+	// can lose acknowledged logs.
+	NoSync bool
+
+	// Store configures the Badger store holding the stable store and the
+	// segment index.
+	Store Options
+}
+
+// SegmentedStore is a raft log and stable store that appends logs to
+// sequential segment files instead of writing them to Badger, which avoids
+// the write amplification of the LSM tree for append-heavy workloads. Badger
+// only keeps the stable store and the index of segments.
+//
+// Logs can only be deleted from the head or the tail of the log, which is
+// all raft ever does.
+type SegmentedStore struct {
+	dir         string
+	segmentSize int64
+	noSync      bool
+
+	// stable holds the stable store and the segment index.
+	stable *BadgerRaftStore
+
+	// mu guards segments. Appends and deletes hold it exclusively.
+	mu       sync.RWMutex
+	segments []*segment
+}
+
+// segment is an open segment file holding the contiguous logs starting at
+// base.
+type segment struct {
+	file *os.File
+	base uint64
+
+	// min is the first live index; logs below it were deleted from the
+	// head of the log.
+	min uint64
+
+	// offsets holds the file offset of log base+i at i, and size the
+	// length of the valid part of the file.
+	offsets []int64
+	size    int64
+}
+
+// last returns the index of the last log in the segment. It is base-1 if
+// the segment is empty.
+func (s *segment) last() uint64 {
+	return s.base + uint64(len(s.offsets)) - 1
+}
+
+func segmentPath(dir string, base uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentFileSuffix))
+}
+
+// OpenSegmented opens the segmented store in dir, creating it if needed.
+// Segment files are kept in dir/segments and the Badger database in
+// dir/badger. A partially written record at the end of the newest segment,
+// left behind by a crash, is discarded.
+func OpenSegmented(dir string, options SegmentOptions) (*SegmentedStore, error) {
+	segDir := filepath.Join(dir, "segments")
+	if err := os.MkdirAll(segDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	stable, err := Open(filepath.Join(dir, "badger"), options.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SegmentedStore{
+		dir:         segDir,
+		segmentSize: options.SegmentSize,
+		noSync:      options.NoSync,
+		stable:      stable,
+	}
+	if s.segmentSize <= 0 {
+		s.segmentSize = defaultSegmentSize
+	}
+
+	if err := s.load(); err != nil {
+		s.closeSegments()
+		stable.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load opens the segments listed in the index and rebuilds their offsets.
+func (s *SegmentedStore) load() error {
+	index := make(map[uint64]uint64)
+	err := s.stable.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(dbSegments); it.ValidForPrefix(dbSegments); it.Next() {
+			item := it.Item()
+			base := bytesToUint64(item.Key()[len(dbSegments):])
+			err := item.Value(func(val []byte) error {
+				index[base] = bytesToUint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storageError(err)
+	}
+
+	bases := make([]uint64, 0, len(index))
+	for base := range index {
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+
+	for i, base := range bases {
+		seg, err := openSegment(s.dir, base, index[base], i == len(bases)-1)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	return s.removeOrphans(index)
+}
+
+// removeOrphans deletes segment files missing from the index, left behind
+// by a crash between deleting a segment from the index and removing its
+// file.
+func (s *SegmentedStore) removeOrphans(index map[uint64]uint64) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentFileSuffix)
+		if !ok {
+			continue
+		}
+		base, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := index[base]; !ok {
+			if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openSegment opens the segment file starting at base and scans its records.
+// A bad record at the end of the newest segment is a torn write and is cut
+// off; anywhere else it is corruption.
+func openSegment(dir string, base, min uint64, newest bool) (*segment, error) {
+	file, err := os.OpenFile(segmentPath(dir, base), os.O_RDWR|os.O_CREATE, dbFileMode)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{file: file, base: base, min: min}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	for seg.size < info.Size() {
+		var l raft.Log
+		n, err := readRecord(file, seg.size, &l)
+		if err == nil && l.Index != base+uint64(len(seg.offsets)) {
+			err = fmt.Errorf("log %d where %d was expected", l.Index, base+uint64(len(seg.offsets)))
+		}
+		if err != nil {
+			if !newest {
+				file.Close()
+				return nil, fmt.Errorf("%w: segment %d at offset %d: %w", ErrCorrupt, base, seg.size, err)
+			}
+			if err := file.Truncate(seg.size); err != nil {
+				file.Close()
+				return nil, err
+			}
+			break
+		}
+
+		seg.offsets = append(seg.offsets, seg.size)
+		seg.size += n
+	}
+	return seg, nil
+}
+
+// readRecord reads the record at off into l and returns its size.
+func readRecord(r io.ReaderAt, off int64, l *raft.Log) (int64, error) {
+	var header [segmentRecordHeaderSize]byte
+	if _, err := r.ReadAt(header[:], off); err != nil {
+		return 0, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[:4]))
+	if _, err := r.ReadAt(payload, off+segmentRecordHeaderSize); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint32(header[4:]) != crc32.Checksum(payload, crcTable) {
+		return 0, ErrChecksumMismatch
+	}
+
+	if err := DecodeMsgPack(payload, l); err != nil {
+		return 0, err
+	}
+	return segmentRecordHeaderSize + int64(len(payload)), nil
+}
+
+// appendRecord encodes l as a record and appends it to buf.
+func appendRecord(buf []byte, l *raft.Log, useNewTimeFormat bool) ([]byte, error) {
+	payload, err := EncodeMsgPack(l, useNewTimeFormat)
+	if err != nil {
+		return nil, err
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(payload.Len()))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(payload.Bytes(), crcTable))
+	return append(buf, payload.Bytes()...), nil
+}
+
+// indexes returns the first and last index. s.mu must be held.
+func (s *SegmentedStore) indexes() (first, last uint64) {
+	if len(s.segments) == 0 {
+		return 0, 0
+	}
+	tail := s.segments[len(s.segments)-1]
+	if len(tail.offsets) == 0 && len(s.segments) == 1 {
+		return 0, 0
+	}
+	return s.segments[0].min, tail.last()
+}
+
+// find returns the segment holding idx, or nil. s.mu must be held.
+func (s *SegmentedStore) find(idx uint64) *segment {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].base > idx
+	})
+	if i == 0 {
+		return nil
+	}
+	seg := s.segments[i-1]
+	if idx < seg.min || idx > seg.last() || len(seg.offsets) == 0 {
+		return nil
+	}
+	return seg
+}
+
+// FirstIndex returns the first index written. 0 for no entries.
+func (s *SegmentedStore) FirstIndex() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	first, _ := s.indexes()
+	return first, nil
+}
+
+// LastIndex returns the last index written. 0 for no entries.
+func (s *SegmentedStore) LastIndex() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, last := s.indexes()
+	return last, nil
+}
+
+// GetLog gets a log entry at a given index.
+func (s *SegmentedStore) GetLog(idx uint64, log *raft.Log) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seg := s.find(idx)
+	if seg == nil {
+		return raft.ErrLogNotFound
+	}
+	if _, err := readRecord(seg.file, seg.offsets[idx-seg.base], log); err != nil {
+		return fmt.Errorf("%w: log %d: %w", ErrCorrupt, idx, err)
+	}
+	return nil
+}
+
+// StoreLog stores a single raft log.
+func (s *SegmentedStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+// StoreLogs appends logs to the newest segment, starting new segments as
+// they fill up. Logs must follow the last index, or overwrite a suffix of
+// the log, which is deleted first.
+func (s *SegmentedStore) StoreLogs(logs []*raft.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Index != logs[i-1].Index+1 {
+			return fmt.Errorf("%w: log %d follows log %d", ErrLogGap, logs[i].Index, logs[i-1].Index)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, last := s.indexes()
+	switch {
+	case last > 0 && logs[0].Index > last+1:
+		return fmt.Errorf("%w: log %d follows last index %d", ErrLogGap, logs[0].Index, last)
+	case last > 0 && logs[0].Index <= first:
+		if err := s.deleteAll(); err != nil {
+			return err
+		}
+	case last > 0 && logs[0].Index <= last:
+		if err := s.truncateTail(logs[0].Index); err != nil {
+			return err
+		}
+	}
+
+	var buf []byte
+	for _, log := range logs {
+		tail := s.tail()
+		if tail == nil || tail.size+int64(len(buf)) >= s.segmentSize {
+			if err := s.flush(tail, buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+
+			var err error
+			if tail, err = s.startSegment(log.Index); err != nil {
+				return err
+			}
+		}
+
+		start := len(buf)
+		var err error
+		if buf, err = appendRecord(buf, log, s.stable.msgpackUseNewTimeFormat); err != nil {
+			s.rollBack(tail)
+			return err
+		}
+		tail.offsets = append(tail.offsets, tail.size+int64(start))
+	}
+	return s.flush(s.tail(), buf)
+}
+
+// tail returns the newest segment, or nil. s.mu must be held.
+func (s *SegmentedStore) tail() *segment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// flush writes buf, holding the records whose offsets were already added,
+// to the end of seg and syncs it.
+func (s *SegmentedStore) flush(seg *segment, buf []byte) error {
+	if seg == nil || len(buf) == 0 {
+		return nil
+	}
+
+	_, err := seg.file.WriteAt(buf, seg.size)
+	if err == nil && !s.noSync {
+		err = seg.file.Sync()
+	}
+	if err != nil {
+		s.rollBack(seg)
+		return storageError(err)
+	}
+	seg.size += int64(len(buf))
+	return nil
+}
+
+// rollBack forgets the offsets of records that were not written to seg.
+func (s *SegmentedStore) rollBack(seg *segment) {
+	for len(seg.offsets) > 0 && seg.offsets[len(seg.offsets)-1] >= seg.size {
+		seg.offsets = seg.offsets[:len(seg.offsets)-1]
+	}
+	seg.file.Truncate(seg.size)
+}
+
+// startSegment adds a segment starting at base to the index and creates
+// its file. s.mu must be held.
+func (s *SegmentedStore) startSegment(base uint64) (*segment, error) {
+	// An empty newest segment, left by truncating the tail, is reused
+	if tail := s.tail(); tail != nil && len(tail.offsets) == 0 {
+		if err := s.removeSegments(len(s.segments) - 1); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.stable.update(s.stable.db, func(txn *badger.Txn) error {
+		return txn.Set(prefixedKey(dbSegments, uint64ToBytes(base)), uint64ToBytes(base))
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	file, err := os.OpenFile(segmentPath(s.dir, base), os.O_RDWR|os.O_CREATE|os.O_TRUNC, dbFileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncDir(s.dir); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	seg := &segment{file: file, base: base, min: base}
+	s.segments = append(s.segments, seg)
+	return seg, nil
+}
+
+// DeleteRange deletes logs min through max. The range must include the
+// first or the last log.
+func (s *SegmentedStore) DeleteRange(min, max uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, last := s.indexes()
+	switch {
+	case last == 0 || min > last || max < first:
+		return nil
+	case min <= first && max >= last:
+		return s.deleteAll()
+	case min <= first:
+		return s.truncateHead(max + 1)
+	case max >= last:
+		return s.truncateTail(min)
+	default:
+		return fmt.Errorf("%w: can only delete logs from the head or the tail, not %d-%d", errors.ErrUnsupported, min, max)
+	}
+}
+
+// deleteAll removes every segment. s.mu must be held.
+func (s *SegmentedStore) deleteAll() error {
+	return s.removeSegments(0)
+}
+
+// truncateHead deletes the logs below newFirst. Segments left without live
+// logs are removed. s.mu must be held.
+func (s *SegmentedStore) truncateHead(newFirst uint64) error {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].last() >= newFirst
+	})
+
+	seg := s.segments[i]
+	err := s.stable.update(s.stable.db, func(txn *badger.Txn) error {
+		for _, dead := range s.segments[:i] {
+			if err := txn.Delete(prefixedKey(dbSegments, uint64ToBytes(dead.base))); err != nil {
+				return err
+			}
+		}
+		return txn.Set(prefixedKey(dbSegments, uint64ToBytes(seg.base)), uint64ToBytes(newFirst))
+	})
+	if err != nil {
+		return storageError(err)
+	}
+	seg.min = newFirst
+
+	dead := s.segments[:i]
+	s.segments = s.segments[i:]
+	return closeAndRemove(s.dir, dead)
+}
+
+// truncateTail deletes the logs from min on. s.mu must be held.
+func (s *SegmentedStore) truncateTail(min uint64) error {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].last() >= min
+	})
+	seg := s.segments[i]
+
+	if min <= seg.base || (i == 0 && min <= seg.min) {
+		if i == 0 {
+			return s.deleteAll()
+		}
+		return s.removeSegments(i)
+	}
+
+	if err := s.removeSegments(i + 1); err != nil {
+		return err
+	}
+
+	size := seg.offsets[min-seg.base]
+	if err := seg.file.Truncate(size); err != nil {
+		return err
+	}
+	if !s.noSync {
+		if err := seg.file.Sync(); err != nil {
+			return err
+		}
+	}
+	seg.offsets = seg.offsets[:min-seg.base]
+	seg.size = size
+	return nil
+}
+
+// removeSegments removes the segments from i on. s.mu must be held.
+func (s *SegmentedStore) removeSegments(i int) error {
+	if i >= len(s.segments) {
+		return nil
+	}
+
+	err := s.stable.update(s.stable.db, func(txn *badger.Txn) error {
+		for _, seg := range s.segments[i:] {
+			if err := txn.Delete(prefixedKey(dbSegments, uint64ToBytes(seg.base))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storageError(err)
+	}
+
+	dead := s.segments[i:]
+	s.segments = s.segments[:i:i]
+	return closeAndRemove(s.dir, dead)
+}
+
+func closeAndRemove(dir string, segments []*segment) error {
+	var errs []error
+	for _, seg := range segments {
+		errs = append(errs, seg.file.Close(), os.Remove(segmentPath(dir, seg.base)))
+	}
+	return errors.Join(errs...)
+}
+
+// syncDir fsyncs a directory so files created in it survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// IsMonotonic reports that logs must be stored without gaps.
+func (s *SegmentedStore) IsMonotonic() bool {
+	return true
+}
+
+// Set is used to set a key value set outside of the raft log.
+func (s *SegmentedStore) Set(k, v []byte) error {
+	return s.stable.Set(k, v)
+}
+
+// Get is used to retrieve a value from the k/v store by key.
+func (s *SegmentedStore) Get(k []byte) ([]byte, error) {
+	return s.stable.Get(k)
+}
+
+// SetUint64 is like Set, but handles uint64 values.
+func (s *SegmentedStore) SetUint64(key []byte, val uint64) error {
+	return s.stable.SetUint64(key, val)
+}
+
+// GetUint64 is like Get, but handles uint64 values.
+func (s *SegmentedStore) GetUint64(key []byte) (uint64, error) {
+	return s.stable.GetUint64(key)
+}
+
+// Close closes the segment files and the Badger store.
+func (s *SegmentedStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.closeSegments()
+	return errors.Join(err, s.stable.Close())
+}
+
+func (s *SegmentedStore) closeSegments() error {
+	var errs []error
+	for _, seg := range s.segments {
+		errs = append(errs, seg.file.Close())
+	}
+	s.segments = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/kgantsov/raft-badgerstore/raftstoretest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSegmentOptions(segmentSize int64) SegmentOptions {
+	opts := badger.DefaultOptions("").WithLogger(nil)
+	return SegmentOptions{SegmentSize: segmentSize, Store: Options{BadgerOptions: &opts}}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "segments", "*"+segmentFileSuffix))
+	require.NoError(t, err)
+	return files
+}
+
+func TestSegmentedStore_Implements(t *testing.T) {
+	var store interface{} = &SegmentedStore{}
+	_, ok := store.(raft.LogStore)
+	assert.True(t, ok)
+
+	_, ok = store.(raft.StableStore)
+	assert.True(t, ok)
+
+	_, ok = store.(raft.MonotonicLogStore)
+	assert.True(t, ok)
+}
+
+func TestSegmentedStore_Conformance(t *testing.T) {
+	for name, size := range map[string]int64{"OneSegment": 0, "ManySegments": 256} {
+		t.Run(name, func(t *testing.T) {
+			raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+				store, err := OpenSegmented(dir, testSegmentOptions(size))
+				require.NoError(t, err)
+				return store
+			})
+		})
+	}
+}
+
+func TestSegmentedStore_Segments(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenSegmented(dir, testSegmentOptions(256))
+	require.NoError(t, err)
+	defer store.Close()
+
+	for i := uint64(1); i <= 100; i++ {
+		require.NoError(t, store.StoreLog(testRaftLog(i, "log")))
+	}
+	files := len(segmentFiles(t, dir))
+	assert.Greater(t, files, 5)
+
+	// Deleting from the head removes whole segments only
+	require.NoError(t, store.DeleteRange(1, 50))
+	assert.Less(t, len(segmentFiles(t, dir)), files)
+	assert.ErrorIs(t, store.GetLog(50, new(raft.Log)), raft.ErrLogNotFound)
+	require.NoError(t, store.GetLog(51, new(raft.Log)))
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(51), first)
+
+	// Deleting from the middle isn't supported
+	err = store.DeleteRange(60, 70)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+func TestSegmentedStore_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenSegmented(dir, testSegmentOptions(0))
+	require.NoError(t, err)
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2")}))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash in the middle of appending a record
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 40, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = OpenSegmented(dir, testSegmentOptions(0))
+	require.NoError(t, err)
+	defer store.Close()
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), last)
+	require.NoError(t, store.StoreLog(testRaftLog(3, "log3")))
+
+	var log raft.Log
+	require.NoError(t, store.GetLog(3, &log))
+	assert.Equal(t, []byte("log3"), log.Data)
+}
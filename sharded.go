@@ -0,0 +1,420 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// How many consecutive logs go to the same shard if no stripe size is
+	// configured
+	defaultStripeSize = 1024
+)
+
+var (
+	// Keyspace holding the sharding state of a shard: its place in the
+	// layout and, in the first shard, the first index of the log
+	dbShard = []byte("shard")
+
+	shardLayoutKey = prefixedKey(dbShard, []byte("layout"))
+	shardFirstKey  = prefixedKey(dbShard, []byte("first"))
+
+	// An error indicating the shards of a ShardedStore were opened in a
+	// different layout than they were written with
+	ErrShardLayout = errors.New("shard layout mismatch")
+)
+
+// ShardOptions configures a ShardedStore.
+type ShardOptions struct {
+	// StripeSize is how many consecutive logs are stored in the same shard
+	// before moving on to the next. Defaults to 1024. It can't be changed
+	// once logs were stored.
+	StripeSize uint64
+
+	// Store configures the Badger store of every shard. The stable store
+	// is kept in the first shard.
+	Store Options
+}
+
+// ShardedStore is a raft log and stable store that partitions the logs by
+// index range across multiple Badger instances, for deployments that
+// saturate a single disk. Logs are striped across the shards in runs of
+// StripeSize, and a batch spanning several shards is written to all of them
+// in parallel. Routing is hidden behind the raft.LogStore API.
+//
+// A batch is not atomic across shards. A crash can leave part of the last
+// batch stored, which raft never acknowledged, so the incomplete tail is
+// removed when the store is opened.
+type ShardedStore struct {
+	stripeSize uint64
+	shards     []*BadgerRaftStore
+
+	// mu guards first and last. Appends and deletes hold it exclusively.
+	mu          sync.RWMutex
+	first, last uint64
+}
+
+// OpenSharded opens the sharded store with one shard in each of dirs,
+// creating it if needed. The directories must be passed in the same order
+// every time, which is checked against the layout recorded in each shard.
+func OpenSharded(dirs []string, options ShardOptions) (*ShardedStore, error) {
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%w: no shard directories", ErrShardLayout)
+	}
+
+	s := &ShardedStore{stripeSize: options.StripeSize}
+	if s.stripeSize == 0 {
+		s.stripeSize = defaultStripeSize
+	}
+
+	storeOpts := options.Store
+	storeOpts.AllowLogGaps = true
+	for _, dir := range dirs {
+		shard, err := Open(dir, storeOpts)
+		if err != nil {
+			s.closeShards()
+			return nil, err
+		}
+		s.shards = append(s.shards, shard)
+	}
+
+	if err := s.load(); err != nil {
+		s.closeShards()
+		return nil, err
+	}
+	return s, nil
+}
+
+// shardLayout is the value of shardLayoutKey: the position of the shard,
+// the number of shards and the stripe size.
+func (s *ShardedStore) shardLayout(i int) []byte {
+	val := uint64ToBytes(uint64(i))
+	val = append(val, uint64ToBytes(uint64(len(s.shards)))...)
+	return append(val, uint64ToBytes(s.stripeSize)...)
+}
+
+// load checks the layout of the shards and recovers the range of the log.
+func (s *ShardedStore) load() error {
+	for i, shard := range s.shards {
+		if err := s.checkLayout(i, shard); err != nil {
+			return err
+		}
+	}
+
+	var first uint64
+	err := s.shards[0].db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(shardFirstKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err == nil {
+			first = bytesToUint64(val)
+		}
+		return err
+	})
+	if err != nil {
+		return storageError(err)
+	}
+
+	return s.recover(first)
+}
+
+// checkLayout records the layout in a new shard, or checks that an
+// existing shard was written with the same one.
+func (s *ShardedStore) checkLayout(i int, shard *BadgerRaftStore) error {
+	want := s.shardLayout(i)
+	return shard.update(shard.db, func(txn *badger.Txn) error {
+		item, err := txn.Get(shardLayoutKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return txn.Set(shardLayoutKey, want)
+		}
+		if err != nil {
+			return storageError(err)
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return storageError(err)
+		}
+		if len(val) != len(want) {
+			return fmt.Errorf("%w: shard %d has an invalid layout", ErrCorrupt, i)
+		}
+		pos, n, stripe := bytesToUint64(val[:8]), bytesToUint64(val[8:16]), bytesToUint64(val[16:])
+		if pos != uint64(i) || n != uint64(len(s.shards)) || stripe != s.stripeSize {
+			return fmt.Errorf("%w: %s was written as shard %d of %d with stripe size %d, not shard %d of %d with stripe size %d",
+				ErrShardLayout, shard.path, pos, n, stripe, i, len(s.shards), s.stripeSize)
+		}
+		return nil
+	})
+}
+
+// shardRange is the range of logs a shard holds.
+type shardRange struct {
+	first, last uint64
+}
+
+// recover finds the range of the log and removes what a crash left behind:
+// logs below first, from an interrupted head deletion, and the logs after
+// the first hole, from an interrupted append or tail deletion. Each shard
+// holds a contiguous run of the indexes striped to it, so the log is
+// followed stripe by stripe until a shard is missing part of its stripe.
+func (s *ShardedStore) recover(first uint64) error {
+	ranges := make([]shardRange, len(s.shards))
+	var lowest uint64
+	for i, shard := range s.shards {
+		var err error
+		if ranges[i].first, err = shard.FirstIndex(); err != nil {
+			return err
+		}
+		if ranges[i].last, err = shard.LastIndex(); err != nil {
+			return err
+		}
+		if ranges[i].last > 0 && (lowest == 0 || ranges[i].first < lowest) {
+			lowest = ranges[i].first
+		}
+	}
+	if first == 0 {
+		first = lowest
+	}
+
+	last := first - 1
+	for first > 0 {
+		r := ranges[s.shardOf(last+1)]
+		if r.last == 0 || last+1 < r.first || last+1 > r.last {
+			break
+		}
+		end := s.stripeEnd(last + 1)
+		last = min(end, r.last)
+		if last < end {
+			break
+		}
+	}
+	if first == 0 || last < first {
+		first, last = 0, 0
+	}
+
+	err := s.each(func(i int, shard *BadgerRaftStore) error {
+		r := ranges[i]
+		if r.last == 0 {
+			return nil
+		}
+		if first == 0 {
+			return shard.DeleteRange(r.first, r.last)
+		}
+		if r.first < first {
+			if err := shard.DeleteRange(r.first, first-1); err != nil {
+				return err
+			}
+		}
+		if r.last > last {
+			return shard.DeleteRange(last+1, r.last)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.first, s.last = first, last
+	return nil
+}
+
+// shardOf returns the position of the shard holding the log at idx.
+func (s *ShardedStore) shardOf(idx uint64) int {
+	return int(idx / s.stripeSize % uint64(len(s.shards)))
+}
+
+// stripeEnd returns the last index of the stripe holding idx.
+func (s *ShardedStore) stripeEnd(idx uint64) uint64 {
+	return (idx/s.stripeSize+1)*s.stripeSize - 1
+}
+
+// each calls fn for every shard in parallel and returns their errors.
+func (s *ShardedStore) each(fn func(i int, shard *BadgerRaftStore) error) error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// setFirst records the first index of the log before logs below it are
+// deleted, so a deletion interrupted by a crash is completed on open.
+func (s *ShardedStore) setFirst(first uint64) error {
+	stable := s.shards[0]
+	err := stable.update(stable.db, func(txn *badger.Txn) error {
+		return txn.Set(shardFirstKey, uint64ToBytes(first))
+	})
+	return storageError(err)
+}
+
+// FirstIndex returns the first index written. 0 for no entries.
+func (s *ShardedStore) FirstIndex() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.first, nil
+}
+
+// LastIndex returns the last index written. 0 for no entries.
+func (s *ShardedStore) LastIndex() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.last, nil
+}
+
+// GetLog gets a log entry at a given index from the shard holding it.
+func (s *ShardedStore) GetLog(idx uint64, log *raft.Log) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.last == 0 || idx < s.first || idx > s.last {
+		return raft.ErrLogNotFound
+	}
+	return s.shards[s.shardOf(idx)].GetLog(idx, log)
+}
+
+// StoreLog stores a single raft log.
+func (s *ShardedStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+// StoreLogs splits logs by shard and stores each part in parallel. Logs
+// must follow the last index, or overwrite a suffix of the log, which is
+// deleted first.
+func (s *ShardedStore) StoreLogs(logs []*raft.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Index != logs[i-1].Index+1 {
+			return fmt.Errorf("%w: log %d follows log %d", ErrLogGap, logs[i].Index, logs[i-1].Index)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last > 0 && logs[0].Index > s.last+1 {
+		return fmt.Errorf("%w: log %d follows last index %d", ErrLogGap, logs[0].Index, s.last)
+	}
+	if s.last > 0 && logs[0].Index <= s.last {
+		if err := s.deleteRange(logs[0].Index, s.last); err != nil {
+			return err
+		}
+	}
+	if s.last == 0 {
+		if err := s.setFirst(logs[0].Index); err != nil {
+			return err
+		}
+	}
+
+	parts := make([][]*raft.Log, len(s.shards))
+	for _, log := range logs {
+		i := s.shardOf(log.Index)
+		parts[i] = append(parts[i], log)
+	}
+	err := s.each(func(i int, shard *BadgerRaftStore) error {
+		return shard.StoreLogs(parts[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.last == 0 {
+		s.first = logs[0].Index
+	}
+	s.last = logs[len(logs)-1].Index
+	return nil
+}
+
+// DeleteRange deletes logs min through max from every shard. The range must
+// include the first or the last log.
+func (s *ShardedStore) DeleteRange(min, max uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteRange(min, max)
+}
+
+// deleteRange deletes logs min through max. s.mu must be held.
+func (s *ShardedStore) deleteRange(min, max uint64) error {
+	first, last := s.first, s.last
+	switch {
+	case last == 0 || min > last || max < first:
+		return nil
+	case min <= first && max >= last:
+		if err := s.setFirst(last + 1); err != nil {
+			return err
+		}
+		s.first, s.last = 0, 0
+	case min <= first:
+		if err := s.setFirst(max + 1); err != nil {
+			return err
+		}
+		s.first, last = max+1, max
+	case max >= last:
+		s.last, first = min-1, min
+	default:
+		return fmt.Errorf("%w: can only delete logs from the head or the tail, not %d-%d", errors.ErrUnsupported, min, max)
+	}
+
+	return s.each(func(i int, shard *BadgerRaftStore) error {
+		return shard.DeleteRange(first, last)
+	})
+}
+
+// IsMonotonic reports that logs must be stored without gaps.
+func (s *ShardedStore) IsMonotonic() bool {
+	return true
+}
+
+// Set is used to set a key value set outside of the raft log.
+func (s *ShardedStore) Set(k, v []byte) error {
+	return s.shards[0].Set(k, v)
+}
+
+// Get is used to retrieve a value from the k/v store by key.
+func (s *ShardedStore) Get(k []byte) ([]byte, error) {
+	return s.shards[0].Get(k)
+}
+
+// SetUint64 is like Set, but handles uint64 values.
+func (s *ShardedStore) SetUint64(key []byte, val uint64) error {
+	return s.shards[0].SetUint64(key, val)
+}
+
+// GetUint64 is like Get, but handles uint64 values.
+func (s *ShardedStore) GetUint64(key []byte) (uint64, error) {
+	return s.shards[0].GetUint64(key)
+}
+
+// Close closes every shard.
+func (s *ShardedStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeShards()
+}
+
+func (s *ShardedStore) closeShards() error {
+	var errs []error
+	for _, shard := range s.shards {
+		errs = append(errs, shard.Close())
+	}
+	s.shards = nil
+	return errors.Join(errs...)
+}
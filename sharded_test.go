@@ -0,0 +1,161 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/kgantsov/raft-badgerstore/raftstoretest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testShardOptions(stripeSize uint64) ShardOptions {
+	opts := badger.DefaultOptions("").WithLogger(nil)
+	return ShardOptions{StripeSize: stripeSize, Store: Options{BadgerOptions: &opts}}
+}
+
+func shardDirs(dir string, n int) []string {
+	dirs := make([]string, n)
+	for i := range dirs {
+		dirs[i] = filepath.Join(dir, string(rune('a'+i)))
+	}
+	return dirs
+}
+
+func TestShardedStore_Implements(t *testing.T) {
+	var store interface{} = &ShardedStore{}
+	_, ok := store.(raft.LogStore)
+	assert.True(t, ok)
+
+	_, ok = store.(raft.StableStore)
+	assert.True(t, ok)
+
+	_, ok = store.(raft.MonotonicLogStore)
+	assert.True(t, ok)
+}
+
+func TestShardedStore_Conformance(t *testing.T) {
+	for name, stripe := range map[string]uint64{"OneStripe": 0, "ManyStripes": 3} {
+		t.Run(name, func(t *testing.T) {
+			raftstoretest.Run(t, func(t testing.TB, dir string) raftstoretest.Store {
+				store, err := OpenSharded(shardDirs(dir, 3), testShardOptions(stripe))
+				require.NoError(t, err)
+				return store
+			})
+		})
+	}
+}
+
+func TestShardedStore_Striping(t *testing.T) {
+	store, err := OpenSharded(shardDirs(t.TempDir(), 3), testShardOptions(10))
+	require.NoError(t, err)
+	defer store.Close()
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 100; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Stripes 0, 3, 6 and 9 go to the first shard, 1, 4, 7 and 10 to the
+	// second, and stripe 0 holds no log 0
+	assert.Equal(t, 39, countKeys(t, store.shards[0], dbLogs))
+	assert.Equal(t, 31, countKeys(t, store.shards[1], dbLogs))
+	assert.Equal(t, 30, countKeys(t, store.shards[2], dbLogs))
+
+	for _, idx := range []uint64{1, 9, 10, 29, 30, 100} {
+		var log raft.Log
+		require.NoError(t, store.GetLog(idx, &log))
+		assert.Equal(t, idx, log.Index)
+	}
+
+	// Deleting from the middle isn't supported
+	err = store.DeleteRange(40, 50)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+func TestShardedStore_LayoutMismatch(t *testing.T) {
+	dirs := shardDirs(t.TempDir(), 3)
+	store, err := OpenSharded(dirs, testShardOptions(10))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	_, err = OpenSharded([]string{dirs[1], dirs[0], dirs[2]}, testShardOptions(10))
+	assert.ErrorIs(t, err, ErrShardLayout)
+
+	_, err = OpenSharded(dirs[:2], testShardOptions(10))
+	assert.ErrorIs(t, err, ErrShardLayout)
+
+	_, err = OpenSharded(dirs, testShardOptions(20))
+	assert.ErrorIs(t, err, ErrShardLayout)
+
+	store, err = OpenSharded(dirs, testShardOptions(10))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+}
+
+func TestShardedStore_RecoversTornAppend(t *testing.T) {
+	dirs := shardDirs(t.TempDir(), 2)
+	store, err := OpenSharded(dirs, testShardOptions(10))
+	require.NoError(t, err)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 25; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Simulate a crash that stored the second shard's part of a batch of
+	// logs 26 to 45, but not the first's
+	var torn []*raft.Log
+	for i := uint64(30); i <= 39; i++ {
+		torn = append(torn, testRaftLog(i, "torn"))
+	}
+	require.NoError(t, store.shards[1].StoreLogs(torn))
+	require.NoError(t, store.Close())
+
+	store, err = OpenSharded(dirs, testShardOptions(10))
+	require.NoError(t, err)
+	defer store.Close()
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(25), last)
+	assert.Equal(t, 10, countKeys(t, store.shards[1], dbLogs))
+	assert.ErrorIs(t, store.GetLog(30, new(raft.Log)), raft.ErrLogNotFound)
+
+	require.NoError(t, store.StoreLog(testRaftLog(26, "log")))
+}
+
+func TestShardedStore_RecoversTornDelete(t *testing.T) {
+	dirs := shardDirs(t.TempDir(), 2)
+	store, err := OpenSharded(dirs, testShardOptions(10))
+	require.NoError(t, err)
+
+	var logs []*raft.Log
+	for i := uint64(1); i <= 40; i++ {
+		logs = append(logs, testRaftLog(i, "log"))
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	// Simulate a crash after recording the new first index of a deletion
+	// of logs 1 to 25, which only reached the first shard
+	require.NoError(t, store.setFirst(26))
+	require.NoError(t, store.shards[0].DeleteRange(1, 25))
+	require.NoError(t, store.Close())
+
+	store, err = OpenSharded(dirs, testShardOptions(10))
+	require.NoError(t, err)
+	defer store.Close()
+
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(26), first)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(40), last)
+	assert.Equal(t, 10, countKeys(t, store.shards[1], dbLogs))
+}
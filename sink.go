@@ -0,0 +1,234 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/raft"
+)
+
+var (
+	// An error indicating an object does not exist in a Sink
+	ErrObjectNotFound = errors.New("object not found")
+)
+
+// Sink stores named objects, for example in a directory or a bucket of an
+// object store. Backups and snapshots are streamed to and from it, so they
+// never have to be staged in full on local disk.
+//
+// Names are slash separated paths, like the keys of an object store.
+type Sink interface {
+	// Put stores the data read from r under name, replacing any object
+	// already stored there. The object only becomes visible to Get and List
+	// once all of r was stored.
+	Put(ctx context.Context, name string, r io.Reader) error
+
+	// Get returns a reader streaming the object stored under name, or
+	// ErrObjectNotFound if there is none.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// List returns the names of the objects starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// FileSink is a Sink keeping objects as files below a directory.
+type FileSink struct {
+	dir string
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// NewFileSink returns a Sink keeping objects in dir, which is created if it
+// doesn't exist.
+func NewFileSink(dir string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSink{dir: dir}, nil
+}
+
+// path returns the file an object is kept in.
+func (s *FileSink) path(name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name)), nil
+}
+
+// Put writes r to a temporary file next to the object and renames it into
+// place once it is synced.
+func (s *FileSink) Put(ctx context.Context, name string, r io.Reader) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".put-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, contextReader{ctx, r}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Get opens the file of the object.
+func (s *FileSink) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	return f, err
+}
+
+// List walks the directory for the files of objects starting with prefix.
+func (s *FileSink) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".put-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	slices.Sort(names)
+	return names, err
+}
+
+// contextReader stops reading from r once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// BackupTo streams a Backup of the store to sink under name, and returns the
+// newest version it contains.
+func (b *BadgerRaftStore) BackupTo(ctx context.Context, sink Sink, name string) (uint64, error) {
+	pr, pw := io.Pipe()
+
+	type result struct {
+		version uint64
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		version, err := b.Backup(pw)
+		pw.CloseWithError(err)
+		done <- result{version, err}
+	}()
+
+	err := sink.Put(ctx, name, pr)
+	// Unblocks the backup if Put gave up early
+	pr.CloseWithError(err)
+	res := <-done
+	if err != nil {
+		return 0, fmt.Errorf("storing backup %s: %w", name, err)
+	}
+	return res.version, res.err
+}
+
+// LoadBackupFrom loads the backup stored in sink under name, see LoadBackup.
+func (b *BadgerRaftStore) LoadBackupFrom(ctx context.Context, sink Sink, name string) error {
+	r, err := sink.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return b.LoadBackup(contextReader{ctx, r})
+}
+
+// ExportTo streams the snapshot with the given id to sink, storing its
+// metadata as JSON under <id>/meta.json and its data under <id>/state.bin.
+// The data is written first, so the metadata marks a complete export.
+func (s *SnapshotStore) ExportTo(ctx context.Context, sink Sink, id string) error {
+	meta, r, err := s.Open(id)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := sink.Put(ctx, path.Join(id, "state.bin"), r); err != nil {
+		return fmt.Errorf("storing snapshot %s: %w", id, err)
+	}
+	val, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := sink.Put(ctx, path.Join(id, "meta.json"), bytes.NewReader(val)); err != nil {
+		return fmt.Errorf("storing snapshot %s: %w", id, err)
+	}
+	return nil
+}
+
+// OpenExport opens a snapshot stored in sink by ExportTo, for example to
+// restore it with raft.RecoverCluster.
+func OpenExport(ctx context.Context, sink Sink, id string) (*raft.SnapshotMeta, io.ReadCloser, error) {
+	r, err := sink.Get(ctx, path.Join(id, "meta.json"))
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := new(raft.SnapshotMeta)
+	err = json.NewDecoder(r).Decode(meta)
+	r.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: snapshot %s: %w", ErrCorrupt, id, err)
+	}
+
+	data, err := sink.Get(ctx, path.Join(id, "state.bin"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, data, nil
+}
@@ -0,0 +1,146 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Put(ctx, "backups/a", strings.NewReader("one")))
+	require.NoError(t, sink.Put(ctx, "backups/b", strings.NewReader("two")))
+	require.NoError(t, sink.Put(ctx, "snapshots/c", strings.NewReader("three")))
+	require.NoError(t, sink.Put(ctx, "backups/a", strings.NewReader("replaced")))
+
+	r, err := sink.Get(ctx, "backups/a")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "replaced", string(data))
+
+	names, err := sink.List(ctx, "backups/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/a", "backups/b"}, names)
+	names, err = sink.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, names, 3)
+
+	_, err = sink.Get(ctx, "backups/missing")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	assert.Error(t, sink.Put(ctx, "../escape", strings.NewReader("x")))
+}
+
+// failingReader fails after returning some data, like a connection that
+// breaks in the middle of an upload.
+type failingReader struct{ n int }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("connection reset")
+	}
+	r.n--
+	return copy(p, "data"), nil
+}
+
+func TestFileSink_PutFails(t *testing.T) {
+	ctx := context.Background()
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+
+	require.Error(t, sink.Put(ctx, "a", &failingReader{n: 3}))
+
+	// A failed Put leaves nothing behind
+	names, err := sink.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+	_, err = sink.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestBadgerStore_BackupTo(t *testing.T) {
+	ctx := context.Background()
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "a"), testRaftLog(2, "b")}))
+
+	_, err = store.BackupTo(ctx, sink, "backups/node1")
+	require.NoError(t, err)
+
+	restored := testBadgerStore(t)
+	defer restored.Close()
+	defer os.Remove(restored.path)
+	require.NoError(t, restored.LoadBackupFrom(ctx, sink, "backups/node1"))
+
+	var l raft.Log
+	require.NoError(t, restored.GetLog(2, &l))
+	assert.Equal(t, "b", string(l.Data))
+
+	assert.ErrorIs(t, restored.LoadBackupFrom(ctx, sink, "backups/missing"), ErrObjectNotFound)
+}
+
+func TestBadgerStore_BackupTo_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+	require.NoError(t, store.StoreLog(testRaftLog(1, "a")))
+
+	_, err = store.BackupTo(ctx, sink, "backup")
+	assert.ErrorIs(t, err, context.Canceled)
+	names, err := sink.List(context.Background(), "")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestSnapshotStore_ExportTo(t *testing.T) {
+	ctx := context.Background()
+	sink, err := NewFileSink(t.TempDir())
+	require.NoError(t, err)
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	data := make([]byte, snapshotChunkSize+100)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	require.NoError(t, snapshots.ExportTo(ctx, sink, id))
+
+	meta, r, err := OpenExport(ctx, sink, id)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, id, meta.ID)
+	assert.Equal(t, uint64(10), meta.Index)
+	exported, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, exported))
+
+	_, _, err = OpenExport(ctx, sink, "missing")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+}
@@ -0,0 +1,679 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Size of the chunks snapshots are split into, each stored under its
+	// own key so no single value or transaction grows too large.
+	snapshotChunkSize = 1 << 20
+
+	// How many snapshots NewNodeStore keeps if RetainSnapshots is not set.
+	defaultRetainSnapshots = 2
+)
+
+var (
+	// Bucket names snapshot metadata and data are stored in
+	dbSnapMeta = []byte("snapmeta")
+	dbSnapData = []byte("snapdata")
+
+	// An error indicating a snapshot does not exist
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// SnapshotStore is a raft.SnapshotStore keeping snapshots in the Badger
+// database of a store, next to the logs. Snapshots are split into chunks
+// of 1MiB and only become visible once their metadata is written on Close.
+// A manifest records how many chunks are durable, so a snapshot whose
+// writing failed can be resumed, see Resume.
+type SnapshotStore struct {
+	store     *BadgerRaftStore
+	retention SnapshotRetention
+
+	// active holds the IDs of the snapshots being written
+	mu     sync.Mutex
+	active map[string]bool
+
+	// onPersist is called with the index of every snapshot once it is
+	// durable, if set.
+	onPersist func(index uint64)
+}
+
+var _ raft.SnapshotStore = (*SnapshotStore)(nil)
+
+// SnapshotRetention selects the snapshots a SnapshotStore keeps. Snapshots
+// beyond it are deleted every time a new one was created. The newest
+// snapshot is always kept.
+type SnapshotRetention struct {
+	// Count is how many of the newest snapshots are kept. Zero keeps any
+	// number of snapshots younger than MaxAge.
+	Count int
+
+	// MaxAge deletes snapshots created longer than MaxAge ago. Zero keeps
+	// snapshots regardless of their age.
+	MaxAge time.Duration
+}
+
+// retained splits snapshots, sorted newest first, into those the retention
+// keeps and those it drops at the time now.
+func (r SnapshotRetention) retained(snapshots []*raft.SnapshotMeta, now time.Time) (keep, drop []*raft.SnapshotMeta) {
+	for i, meta := range snapshots {
+		expired := false
+		if created, ok := snapshotCreatedAt(meta.ID); ok && r.MaxAge > 0 {
+			expired = now.Sub(created) > r.MaxAge
+		}
+		if i > 0 && (expired || (r.Count > 0 && i >= r.Count)) {
+			drop = append(drop, meta)
+		} else {
+			keep = append(keep, meta)
+		}
+	}
+	return keep, drop
+}
+
+// snapshotCreatedAt returns when the snapshot with the given id was created,
+// which Create records in the id.
+func snapshotCreatedAt(id string) (time.Time, bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// NewSnapshotStore returns a SnapshotStore keeping the newest retain
+// snapshots in store. Chunks left behind by snapshots that were never
+// completed, for example because of a crash, are removed.
+func NewSnapshotStore(store *BadgerRaftStore, retain int) (*SnapshotStore, error) {
+	if retain < 1 {
+		return nil, fmt.Errorf("must retain at least one snapshot")
+	}
+	return NewSnapshotStoreWithRetention(store, SnapshotRetention{Count: retain})
+}
+
+// NewSnapshotStoreWithRetention is like NewSnapshotStore, but keeps the
+// snapshots selected by retention.
+func NewSnapshotStoreWithRetention(store *BadgerRaftStore, retention SnapshotRetention) (*SnapshotStore, error) {
+	if retention.Count < 0 || retention.MaxAge < 0 || (retention.Count == 0 && retention.MaxAge == 0) {
+		return nil, fmt.Errorf("snapshot retention needs a positive count or max age")
+	}
+
+	s := &SnapshotStore{store: store, retention: retention, active: make(map[string]bool)}
+	if err := s.removeIncomplete(); err != nil {
+		return nil, storageError(err)
+	}
+	return s, nil
+}
+
+// Create starts a new snapshot. Its data is written through the returned
+// sink and the snapshot becomes visible to List and Open once the sink is
+// closed.
+func (s *SnapshotStore) Create(version raft.SnapshotVersion, index, term uint64,
+	configuration raft.Configuration, configurationIndex uint64, trans raft.Transport) (raft.SnapshotSink, error) {
+	if version != 1 {
+		return nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	id := fmt.Sprintf("%d-%d-%d", term, index, time.Now().UnixMilli())
+	log.Info().Str("id", id).Msg("Creating new snapshot")
+	s.activate(id)
+
+	return &snapshotSink{
+		snapshots: s,
+		meta: raft.SnapshotMeta{
+			Version:            version,
+			ID:                 id,
+			Index:              index,
+			Term:               term,
+			Configuration:      configuration,
+			ConfigurationIndex: configurationIndex,
+		},
+	}, nil
+}
+
+// List returns the metadata of the retained snapshots, newest first.
+func (s *SnapshotStore) List() ([]*raft.SnapshotMeta, error) {
+	if err := s.store.enter(); err != nil {
+		return nil, err
+	}
+	defer s.store.exit()
+
+	txn := s.store.newTransaction(s.store.db, false)
+	defer txn.Discard()
+
+	snapshots, err := listSnapshots(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	snapshots, _ = s.retention.retained(snapshots, time.Now())
+	return snapshots, nil
+}
+
+// Open returns the metadata of the snapshot with the given id and a reader
+// streaming its data. The reader sees the snapshot as it was when Open was
+// called, even if it is removed or overwritten while being read, until it
+// is closed.
+func (s *SnapshotStore) Open(id string) (*raft.SnapshotMeta, io.ReadCloser, error) {
+	if err := s.store.enter(); err != nil {
+		return nil, nil, err
+	}
+	defer s.store.exit()
+
+	txn := s.store.newTransaction(s.store.db, false)
+	item, err := txn.Get(prefixedKey(dbSnapMeta, []byte(id)))
+	if err != nil {
+		txn.Discard()
+		return nil, nil, readError(err, ErrSnapshotNotFound)
+	}
+
+	meta := new(raft.SnapshotMeta)
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, meta)
+	}); err != nil {
+		txn.Discard()
+		return nil, nil, fmt.Errorf("%w: snapshot %s: %w", ErrCorrupt, id, err)
+	}
+
+	return meta, &snapshotReader{store: s.store, txn: txn, id: id, size: meta.Size}, nil
+}
+
+// SnapshotInfo describes a snapshot kept by the store, see ListSnapshots.
+type SnapshotInfo struct {
+	ID    string `json:"id"`
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+
+	// Size is the size of the snapshot data before compression.
+	Size int64 `json:"size"`
+
+	// Configuration is the cluster configuration as of ConfigurationIndex.
+	Configuration      raft.Configuration `json:"configuration"`
+	ConfigurationIndex uint64             `json:"configuration_index"`
+
+	// CreatedAt is when the snapshot was created, or zero if its ID doesn't
+	// tell.
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// ListSnapshots describes the complete snapshots kept in the store, newest
+// first, to see which restore points exist.
+func (b *BadgerRaftStore) ListSnapshots() ([]SnapshotInfo, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	snapshots, err := listSnapshots(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	infos := make([]SnapshotInfo, 0, len(snapshots))
+	for _, meta := range snapshots {
+		createdAt, _ := snapshotCreatedAt(meta.ID)
+		infos = append(infos, SnapshotInfo{
+			ID:                 meta.ID,
+			Index:              meta.Index,
+			Term:               meta.Term,
+			Size:               meta.Size,
+			Configuration:      meta.Configuration,
+			ConfigurationIndex: meta.ConfigurationIndex,
+			CreatedAt:          createdAt,
+		})
+	}
+	return infos, nil
+}
+
+// listSnapshots returns the metadata of all complete snapshots, newest
+// first.
+func listSnapshots(txn *badger.Txn) ([]*raft.SnapshotMeta, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var snapshots []*raft.SnapshotMeta
+	for it.Seek(dbSnapMeta); it.ValidForPrefix(dbSnapMeta); it.Next() {
+		meta := new(raft.SnapshotMeta)
+		err := it.Item().Value(func(val []byte) error {
+			return json.Unmarshal(val, meta)
+		})
+		if err != nil {
+			log.Warn().Err(err).Str("id", string(it.Item().Key()[len(dbSnapMeta):])).Msg("Skipping unreadable snapshot")
+			continue
+		}
+		snapshots = append(snapshots, meta)
+	}
+
+	// Newest first, the same order as raft's FileSnapshotStore
+	slices.SortFunc(snapshots, func(a, b *raft.SnapshotMeta) int {
+		if a.Term != b.Term {
+			return cmp.Compare(b.Term, a.Term)
+		}
+		if a.Index != b.Index {
+			return cmp.Compare(b.Index, a.Index)
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return snapshots, nil
+}
+
+// Prune deletes the snapshots beyond the retention. It runs every time a
+// snapshot was created, so it is only needed to apply MaxAge without new
+// snapshots being taken.
+func (s *SnapshotStore) Prune() error {
+	if err := s.store.enter(); err != nil {
+		return err
+	}
+	defer s.store.exit()
+
+	if err := s.reap(); err != nil {
+		return storageError(err)
+	}
+	return nil
+}
+
+// reap deletes the snapshots beyond the retention.
+func (s *SnapshotStore) reap() error {
+	txn := s.store.newTransaction(s.store.db, false)
+	snapshots, err := listSnapshots(txn)
+	txn.Discard()
+	if err != nil {
+		return err
+	}
+
+	_, drop := s.retention.retained(snapshots, time.Now())
+	for _, meta := range drop {
+		log.Info().Str("id", meta.ID).Msg("Reaping snapshot")
+		if err := s.deleteSnapshot(meta.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSnapshot removes the metadata of a snapshot, then its data.
+func (s *SnapshotStore) deleteSnapshot(id string) error {
+	err := s.store.update(s.store.db, func(txn *badger.Txn) error {
+		return txn.Delete(prefixedKey(dbSnapMeta, []byte(id)))
+	})
+	if err != nil {
+		return err
+	}
+	return s.store.deletePrefix(snapshotDataPrefix(id))
+}
+
+// removeIncomplete deletes the data of snapshots that have neither metadata
+// nor a manifest to resume them from.
+func (s *SnapshotStore) removeIncomplete() error {
+	var incomplete []string
+	err := s.store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(dbSnapData); it.ValidForPrefix(dbSnapData); it.Next() {
+			key := it.Item().Key()
+			id, _, ok := bytes.Cut(key[len(dbSnapData):], []byte("/"))
+			if !ok || slices.Contains(incomplete, string(id)) {
+				continue
+			}
+			_, err := txn.Get(prefixedKey(dbSnapMeta, id))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				_, err = txn.Get(snapshotManifestKey(string(id)))
+			}
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				incomplete = append(incomplete, string(id))
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range incomplete {
+		log.Warn().Str("id", id).Msg("Removing incomplete snapshot")
+		if err := s.store.deletePrefix(snapshotDataPrefix(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePrefix deletes every key starting with prefix, committing as often
+// as Badger's transaction size limit requires.
+func (b *BadgerRaftStore) deletePrefix(prefix []byte) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	for {
+		txn := b.newTransaction(b.db, true)
+		it := txn.NewIterator(opts)
+
+		count := 0
+		var err error
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err = txn.Delete(it.Item().KeyCopy(nil)); err != nil {
+				break
+			}
+			count++
+		}
+		it.Close()
+
+		if err != nil && !errors.Is(err, badger.ErrTxnTooBig) {
+			txn.Discard()
+			return err
+		}
+		if count == 0 {
+			txn.Discard()
+			return nil
+		}
+		if cerr := b.commit(txn); cerr != nil {
+			return cerr
+		}
+		if err == nil {
+			return nil
+		}
+	}
+}
+
+// snapshotDataPrefix returns the prefix of the data chunks of a snapshot.
+func snapshotDataPrefix(id string) []byte {
+	return prefixedKey(dbSnapData, []byte(id+"/"))
+}
+
+// snapshotChunkKey returns the key of the n-th data chunk of a snapshot.
+func snapshotChunkKey(id string, n uint32) []byte {
+	key := snapshotDataPrefix(id)
+	return binary.BigEndian.AppendUint32(key, n)
+}
+
+// snapshotSink buffers snapshot data and writes it in chunks.
+type snapshotSink struct {
+	snapshots *SnapshotStore
+	meta      raft.SnapshotMeta
+
+	buf    []byte
+	chunks uint32
+	closed bool
+
+	// failed is set once a write failed, so the durable chunks are kept
+	// to resume the snapshot from
+	failed bool
+}
+
+// ID returns the ID of the snapshot being written.
+func (s *snapshotSink) ID() string {
+	return s.meta.ID
+}
+
+// Write buffers p and stores every chunk that fills up.
+func (s *snapshotSink) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	s.meta.Size += int64(len(p))
+
+	if s.failed {
+		return 0, fmt.Errorf("snapshot %s failed to be written, resume it instead", s.meta.ID)
+	}
+	for len(s.buf) >= snapshotChunkSize {
+		if err := s.writeChunk(s.buf[:snapshotChunkSize]); err != nil {
+			s.failed = true
+			return 0, err
+		}
+		s.buf = s.buf[snapshotChunkSize:]
+	}
+	return len(p), nil
+}
+
+// writeChunk stores the next chunk of data, together with a manifest
+// recording it. The last chunk of a snapshot is written by Close, which
+// removes the manifest.
+func (s *snapshotSink) writeChunk(chunk []byte) error {
+	store := s.snapshots.store
+	if err := store.enter(); err != nil {
+		return err
+	}
+	defer store.exit()
+
+	txn := store.newTransaction(store.db, true)
+	defer txn.Discard()
+
+	key := snapshotChunkKey(s.meta.ID, s.chunks)
+	val, flags, err := encodeChunk(key, chunk, store.compressSnapshots, store.snapshotCipher)
+	if err != nil {
+		return err
+	}
+	if err := txn.SetEntry(badger.NewEntry(key, slices.Clone(val)).WithMeta(flags)); err != nil {
+		return storageError(err)
+	}
+	if len(chunk) == snapshotChunkSize {
+		if err := writeManifest(txn, s.meta, s.chunks+1); err != nil {
+			return storageError(err)
+		}
+	}
+	if err := store.commit(txn); err != nil {
+		return store.writeError(err)
+	}
+	s.chunks++
+	return nil
+}
+
+// Close stores the remaining data and the metadata, making the snapshot
+// visible, then deletes snapshots beyond the retention and those that
+// failed to be written. If storing fails, the durable chunks are kept to
+// resume the snapshot from.
+func (s *snapshotSink) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer s.snapshots.deactivate(s.meta.ID)
+
+	if s.failed {
+		return fmt.Errorf("snapshot %s failed to be written, resume it instead", s.meta.ID)
+	}
+	if len(s.buf) > 0 {
+		if err := s.writeChunk(s.buf); err != nil {
+			s.failed = true
+			return err
+		}
+		s.buf = nil
+	}
+
+	if err := s.writeMeta(); err != nil {
+		s.failed = true
+		return err
+	}
+
+	store := s.snapshots.store
+	store.stats.snapshots.Add(1)
+
+	if err := s.snapshots.reap(); err != nil {
+		log.Error().Err(err).Msg("Failed to reap snapshots")
+	}
+	s.snapshots.deactivate(s.meta.ID)
+	if err := s.snapshots.reapResumable(); err != nil {
+		log.Error().Err(err).Msg("Failed to reap incomplete snapshots")
+	}
+	if s.snapshots.onPersist != nil {
+		s.snapshots.onPersist(s.meta.Index)
+	}
+	return nil
+}
+
+// writeMeta stores the metadata of the snapshot and removes its manifest.
+func (s *snapshotSink) writeMeta() error {
+	store := s.snapshots.store
+	if err := store.enter(); err != nil {
+		return err
+	}
+	defer store.exit()
+
+	val, err := json.Marshal(s.meta)
+	if err != nil {
+		return err
+	}
+
+	txn := store.newTransaction(store.db, true)
+	defer txn.Discard()
+
+	if err := txn.Set(prefixedKey(dbSnapMeta, []byte(s.meta.ID)), val); err != nil {
+		return storageError(err)
+	}
+	if err := txn.Delete(snapshotManifestKey(s.meta.ID)); err != nil {
+		return storageError(err)
+	}
+	return store.writeError(store.commit(txn))
+}
+
+// Cancel discards the snapshot and the chunks written so far. If a write
+// failed, raft cancels the sink too; the durable chunks are kept then, to
+// resume the snapshot from.
+func (s *snapshotSink) Cancel() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer s.snapshots.deactivate(s.meta.ID)
+
+	if s.failed {
+		log.Warn().Str("id", s.meta.ID).Int64("offset", int64(s.chunks)*snapshotChunkSize).Msg("Keeping failed snapshot to resume")
+		return nil
+	}
+	return s.cancel()
+}
+
+func (s *snapshotSink) cancel() error {
+	store := s.snapshots.store
+	if err := store.enter(); err != nil {
+		return err
+	}
+	defer store.exit()
+
+	err := store.update(store.db, func(txn *badger.Txn) error {
+		return txn.Delete(snapshotManifestKey(s.meta.ID))
+	})
+	if err != nil {
+		return storageError(err)
+	}
+	return store.deletePrefix(snapshotDataPrefix(s.meta.ID))
+}
+
+// snapshotReader streams the data of a snapshot, one chunk at a time. All
+// chunks are read in the transaction the metadata was read in, so they all
+// belong to the same snapshot.
+type snapshotReader struct {
+	store *BadgerRaftStore
+	txn   *badger.Txn
+	id    string
+	size  int64
+	read  int64
+	chunk uint32
+	buf   []byte
+	eof   bool
+}
+
+// Read implements io.Reader.
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next loads the next chunk, or sets eof if there are no more. It fails
+// with ErrCorrupt if the chunks hold less or more data than the snapshot
+// metadata records.
+func (r *snapshotReader) next() error {
+	if r.txn == nil {
+		return ErrClosed
+	}
+	if err := r.store.enter(); err != nil {
+		return err
+	}
+	defer r.store.exit()
+
+	if err := r.store.beforeRead(); err != nil {
+		return err
+	}
+
+	key := snapshotChunkKey(r.id, r.chunk)
+	item, err := r.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		if r.read != r.size {
+			return fmt.Errorf("%w: snapshot %s: read %d of %d bytes: %w", ErrCorrupt, r.id, r.read, r.size, io.ErrUnexpectedEOF)
+		}
+		r.eof = true
+		return nil
+	}
+	if err != nil {
+		return storageError(err)
+	}
+
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return storageError(err)
+	}
+	r.buf, err = decodeChunk(key, val, item.UserMeta(), r.store.snapshotCipher)
+	if errors.Is(err, ErrSnapshotKeyMissing) {
+		return fmt.Errorf("snapshot %s: %w", r.id, err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: snapshot %s chunk %d: %w", ErrCorrupt, r.id, r.chunk, err)
+	}
+	r.read += int64(len(r.buf))
+	if r.read > r.size {
+		return fmt.Errorf("%w: snapshot %s: more than %d bytes", ErrCorrupt, r.id, r.size)
+	}
+	r.chunk++
+	return nil
+}
+
+// Close implements io.Closer and releases the transaction the snapshot is
+// read in.
+func (r *snapshotReader) Close() error {
+	if r.txn == nil {
+		return nil
+	}
+	// Once the store is closed the transaction went with it
+	if err := r.store.enter(); err == nil {
+		r.txn.Discard()
+		r.store.exit()
+	}
+	r.txn = nil
+	return nil
+}
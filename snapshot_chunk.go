@@ -0,0 +1,98 @@
+package raftbadgerstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Flags describing how a snapshot chunk is stored, kept as the user meta of
+// its entry. Chunks are compressed first, then encrypted. Chunks written
+// before either was supported have no flags.
+const (
+	chunkCompressed byte = 1 << 0
+	chunkEncrypted  byte = 1 << 1
+)
+
+var (
+	// Encoders and decoders are safe for concurrent EncodeAll and
+	// DecodeAll calls, so all snapshots share them.
+	chunkEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	})
+	chunkDecoder = sync.OnceValue(func() *zstd.Decoder {
+		dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(4*snapshotChunkSize))
+		return dec
+	})
+
+	// An error indicating a snapshot is encrypted but the store has no key
+	// to decrypt it, see Options.SnapshotEncryptionKey
+	ErrSnapshotKeyMissing = errors.New("snapshot is encrypted and no snapshot key is set")
+)
+
+// newSnapshotCipher returns the AES-GCM cipher snapshot chunks are encrypted
+// with, or nil if key is empty.
+func newSnapshotCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encodeChunk returns the value and flags a chunk of snapshot data is stored
+// as under key. Chunks that don't compress are stored uncompressed. Encrypted
+// chunks start with their nonce, and are bound to key so they can't be
+// swapped for other chunks.
+func encodeChunk(key, chunk []byte, compress bool, aead cipher.AEAD) ([]byte, byte, error) {
+	var flags byte
+	if compress {
+		compressed := chunkEncoder().EncodeAll(chunk, nil)
+		if len(compressed) < len(chunk) {
+			chunk, flags = compressed, flags|chunkCompressed
+		}
+	}
+
+	if aead != nil {
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(chunk)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, 0, err
+		}
+		chunk, flags = aead.Seal(nonce, nonce, chunk, key), flags|chunkEncrypted
+	}
+	return chunk, flags, nil
+}
+
+// decodeChunk returns the snapshot data of a chunk stored as val under key.
+func decodeChunk(key, val []byte, flags byte, aead cipher.AEAD) ([]byte, error) {
+	if flags&^(chunkCompressed|chunkEncrypted) != 0 {
+		return nil, fmt.Errorf("unknown chunk flags %#x", flags)
+	}
+
+	if flags&chunkEncrypted != 0 {
+		if aead == nil {
+			return nil, ErrSnapshotKeyMissing
+		}
+		if len(val) < aead.NonceSize() {
+			return nil, errors.New("encrypted chunk is truncated")
+		}
+		var err error
+		if val, err = aead.Open(nil, val[:aead.NonceSize()], val[aead.NonceSize():], key); err != nil {
+			return nil, err
+		}
+	}
+
+	if flags&chunkCompressed != 0 {
+		return chunkDecoder().DecodeAll(val, nil)
+	}
+	return val, nil
+}
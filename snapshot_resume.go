@@ -0,0 +1,185 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Bucket the manifests of snapshots being written are stored in
+	dbSnapManifest = []byte("snapmanifest")
+
+	// An error indicating a snapshot can't be resumed, because it is
+	// complete, still being written or was never started
+	ErrSnapshotNotResumable = errors.New("snapshot can't be resumed")
+)
+
+// snapshotManifest records how much of a snapshot is durable. It is written
+// in the same transaction as every chunk, and deleted once the snapshot is
+// complete.
+type snapshotManifest struct {
+	// Meta is the metadata of the snapshot, with Size the number of bytes
+	// in the durable chunks.
+	Meta raft.SnapshotMeta `json:"meta"`
+
+	// Chunks is the number of durable chunks, all of them full.
+	Chunks uint32 `json:"chunks"`
+}
+
+func snapshotManifestKey(id string) []byte {
+	return prefixedKey(dbSnapManifest, []byte(id))
+}
+
+// writeManifest records in txn that the first chunks chunks of the snapshot
+// described by meta are durable.
+func writeManifest(txn *badger.Txn, meta raft.SnapshotMeta, chunks uint32) error {
+	meta.Size = int64(chunks) * snapshotChunkSize
+	val, err := json.Marshal(snapshotManifest{Meta: meta, Chunks: chunks})
+	if err != nil {
+		return err
+	}
+	return txn.Set(snapshotManifestKey(meta.ID), val)
+}
+
+// readManifest reads the manifest of the snapshot with the given id.
+func readManifest(txn *badger.Txn, id string) (*snapshotManifest, error) {
+	item, err := txn.Get(snapshotManifestKey(id))
+	if err != nil {
+		return nil, err
+	}
+	m := new(snapshotManifest)
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, m)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: manifest of snapshot %s: %w", ErrCorrupt, id, err)
+	}
+	return m, nil
+}
+
+// ListResumable returns the metadata of snapshots whose writing failed and
+// that can be resumed with Resume, with Size the number of bytes already
+// stored.
+func (s *SnapshotStore) ListResumable() ([]*raft.SnapshotMeta, error) {
+	if err := s.store.enter(); err != nil {
+		return nil, err
+	}
+	defer s.store.exit()
+
+	txn := s.store.newTransaction(s.store.db, false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var metas []*raft.SnapshotMeta
+	for it.Seek(dbSnapManifest); it.ValidForPrefix(dbSnapManifest); it.Next() {
+		id := string(it.Item().Key()[len(dbSnapManifest):])
+		if s.isActive(id) {
+			continue
+		}
+		m, err := readManifest(txn, id)
+		if err != nil {
+			return nil, storageError(err)
+		}
+		metas = append(metas, &m.Meta)
+	}
+	return metas, nil
+}
+
+// Resume continues writing a snapshot whose writing failed, from the last
+// durable chunk on. It returns a sink for the rest of the snapshot and the
+// offset in the snapshot data its first Write continues at; the caller
+// must skip that many bytes of the data. It fails with
+// ErrSnapshotNotResumable if the snapshot is complete, still being written
+// or unknown.
+//
+// Resumable snapshots survive restarts of the store, and are deleted once
+// a newer snapshot is complete.
+func (s *SnapshotStore) Resume(id string) (raft.SnapshotSink, int64, error) {
+	if err := s.store.enter(); err != nil {
+		return nil, 0, err
+	}
+	defer s.store.exit()
+
+	txn := s.store.newTransaction(s.store.db, false)
+	defer txn.Discard()
+
+	m, err := readManifest(txn, id)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrSnapshotNotResumable, id)
+	}
+	if err != nil {
+		return nil, 0, storageError(err)
+	}
+	if !s.activate(id) {
+		return nil, 0, fmt.Errorf("%w: %s is being written", ErrSnapshotNotResumable, id)
+	}
+
+	log.Info().Str("id", id).Int64("offset", m.Meta.Size).Msg("Resuming snapshot")
+	return &snapshotSink{snapshots: s, meta: m.Meta, chunks: m.Chunks}, m.Meta.Size, nil
+}
+
+// activate marks the snapshot with the given id as being written, and
+// reports false if it already was.
+func (s *SnapshotStore) activate(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[id] {
+		return false
+	}
+	s.active[id] = true
+	return true
+}
+
+func (s *SnapshotStore) deactivate(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, id)
+}
+
+func (s *SnapshotStore) isActive(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active[id]
+}
+
+// reapResumable deletes snapshots that failed to be written and aren't
+// being resumed.
+func (s *SnapshotStore) reapResumable() error {
+	metas, err := s.ListResumable()
+	if err != nil {
+		return err
+	}
+	for _, meta := range metas {
+		if err := s.deleteResumable(meta.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteResumable deletes the manifest of a snapshot that failed to be
+// written, then its data, unless it is being resumed.
+func (s *SnapshotStore) deleteResumable(id string) error {
+	if !s.activate(id) {
+		return nil
+	}
+	defer s.deactivate(id)
+
+	log.Info().Str("id", id).Msg("Reaping incomplete snapshot")
+	err := s.store.update(s.store.db, func(txn *badger.Txn) error {
+		return txn.Delete(snapshotManifestKey(id))
+	})
+	if err != nil {
+		return err
+	}
+	return s.store.deletePrefix(snapshotDataPrefix(id))
+}
@@ -0,0 +1,127 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/raft"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotStore_Resume(t *testing.T) {
+	dir := t.TempDir()
+	fp := NewFailpoints()
+	store, err := Open(dir, Options{Failpoints: fp})
+	require.NoError(t, err)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	data := make([]byte, 3*snapshotChunkSize+100)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+
+	// The third chunk fails to be stored
+	sink, err := snapshots.Create(1, 10, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	_, err = sink.Write(data[:2*snapshotChunkSize])
+	require.NoError(t, err)
+	fp.FailCommits(1, nil)
+	_, err = sink.Write(data[2*snapshotChunkSize:])
+	require.ErrorIs(t, err, ErrIO)
+	_, err = sink.Write(data)
+	require.Error(t, err)
+	require.NoError(t, sink.Cancel())
+
+	list, err := snapshots.List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// Resumable snapshots survive a restart
+	require.NoError(t, store.Close())
+	store, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer store.Close()
+	snapshots, err = NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	resumable, err := snapshots.ListResumable()
+	require.NoError(t, err)
+	require.Len(t, resumable, 1)
+	assert.Equal(t, sink.ID(), resumable[0].ID)
+	assert.Equal(t, int64(2*snapshotChunkSize), resumable[0].Size)
+
+	resumed, offset, err := snapshots.Resume(sink.ID())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*snapshotChunkSize), offset)
+	_, _, err = snapshots.Resume(sink.ID())
+	assert.ErrorIs(t, err, ErrSnapshotNotResumable)
+
+	_, err = resumed.Write(data[offset:])
+	require.NoError(t, err)
+	require.NoError(t, resumed.Close())
+
+	meta, r, err := snapshots.Open(sink.ID())
+	require.NoError(t, err)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, read))
+	assert.Equal(t, int64(len(data)), meta.Size)
+
+	resumable, err = snapshots.ListResumable()
+	require.NoError(t, err)
+	assert.Empty(t, resumable)
+	_, _, err = snapshots.Resume(sink.ID())
+	assert.ErrorIs(t, err, ErrSnapshotNotResumable)
+}
+
+func TestSnapshotStore_Resume_ReapedByNewerSnapshot(t *testing.T) {
+	fp := NewFailpoints()
+	store, err := Open(t.TempDir(), Options{Failpoints: fp})
+	require.NoError(t, err)
+	defer store.Close()
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	sink, err := snapshots.Create(1, 10, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	_, err = sink.Write(make([]byte, snapshotChunkSize))
+	require.NoError(t, err)
+	fp.FailCommits(1, nil)
+	_, err = sink.Write(make([]byte, snapshotChunkSize))
+	require.Error(t, err)
+	require.NoError(t, sink.Cancel())
+
+	testCreateSnapshot(t, snapshots, 20, []byte("data"))
+
+	resumable, err := snapshots.ListResumable()
+	require.NoError(t, err)
+	assert.Empty(t, resumable)
+	assert.Equal(t, 0, countKeys(t, store, snapshotDataPrefix(sink.ID())))
+	assert.Equal(t, 0, countKeys(t, store, snapshotManifestKey(sink.ID())))
+}
+
+func TestSnapshotStore_Cancel_RemovesManifest(t *testing.T) {
+	store, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	sink, err := snapshots.Create(1, 10, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	_, err = sink.Write(make([]byte, 2*snapshotChunkSize))
+	require.NoError(t, err)
+	require.NoError(t, sink.Cancel())
+
+	resumable, err := snapshots.ListResumable()
+	require.NoError(t, err)
+	assert.Empty(t, resumable)
+	assert.Equal(t, 0, countKeys(t, store, snapshotDataPrefix(sink.ID())))
+}
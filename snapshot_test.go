@@ -0,0 +1,502 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCreateSnapshot(t *testing.T, snapshots *SnapshotStore, index uint64, data []byte) string {
+	sink, err := snapshots.Create(1, index, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+
+	_, err = sink.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+	return sink.ID()
+}
+
+func TestSnapshotStore_CreateOpen(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	// Large enough to span several chunks
+	data := make([]byte, 2*snapshotChunkSize+100)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	meta, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, uint64(10), meta.Index)
+	assert.Equal(t, int64(len(data)), meta.Size)
+
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, got))
+	assert.Equal(t, uint64(1), store.Stats().Snapshots)
+
+	_, _, err = snapshots.Open("missing")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestSnapshotStore_Retain(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	for i := uint64(1); i <= 3; i++ {
+		testCreateSnapshot(t, snapshots, i*10, []byte("data"))
+	}
+
+	list, err := snapshots.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, uint64(30), list[0].Index)
+	assert.Equal(t, uint64(20), list[1].Index)
+}
+
+func TestSnapshotStore_RetainMaxAge(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStoreWithRetention(store, SnapshotRetention{MaxAge: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	first := testCreateSnapshot(t, snapshots, 10, []byte("data"))
+	testCreateSnapshot(t, snapshots, 20, []byte("data"))
+	list, err := snapshots.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	// Creating a snapshot prunes those that expired
+	time.Sleep(100 * time.Millisecond)
+	testCreateSnapshot(t, snapshots, 30, []byte("data"))
+	list, err = snapshots.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, uint64(30), list[0].Index)
+	assert.Equal(t, 0, countKeys(t, store, snapshotDataPrefix(first)))
+
+	// The newest snapshot is kept however old it is
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, snapshots.Prune())
+	list, err = snapshots.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	now := time.Now()
+	snapshot := func(index uint64, age time.Duration) *raft.SnapshotMeta {
+		return &raft.SnapshotMeta{ID: fmt.Sprintf("1-%d-%d", index, now.Add(-age).UnixMilli()), Index: index}
+	}
+	snapshots := []*raft.SnapshotMeta{
+		snapshot(40, time.Minute),
+		snapshot(30, 2*time.Minute),
+		snapshot(20, 3*time.Hour),
+		{ID: "unparsable", Index: 10},
+	}
+	indexes := func(metas []*raft.SnapshotMeta) []uint64 {
+		var idx []uint64
+		for _, m := range metas {
+			idx = append(idx, m.Index)
+		}
+		return idx
+	}
+
+	for _, tc := range []struct {
+		retention  SnapshotRetention
+		keep, drop []uint64
+	}{
+		{SnapshotRetention{Count: 2}, []uint64{40, 30}, []uint64{20, 10}},
+		{SnapshotRetention{MaxAge: time.Hour}, []uint64{40, 30, 10}, []uint64{20}},
+		{SnapshotRetention{Count: 1, MaxAge: time.Hour}, []uint64{40}, []uint64{30, 20, 10}},
+		{SnapshotRetention{MaxAge: time.Second}, []uint64{40, 10}, []uint64{30, 20}},
+	} {
+		keep, drop := tc.retention.retained(snapshots, now)
+		assert.Equal(t, tc.keep, indexes(keep), "%+v", tc.retention)
+		assert.Equal(t, tc.drop, indexes(drop), "%+v", tc.retention)
+	}
+
+	_, err := NewSnapshotStoreWithRetention(nil, SnapshotRetention{})
+	assert.Error(t, err)
+}
+
+// storedChunkBytes returns the size of the stored chunks of a snapshot.
+func storedChunkBytes(t *testing.T, store *BadgerRaftStore, id string) int {
+	size := 0
+	err := store.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := snapshotDataPrefix(id)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			size += len(val)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return size
+}
+
+func TestSnapshotStore_Compression(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{CompressSnapshots: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 3)
+	require.NoError(t, err)
+
+	compressible := bytes.Repeat([]byte("raft state "), 2*snapshotChunkSize/10)
+	random := make([]byte, snapshotChunkSize+100)
+	_, err = rand.Read(random)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		data         []byte
+		compressible bool
+	}{
+		{compressible, true},
+		{random, false},
+	} {
+		data := tc.data
+		id := testCreateSnapshot(t, snapshots, 10, data)
+
+		meta, r, err := snapshots.Open(id)
+		require.NoError(t, err)
+		read, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.True(t, bytes.Equal(data, read))
+		assert.Equal(t, int64(len(data)), meta.Size)
+
+		stored := storedChunkBytes(t, store, id)
+		if tc.compressible {
+			assert.Less(t, stored, len(data)/10)
+		} else {
+			// Data that doesn't compress is stored as is
+			assert.Equal(t, len(data), stored)
+		}
+	}
+}
+
+func TestSnapshotStore_Compression_ReadsUncompressed(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	data := bytes.Repeat([]byte("raft state "), 1000)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+	assert.Equal(t, len(data), storedChunkBytes(t, store, id))
+
+	// Snapshots written without compression stay readable once it is on
+	store.compressSnapshots = true
+	_, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, read)
+}
+
+// storedChunks returns the stored chunks of a snapshot concatenated.
+func storedChunks(t *testing.T, store *BadgerRaftStore, id string) []byte {
+	var stored []byte
+	err := store.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := snapshotDataPrefix(id)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			stored = append(stored, val...)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return stored
+}
+
+func TestSnapshotStore_Encryption(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	store := testBadgerStoreWithOptions(t, Options{SnapshotEncryptionKey: key, CompressSnapshots: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	data := bytes.Repeat([]byte("secret fsm state "), snapshotChunkSize/8)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	assert.NotContains(t, string(storedChunks(t, store, id)), "secret")
+
+	_, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, read))
+
+	// Without the key, or with another one, the data can't be read
+	store.snapshotCipher = nil
+	_, r, err = snapshots.Open(id)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrSnapshotKeyMissing)
+
+	store.snapshotCipher, err = newSnapshotCipher(bytes.Repeat([]byte{8}, 32))
+	require.NoError(t, err)
+	_, r, err = snapshots.Open(id)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestSnapshotStore_Encryption_SwappedChunks(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{SnapshotEncryptionKey: bytes.Repeat([]byte{7}, 16)})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	data := make([]byte, 2*snapshotChunkSize)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	// Chunks are bound to their position
+	err = store.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(snapshotChunkKey(id, 1))
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(snapshotChunkKey(id, 0), val).WithMeta(item.UserMeta()))
+	})
+	require.NoError(t, err)
+
+	_, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestSnapshotStore_Encryption_BadgerKey(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := bytes.Repeat([]byte{7}, 32)
+	opts := badger.DefaultOptions(dir).WithLogger(nil).WithEncryptionKey(key).WithIndexCacheSize(1 << 20)
+	store, err := Open(dir, Options{BadgerOptions: &opts})
+	require.NoError(t, err)
+	require.NotNil(t, store.snapshotCipher)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, []byte("secret fsm state"))
+	assert.NotContains(t, string(storedChunks(t, store, id)), "secret")
+	require.NoError(t, store.Close())
+}
+
+func TestSnapshotStore_Encryption_InvalidKey(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = New(db, Options{SnapshotEncryptionKey: []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestBadgerStore_ListSnapshots(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	infos, err := store.ListSnapshots()
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	configuration := raft.Configuration{Servers: []raft.Server{
+		{Suffrage: raft.Voter, ID: "a", Address: "a:1"},
+	}}
+	sink, err := snapshots.Create(1, 20, 3, configuration, 15, nil)
+	require.NoError(t, err)
+	_, err = sink.Write([]byte("state"))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+	testCreateSnapshot(t, snapshots, 10, []byte("older"))
+
+	infos, err = store.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, sink.ID(), infos[0].ID)
+	assert.Equal(t, uint64(20), infos[0].Index)
+	assert.Equal(t, uint64(3), infos[0].Term)
+	assert.Equal(t, int64(5), infos[0].Size)
+	assert.Equal(t, configuration, infos[0].Configuration)
+	assert.Equal(t, uint64(15), infos[0].ConfigurationIndex)
+	assert.WithinDuration(t, time.Now(), infos[0].CreatedAt, time.Minute)
+	assert.Equal(t, uint64(10), infos[1].Index)
+}
+
+func TestSnapshotStore_Cancel(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+
+	sink, err := snapshots.Create(1, 10, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	_, err = sink.Write(make([]byte, snapshotChunkSize+1))
+	require.NoError(t, err)
+	require.NoError(t, sink.Cancel())
+
+	list, err := snapshots.List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Equal(t, 0, countKeys(t, store, dbSnapData))
+}
+
+func TestSnapshotStore_RemoveIncomplete(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, []byte("complete"))
+
+	// Simulate a crash before the sink was closed
+	sink, err := snapshots.Create(1, 20, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	_, err = sink.Write(make([]byte, snapshotChunkSize))
+	require.NoError(t, err)
+	assert.Equal(t, 2, countKeys(t, store, dbSnapData))
+
+	// Its durable chunks are kept to resume it from
+	reopened, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countKeys(t, store, dbSnapData))
+	resumable, err := reopened.ListResumable()
+	require.NoError(t, err)
+	require.Len(t, resumable, 1)
+
+	// Chunks without a manifest, as written by older versions, are removed
+	require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(snapshotManifestKey(sink.ID()))
+	}))
+	_, err = NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countKeys(t, store, dbSnapData))
+
+	_, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("complete"), got)
+}
+
+func countKeys(t *testing.T, store *BadgerRaftStore, prefix []byte) int {
+	count := 0
+	err := store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			count++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return count
+}
+
+func TestSnapshotStore_Open_RemovedWhileReading(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 1)
+	require.NoError(t, err)
+	data := make([]byte, 2*snapshotChunkSize+100)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	_, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	defer r.Close()
+	buf := make([]byte, 100)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+
+	// A newer snapshot replaces the one being read
+	testCreateSnapshot(t, snapshots, 20, []byte("newer"))
+	_, _, err = snapshots.Open(id)
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, append(buf, rest...)))
+}
+
+func TestSnapshotStore_Open_MissingChunk(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	snapshots, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	data := make([]byte, 2*snapshotChunkSize+100)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(snapshotChunkKey(id, 2))
+	})
+	require.NoError(t, err)
+
+	_, r, err := snapshots.Open(id)
+	require.NoError(t, err)
+	defer r.Close()
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,447 @@
+package raftbadgerstore
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// Version of the state bundle layout written by ExportState. Version 1
+	// bundles have no snapshots file.
+	stateBundleVersion = 2
+
+	stateManifestFile  = "manifest.json"
+	stateLogsFile      = "logs.ndjson"
+	stateStableFile    = "stable.json"
+	stateSnapshotsFile = "snapshots.ndjson"
+)
+
+var (
+	// Keyspaces holding the snapshots kept by a SnapshotStore, copied into
+	// state bundles as they are stored.
+	stateSnapshotKeyspaces = [][]byte{dbSnapMeta, dbSnapManifest, dbSnapData}
+)
+
+var (
+	// An error indicating ImportState was called on a store holding data
+	ErrStoreNotEmpty = errors.New("store is not empty")
+
+	// An error indicating a state bundle is malformed or fails verification
+	ErrInvalidBundle = errors.New("invalid state bundle")
+)
+
+// stateManifest describes the contents of a state bundle.
+type stateManifest struct {
+	Version    int                  `json:"version"`
+	CreatedAt  time.Time            `json:"created_at"`
+	FirstIndex uint64               `json:"first_index"`
+	LastIndex  uint64               `json:"last_index"`
+	Files      []stateManifestEntry `json:"files"`
+}
+
+type stateManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// stableKV is the JSON representation of a stable store key/value pair.
+type stableKV struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// snapshotKV is the NDJSON representation of a key of the snapshot
+// keyspaces. Chunks are copied as they are stored, compressed or encrypted
+// as UserMeta says.
+type snapshotKV struct {
+	Key      []byte `json:"key"`
+	Value    []byte `json:"value"`
+	UserMeta byte   `json:"user_meta,omitempty"`
+}
+
+// stagedFile is a bundle member written to a temporary file so its size and
+// checksum are known before it is added to the tar stream.
+type stagedFile struct {
+	name string
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+func newStagedFile(dir, name string) (*stagedFile, error) {
+	f, err := os.CreateTemp(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	return &stagedFile{name: name, file: f, hash: sha256.New()}, nil
+}
+
+func (s *stagedFile) Write(p []byte) (int, error) {
+	n, err := s.file.Write(p)
+	s.hash.Write(p[:n])
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *stagedFile) manifest() stateManifestEntry {
+	return stateManifestEntry{Name: s.name, Size: s.size, SHA256: hex.EncodeToString(s.hash.Sum(nil))}
+}
+
+// ExportState writes a tar bundle holding the complete raft state of the
+// store: every log, every stable store key, the snapshots kept by a
+// SnapshotStore and a manifest with checksums of them. Everything is read
+// in a single transaction, so the bundle is consistent while raft keeps
+// writing; with SeparateStableDir the stable store is read in a transaction
+// of its own, started at the same time. Use ImportState to load it into an
+// empty store on another machine.
+func (b *BadgerRaftStore) ExportState(w io.Writer) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+	stableTxn := txn
+	if b.stableDB != b.db {
+		stableTxn = b.newTransaction(b.stableDB, false)
+		defer stableTxn.Discard()
+	}
+
+	tmpDir, err := os.MkdirTemp("", "raft-badgerstore-export")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	first, err := firstIndex(txn)
+	if err != nil {
+		return storageError(err)
+	}
+	last, err := lastIndex(txn)
+	if err != nil {
+		return storageError(err)
+	}
+
+	logs, err := newStagedFile(tmpDir, stateLogsFile)
+	if err != nil {
+		return err
+	}
+	defer logs.file.Close()
+	if err := b.exportJSON(txn, logs, first, last); err != nil {
+		return err
+	}
+
+	stable, err := newStagedFile(tmpDir, stateStableFile)
+	if err != nil {
+		return err
+	}
+	defer stable.file.Close()
+	if err := exportStable(stableTxn, stable); err != nil {
+		return storageError(err)
+	}
+
+	snapshots, err := newStagedFile(tmpDir, stateSnapshotsFile)
+	if err != nil {
+		return err
+	}
+	defer snapshots.file.Close()
+	if err := exportSnapshots(txn, snapshots); err != nil {
+		return storageError(err)
+	}
+
+	staged := []*stagedFile{logs, stable, snapshots}
+	manifest := stateManifest{
+		Version:    stateBundleVersion,
+		CreatedAt:  time.Now().UTC(),
+		FirstIndex: first,
+		LastIndex:  last,
+	}
+	for _, f := range staged {
+		manifest.Files = append(manifest.Files, f.manifest())
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeTarFile(tw, stateManifestFile, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return err
+	}
+	for _, f := range staged {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, f.name, f.size, f.file); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// exportStable writes every stable store key/value pair seen by txn to w as
+// JSON.
+func exportStable(txn *badger.Txn, w io.Writer) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var kvs []stableKV
+	for it.Seek(dbConf); it.ValidForPrefix(dbConf); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, stableKV{Key: item.KeyCopy(nil)[len(dbConf):], Value: val})
+	}
+	return json.NewEncoder(w).Encode(kvs)
+}
+
+// exportSnapshots writes every key of the snapshot keyspaces seen by txn to
+// w as newline delimited JSON.
+func exportSnapshots(txn *badger.Txn, w io.Writer) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 4
+
+	enc := json.NewEncoder(w)
+	for _, prefix := range stateSnapshotKeyspaces {
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err == nil {
+				err = enc.Encode(snapshotKV{Key: item.KeyCopy(nil), Value: val, UserMeta: item.UserMeta()})
+			}
+			if err != nil {
+				it.Close()
+				return err
+			}
+		}
+		it.Close()
+	}
+	return nil
+}
+
+// ImportState loads a bundle written by ExportState into the store. Every
+// file is verified against the manifest before anything is written, and the
+// store must not hold any logs, stable store keys or snapshots. Encrypted
+// snapshots can only be read with the SnapshotEncryptionKey they were
+// written with.
+func (b *BadgerRaftStore) ImportState(r io.Reader) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	empty, err := b.isEmpty()
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return ErrStoreNotEmpty
+	}
+
+	tmpDir, err := os.MkdirTemp("", "raft-badgerstore-import")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+	if hdr.Name != stateManifestFile {
+		return fmt.Errorf("%w: expected %s, got %s", ErrInvalidBundle, stateManifestFile, hdr.Name)
+	}
+	var manifest stateManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+	if manifest.Version < 1 || manifest.Version > stateBundleVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, manifest.Version)
+	}
+
+	expected := make(map[string]stateManifestEntry, len(manifest.Files))
+	for _, f := range manifest.Files {
+		expected[f.Name] = f
+	}
+
+	// Stage and verify every file before touching the store
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+
+		want, ok := expected[hdr.Name]
+		if !ok {
+			return fmt.Errorf("%w: unexpected file %s", ErrInvalidBundle, hdr.Name)
+		}
+		delete(expected, hdr.Name)
+
+		staged, err := newStagedFile(tmpDir, filepath.Base(hdr.Name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(staged, tr)
+		staged.file.Close()
+		if err != nil {
+			return err
+		}
+		if got := staged.manifest(); got != want {
+			return fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBundle, hdr.Name)
+		}
+		if err := os.Rename(staged.file.Name(), filepath.Join(tmpDir, hdr.Name)); err != nil {
+			return err
+		}
+	}
+	for name := range expected {
+		return fmt.Errorf("%w: missing file %s", ErrInvalidBundle, name)
+	}
+
+	if err := b.importStable(filepath.Join(tmpDir, stateStableFile)); err != nil {
+		return err
+	}
+	if manifest.Version >= 2 {
+		if err := b.importSnapshots(filepath.Join(tmpDir, stateSnapshotsFile)); err != nil {
+			return err
+		}
+	}
+
+	logs, err := os.Open(filepath.Join(tmpDir, stateLogsFile))
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	return b.ImportJSON(logs)
+}
+
+func (b *BadgerRaftStore) importStable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var kvs []stableKV
+	if err := json.NewDecoder(f).Decode(&kvs); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+	for _, kv := range kvs {
+		if err := b.Set(kv.Key, kv.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importSnapshots stores the snapshot keys of a bundle, one transaction
+// per key as chunks are large.
+func (b *BadgerRaftStore) importSnapshots(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var kv snapshotKV
+		if err := dec.Decode(&kv); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		if !isSnapshotKey(kv.Key) {
+			return fmt.Errorf("%w: %q is not a snapshot key", ErrInvalidBundle, kv.Key)
+		}
+		err := b.update(b.db, func(txn *badger.Txn) error {
+			return txn.SetEntry(badger.NewEntry(kv.Key, kv.Value).WithMeta(kv.UserMeta))
+		})
+		if err != nil {
+			return b.writeError(err)
+		}
+	}
+}
+
+// isSnapshotKey reports whether key belongs to a snapshot keyspace.
+func isSnapshotKey(key []byte) bool {
+	for _, prefix := range stateSnapshotKeyspaces {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty reports whether the store holds no logs, stable keys or
+// snapshots.
+func (b *BadgerRaftStore) isEmpty() (bool, error) {
+	empty := true
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, prefix := range append([][]byte{dbLogs}, stateSnapshotKeyspaces...) {
+			it.Seek(prefix)
+			if it.ValidForPrefix(prefix) {
+				empty = false
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil || !empty {
+		return empty, err
+	}
+
+	err = b.stableDB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		it.Seek(dbConf)
+		empty = !it.ValidForPrefix(dbConf)
+		return nil
+	})
+	return empty, err
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    dbFileMode,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
@@ -0,0 +1,202 @@
+package raftbadgerstore
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_ExportState_ImportState(t *testing.T) {
+	source := testBadgerStore(t)
+	defer source.Close()
+	defer os.Remove(source.path)
+
+	logs := []*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}
+	require.NoError(t, source.StoreLogs(logs))
+	require.NoError(t, source.SetUint64([]byte("CurrentTerm"), 7))
+	require.NoError(t, source.Set([]byte("LastVoteCand"), []byte("node1")))
+
+	var bundle bytes.Buffer
+	require.NoError(t, source.ExportState(&bundle))
+	data := bundle.Bytes()
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.ImportState(bytes.NewReader(data)))
+
+	for _, l := range logs {
+		result := new(raft.Log)
+		require.NoError(t, store.GetLog(l.Index, result))
+		assert.Equal(t, l, result)
+	}
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), term)
+	cand, err := store.Get([]byte("LastVoteCand"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("node1"), cand)
+
+	// A store holding data refuses to import
+	err = store.ImportState(bytes.NewReader(data))
+	assert.ErrorIs(t, err, ErrStoreNotEmpty)
+}
+
+func TestBadgerStore_ImportState_Corrupt(t *testing.T) {
+	source := testBadgerStore(t)
+	defer source.Close()
+	defer os.Remove(source.path)
+
+	require.NoError(t, source.StoreLogs([]*raft.Log{testRaftLog(1, "log1")}))
+
+	var bundle bytes.Buffer
+	require.NoError(t, source.ExportState(&bundle))
+
+	// Rewrite the bundle with a tampered logs file
+	var tampered bytes.Buffer
+	tr := tar.NewReader(&bundle)
+	tw := tar.NewWriter(&tampered)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		if hdr.Name == stateLogsFile {
+			data = bytes.Replace(data, []byte(`"index":1`), []byte(`"index":2`), 1)
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	err := store.ImportState(&tampered)
+	assert.ErrorIs(t, err, ErrInvalidBundle)
+
+	idx, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), idx)
+}
+
+func TestBadgerStore_ExportState_Snapshots(t *testing.T) {
+	source := testBadgerStoreWithOptions(t, Options{CompressSnapshots: true})
+	defer source.Close()
+	defer os.Remove(source.path)
+
+	storeTestLogs(t, source, 1, 10)
+	snapshots, err := NewSnapshotStore(source, 2)
+	require.NoError(t, err)
+	data := make([]byte, 2*snapshotChunkSize+100)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	id := testCreateSnapshot(t, snapshots, 10, data)
+
+	var bundle bytes.Buffer
+	require.NoError(t, source.ExportState(&bundle))
+	exported := bundle.Bytes()
+
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+	require.NoError(t, store.ImportState(bytes.NewReader(exported)))
+
+	imported, err := NewSnapshotStore(store, 2)
+	require.NoError(t, err)
+	list, err := imported.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, id, list[0].ID)
+
+	meta, r, err := imported.Open(id)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, uint64(10), meta.Index)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, got))
+
+	// Snapshots alone make a store non-empty
+	other := testBadgerStore(t)
+	defer other.Close()
+	defer os.Remove(other.path)
+	otherSnapshots, err := NewSnapshotStore(other, 2)
+	require.NoError(t, err)
+	testCreateSnapshot(t, otherSnapshots, 1, []byte("state"))
+	assert.ErrorIs(t, other.ImportState(bytes.NewReader(exported)), ErrStoreNotEmpty)
+}
+
+func TestBadgerStore_ExportState_Consistent(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 100)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := uint64(101); ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := store.StoreLog(testRaftLog(i, "log")); err != nil {
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		var bundle bytes.Buffer
+		require.NoError(t, store.ExportState(&bundle))
+
+		var manifest stateManifest
+		var last uint64
+		tr := tar.NewReader(&bundle)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			dec := json.NewDecoder(tr)
+			switch hdr.Name {
+			case stateManifestFile:
+				require.NoError(t, dec.Decode(&manifest))
+			case stateLogsFile:
+				for dec.More() {
+					var l jsonLog
+					require.NoError(t, dec.Decode(&l))
+					last = l.Index
+				}
+			}
+		}
+		assert.Equal(t, manifest.LastIndex, last)
+	}
+	close(done)
+	wg.Wait()
+}
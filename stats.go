@@ -0,0 +1,137 @@
+package raftbadgerstore
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// expvarMu makes checking whether an expvar name is taken and publishing
+// it atomic, since expvar.Publish panics on duplicates.
+var expvarMu sync.Mutex
+
+// storeStats holds the live operation counters of a store.
+type storeStats struct {
+	appends atomic.Uint64
+	reads   atomic.Uint64
+	deletes atomic.Uint64
+	errors  atomic.Uint64
+
+	snapshots atomic.Uint64
+
+	conflicts        atomic.Uint64
+	retries          atomic.Uint64
+	retriesExhausted atomic.Uint64
+
+	throttled atomic.Uint64
+
+	readaheadHits atomic.Uint64
+
+	watchdogAnomalies atomic.Uint64
+
+	fidelityFallbacks atomic.Uint64
+}
+
+// Stats is a snapshot of a store's operation counters and on-disk size.
+type Stats struct {
+	// Number of logs stored, logs and stable keys read, and logs deleted
+	// since the store was opened.
+	Appends uint64 `json:"appends"`
+	Reads   uint64 `json:"reads"`
+	Deletes uint64 `json:"deletes"`
+
+	// Number of failed operations. Lookups of missing logs and keys are
+	// not counted as failures.
+	Errors uint64 `json:"errors"`
+
+	// Number of failed operations by the kind of error, such as "io",
+	// "corrupt" or "disk_full". Kinds without failures are left out.
+	ErrorsByCategory map[string]uint64 `json:"errors_by_category"`
+
+	// Number of snapshots persisted through a SnapshotStore using the store.
+	Snapshots uint64 `json:"snapshots"`
+
+	// Number of commits that failed because of a conflict with another
+	// writer sharing the Badger database, retries of failed operations
+	// and operations that still failed once out of retries.
+	Conflicts        uint64 `json:"conflicts"`
+	Retries          uint64 `json:"retries"`
+	RetriesExhausted uint64 `json:"retries_exhausted"`
+
+	// Number of appends delayed by the append rate limit or by compaction
+	// falling behind, including those that failed with ErrBackpressure.
+	Throttled uint64 `json:"throttled"`
+
+	// Number of GetLog calls served from logs read ahead, see
+	// Options.ReadaheadSize.
+	ReadaheadHits uint64 `json:"readahead_hits"`
+
+	// Number of problems found by SpotCheck.
+	WatchdogAnomalies uint64 `json:"watchdog_anomalies"`
+
+	// Number of logs stored with msgpack instead of the configured codec,
+	// as it would have changed them, see Options.StrictFidelity.
+	FidelityFallbacks uint64 `json:"fidelity_fallbacks"`
+
+	// Value log GC cycles run since the store was opened.
+	GC GCStats `json:"gc"`
+
+	// Size of the LSM tree and the value log in bytes.
+	LSMSize  int64 `json:"lsm_size"`
+	VlogSize int64 `json:"vlog_size"`
+}
+
+// Stats returns the current operation counters and sizes of the store.
+func (b *BadgerRaftStore) Stats() Stats {
+	lsm, vlog := b.Size()
+	return Stats{
+		Appends: b.stats.appends.Load(),
+		Reads:   b.stats.reads.Load(),
+		Deletes: b.stats.deletes.Load(),
+		Errors:  b.stats.errors.Load(),
+
+		ErrorsByCategory: b.errors.byCategory(),
+
+		Snapshots: b.stats.snapshots.Load(),
+
+		Conflicts:        b.stats.conflicts.Load(),
+		Retries:          b.stats.retries.Load(),
+		RetriesExhausted: b.stats.retriesExhausted.Load(),
+
+		Throttled: b.stats.throttled.Load(),
+
+		ReadaheadHits:     b.stats.readaheadHits.Load(),
+		WatchdogAnomalies: b.stats.watchdogAnomalies.Load(),
+		FidelityFallbacks: b.stats.fidelityFallbacks.Load(),
+
+		GC: b.GCStats(),
+
+		LSMSize:  lsm,
+		VlogSize: vlog,
+	}
+}
+
+// PublishExpvar exports the store's Stats under the given name, making them
+// available on the standard /debug/vars endpoint. Names are global to the
+// process, so publishing twice under the same name fails. Expvars can't be
+// removed, so once the store is closed the name reports {"closed":true}.
+func (b *BadgerRaftStore) PublishExpvar(prefix string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if expvar.Get(prefix) != nil {
+		return fmt.Errorf("expvar %q is already published", prefix)
+	}
+
+	expvar.Publish(prefix, expvar.Func(func() any {
+		if err := b.enter(); err != nil {
+			return struct {
+				Closed bool `json:"closed"`
+			}{true}
+		}
+		defer b.exit()
+		return b.Stats()
+	}))
+	return nil
+}
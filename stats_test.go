@@ -0,0 +1,92 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_Stats(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}))
+	require.NoError(t, store.GetLog(2, new(raft.Log)))
+	require.NoError(t, store.DeleteRange(1, 2))
+	require.NoError(t, store.Set([]byte("key"), []byte("val")))
+	_, err := store.Get([]byte("key"))
+	require.NoError(t, err)
+
+	// Missing logs are not errors, gaps are
+	assert.ErrorIs(t, store.GetLog(1, new(raft.Log)), raft.ErrLogNotFound)
+	assert.ErrorIs(t, store.StoreLog(testRaftLog(5, "log5")), ErrLogGap)
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(3), stats.Appends)
+	assert.Equal(t, uint64(2), stats.Reads)
+	assert.Equal(t, uint64(2), stats.Deletes)
+	assert.Equal(t, uint64(1), stats.Errors)
+}
+
+// expvarNames makes the names tests publish unique, since expvars are
+// global to the process and live as long as it when tests are repeated.
+var expvarNames atomic.Uint64
+
+func testExpvarName(t *testing.T) string {
+	return fmt.Sprintf("%s_%d", t.Name(), expvarNames.Add(1))
+}
+
+func TestBadgerStore_PublishExpvar(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	name := testExpvarName(t)
+	require.NoError(t, store.PublishExpvar(name))
+	require.Error(t, store.PublishExpvar(name))
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &stats))
+	assert.Equal(t, uint64(1), stats.Appends)
+}
+
+func TestBadgerStore_PublishExpvar_Concurrent(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.Remove(store.path)
+
+	name := testExpvarName(t)
+
+	// Racing to publish the same name fails all but one call
+	var wg sync.WaitGroup
+	var published atomic.Int32
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.PublishExpvar(name) == nil {
+				published.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), published.Load())
+
+	// The closed store's database is no longer read
+	require.NoError(t, store.Close())
+	assert.JSONEq(t, `{"closed":true}`, expvar.Get(name).String())
+}
@@ -1,19 +1,132 @@
 package raftbadgerstore
 
-import "github.com/hashicorp/raft"
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
 
+	"github.com/hashicorp/raft"
+)
+
+// Store is what raft needs from a store: a log store and stable store that
+// can be closed. Everything else BadgerRaftStore offers is grouped into the
+// small optional interfaces below, which callers type-assert, or look up
+// through wrapping middleware with As.
 type Store interface {
-	Close() error
-	FirstIndex() (uint64, error)
-	LastIndex() (uint64, error)
-	GetLog(idx uint64, log *raft.Log) error
-	StoreLog(log *raft.Log) error
-	StoreLogs(logs []*raft.Log) error
-	DeleteRange(min, max uint64) error
-	Set(k, v []byte) error
-	Get(k []byte) ([]byte, error)
-	SetUint64(key []byte, val uint64) error
-	GetUint64(key []byte) (uint64, error)
+	raft.LogStore
+	raft.StableStore
+	io.Closer
+}
+
+// LogReader reads the log beyond what raft.LogStore offers.
+type LogReader interface {
+	GetLogs(min, max uint64) ([]*raft.Log, error)
+	LastLogEntry() (*raft.Log, error)
+	LogCount() (uint64, error)
+	LogTimeSpan() (*LogTimeSpan, error)
+	SubscribeLogs(ctx context.Context, fromIndex uint64) (<-chan *raft.Log, error)
+}
+
+// Compactor deletes logs that are no longer needed.
+type Compactor interface {
+	DeleteRangeWithProgress(ctx context.Context, min, max uint64, progress func(deleted, remaining uint64)) error
+	RetainLast(n uint64) error
+	EnforceRetention() error
+	SetMinRetainIndex(idx uint64)
+	OnSnapshotPersisted(index uint64)
+}
+
+// Exporter moves logs and state in and out of the store.
+type Exporter interface {
+	ExportJSON(w io.Writer, min, max uint64) error
+	ImportJSON(r io.Reader) error
+	ExportState(w io.Writer) error
+	ImportState(r io.Reader) error
+	StreamLogs(fromIndex uint64, w io.Writer) (uint64, error)
+	ApplyLogStream(r io.Reader) (uint64, error)
+}
+
+// Backuper takes and loads backups of the database.
+type Backuper interface {
+	Backup(w io.Writer) (uint64, error)
+	LoadBackup(r io.Reader) error
+	BackupTo(ctx context.Context, sink Sink, name string) (uint64, error)
+	LoadBackupFrom(ctx context.Context, sink Sink, name string) error
+}
+
+// Verifier checks the store for corruption and repairs it.
+type Verifier interface {
+	VerifyConsistency() (*VerifyReport, error)
+	SpotCheck(samples int) (*VerifyReport, error)
+	Repair() (*RepairReport, error)
+	OpenReport() *VerifyReport
+	TornWrites() []uint64
+	LostIndexes() ([]IndexRange, error)
+}
+
+// Versioned reads and restores earlier versions of the log, see
+// Options.KeepVersions.
+type Versioned interface {
+	LogVersions(idx uint64) ([]LogVersion, error)
+	GetLogAt(at time.Time, idx uint64, log *raft.Log) error
+	RestoreToTime(at time.Time) (*RestoreReport, error)
+	RestoreToVersion(version uint64) (*RestoreReport, error)
+	SnapshotView() (*SnapshotView, error)
+}
+
+// Maintainer reclaims space and upgrades the stored data.
+type Maintainer interface {
 	RunValueLogGC(discardRatio float64) error
+	RunValueLogGCUntilClean(ctx context.Context, discardRatio float64, maxRuns int) (int, error)
+	MoveToColdTier() (int, error)
+	TrainCompressionDictionary(samples int) (uint32, error)
+	UpgradeTimeFormat() (int, error)
+	KeyLayoutVersion() (uint64, error)
+}
+
+// Monitor reports the state and health of the store.
+type Monitor interface {
+	Path() string
 	Size() (lsm, vlog int64)
+	KeyspaceSizes() (map[string]KeyspaceSize, error)
+	GCStats() GCStats
+	Stats() Stats
+	PublishExpvar(prefix string) error
+	Health(ctx context.Context) error
+	Degraded() bool
+	LastError() (time.Time, error)
+	ListSnapshots() ([]SnapshotInfo, error)
+	AdminHandler(options AdminOptions) http.Handler
+}
+
+var (
+	_ Store                  = (*BadgerRaftStore)(nil)
+	_ raft.MonotonicLogStore = (*BadgerRaftStore)(nil)
+	_ LogReader              = (*BadgerRaftStore)(nil)
+	_ Compactor              = (*BadgerRaftStore)(nil)
+	_ Exporter               = (*BadgerRaftStore)(nil)
+	_ Backuper               = (*BadgerRaftStore)(nil)
+	_ Verifier               = (*BadgerRaftStore)(nil)
+	_ Versioned              = (*BadgerRaftStore)(nil)
+	_ Maintainer             = (*BadgerRaftStore)(nil)
+	_ Monitor                = (*BadgerRaftStore)(nil)
+)
+
+// As returns s as a T, looking through stores that wrap another and return
+// it from an Unwrap method, such as the middleware package's. It returns
+// false if neither s nor a store it wraps is a T.
+func As[T any](s Store) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		u, ok := s.(interface{ Unwrap() Store })
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	var zero T
+	return zero, false
 }
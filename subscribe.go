@@ -0,0 +1,206 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Number of logs buffered for a subscriber
+	subscribeBufferSize = 64
+
+	// How often subscribers check for new logs even without a notification
+	// from Badger, which can miss writes made while the subscription starts.
+	subscribePollInterval = time.Second
+)
+
+// logSubscription tracks the writes a subscriber hasn't caught up with.
+type logSubscription struct {
+	notifyCh chan struct{}
+
+	// batchStart and readTs describe the batch of logs being read, and
+	// rewind holds the lowest index written since then that the batch
+	// may have missed, so overwritten logs are delivered again.
+	mu         sync.Mutex
+	batchStart uint64
+	readTs     uint64
+	rewind     uint64
+}
+
+// notify records the logs written in kvs. It must not block, since Badger
+// holds up commits while subscribers are busy.
+func (s *logSubscription) notify(kvs *badger.KVList) error {
+	s.mu.Lock()
+	for _, kv := range kvs.Kv {
+		// Deletions are published with empty values
+		if len(kv.Value) == 0 {
+			continue
+		}
+		idx := logIndex(kv.Key)
+
+		// The current batch already sees this write
+		if kv.Version <= s.readTs && idx >= s.batchStart {
+			continue
+		}
+		if s.rewind == 0 || idx < s.rewind {
+			s.rewind = idx
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.notifyCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// startBatch records that a batch of logs from next on is read at readTs,
+// and returns where the batch must start so earlier logs that were
+// overwritten meanwhile are read again.
+func (s *logSubscription) startBatch(next, readTs uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rewind != 0 && s.rewind < next {
+		next = s.rewind
+	}
+	s.rewind = 0
+	s.batchStart = next
+	s.readTs = readTs
+	return next
+}
+
+// SubscribeLogs returns a channel receiving every log from fromIndex on,
+// first those already stored and then each log as soon as it is committed
+// to the store. Logs that were compacted before they could be delivered are
+// skipped, and logs that raft overwrites after truncating a conflicting
+// suffix are delivered again, so subscribers must handle indexes going
+// backwards. A log may occasionally be delivered more than once, but none
+// is missed.
+//
+// The channel is closed once ctx is done, the store is closed or a log
+// can't be read. A subscriber that doesn't keep up only delays its own
+// delivery; it never blocks writes.
+func (b *BadgerRaftStore) SubscribeLogs(ctx context.Context, fromIndex uint64) (<-chan *raft.Log, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &logSubscription{notifyCh: make(chan struct{}, 1)}
+	out := make(chan *raft.Log, subscribeBufferSize)
+
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		err := b.db.Subscribe(ctx, sub.notify, []pb.Match{{Prefix: dbLogs}})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Error().Err(err).Msg("Log subscription failed")
+			cancel()
+		}
+	}()
+
+	go func() {
+		defer b.exit()
+		defer func() {
+			cancel()
+			<-subscribed
+			close(out)
+		}()
+
+		// Stop once the store closes, so Close doesn't wait forever
+		go func() {
+			select {
+			case <-b.shutdownCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		b.deliverLogs(ctx, sub, fromIndex, out)
+	}()
+
+	return out, nil
+}
+
+// deliverLogs sends logs from next on to out, and then each time new logs
+// are written, until ctx is done.
+func (b *BadgerRaftStore) deliverLogs(ctx context.Context, sub *logSubscription, next uint64, out chan<- *raft.Log) {
+	ticker := time.NewTicker(subscribePollInterval)
+	defer ticker.Stop()
+
+	for {
+		batch, more, err := b.readLogBatch(sub, &next)
+		if err != nil {
+			log.Error().Err(err).Uint64("index", next).Msg("Log subscription failed to read logs")
+			return
+		}
+
+		for _, entry := range batch {
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if more {
+			continue
+		}
+
+		select {
+		case <-sub.notifyCh:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readLogBatch reads up to subscribeBufferSize logs from *next on and
+// advances *next past them. more reports whether there are more logs to
+// read. Logs compacted in the meantime are skipped.
+func (b *BadgerRaftStore) readLogBatch(sub *logSubscription, next *uint64) (batch []*raft.Log, more bool, err error) {
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	*next = sub.startBatch(*next, txn.ReadTs())
+
+	first, err := firstIndex(txn)
+	if err != nil {
+		return nil, false, err
+	}
+	last, err := lastIndex(txn)
+	if err != nil || last == 0 {
+		return nil, false, err
+	}
+	*next = max(*next, first)
+
+	for ; *next <= last && len(batch) < subscribeBufferSize; *next++ {
+		item, err := txn.Get(logKey(*next))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			// A gap, if AllowLogGaps is set
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		entry := new(raft.Log)
+		err = item.Value(func(val []byte) error {
+			return decodeLog(val, entry)
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		batch = append(batch, entry)
+	}
+	return batch, *next <= last, nil
+}
@@ -0,0 +1,78 @@
+package raftbadgerstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receiveLog(t *testing.T, ch <-chan *raft.Log) *raft.Log {
+	select {
+	case entry, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return entry
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for log")
+		return nil
+	}
+}
+
+func TestBadgerStore_SubscribeLogs(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(1, "log1"), testRaftLog(2, "log2"), testRaftLog(3, "log3"),
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := store.SubscribeLogs(ctx, 2)
+	require.NoError(t, err)
+
+	// Stored logs are replayed first
+	assert.Equal(t, uint64(2), receiveLog(t, ch).Index)
+	assert.Equal(t, uint64(3), receiveLog(t, ch).Index)
+
+	// Then new logs are delivered as they are stored
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(4, "log4"), testRaftLog(5, "log5")}))
+	assert.Equal(t, uint64(4), receiveLog(t, ch).Index)
+	assert.Equal(t, uint64(5), receiveLog(t, ch).Index)
+
+	// Overwritten logs are delivered again
+	require.NoError(t, store.StoreLog(testRaftLog(4, "new4")))
+	for {
+		entry := receiveLog(t, ch)
+		if entry.Index == 4 && string(entry.Data) == "new4" {
+			break
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestBadgerStore_SubscribeLogs_Close(t *testing.T) {
+	store := testBadgerStore(t)
+	defer os.Remove(store.path)
+
+	ch, err := store.SubscribeLogs(context.Background(), 0)
+	require.NoError(t, err)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "log1")))
+	assert.Equal(t, uint64(1), receiveLog(t, ch).Index)
+
+	// Closing the store ends the subscription
+	require.NoError(t, store.Close())
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	_, err = store.SubscribeLogs(context.Background(), 0)
+	assert.ErrorIs(t, err, ErrClosed)
+}
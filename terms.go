@@ -0,0 +1,63 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+var (
+	// An error indicating a log's term is lower than the term of the log
+	// before it, see Options.CheckTerms
+	ErrTermRegression = errors.New("term regression")
+)
+
+// TermRegressionError is returned by StoreLogs with CheckTerms set when a
+// log's term is lower than the term of the log at the index before it. It
+// matches ErrTermRegression.
+type TermRegressionError struct {
+	Index    uint64
+	Term     uint64
+	PrevTerm uint64
+}
+
+func (e *TermRegressionError) Error() string {
+	return fmt.Sprintf("log %d has term %d, lower than term %d of log %d", e.Index, e.Term, e.PrevTerm, e.Index-1)
+}
+
+func (e *TermRegressionError) Unwrap() error {
+	return ErrTermRegression
+}
+
+// checkTerms fails with a TermRegressionError if the term of any of logs is
+// lower than that of the log at the index before it, be it earlier in logs
+// or already stored. Logs without a log at the index before them, as at
+// the start of the log or after a gap, aren't checked.
+func checkTerms(txn *badger.Txn, logs []*raft.Log) error {
+	var prev raft.Log
+	for i, l := range logs {
+		switch {
+		case l.Index == 0:
+			continue
+		case i > 0 && logs[i-1].Index == l.Index-1:
+			prev = *logs[i-1]
+		case i == 0:
+			err := readLog(txn, l.Index-1, &prev)
+			if errors.Is(err, raft.ErrLogNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+
+		if l.Term < prev.Term {
+			return &TermRegressionError{Index: l.Index, Term: l.Term, PrevTerm: prev.Term}
+		}
+	}
+	return nil
+}
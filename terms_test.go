@@ -0,0 +1,55 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func termLog(idx, term uint64) *raft.Log {
+	return &raft.Log{Index: idx, Term: term, Data: []byte("log")}
+}
+
+func TestBadgerStore_CheckTerms(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{CheckTerms: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{termLog(1, 1), termLog(2, 1), termLog(3, 2)}))
+
+	// Against the stored log before the batch
+	err := store.StoreLog(termLog(4, 1))
+	assert.ErrorIs(t, err, ErrTermRegression)
+	var regression *TermRegressionError
+	require.ErrorAs(t, err, &regression)
+	assert.Equal(t, TermRegressionError{Index: 4, Term: 1, PrevTerm: 2}, *regression)
+
+	// Within the batch
+	err = store.StoreLogs([]*raft.Log{termLog(4, 3), termLog(5, 2)})
+	require.ErrorAs(t, err, &regression)
+	assert.Equal(t, uint64(5), regression.Index)
+	assert.Equal(t, uint64(2), store.Stats().ErrorsByCategory["term_regression"])
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), last)
+
+	// Equal and higher terms are fine, as is overwriting a conflicting
+	// suffix with a newer term
+	require.NoError(t, store.StoreLogs([]*raft.Log{termLog(4, 2), termLog(5, 3)}))
+	require.NoError(t, store.StoreLogs([]*raft.Log{termLog(5, 4), termLog(6, 4)}))
+}
+
+func TestBadgerStore_CheckTerms_Gaps(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{CheckTerms: true, AllowLogGaps: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Logs without a log right before them aren't checked
+	require.NoError(t, store.StoreLogs([]*raft.Log{termLog(5, 3)}))
+	require.NoError(t, store.StoreLogs([]*raft.Log{termLog(10, 1), termLog(12, 1)}))
+	assert.ErrorIs(t, store.StoreLog(termLog(6, 2)), ErrTermRegression)
+}
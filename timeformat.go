@@ -0,0 +1,102 @@
+package raftbadgerstore
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+// UpgradeTimeFormat re-encodes every stored log with the msgpack time format
+// used when MsgpackUseNewTimeFormat is set, keeping the checksums of logs
+// that have one, and returns the number of logs it rewrote. Logs that
+// already use the new format are left alone, so it can be run again after
+// an interruption.
+//
+// Once every node of a cluster has been upgraded, they can all be opened
+// with MsgpackUseNewTimeFormat set. Logs stored while the upgrade runs use
+// the format the store was opened with, so it is best run with the node
+// stopped, for example with the upgrade-time-format command.
+func (b *BadgerRaftStore) UpgradeTimeFormat() (upgraded int, err error) {
+	if err := b.enter(); err != nil {
+		return 0, err
+	}
+	defer b.exit()
+
+	if err := b.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	next := logKey(0)
+	for next != nil {
+		var n int
+		if next, n, err = b.upgradeTimeFormatBatch(next); err != nil {
+			return upgraded, err
+		}
+		upgraded += n
+	}
+
+	log.Info().Int("logs", upgraded).Msg("Upgraded logs to the new msgpack time format")
+	return upgraded, nil
+}
+
+// upgradeTimeFormatBatch re-encodes up to migrationBatchSize logs from start
+// on. It returns the key of the log the next batch starts at, or nil once
+// there are no more logs, and the number of logs it rewrote.
+func (b *BadgerRaftStore) upgradeTimeFormatBatch(start []byte) (next []byte, upgraded int, err error) {
+	txn := b.newTransaction(b.db, true)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	n := 0
+	for it.Seek(start); it.ValidForPrefix(dbLogs); it.Next() {
+		item := it.Item()
+		if n == migrationBatchSize {
+			next = item.KeyCopy(nil)
+			break
+		}
+		n++
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, 0, storageError(err)
+		}
+
+		// Only msgpack has different time formats
+		if storedEncoding(val).codec != CodecMsgpack {
+			continue
+		}
+
+		var l raft.Log
+		if err := decodeLog(val, &l); err != nil {
+			return nil, 0, fmt.Errorf("%w: log %d: %w", ErrCorrupt, logIndex(item.Key()), err)
+		}
+		enc := storedEncoding(val)
+		enc.newTimeFormat = true
+		newVal, err := encodeLogValue(&l, enc)
+		if err != nil {
+			return nil, 0, err
+		}
+		if bytes.Equal(val, newVal) {
+			continue
+		}
+
+		if err := txn.Set(item.KeyCopy(nil), newVal); err != nil {
+			return nil, 0, storageError(err)
+		}
+		upgraded++
+	}
+	it.Close()
+
+	if upgraded == 0 {
+		return next, 0, nil
+	}
+	if err := b.commit(txn); err != nil {
+		return nil, 0, b.writeError(err)
+	}
+	return next, upgraded, nil
+}
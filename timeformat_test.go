@@ -0,0 +1,55 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_UpgradeTimeFormat(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{Checksums: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	appendedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var logs []*raft.Log
+	for i := uint64(1); i <= 2*migrationBatchSize+10; i++ {
+		l := testRaftLog(i, "log")
+		l.AppendedAt = appendedAt
+		logs = append(logs, l)
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	upgraded, err := store.UpgradeTimeFormat()
+	require.NoError(t, err)
+	assert.Equal(t, len(logs), upgraded)
+
+	// Logs are stored as if MsgpackUseNewTimeFormat had been set, and keep
+	// their checksums
+	err = store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(logKey(7))
+		require.NoError(t, err)
+		val, err := item.ValueCopy(nil)
+		require.NoError(t, err)
+
+		want, err := encodeLogValue(logs[6], logEncoding{codec: CodecMsgpack, newTimeFormat: true, checksum: true})
+		require.NoError(t, err)
+		assert.Equal(t, want, val)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var got raft.Log
+	require.NoError(t, store.GetLog(7, &got))
+	assert.True(t, appendedAt.Equal(got.AppendedAt))
+
+	// Running it again has nothing left to do
+	upgraded, err = store.UpgradeTimeFormat()
+	require.NoError(t, err)
+	assert.Equal(t, 0, upgraded)
+}
@@ -0,0 +1,108 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+// LogTimeSpan describes how far back the log reaches.
+type LogTimeSpan struct {
+	FirstIndex uint64 `json:"first_index"`
+	LastIndex  uint64 `json:"last_index"`
+
+	// When the first and last log were appended, zero if unknown.
+	Oldest time.Time `json:"oldest,omitzero"`
+	Newest time.Time `json:"newest,omitzero"`
+}
+
+// Duration returns the time between the first and last log, or zero if
+// either time is unknown.
+func (s *LogTimeSpan) Duration() time.Duration {
+	if s.Oldest.IsZero() || s.Newest.IsZero() {
+		return 0
+	}
+	return s.Newest.Sub(s.Oldest)
+}
+
+// LogTimeSpan returns the first and last index and when those logs were
+// appended, to tell how far back the log reaches when planning retention
+// and snapshots. The times are the logs' AppendedAt, which raft sets on the
+// leader. For logs without one, such as those written by old raft versions,
+// they are when the store wrote the log if KeepVersions is set, and zero
+// otherwise. An empty store returns a zero span.
+func (b *BadgerRaftStore) LogTimeSpan() (*LogTimeSpan, error) {
+	var result *LogTimeSpan
+	err := b.do(op{name: "LogTimeSpan"}, func() error {
+		span, err := b.logTimeSpan()
+		if err == nil {
+			result = span
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *BadgerRaftStore) logTimeSpan() (*LogTimeSpan, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	if err := b.beforeRead(); err != nil {
+		return nil, err
+	}
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	span := &LogTimeSpan{LastIndex: boundaryLogIndex(txn, true)}
+	if span.LastIndex == 0 {
+		return span, nil
+	}
+	first, err := coldFirstIndex(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if first == 0 {
+		first = boundaryLogIndex(txn, false)
+	}
+	span.FirstIndex = first
+
+	if span.Oldest, err = b.logTime(txn, span.FirstIndex); err != nil {
+		return nil, err
+	}
+	if span.Newest, err = b.logTime(txn, span.LastIndex); err != nil {
+		return nil, err
+	}
+	b.stats.reads.Add(2)
+	return span, nil
+}
+
+// logTime returns when log idx was appended, or when it was written if it
+// has no AppendedAt and versions are kept, as their versions are times.
+func (b *BadgerRaftStore) logTime(txn *badger.Txn, idx uint64) (time.Time, error) {
+	var l raft.Log
+	err := readLog(txn, idx, &l)
+	if errors.Is(err, raft.ErrLogNotFound) && b.cold != nil {
+		err = b.readColdLog(txn, idx, &l)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !l.AppendedAt.IsZero() || b.clock == nil {
+		return l.AppendedAt, nil
+	}
+
+	// Logs in the cold tier have no version
+	item, err := txn.Get(logKey(idx))
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, int64(item.Version())), nil
+}
@@ -0,0 +1,77 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_LogTimeSpan(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	span, err := store.LogTimeSpan()
+	require.NoError(t, err)
+	assert.Equal(t, &LogTimeSpan{}, span)
+
+	oldest := time.Now().Add(-time.Hour).Round(0)
+	newest := time.Now().Round(0)
+	var logs []*raft.Log
+	for i := uint64(1); i <= 10; i++ {
+		l := testRaftLog(i, "log")
+		l.AppendedAt = oldest.Add(time.Duration(i-1) * time.Hour / 9)
+		logs = append(logs, l)
+	}
+	logs[9].AppendedAt = newest
+	require.NoError(t, store.StoreLogs(logs))
+	require.NoError(t, store.DeleteRange(1, 3))
+
+	span, err = store.LogTimeSpan()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), span.FirstIndex)
+	assert.Equal(t, uint64(10), span.LastIndex)
+	assert.True(t, logs[3].AppendedAt.Equal(span.Oldest))
+	assert.True(t, newest.Equal(span.Newest))
+	assert.Equal(t, newest.Sub(logs[3].AppendedAt), span.Duration())
+}
+
+func TestBadgerStore_LogTimeSpanWithoutAppendedAt(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 5)
+
+	span, err := store.LogTimeSpan()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), span.FirstIndex)
+	assert.Equal(t, uint64(5), span.LastIndex)
+	assert.True(t, span.Oldest.IsZero())
+	assert.True(t, span.Newest.IsZero())
+	assert.Zero(t, span.Duration())
+}
+
+func TestBadgerStore_LogTimeSpanKeepVersions(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	// Without AppendedAt, the times logs were written are used
+	before := time.Now()
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "a")}))
+	between := time.Now()
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(2, "b")}))
+	after := time.Now()
+
+	span, err := store.LogTimeSpan()
+	require.NoError(t, err)
+	assert.False(t, span.Oldest.Before(before))
+	assert.False(t, span.Oldest.After(between))
+	assert.False(t, span.Newest.Before(between))
+	assert.False(t, span.Newest.After(after))
+}
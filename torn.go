@@ -0,0 +1,167 @@
+package raftbadgerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How many of the newest logs are checked for torn writes on open
+	tornWriteScanDepth = 16
+)
+
+var (
+	// Key of the ranges of logs discarded by recovery on open
+	metaLostIndexes = []byte("lost_indexes")
+)
+
+// TornWrites returns the indexes of the logs discarded when the store was
+// opened because they were only partially written or their values were
+// lost, newest first. It is empty if the tail of the log was intact.
+func (b *BadgerRaftStore) TornWrites() []uint64 {
+	return b.tornWrites
+}
+
+// LostIndexes returns every range of logs that was ever discarded on open,
+// oldest first. Unlike TornWrites it is persisted, so losses can be audited
+// after later restarts.
+func (b *BadgerRaftStore) LostIndexes() ([]IndexRange, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	lost, err := readLostIndexes(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return lost, nil
+}
+
+// rollBackTornWrites checks that the newest logs can be read, decode and pass
+// their checksum, and deletes those at the tail of the log that don't. A few
+// such logs are left behind by a crash in the middle of a write with NoSync
+// set, and were never acknowledged to raft. Corruption below the newest valid
+// log is left alone; VerifyConsistency reports it.
+//
+// Many unreadable logs at the tail mean the value log was truncated, for
+// example by a crash of the file system. They are only discarded if
+// recoverTruncated is set, since raft may have acknowledged them; otherwise
+// the store refuses to open.
+func (b *BadgerRaftStore) rollBackTornWrites(recoverTruncated bool) error {
+	txn := b.newTransaction(b.db, !b.db.Opts().ReadOnly)
+	defer txn.Discard()
+
+	limit := tornWriteScanDepth
+	if recoverTruncated {
+		limit = -1
+	}
+	torn, truncated, err := findTornWrites(txn, limit)
+	if err != nil || len(torn) == 0 {
+		return err
+	}
+	if truncated {
+		return fmt.Errorf(
+			"%w: more than %d logs at the tail of the log, from log %d down, are unreadable; the value log may have been truncated, set RecoverTruncatedLog to discard them",
+			ErrCorrupt, tornWriteScanDepth, torn[0],
+		)
+	}
+
+	if b.db.Opts().ReadOnly {
+		log.Warn().Uints64("indexes", torn).Msg("Found torn writes at the tail of the log, not rolling back in read-only mode")
+		return nil
+	}
+
+	for _, idx := range torn {
+		if err := txn.Delete(logKey(idx)); err != nil {
+			return err
+		}
+	}
+
+	meta, err := scanLogMeta(txn)
+	if err != nil {
+		return err
+	}
+	if err := writeLogMeta(txn, meta); err != nil {
+		return err
+	}
+	if err := adjustLogCount(txn, 0, uint64(len(torn))); err != nil {
+		return err
+	}
+
+	lost, err := readLostIndexes(txn)
+	if err != nil {
+		return err
+	}
+	lost = append(lost, IndexRange{Min: torn[len(torn)-1], Max: torn[0]})
+	val, err := json.Marshal(lost)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(prefixedKey(dbMeta, metaLostIndexes), val); err != nil {
+		return err
+	}
+
+	if err := b.commitTxn(txn); err != nil {
+		return err
+	}
+
+	b.tornWrites = torn
+	log.Warn().Uints64("indexes", torn).Uint64("last_index", meta.LastIndex).Msg("Rolled back torn writes at the tail of the log")
+	return nil
+}
+
+// findTornWrites returns the indexes of the newest logs that can't be read or
+// decoded, stopping at the first one that can. If limit is not negative, at
+// most limit logs are returned and truncated reports whether there were more.
+func findTornWrites(txn *badger.Txn, limit int) (torn []uint64, truncated bool, err error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = tornWriteScanDepth
+	opts.Reverse = true
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(lastLogKey); it.ValidForPrefix(dbLogs); it.Next() {
+		item := it.Item()
+		idx := logIndex(item.Key())
+
+		// A truncated value log shows up as empty values or read errors
+		var l raft.Log
+		val, err := item.ValueCopy(nil)
+		if err == nil && len(val) > 0 && decodeLog(val, &l) == nil && l.Index == idx {
+			break
+		}
+
+		if limit >= 0 && len(torn) == limit {
+			return torn, true, nil
+		}
+		torn = append(torn, idx)
+	}
+	return torn, false, nil
+}
+
+// readLostIndexes reads the ranges of logs discarded on open.
+func readLostIndexes(txn *badger.Txn) ([]IndexRange, error) {
+	item, err := txn.Get(prefixedKey(dbMeta, metaLostIndexes))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lost []IndexRange
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &lost)
+	})
+	return lost, err
+}
@@ -0,0 +1,97 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_RollBackTornWrites(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{Checksums: true})
+	defer os.RemoveAll(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+		testRaftLog(4, "log4"),
+	}))
+	assert.Empty(t, store.TornWrites())
+
+	// Simulate the last two logs being cut short by a crash
+	for _, idx := range []uint64{3, 4} {
+		val, err := store.encodeLog(testRaftLog(idx, "log"))
+		require.NoError(t, err)
+		require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(logKey(idx), val[:len(val)-2])
+		}))
+	}
+	require.NoError(t, store.Close())
+
+	db, err := badger.Open(badger.DefaultOptions(store.path).WithLogger(nil))
+	require.NoError(t, err)
+	store, err = New(db, Options{Checksums: true})
+	require.NoError(t, err)
+	defer store.Close()
+
+	assert.Equal(t, []uint64{4, 3}, store.TornWrites())
+	lost, err := store.LostIndexes()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexRange{{Min: 3, Max: 4}}, lost)
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), last)
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+
+	// raft can append where the intact log ends
+	require.NoError(t, store.StoreLog(testRaftLog(3, "log3")))
+}
+
+func TestBadgerStore_RecoverTruncatedLog(t *testing.T) {
+	dir := t.TempDir()
+	opts := badger.DefaultOptions(dir).WithLogger(nil).WithValueThreshold(64)
+
+	store, err := Open(dir, Options{BadgerOptions: &opts})
+	require.NoError(t, err)
+	for i := uint64(1); i <= 40; i++ {
+		require.NoError(t, store.StoreLog(testRaftLog(i, strings.Repeat("x", 1000))))
+	}
+	require.NoError(t, store.Close())
+
+	// Lose the values of the newest 30 logs
+	vlogs, err := filepath.Glob(filepath.Join(dir, "*.vlog"))
+	require.NoError(t, err)
+	require.Len(t, vlogs, 1)
+	info, err := os.Stat(vlogs[0])
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(vlogs[0], info.Size()-30*1050))
+
+	_, err = Open(dir, Options{BadgerOptions: &opts})
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	store, err = Open(dir, Options{BadgerOptions: &opts, RecoverTruncatedLog: true})
+	require.NoError(t, err)
+	defer store.Close()
+
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	require.Len(t, store.TornWrites(), int(40-last))
+
+	lost, err := store.LostIndexes()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexRange{{Min: last + 1, Max: 40}}, lost)
+
+	for i := uint64(1); i <= last; i++ {
+		require.NoError(t, store.GetLog(i, new(raft.Log)))
+	}
+}
@@ -3,27 +3,58 @@ package raftbadgerstore
 import (
 	"bytes"
 	"encoding/binary"
+	"sync"
 
 	"github.com/hashicorp/go-msgpack/v2/codec"
 )
 
-// Decode reverses the encode operation on a byte slice input
-func DecodeMsgPack(buf []byte, out interface{}) error {
-	r := bytes.NewBuffer(buf)
-	hd := codec.MsgpackHandle{}
-	dec := codec.NewDecoder(r, &hd)
-	return dec.Decode(out)
+// The handles every log is encoded with, in the old and the new time
+// format, and decoded with. A handle caches how each type is encoded, which
+// is costly to build, so they are shared instead of being constructed for
+// every call. Handles are safe for concurrent use once configured; settings
+// such as RawToString belong in newMsgpackHandle.
+var (
+	msgpackHandleOldTime = newMsgpackHandle(false)
+	msgpackHandleNewTime = newMsgpackHandle(true)
+)
+
+// msgpackDecoders pools decoders using the handle logs are decoded with.
+var msgpackDecoders = sync.Pool{
+	New: func() any { return codec.NewDecoderBytes(nil, msgpackHandle(true)) },
 }
 
-// Encode writes an encoded object to a new bytes buffer
-func EncodeMsgPack(in interface{}, useNewTimeFormat bool) (*bytes.Buffer, error) {
-	buf := bytes.NewBuffer(nil)
-	hd := codec.MsgpackHandle{
+func newMsgpackHandle(useNewTimeFormat bool) *codec.MsgpackHandle {
+	return &codec.MsgpackHandle{
 		BasicHandle: codec.BasicHandle{
 			TimeNotBuiltin: !useNewTimeFormat,
 		},
 	}
-	enc := codec.NewEncoder(buf, &hd)
+}
+
+// msgpackHandle returns the shared handle for the given time format.
+func msgpackHandle(useNewTimeFormat bool) *codec.MsgpackHandle {
+	if useNewTimeFormat {
+		return msgpackHandleNewTime
+	}
+	return msgpackHandleOldTime
+}
+
+// Decode reverses the encode operation on a byte slice input. Either time
+// format is decoded.
+func DecodeMsgPack(buf []byte, out interface{}) error {
+	dec := msgpackDecoders.Get().(*codec.Decoder)
+	dec.ResetBytes(buf)
+	err := dec.Decode(out)
+	// Don't keep buf alive from the pool
+	dec.ResetBytes(nil)
+	msgpackDecoders.Put(dec)
+	return err
+}
+
+// Encode writes an encoded object to a new bytes buffer
+func EncodeMsgPack(in interface{}, useNewTimeFormat bool) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := codec.NewEncoder(buf, msgpackHandle(useNewTimeFormat))
 	err := enc.Encode(in)
 	return buf, err
 }
@@ -40,12 +71,10 @@ func uint64ToBytes(u uint64) []byte {
 	return buf
 }
 
-func addPrefix(prefix []byte, key []byte) []byte {
-	return append(prefix, key...)
-}
-
-// Copy the prefix into a new slice that is one larger than
-// the prefix and add an `0xFF` byte to it so
+// End returns prefix followed by a single 0xFF byte, which sorts after
+// every key within prefix whose next byte is below 0xFF. It is not a bound
+// for keys that continue with 0xFF, so reverse iterations over logs seek
+// to the key of the largest possible index instead.
 func End(prefix []byte) []byte {
 	end := make([]byte, len(prefix)+1)
 	copy(end, prefix)
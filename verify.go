@@ -0,0 +1,211 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+// IndexRange is an inclusive range of log indexes.
+type IndexRange struct {
+	Min uint64 `json:"min"`
+	Max uint64 `json:"max"`
+}
+
+// VerifyReport describes the result of VerifyConsistency.
+type VerifyReport struct {
+	FirstIndex uint64 `json:"first_index"`
+	LastIndex  uint64 `json:"last_index"`
+	Entries    uint64 `json:"entries"`
+
+	// Gaps lists the ranges of indexes missing between FirstIndex and
+	// LastIndex.
+	Gaps []IndexRange `json:"gaps,omitempty"`
+
+	// TermRegressions lists the indexes of logs whose term is lower than
+	// the term of the log before them.
+	TermRegressions []uint64 `json:"term_regressions,omitempty"`
+
+	// IndexMismatches lists keys whose decoded log carries another index.
+	IndexMismatches []uint64 `json:"index_mismatches,omitempty"`
+
+	// Undecodable lists the indexes of logs that could not be decoded.
+	Undecodable []uint64 `json:"undecodable,omitempty"`
+
+	// ChecksumFailures lists the indexes of logs that failed their checksum.
+	ChecksumFailures []uint64 `json:"checksum_failures,omitempty"`
+
+	// MetadataMismatch is set if the persisted log metadata doesn't match
+	// the logs. Repair rebuilds it.
+	MetadataMismatch bool `json:"metadata_mismatch,omitempty"`
+}
+
+// OK reports whether no problems were found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Gaps) == 0 &&
+		len(r.TermRegressions) == 0 &&
+		len(r.IndexMismatches) == 0 &&
+		len(r.Undecodable) == 0 &&
+		len(r.ChecksumFailures) == 0 &&
+		!r.MetadataMismatch
+}
+
+// VerifyMode selects how thoroughly the store is checked when it is opened.
+type VerifyMode int
+
+const (
+	// VerifyOff skips the check.
+	VerifyOff VerifyMode = iota
+
+	// VerifyQuick checks the log metadata and that the logs are contiguous,
+	// reading keys only.
+	VerifyQuick
+
+	// VerifyFull also decodes every log and verifies its checksum, like
+	// VerifyConsistency.
+	VerifyFull
+)
+
+// VerifyError is returned when the check selected by Options.VerifyOnOpen
+// finds problems and ForceOpen isn't set. It matches ErrCorrupt.
+type VerifyError struct {
+	Report *VerifyReport
+}
+
+func (e *VerifyError) Error() string {
+	r := e.Report
+	return fmt.Sprintf(
+		"store failed verification on open: %d gaps, %d term regressions, %d index mismatches, %d undecodable, %d checksum failures, metadata mismatch %t",
+		len(r.Gaps), len(r.TermRegressions), len(r.IndexMismatches), len(r.Undecodable), len(r.ChecksumFailures), r.MetadataMismatch,
+	)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return ErrCorrupt
+}
+
+// OpenReport returns the report of the check selected by
+// Options.VerifyOnOpen, or nil if it was off.
+func (b *BadgerRaftStore) OpenReport() *VerifyReport {
+	return b.openReport
+}
+
+// verifyOnOpen runs the check selected by mode and fails with a VerifyError
+// if it finds problems, unless force is set.
+func (b *BadgerRaftStore) verifyOnOpen(mode VerifyMode, force bool) error {
+	if mode == VerifyOff {
+		return nil
+	}
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	report, err := verifyLogs(txn, mode == VerifyFull)
+	if err != nil {
+		return err
+	}
+	b.openReport = report
+
+	if report.OK() {
+		return nil
+	}
+	if !force {
+		return &VerifyError{Report: report}
+	}
+	log.Warn().Interface("report", report).Msg("Store failed verification on open, opening anyway")
+	return nil
+}
+
+// VerifyConsistency reads every log and checks that the indexes are
+// contiguous, terms never decrease, every log decodes, checksums, where
+// present, are valid and the log metadata matches the logs. Problems are
+// collected in the returned report; the error is only set if the store could
+// not be read.
+func (b *BadgerRaftStore) VerifyConsistency() (*VerifyReport, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	return verifyLogs(txn, true)
+}
+
+// verifyLogs checks the logs keyspace and the log metadata. Unless decode
+// is set only keys are read, which checks contiguity and metadata but not
+// the logs themselves.
+func verifyLogs(txn *badger.Txn, decode bool) (*VerifyReport, error) {
+	report := &VerifyReport{}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 100
+	opts.PrefetchValues = decode
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var prevIdx, prevTerm uint64
+	for it.Seek(dbLogs); it.ValidForPrefix(dbLogs); it.Next() {
+		item := it.Item()
+		idx := logIndex(item.Key())
+
+		if report.Entries == 0 {
+			report.FirstIndex = idx
+		} else if idx != prevIdx+1 {
+			report.Gaps = append(report.Gaps, IndexRange{Min: prevIdx + 1, Max: idx - 1})
+		}
+		report.LastIndex = idx
+		report.Entries++
+		prevIdx = idx
+
+		if !decode {
+			continue
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		var entry raft.Log
+		if err := decodeLog(val, &entry); err != nil {
+			if errors.Is(err, ErrChecksumMismatch) {
+				report.ChecksumFailures = append(report.ChecksumFailures, idx)
+			} else {
+				report.Undecodable = append(report.Undecodable, idx)
+			}
+			continue
+		}
+
+		if entry.Index != idx {
+			report.IndexMismatches = append(report.IndexMismatches, idx)
+		}
+		if entry.Term < prevTerm {
+			report.TermRegressions = append(report.TermRegressions, idx)
+		}
+		prevTerm = entry.Term
+	}
+
+	meta, ok, err := readLogMeta(txn)
+	if err != nil {
+		return nil, err
+	}
+	// Logs moved to the cold tier aren't read, but still count for the
+	// metadata
+	first, err := coldFirstIndex(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if first == 0 {
+		first = report.FirstIndex
+	}
+	if ok && (meta.FirstIndex != first || meta.LastIndex != report.LastIndex) {
+		report.MetadataMismatch = true
+	}
+	return report, nil
+}
@@ -0,0 +1,106 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_VerifyConsistency(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{Checksums: true, AllowLogGaps: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	logs := []*raft.Log{
+		{Index: 1, Term: 1, Data: []byte("log1")},
+		{Index: 2, Term: 2, Data: []byte("log2")},
+		{Index: 3, Term: 2, Data: []byte("log3")},
+	}
+	require.NoError(t, store.StoreLogs(logs))
+
+	report, err := store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, &VerifyReport{FirstIndex: 1, LastIndex: 3, Entries: 3}, report)
+
+	// Introduce a gap, a term regression and some corruption
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		{Index: 6, Term: 1, Data: []byte("log6")},
+		{Index: 7, Term: 1, Data: []byte("log7")},
+		{Index: 8, Term: 1, Data: []byte("log8")},
+	}))
+
+	val, err := store.encodeLog(&raft.Log{Index: 7, Term: 1, Data: []byte("log7")})
+	require.NoError(t, err)
+	val[len(val)-1] ^= 0xFF
+	require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(logKey(7), val); err != nil {
+			return err
+		}
+		return txn.Set(logKey(8), []byte("garbage"))
+	}))
+
+	report, err = store.VerifyConsistency()
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, uint64(6), report.Entries)
+	assert.Equal(t, []IndexRange{{Min: 4, Max: 5}}, report.Gaps)
+	assert.Equal(t, []uint64{6}, report.TermRegressions)
+	assert.Equal(t, []uint64{7}, report.ChecksumFailures)
+	assert.Equal(t, []uint64{8}, report.Undecodable)
+
+	// Reading a log that fails its checksum is an error
+	err = store.GetLog(7, new(raft.Log))
+	assert.Error(t, err)
+}
+
+func TestBadgerStore_VerifyOnOpen(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{Checksums: true})
+	defer os.RemoveAll(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{
+		testRaftLog(1, "log1"),
+		testRaftLog(2, "log2"),
+		testRaftLog(3, "log3"),
+	}))
+
+	val, err := store.encodeLog(testRaftLog(2, "log2"))
+	require.NoError(t, err)
+	val[len(val)-1] ^= 0xFF
+	require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(logKey(2), val)
+	}))
+	require.NoError(t, store.Close())
+
+	open := func(options Options) (*BadgerRaftStore, error) {
+		db, err := badger.Open(badger.DefaultOptions(store.path).WithLogger(nil))
+		require.NoError(t, err)
+		s, err := New(db, options)
+		if err != nil {
+			db.Close()
+		}
+		return s, err
+	}
+
+	// A quick check only reads keys, so it misses the bad checksum
+	s, err := open(Options{VerifyOnOpen: VerifyQuick})
+	require.NoError(t, err)
+	assert.True(t, s.OpenReport().OK())
+	require.NoError(t, s.Close())
+
+	_, err = open(Options{VerifyOnOpen: VerifyFull})
+	assert.ErrorIs(t, err, ErrCorrupt)
+	var verr *VerifyError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []uint64{2}, verr.Report.ChecksumFailures)
+
+	s, err = open(Options{VerifyOnOpen: VerifyFull, ForceOpen: true})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2}, s.OpenReport().ChecksumFailures)
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,273 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// How long versions are kept at least if VersionRetention is not set
+	defaultVersionRetention = 10 * time.Minute
+)
+
+var (
+	// An error indicating an operation needs the store to keep versions,
+	// see Options.KeepVersions
+	ErrVersionsDisabled = errors.New("versions are not kept, set KeepVersions")
+)
+
+// versionClock hands out the timestamps of transactions when Badger runs in
+// managed mode. Timestamps are the Unix nanoseconds of the commit, kept
+// strictly increasing, so the version of a key tells when it was written.
+type versionClock struct {
+	// mu serializes commits, so a reader never sees a commit without the
+	// ones before it
+	mu   sync.Mutex
+	last atomic.Uint64
+
+	// discardTs is the timestamp Badger may discard older versions below
+	discardTs atomic.Uint64
+
+	// pins counts the open snapshot views reading at each timestamp, which
+	// must not be discarded
+	pinsMu sync.Mutex
+	pins   map[uint64]int
+}
+
+func newVersionClock(dbs ...*badger.DB) *versionClock {
+	c := &versionClock{pins: make(map[uint64]int)}
+	for _, db := range dbs {
+		if v := db.MaxVersion(); v > c.last.Load() {
+			c.last.Store(v)
+		}
+	}
+	return c
+}
+
+// readTs returns the timestamp transactions read at, which sees every
+// committed write.
+func (c *versionClock) readTs() uint64 {
+	return c.last.Load()
+}
+
+// commit calls fn with the timestamp to commit at, and makes the commit
+// visible to readers if fn succeeds.
+func (c *versionClock) commit(fn func(commitTs uint64) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ts := max(c.last.Load()+1, uint64(time.Now().UnixNano()))
+	if err := fn(ts); err != nil {
+		return err
+	}
+	c.last.Store(ts)
+	return nil
+}
+
+// advance makes versions up to ts visible to readers, after they were
+// written behind the clock's back, for example by loading a backup.
+func (c *versionClock) advance(ts uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ts > c.last.Load() {
+		c.last.Store(ts)
+	}
+}
+
+// pin returns the current read timestamp and keeps its versions from being
+// discarded until unpin is called with it.
+func (c *versionClock) pin() uint64 {
+	c.pinsMu.Lock()
+	defer c.pinsMu.Unlock()
+
+	ts := c.readTs()
+	c.pins[ts]++
+	return ts
+}
+
+func (c *versionClock) unpin(ts uint64) {
+	c.pinsMu.Lock()
+	defer c.pinsMu.Unlock()
+
+	if c.pins[ts]--; c.pins[ts] == 0 {
+		delete(c.pins, ts)
+	}
+}
+
+// discardLimit returns the newest timestamp versions may be discarded below,
+// which is at most ts.
+func (c *versionClock) discardLimit(ts uint64) uint64 {
+	c.pinsMu.Lock()
+	defer c.pinsMu.Unlock()
+
+	ts = min(ts, c.readTs())
+	for pinned := range c.pins {
+		ts = min(ts, pinned)
+	}
+	return ts
+}
+
+// newTransaction starts a transaction on db, which is either the logs or the
+// stable database of the store.
+func (b *BadgerRaftStore) newTransaction(db *badger.DB, update bool) *badger.Txn {
+	if b.clock != nil {
+		return db.NewTransactionAt(b.clock.readTs(), update)
+	}
+	return db.NewTransaction(update)
+}
+
+// commitTxn commits a transaction started by newTransaction.
+func (b *BadgerRaftStore) commitTxn(txn *badger.Txn) error {
+	if b.clock != nil {
+		return b.clock.commit(func(commitTs uint64) error {
+			return txn.CommitAt(commitTs, nil)
+		})
+	}
+	return txn.Commit()
+}
+
+// update runs fn in a read-write transaction on db and commits it, like
+// badger.DB.Update.
+func (b *BadgerRaftStore) update(db *badger.DB, fn func(txn *badger.Txn) error) error {
+	txn := b.newTransaction(db, true)
+	defer txn.Discard()
+
+	if err := fn(txn); err != nil {
+		return err
+	}
+	return b.commitTxn(txn)
+}
+
+// isManaged reports whether db was opened in managed mode. Badger doesn't
+// tell, but panics when managed transactions are used on other databases.
+func isManaged(db *badger.DB) (managed bool) {
+	defer func() {
+		if recover() != nil {
+			managed = false
+		}
+	}()
+	db.NewTransactionAt(0, false).Discard()
+	return true
+}
+
+// runVersionDiscard lets Badger discard versions once they are older than
+// the version retention, keeping KeepVersions versions of every key.
+func (b *BadgerRaftStore) runVersionDiscard(shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(max(b.versionRetention/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			ts := b.clock.discardLimit(uint64(time.Now().Add(-b.versionRetention).UnixNano()))
+			b.clock.discardTs.Store(ts)
+			b.db.SetDiscardTs(ts)
+			if b.stableDB != b.db {
+				b.stableDB.SetDiscardTs(ts)
+			}
+		}
+	}
+}
+
+// LogVersion is a version of a log kept by the store, see LogVersions.
+type LogVersion struct {
+	// Version is the Badger version of the write.
+	Version uint64 `json:"version"`
+
+	// WrittenAt is when the version was written.
+	WrittenAt time.Time `json:"written_at"`
+
+	// Deleted is set if the version deleted the log.
+	Deleted bool `json:"deleted"`
+
+	// Log is the log as written, nil if the version deleted it.
+	Log *raft.Log `json:"log,omitempty"`
+}
+
+// LogVersions returns the versions of the log at idx the store still keeps,
+// newest first, including those that were overwritten or deleted. It fails
+// with ErrVersionsDisabled unless KeepVersions is set.
+func (b *BadgerRaftStore) LogVersions(idx uint64) ([]LogVersion, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	if b.clock == nil {
+		return nil, ErrVersionsDisabled
+	}
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.AllVersions = true
+	opts.Prefix = logKey(idx)
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var versions []LogVersion
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		v := LogVersion{
+			Version:   item.Version(),
+			WrittenAt: time.Unix(0, int64(item.Version())),
+			Deleted:   item.IsDeletedOrExpired(),
+		}
+		if !v.Deleted {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, storageError(err)
+			}
+			v.Log = new(raft.Log)
+			if err := decodeLog(val, v.Log); err != nil {
+				return nil, fmt.Errorf("%w: log %d version %d: %w", ErrCorrupt, idx, v.Version, err)
+			}
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// GetLogAt reads the log at idx as it was at the given time, failing with
+// raft.ErrLogNotFound if it didn't exist then. Versions superseded longer
+// than VersionRetention ago may have been discarded, so reading further back
+// can miss logs that existed at the time. It fails with ErrVersionsDisabled
+// unless KeepVersions is set.
+func (b *BadgerRaftStore) GetLogAt(at time.Time, idx uint64, log *raft.Log) error {
+	if err := b.enter(); err != nil {
+		return err
+	}
+	defer b.exit()
+
+	if b.clock == nil {
+		return ErrVersionsDisabled
+	}
+
+	// Commits in flight may already use timestamps up to now
+	txn := b.db.NewTransactionAt(min(uint64(at.UnixNano()), b.clock.readTs()), false)
+	defer txn.Discard()
+
+	item, err := txn.Get(logKey(idx))
+	if err != nil {
+		return readError(err, raft.ErrLogNotFound)
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return readError(err, raft.ErrLogNotFound)
+	}
+	if err := decodeLog(val, log); err != nil {
+		return fmt.Errorf("%w: log %d: %w", ErrCorrupt, idx, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testVersionedStore(t *testing.T) *BadgerRaftStore {
+	dir, err := os.MkdirTemp("", "store")
+	require.NoError(t, err)
+
+	opts := badger.DefaultOptions(dir).WithLogger(nil)
+	store, err := Open(dir, Options{BadgerOptions: &opts, KeepVersions: 3})
+	require.NoError(t, err)
+	return store
+}
+
+func TestBadgerStore_LogVersions(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "a"), testRaftLog(2, "b")}))
+	beforeOverwrite := time.Now()
+
+	// Raft truncates a conflicting suffix and overwrites it
+	require.NoError(t, store.DeleteRange(2, 2))
+	beforeRewrite := time.Now()
+	require.NoError(t, store.StoreLog(testRaftLog(2, "c")))
+
+	versions, err := store.LogVersions(2)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "c", string(versions[0].Log.Data))
+	assert.True(t, versions[1].Deleted)
+	assert.Nil(t, versions[1].Log)
+	assert.Equal(t, "b", string(versions[2].Log.Data))
+	assert.Greater(t, versions[0].Version, versions[1].Version)
+	assert.WithinDuration(t, time.Now(), versions[0].WrittenAt, time.Minute)
+
+	var l raft.Log
+	require.NoError(t, store.GetLogAt(beforeOverwrite, 2, &l))
+	assert.Equal(t, "b", string(l.Data))
+	assert.ErrorIs(t, store.GetLogAt(beforeRewrite, 2, &l), raft.ErrLogNotFound)
+	require.NoError(t, store.GetLogAt(time.Now(), 2, &l))
+	assert.Equal(t, "c", string(l.Data))
+}
+
+func TestBadgerStore_LogVersions_Reopen(t *testing.T) {
+	store := testVersionedStore(t)
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "a")))
+	require.NoError(t, store.Set([]byte("k"), []byte("v")))
+	require.NoError(t, store.Close())
+
+	// Versions keep increasing across restarts
+	opts := badger.DefaultOptions(store.path).WithLogger(nil)
+	store, err := Open(store.path, Options{BadgerOptions: &opts, KeepVersions: 3})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "b")))
+	versions, err := store.LogVersions(1)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "b", string(versions[0].Log.Data))
+
+	val, err := store.Get([]byte("k"))
+	require.NoError(t, err)
+	assert.Equal(t, "v", string(val))
+}
+
+func TestBadgerStore_LogVersions_Disabled(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	_, err := store.LogVersions(1)
+	assert.ErrorIs(t, err, ErrVersionsDisabled)
+	assert.ErrorIs(t, store.GetLogAt(time.Now(), 1, new(raft.Log)), ErrVersionsDisabled)
+}
+
+func TestNew_KeepVersionsNeedsManagedDB(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = New(db, Options{KeepVersions: 3})
+	assert.Error(t, err)
+}
+
+func TestOpen_KeepVersions_SeparateStableDir(t *testing.T) {
+	dir, stableDir := t.TempDir(), t.TempDir()
+	store, err := Open(dir, Options{SeparateStableDir: stableDir, KeepVersions: 2})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 3))
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), term)
+}
@@ -0,0 +1,166 @@
+package raftbadgerstore
+
+import (
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+)
+
+// SnapshotView is a read-only view of the store as it was when SnapshotView
+// was called. Logs and keys written or deleted afterwards are not visible
+// through it, so external tooling can read a consistent image while raft
+// keeps writing. It must be closed when done, as it keeps Badger from
+// discarding the versions it reads.
+//
+// A view is safe for concurrent use. Its methods fail with ErrClosed once the
+// view or the store is closed.
+type SnapshotView struct {
+	b *BadgerRaftStore
+
+	// txn reads the logs, stableTxn the stable store, which is txn unless
+	// StableDB is set
+	txn       *badger.Txn
+	stableTxn *badger.Txn
+
+	// pinned is the read timestamp pinned in the version clock, if the
+	// store keeps versions
+	pinned uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// SnapshotView returns a view of the store pinned to a single Badger read
+// timestamp. With a separate StableDB and without KeepVersions, the logs and
+// the stable store are each pinned as of the call, but writes to both that
+// happen during the call may be seen in one and not the other.
+func (b *BadgerRaftStore) SnapshotView() (*SnapshotView, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	v := &SnapshotView{b: b}
+	if b.clock != nil {
+		v.pinned = b.clock.pin()
+		v.txn = b.db.NewTransactionAt(v.pinned, false)
+		v.stableTxn = v.txn
+		if b.stableDB != b.db {
+			v.stableTxn = b.stableDB.NewTransactionAt(v.pinned, false)
+		}
+		return v, nil
+	}
+
+	v.txn = b.db.NewTransaction(false)
+	v.stableTxn = v.txn
+	if b.stableDB != b.db {
+		v.stableTxn = b.stableDB.NewTransaction(false)
+	}
+	return v, nil
+}
+
+// enter starts a read through the view, failing with ErrClosed if the view or
+// the store is closed. exit must be called when the read is done.
+func (v *SnapshotView) enter() error {
+	v.mu.RLock()
+	if v.closed {
+		v.mu.RUnlock()
+		return ErrClosed
+	}
+	if err := v.b.enter(); err != nil {
+		v.mu.RUnlock()
+		return err
+	}
+	return nil
+}
+
+func (v *SnapshotView) exit() {
+	v.b.exit()
+	v.mu.RUnlock()
+}
+
+// ReadTs returns the Badger read timestamp of the view's logs. With
+// KeepVersions set it is a version as taken by RestoreToVersion.
+func (v *SnapshotView) ReadTs() uint64 {
+	return v.txn.ReadTs()
+}
+
+// FirstIndex returns the first index of the log in the view, or 0 if it has
+// no logs.
+func (v *SnapshotView) FirstIndex() (uint64, error) {
+	if err := v.enter(); err != nil {
+		return 0, err
+	}
+	defer v.exit()
+
+	idx, err := firstIndex(v.txn)
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return idx, nil
+}
+
+// LastIndex returns the last index of the log in the view, or 0 if it has no
+// logs.
+func (v *SnapshotView) LastIndex() (uint64, error) {
+	if err := v.enter(); err != nil {
+		return 0, err
+	}
+	defer v.exit()
+
+	idx, err := lastIndex(v.txn)
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return idx, nil
+}
+
+// GetLog reads the log at idx as it was in the view.
+func (v *SnapshotView) GetLog(idx uint64, log *raft.Log) error {
+	if err := v.enter(); err != nil {
+		return err
+	}
+	defer v.exit()
+
+	return readLog(v.txn, idx, log)
+}
+
+// Get reads the stable store key k as it was in the view.
+func (v *SnapshotView) Get(k []byte) ([]byte, error) {
+	if err := v.enter(); err != nil {
+		return nil, err
+	}
+	defer v.exit()
+
+	return readConf(v.stableTxn, k)
+}
+
+// GetUint64 is like Get, but handles uint64 values
+func (v *SnapshotView) GetUint64(k []byte) (uint64, error) {
+	val, err := v.Get(k)
+	if err != nil {
+		return 0, err
+	}
+	return confUint64(k, val)
+}
+
+// Close releases the view. It is safe to call Close multiple times.
+func (v *SnapshotView) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+
+	v.txn.Discard()
+	if v.stableTxn != v.txn {
+		v.stableTxn.Discard()
+	}
+	if v.b.clock != nil {
+		v.b.clock.unpin(v.pinned)
+	}
+	return nil
+}
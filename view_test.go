@@ -0,0 +1,94 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSnapshotView(t *testing.T, store *BadgerRaftStore) {
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(1, "a"), testRaftLog(2, "b")}))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 1))
+
+	view, err := store.SnapshotView()
+	require.NoError(t, err)
+	defer view.Close()
+
+	// Raft keeps writing after the view was taken
+	require.NoError(t, store.DeleteRange(1, 1))
+	require.NoError(t, store.StoreLogs([]*raft.Log{testRaftLog(2, "x"), testRaftLog(3, "c")}))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 2))
+	require.NoError(t, store.Set([]byte("k"), []byte("v")))
+
+	first, err := view.FirstIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first)
+	last, err := view.LastIndex()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), last)
+
+	var l raft.Log
+	require.NoError(t, view.GetLog(1, &l))
+	assert.Equal(t, "a", string(l.Data))
+	require.NoError(t, view.GetLog(2, &l))
+	assert.Equal(t, "b", string(l.Data))
+	assert.ErrorIs(t, view.GetLog(3, &l), raft.ErrLogNotFound)
+
+	term, err := view.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), term)
+	_, err = view.Get([]byte("k"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// The store sees the new state
+	require.NoError(t, store.GetLog(2, &l))
+	assert.Equal(t, "x", string(l.Data))
+
+	require.NoError(t, view.Close())
+	require.NoError(t, view.Close())
+	assert.ErrorIs(t, view.GetLog(1, &l), ErrClosed)
+}
+
+func TestBadgerStore_SnapshotView(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	testSnapshotView(t, store)
+}
+
+func TestBadgerStore_SnapshotView_KeepVersions(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	testSnapshotView(t, store)
+	assert.Empty(t, store.clock.pins)
+}
+
+func TestBadgerStore_SnapshotView_PinsVersions(t *testing.T) {
+	store := testVersionedStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	require.NoError(t, store.StoreLog(testRaftLog(1, "a")))
+	view, err := store.SnapshotView()
+	require.NoError(t, err)
+	defer view.Close()
+	require.NoError(t, store.StoreLog(testRaftLog(2, "b")))
+
+	assert.Equal(t, view.ReadTs(), store.clock.discardLimit(^uint64(0)))
+	require.NoError(t, view.Close())
+	assert.Equal(t, store.clock.readTs(), store.clock.discardLimit(^uint64(0)))
+}
+
+func TestBadgerStore_SnapshotView_SeparateStableDir(t *testing.T) {
+	store, err := Open(t.TempDir(), Options{SeparateStableDir: t.TempDir()})
+	require.NoError(t, err)
+	defer store.Close()
+
+	testSnapshotView(t, store)
+}
@@ -0,0 +1,144 @@
+package raftbadgerstore
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	metrics "github.com/hashicorp/go-metrics/compat"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How many logs the watchdog checks per run unless
+	// Options.WatchdogSamples says otherwise
+	defaultWatchdogSamples = 100
+)
+
+var (
+	metricWatchdogRuns      = []string{"raft", "badgerstore", "watchdog", "runs"}
+	metricWatchdogAnomalies = []string{"raft", "badgerstore", "watchdog", "anomalies"}
+)
+
+// runWatchdog spot checks the store on every tick until it is closed.
+func (b *BadgerRaftStore) runWatchdog(shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(b.watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			if _, err := b.SpotCheck(b.watchdogSamples); err != nil && !errors.Is(err, ErrClosed) {
+				log.Error().Err(err).Msg("Failed to spot check the store")
+			}
+		}
+	}
+}
+
+// SpotCheck reads up to samples logs at random indexes between the first
+// and last index, or all of them if there are no more, and checks that each
+// exists, decodes, passes its checksum and carries its index, and that the
+// log metadata matches the first and last log. It is a cheap stand-in for
+// VerifyConsistency that catches silent corruption early, and is run every
+// WatchdogInterval when that is set. Problems are collected in the returned
+// report, counted in Stats.WatchdogAnomalies and logged; the error is only
+// set if the store could not be read.
+func (b *BadgerRaftStore) SpotCheck(samples int) (*VerifyReport, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.exit()
+
+	txn := b.newTransaction(b.db, false)
+	defer txn.Discard()
+
+	report, err := b.spotCheck(txn, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.IncrCounter(metricWatchdogRuns, 1)
+	if anomalies := report.anomalies(); anomalies > 0 {
+		metrics.IncrCounter(metricWatchdogAnomalies, float32(anomalies))
+		b.stats.watchdogAnomalies.Add(uint64(anomalies))
+		log.Error().Interface("report", report).Msg("Spot check found anomalies")
+	}
+	return report, nil
+}
+
+func (b *BadgerRaftStore) spotCheck(txn *badger.Txn, samples int) (*VerifyReport, error) {
+	report := &VerifyReport{
+		FirstIndex: boundaryLogIndex(txn, false),
+		LastIndex:  boundaryLogIndex(txn, true),
+	}
+
+	meta, ok, err := readLogMeta(txn)
+	if err != nil {
+		return nil, err
+	}
+	// Logs moved to the cold tier aren't sampled, but still count for the
+	// metadata
+	first, err := coldFirstIndex(txn)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if first == 0 {
+		first = report.FirstIndex
+	}
+	if ok && (meta.FirstIndex != first || meta.LastIndex != report.LastIndex) {
+		report.MetadataMismatch = true
+	}
+	if report.LastIndex == 0 {
+		return report, nil
+	}
+
+	// Small logs are checked in full. Samples are read in order, so reads
+	// are sequential.
+	span := report.LastIndex - report.FirstIndex + 1
+	indexes := make([]uint64, 0, min(uint64(samples), span))
+	for i := range uint64(cap(indexes)) {
+		if uint64(samples) >= span {
+			indexes = append(indexes, report.FirstIndex+i)
+		} else {
+			indexes = append(indexes, report.FirstIndex+rand.Uint64N(span))
+		}
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	for _, idx := range indexes {
+		report.Entries++
+
+		var l raft.Log
+		err := readLog(txn, idx, &l)
+		switch {
+		case errors.Is(err, raft.ErrLogNotFound):
+			if !b.allowLogGaps {
+				report.Gaps = append(report.Gaps, IndexRange{Min: idx, Max: idx})
+			}
+		case errors.Is(err, ErrChecksumMismatch):
+			report.ChecksumFailures = append(report.ChecksumFailures, idx)
+		case errors.Is(err, ErrCorrupt):
+			report.Undecodable = append(report.Undecodable, idx)
+		case err != nil:
+			return nil, err
+		case l.Index != idx:
+			report.IndexMismatches = append(report.IndexMismatches, idx)
+		}
+	}
+	return report, nil
+}
+
+// anomalies returns the number of problems in the report.
+func (r *VerifyReport) anomalies() int {
+	n := len(r.Gaps) + len(r.TermRegressions) + len(r.IndexMismatches) + len(r.Undecodable) + len(r.ChecksumFailures)
+	if r.MetadataMismatch {
+		n++
+	}
+	return n
+}
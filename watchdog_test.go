@@ -0,0 +1,88 @@
+package raftbadgerstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerStore_SpotCheck(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{Checksums: true})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	report, err := store.SpotCheck(10)
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Zero(t, report.Entries)
+
+	storeTestLogs(t, store, 1, 20)
+	report, err = store.SpotCheck(100)
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, uint64(1), report.FirstIndex)
+	assert.Equal(t, uint64(20), report.LastIndex)
+	assert.NotZero(t, report.Entries)
+	assert.LessOrEqual(t, report.Entries, uint64(20))
+
+	// Damage every log, so any sample finds them
+	err = store.db.Update(func(txn *badger.Txn) error {
+		for idx := uint64(1); idx <= 20; idx++ {
+			val := testRaftLog(idx, "log")
+			enc, err := store.encodeLog(val)
+			if err != nil {
+				return err
+			}
+			enc[len(enc)-1] ^= 0xff
+			if err := txn.Set(logKey(idx), enc); err != nil {
+				return err
+			}
+		}
+		return txn.Set(prefixedKey(dbMeta, metaLastIndex), uint64ToBytes(30))
+	})
+	require.NoError(t, err)
+
+	report, err = store.SpotCheck(5)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.True(t, report.MetadataMismatch)
+	assert.NotEmpty(t, report.ChecksumFailures)
+	assert.Equal(t, uint64(report.anomalies()), store.Stats().WatchdogAnomalies)
+}
+
+func TestBadgerStore_SpotCheck_Gap(t *testing.T) {
+	store := testBadgerStore(t)
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 3)
+	err := store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(logKey(2))
+	})
+	require.NoError(t, err)
+
+	report, err := store.SpotCheck(1000)
+	require.NoError(t, err)
+	assert.Equal(t, []IndexRange{{Min: 2, Max: 2}}, report.Gaps)
+}
+
+func TestBadgerStore_Watchdog(t *testing.T) {
+	store := testBadgerStoreWithOptions(t, Options{WatchdogInterval: 10 * time.Millisecond})
+	defer store.Close()
+	defer os.Remove(store.path)
+
+	storeTestLogs(t, store, 1, 10)
+	err := store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(logKey(5), []byte{0xc1})
+	})
+	require.NoError(t, err)
+
+	// Sampling at random, the watchdog eventually hits the damaged log
+	require.Eventually(t, func() bool {
+		return store.Stats().WatchdogAnomalies > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}